@@ -2,18 +2,43 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/ui"
+	"github.com/user/terminal-ai/internal/utils"
+	"gopkg.in/yaml.v3"
 )
 
 var (
 	clearCache        bool
 	showStats         bool
 	invalidatePattern string
+
+	cacheModel     string
+	cacheMode      string
+	cacheGrep      string
+	cacheRegex     string
+	cacheOlderThan string
+	cacheJSON      bool
+
+	warmConcurrency int
+	warmTTL         string
 )
 
+// defaultWarmTTL is how long warmed responses stay cached unless the prompt
+// file or --ttl says otherwise
+const defaultWarmTTL = 30 * 24 * time.Hour
+
 // cacheCmd represents the cache command
 var cacheCmd = &cobra.Command{
 	Use:   "cache",
@@ -21,25 +46,190 @@ var cacheCmd = &cobra.Command{
 	Long: `Manage the AI response cache for improved performance.
 
 The cache stores AI responses to avoid redundant API calls for identical queries.
-It uses an LRU (Least Recently Used) eviction strategy and supports TTL.
+Entries expire after the configured TTL and are evicted by cache.strategy when
+the cache is full. Each entry records the model and mode it was requested in
+(shell for -s, query for the query command, quick for -q, or chat) and its
+prompt, so entries can be listed, removed and shared by those instead of by
+their keys.
+
+Filters (all must match):
+  --model name        model the request asked for
+  --mode mode         shell, query, quick or chat
+  --grep text         text in the prompt or answer, ignoring case
+  --regex expr        regular expression matched against the prompt
+  --older-than age    created at least this long ago, e.g. 90m, 12h, 7d or 2w
 
 Examples:
   terminal-ai cache --stats           # Show cache statistics
   terminal-ai cache --clear           # Clear all cached responses
-  terminal-ai cache --invalidate "chat_"  # Invalidate entries matching pattern`,
+  terminal-ai cache list --model gpt-5-mini --grep docker
+  terminal-ai cache show 3f2a9c       # Show an entry by a key prefix
+  terminal-ai cache rm --older-than 7d
+  terminal-ai cache export team.jsonl --mode query
+  terminal-ai cache import team.jsonl`,
 	RunE: runCache,
 }
 
+// cacheListCmd represents the cache list command
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached responses",
+	Long: `List the cached responses matching the filters, oldest first, with their key
+prefix, model, mode, age, hits, size and prompt.
+
+Examples:
+  terminal-ai cache list
+  terminal-ai cache list --mode shell --older-than 1d
+  terminal-ai cache list --grep kubectl --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheList()
+	},
+}
+
+// cacheShowCmd represents the cache show command
+var cacheShowCmd = &cobra.Command{
+	Use:   "show [key]",
+	Short: "Show a cached response",
+	Long: `Show a cached response and its metadata. The key may be any unique prefix of
+the keys shown by 'cache list'.
+
+Examples:
+  terminal-ai cache show 3f2a9c
+  terminal-ai cache show 3f2a9c --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheShow(args[0])
+	},
+}
+
+// cacheRmCmd represents the cache rm command
+var cacheRmCmd = &cobra.Command{
+	Use:   "rm [key...]",
+	Short: "Remove cached responses",
+	Long: `Remove the cached responses with the given keys (or unique key prefixes), or
+every response matching the filters.
+
+Examples:
+  terminal-ai cache rm 3f2a9c 81be04
+  terminal-ai cache rm --model gpt-4o
+  terminal-ai cache rm --regex '^how do i' --mode query`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheRm(args)
+	},
+}
+
+// cacheExportCmd represents the cache export command
+var cacheExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export cached responses as JSON lines",
+	Long: `Write the cached responses matching the filters to a file, or to stdout, as
+JSON lines. Import the file on another machine to share a warmed cache.
+
+Examples:
+  terminal-ai cache export team.jsonl
+  terminal-ai cache export --mode query | gzip > answers.jsonl.gz`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "-"
+		if len(args) == 1 {
+			path = args[0]
+		}
+		return runCacheExport(path)
+	},
+}
+
+// cacheImportCmd represents the cache import command
+var cacheImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import cached responses from JSON lines",
+	Long: `Store the responses exported by 'cache export' in the cache. Entries keep
+their keys, creation time and expiry; those that have expired are skipped.
+Use - to read from stdin.
+
+Examples:
+  terminal-ai cache import team.jsonl
+  gunzip -c answers.jsonl.gz | terminal-ai cache import -`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheImport(args[0])
+	},
+}
+
+// cacheWarmCmd represents the cache warm command
+var cacheWarmCmd = &cobra.Command{
+	Use:   "warm [prompts.yaml]",
+	Short: "Cache the answers to a list of prompts",
+	Long: `Send each prompt in a file through the client, the way its mode would, and
+cache the answers for a long time (30 days unless set), so the same questions
+are answered instantly and offline later. Prompts that are already cached, or
+that a semantic cache hit answers, are skipped.
+
+The prompt file lists prompts with an optional mode, model or preset, and
+system prompt replacing the mode's. Each mode sends prompts the way one
+command does, so that command finds the answers:
+
+  query    'terminal-ai query' (the default)
+  quick    'terminal-ai -q'
+  shell    'terminal-ai -s', or a prompt with no flag
+  chat     'terminal-ai -c'
+
+
+  ttl: 90d           # how long the answers stay cached
+  concurrency: 4     # prompts in flight at once
+  mode: query        # default mode for the prompts below
+  prompts:
+    - How do I undo the last git commit?
+    - prompt: find files larger than 100MB
+      mode: shell
+    - prompt: What is a rebase?
+      mode: quick
+    - prompt: Where are the onboarding docs?
+      model: gpt-5-mini
+      system: You answer questions about the ACME engineering handbook.
+
+Examples:
+  terminal-ai cache warm faq.yaml
+  terminal-ai cache warm faq.yaml --concurrency 8 --ttl 2w`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheWarm(args[0])
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cacheShowCmd)
+	cacheCmd.AddCommand(cacheRmCmd)
+	cacheCmd.AddCommand(cacheExportCmd)
+	cacheCmd.AddCommand(cacheImportCmd)
+	cacheCmd.AddCommand(cacheWarmCmd)
 
 	// Cache command flags
 	cacheCmd.Flags().BoolVarP(&clearCache, "clear", "c", false, "clear all cached responses")
 	cacheCmd.Flags().BoolVarP(&showStats, "stats", "s", false, "show cache statistics")
-	cacheCmd.Flags().StringVarP(&invalidatePattern, "invalidate", "i", "", "invalidate cache entries matching pattern")
+	cacheCmd.Flags().StringVarP(&invalidatePattern, "invalidate", "i", "", "invalidate cache entries whose keys start with pattern")
+	_ = cacheCmd.Flags().MarkDeprecated("invalidate", "use 'cache rm' with --model, --mode, --regex or --older-than instead")
+
+	for _, cmd := range []*cobra.Command{cacheListCmd, cacheRmCmd, cacheExportCmd} {
+		cmd.Flags().StringVar(&cacheModel, "model", "", "only entries requested with this model")
+		cmd.Flags().StringVar(&cacheMode, "mode", "", "only entries from this mode (shell, query, quick, chat)")
+		cmd.Flags().StringVar(&cacheGrep, "grep", "", "only entries whose prompt or answer contains text")
+		cmd.Flags().StringVar(&cacheRegex, "regex", "", "only entries whose prompt matches the regular expression")
+		cmd.Flags().StringVar(&cacheOlderThan, "older-than", "", "only entries created at least this long ago (e.g. 12h, 7d)")
+	}
+	cacheListCmd.Flags().BoolVar(&cacheJSON, "json", false, "print as JSON")
+	cacheShowCmd.Flags().BoolVar(&cacheJSON, "json", false, "print as JSON")
+	cacheWarmCmd.Flags().IntVar(&warmConcurrency, "concurrency", 0, "prompts in flight at once (default from the file, else 4)")
+	cacheWarmCmd.Flags().StringVar(&warmTTL, "ttl", "", "how long the answers stay cached, e.g. 12h, 30d (default from the file, else 30d)")
 }
 
 func runCache(cmd *cobra.Command, args []string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+
 	// Check if cache is enabled
 	if !appConfig.Cache.Enabled {
 		fmt.Println("Cache is disabled. Enable it in the configuration to use caching.")
@@ -82,6 +272,546 @@ func runCache(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// cacheClient returns the client for cache subcommands, which need caching
+// enabled
+func cacheClient() (*ai.OpenAIClient, error) {
+	if err := ensureApp(); err != nil {
+		return nil, err
+	}
+	if !appConfig.Cache.Enabled {
+		return nil, utils.NewValidationError("cache is disabled", "cache.enabled").
+			WithHint("Set cache.enabled: true in the config file")
+	}
+	client, ok := aiClient.(*ai.OpenAIClient)
+	if !ok {
+		return nil, fmt.Errorf("cache operations not supported for this client type")
+	}
+	return client, nil
+}
+
+// cacheFilter builds the filter set by the filter flags
+func cacheFilter() (ai.CacheFilter, error) {
+	filter := ai.CacheFilter{Model: cacheModel, Mode: cacheMode, Grep: cacheGrep}
+
+	switch cacheMode {
+	case "", ai.ModeShell, ai.ModeQuery, ai.ModeQuick, ai.ModeChat:
+	default:
+		return filter, utils.NewValidationError(fmt.Sprintf("invalid mode: %s", cacheMode), "mode").
+			WithHint("Use shell, query, quick or chat")
+	}
+	if cacheRegex != "" {
+		re, err := regexp.Compile(cacheRegex)
+		if err != nil {
+			return filter, utils.NewValidationError(fmt.Sprintf("invalid regular expression: %v", err), "regex")
+		}
+		filter.Regex = re
+	}
+	if cacheOlderThan != "" {
+		age, err := parseAge(cacheOlderThan)
+		if err != nil {
+			return filter, utils.NewValidationError(fmt.Sprintf("invalid age: %s", cacheOlderThan), "older-than").
+				WithHint("Use a duration such as 90m, 12h, 7d or 2w")
+		}
+		filter.OlderThan = age
+	}
+	return filter, nil
+}
+
+// parseAge parses a duration, also accepting whole days (d) and weeks (w)
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age: %s", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// findCacheEntry returns the entry whose key starts with prefix, which must
+// identify a single entry
+func findCacheEntry(items []ai.CacheItem, prefix string) (ai.CacheItem, error) {
+	var matches []ai.CacheItem
+	for _, item := range items {
+		if strings.HasPrefix(item.Key, prefix) {
+			matches = append(matches, item)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return ai.CacheItem{}, utils.NewAppError(utils.ErrCodeNotFound, fmt.Sprintf("no cached response with key %s", prefix), nil).
+			WithHint("Run 'terminal-ai cache list' to see the cached responses")
+	case 1:
+		return matches[0], nil
+	default:
+		return ai.CacheItem{}, utils.NewValidationError(fmt.Sprintf("key %s matches %d cached responses", prefix, len(matches)), "key").
+			WithHint("Use a longer key prefix")
+	}
+}
+
+func runCacheList() error {
+	client, err := cacheClient()
+	if err != nil {
+		return err
+	}
+	filter, err := cacheFilter()
+	if err != nil {
+		return err
+	}
+	items, err := client.CacheEntries(filter)
+	if err != nil {
+		return err
+	}
+
+	if cacheJSON {
+		listings := make([]cacheListing, 0, len(items))
+		for _, item := range items {
+			listings = append(listings, newCacheListing(item))
+		}
+		return printJSON(map[string]interface{}{"entries": listings})
+	}
+
+	formatter := ui.NewFormatter(ui.FormatterOptions{
+		ColorEnabled: GetConfig().UI.ColorOutput,
+		Width:        ui.GetTerminalWidth(),
+	})
+	if len(items) == 0 {
+		formatter.PrintInfo("No cached responses match")
+		return nil
+	}
+
+	headers := []string{"Key", "Model", "Mode", "Age", "Hits", "Size", "Prompt"}
+	rows := make([][]string, 0, len(items))
+	now := time.Now()
+	for _, item := range items {
+		entry := item.Entry
+		rows = append(rows, []string{
+			shortKey(item.Key),
+			valueOrDash(entry.RequestModel()),
+			valueOrDash(entry.Mode),
+			formatAge(now.Sub(entry.CreatedAt)),
+			fmt.Sprintf("%d", entry.AccessCount),
+			formatBytes(entry.SizeBytes),
+			valueOrDash(ai.PromptPreview(entry.Prompt, 50)),
+		})
+	}
+	fmt.Print(formatter.Table(headers, rows))
+	return nil
+}
+
+// shortKey returns the start of a key, as listings show it
+func shortKey(key string) string {
+	if len(key) > 12 {
+		return key[:12]
+	}
+	return key
+}
+
+// cacheListing is a cached response as listed by cache list --json
+type cacheListing struct {
+	Key       string    `json:"key"`
+	Model     string    `json:"model,omitempty"`
+	Mode      string    `json:"mode,omitempty"`
+	Prompt    string    `json:"prompt,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Hits      int64     `json:"hits"`
+	SizeBytes int64     `json:"size_bytes"`
+	Semantic  bool      `json:"semantic"` // whether the entry can serve similar prompts
+}
+
+func newCacheListing(item ai.CacheItem) cacheListing {
+	entry := item.Entry
+	return cacheListing{
+		Key:       item.Key,
+		Model:     entry.RequestModel(),
+		Mode:      entry.Mode,
+		Prompt:    entry.Prompt,
+		CreatedAt: entry.CreatedAt,
+		ExpiresAt: entry.ExpiresAt,
+		Hits:      entry.AccessCount,
+		SizeBytes: entry.SizeBytes,
+		Semantic:  len(entry.Embedding) > 0,
+	}
+}
+
+func runCacheShow(key string) error {
+	client, err := cacheClient()
+	if err != nil {
+		return err
+	}
+	items, err := client.CacheEntries(ai.CacheFilter{})
+	if err != nil {
+		return err
+	}
+	item, err := findCacheEntry(items, key)
+	if err != nil {
+		return err
+	}
+
+	entry := item.Entry
+	if cacheJSON {
+		return printJSON(struct {
+			cacheListing
+			Response *ai.Response `json:"response"`
+		}{newCacheListing(item), entry.Response})
+	}
+
+	formatter := ui.NewFormatter(ui.FormatterOptions{
+		ColorEnabled: GetConfig().UI.ColorOutput,
+		Width:        ui.GetTerminalWidth(),
+	})
+	formatter.PrintSection(item.Key)
+
+	field := func(name, value string) {
+		fmt.Printf("  %-16s %s\n", name+":", value)
+	}
+	field("Model", valueOrDash(entry.RequestModel()))
+	if entry.Response != nil && entry.Response.Model != "" && entry.Response.Model != entry.RequestModel() {
+		field("Answered by", entry.Response.Model)
+	}
+	field("Mode", valueOrDash(entry.Mode))
+	field("Created", fmt.Sprintf("%s (%s ago)", entry.CreatedAt.Format("2006-01-02 15:04:05"), formatAge(time.Since(entry.CreatedAt))))
+	field("Expires", entry.ExpiresAt.Format("2006-01-02 15:04:05"))
+	field("Hits", fmt.Sprintf("%d", entry.AccessCount))
+	field("Size", formatBytes(entry.SizeBytes))
+	field("Tokens", fmt.Sprintf("%d", entry.TokenUsage.TotalTokens))
+	field("Streamed", yesNo(len(entry.Chunks) > 0))
+	field("Semantic", yesNo(len(entry.Embedding) > 0))
+
+	fmt.Println()
+	formatter.PrintSection("Prompt")
+	fmt.Println(valueOrDash(entry.Prompt))
+	fmt.Println()
+	formatter.PrintSection("Response")
+	if entry.Response != nil {
+		fmt.Println(entry.Response.Content)
+	}
+	return nil
+}
+
+func runCacheRm(keys []string) error {
+	client, err := cacheClient()
+	if err != nil {
+		return err
+	}
+	filter, err := cacheFilter()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 && filter.IsZero() {
+		return utils.NewValidationError("nothing to remove", "key").
+			WithHint("Give keys or filters, or use 'terminal-ai cache --clear' to remove everything")
+	}
+
+	items, err := client.CacheEntries(filter)
+	if err != nil {
+		return err
+	}
+	var remove []string
+	if len(keys) == 0 {
+		for _, item := range items {
+			remove = append(remove, item.Key)
+		}
+	}
+	for _, key := range keys {
+		item, err := findCacheEntry(items, key)
+		if err != nil {
+			return err
+		}
+		remove = append(remove, item.Key)
+	}
+
+	count, err := client.RemoveCacheEntries(remove)
+	if err != nil {
+		return fmt.Errorf("failed to remove cache entries: %w", err)
+	}
+	fmt.Printf("✓ Removed %d cached responses\n", count)
+	return nil
+}
+
+func runCacheExport(path string) error {
+	client, err := cacheClient()
+	if err != nil {
+		return err
+	}
+	filter, err := cacheFilter()
+	if err != nil {
+		return err
+	}
+	items, err := client.CacheEntries(filter)
+	if err != nil {
+		return err
+	}
+
+	if path == "-" {
+		return ai.ExportEntries(os.Stdout, items)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	if err := ai.ExportEntries(f, items); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "✓ Exported %d cached responses to %s\n", len(items), path)
+	return nil
+}
+
+func runCacheImport(path string) error {
+	client, err := cacheClient()
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open import file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	imported, skipped, err := client.ImportCache(r)
+	if err != nil {
+		return fmt.Errorf("failed to import cache: %w", err)
+	}
+	fmt.Printf("✓ Imported %d cached responses", imported)
+	if skipped > 0 {
+		fmt.Printf(" (%d expired, skipped)", skipped)
+	}
+	fmt.Println()
+	return nil
+}
+
+// warmFile is a prompt file for cache warm
+type warmFile struct {
+	TTL         string       `yaml:"ttl"`
+	Concurrency int          `yaml:"concurrency"`
+	Mode        string       `yaml:"mode"`
+	Model       string       `yaml:"model"`
+	Prompts     []warmPrompt `yaml:"prompts"`
+}
+
+// warmPrompt is one prompt of a prompt file
+type warmPrompt struct {
+	Prompt string `yaml:"prompt"`
+	Mode   string `yaml:"mode"`
+	Model  string `yaml:"model"`  // model or preset name
+	System string `yaml:"system"` // replaces the mode's system prompt
+}
+
+// UnmarshalYAML accepts a bare string as a prompt with the file's defaults
+func (p *warmPrompt) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&p.Prompt)
+	}
+	type plain warmPrompt
+	return node.Decode((*plain)(p))
+}
+
+// loadWarmFile reads and checks a prompt file
+func loadWarmFile(path string) (*warmFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	var file warmFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, utils.NewValidationError(fmt.Sprintf("invalid prompt file: %v", err), path)
+	}
+	if len(file.Prompts) == 0 {
+		return nil, utils.NewValidationError("the prompt file has no prompts", path).
+			WithHint("List them under prompts:, see 'terminal-ai cache warm --help'")
+	}
+	for i, prompt := range file.Prompts {
+		if strings.TrimSpace(prompt.Prompt) == "" {
+			return nil, utils.NewValidationError(fmt.Sprintf("prompt %d is empty", i+1), path)
+		}
+	}
+	return &file, nil
+}
+
+// warmRequestFor builds the request a prompt's mode would send, so the
+// warmed answer is found by later runs in that mode
+func warmRequestFor(file *warmFile, prompt warmPrompt) (ai.WarmRequest, error) {
+	mode := prompt.Mode
+	if mode == "" {
+		mode = file.Mode
+	}
+	if mode == "" {
+		mode = ai.ModeQuery
+	}
+	model := prompt.Model
+	if model == "" {
+		model = file.Model
+	}
+
+	// A preset changes the whole config the mode's options come from
+	cfg := GetConfig()
+	if model != "" {
+		if _, ok := cfg.Preset(model); ok {
+			applied, err := cfg.WithPreset(model)
+			if err != nil {
+				return ai.WarmRequest{}, unknownPresetError(model)
+			}
+			cfg, model = applied, ""
+		}
+	}
+
+	request := ai.WarmRequest{Mode: mode}
+	system := helpfulAssistantPrompt
+	switch mode {
+	case ai.ModeShell:
+		system = shellCommandPrompt
+		request.Options = simpleOptions(cfg)
+	case ai.ModeQuery:
+		// Built as the query command builds it, so its keys match
+		request.Options = queryOptions(cfg)
+		request.Messages = queryMessages(cfg, prompt.System, prompt.Prompt)
+		request.Stream = true
+	case ai.ModeQuick:
+		// Built as -q builds it, which streams by default
+		request.Messages, request.Options = quickRequest(cfg, prompt.System, prompt.Prompt)
+		request.Stream = true
+	case ai.ModeChat:
+		request.Options = chatOptions(cfg)
+		request.Stream = cfg.UI.StreamingEnabled
+	default:
+		return request, utils.NewValidationError(fmt.Sprintf("invalid mode %q for prompt %q", mode, prompt.Prompt), "mode").
+			WithHint("Use shell, query, quick or chat")
+	}
+	if model != "" {
+		request.Options.Model = model
+	}
+	if request.Messages == nil {
+		if prompt.System != "" {
+			system = prompt.System
+		}
+		request.Messages = []ai.Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt.Prompt},
+		}
+	}
+	return request, nil
+}
+
+func runCacheWarm(path string) error {
+	client, err := cacheClient()
+	if err != nil {
+		return err
+	}
+	file, err := loadWarmFile(path)
+	if err != nil {
+		return err
+	}
+
+	requests := make([]ai.WarmRequest, 0, len(file.Prompts))
+	for _, prompt := range file.Prompts {
+		request, err := warmRequestFor(file, prompt)
+		if err != nil {
+			return err
+		}
+		requests = append(requests, request)
+	}
+
+	ttl := defaultWarmTTL
+	value := warmTTL
+	if value == "" {
+		value = file.TTL
+	}
+	if value != "" {
+		if ttl, err = parseAge(value); err != nil || ttl <= 0 {
+			return utils.NewValidationError(fmt.Sprintf("invalid TTL: %s", value), "ttl").
+				WithHint("Use a duration such as 12h, 30d or 2w")
+		}
+	}
+	concurrency := warmConcurrency
+	if concurrency <= 0 {
+		concurrency = file.Concurrency
+	}
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	fmt.Printf("Warming the cache with %d prompts (%d at a time, cached for %s)\n\n", len(requests), concurrency, formatAge(ttl))
+	theme := ui.GetCurrentTheme()
+	var mu sync.Mutex
+	results, err := client.WarmCache(commandContext(), requests, ai.WarmOptions{
+		Concurrency: concurrency,
+		TTL:         ttl,
+		OnDone: func(index int, result ai.WarmResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			prompt := ai.PromptPreview(file.Prompts[index].Prompt, 60)
+			switch result.Status {
+			case ai.WarmWarmed:
+				fmt.Println(lipgloss.NewStyle().Foreground(theme.Success).Render(
+					fmt.Sprintf("✓ warmed   [%s] %s (%.1fs)", requests[index].Mode, prompt, result.Duration.Seconds())))
+			case ai.WarmSkipped:
+				fmt.Println(lipgloss.NewStyle().Foreground(theme.TextMuted).Render(
+					fmt.Sprintf("- skipped  [%s] %s: %s", requests[index].Mode, prompt, result.Reason)))
+			default:
+				fmt.Println(lipgloss.NewStyle().Foreground(theme.Error).Render(
+					fmt.Sprintf("✗ failed   [%s] %s: %v", requests[index].Mode, prompt, result.Err)))
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	tokens := 0
+	for _, result := range results {
+		counts[result.Status]++
+		tokens += result.Usage.TotalTokens
+	}
+	fmt.Printf("\nWarmed %d, skipped %d, failed %d (%d tokens)\n",
+		counts[ai.WarmWarmed], counts[ai.WarmSkipped], counts[ai.WarmFailed], tokens)
+	if failed := counts[ai.WarmFailed]; failed > 0 {
+		return utils.NewAppError(utils.ErrCodeAPIFailure, fmt.Sprintf("%d of %d prompts could not be warmed", failed, len(results)), nil).
+			WithHint("Run the command again to retry them; cached prompts are skipped")
+	}
+	return nil
+}
+
+// formatAge formats how long ago something happened in its largest unit,
+// e.g. 45s, 12m, 3h or 5d
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// formatBytes formats a size in B, KB or MB
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
 func displayCacheStats(stats *ai.CacheStats) {
 	fmt.Println("Cache Statistics")
 	fmt.Println("================")
@@ -104,6 +834,11 @@ func displayCacheStats(stats *ai.CacheStats) {
 	// Eviction metrics
 	fmt.Println("\nEvictions:")
 	fmt.Printf("  Total:        %d\n", stats.Evictions)
+	for _, strategy := range ai.EvictionStrategies {
+		if count := stats.EvictionsByPolicy[strategy]; count > 0 {
+			fmt.Printf("  %-13s %d\n", strings.ToUpper(strategy)+":", count)
+		}
+	}
 
 	// Last cleanup
 	if !stats.LastCleanup.IsZero() {
@@ -116,11 +851,20 @@ func displayCacheStats(stats *ai.CacheStats) {
 	fmt.Printf("  TTL:          %s\n", appConfig.Cache.TTL)
 	fmt.Printf("  Max Size:     %d MB\n", appConfig.Cache.MaxSize)
 
+	backend := appConfig.Cache.Backend
+	if appConfig.Cache.Dir == "" {
+		backend = "memory"
+	}
+	fmt.Printf("  Backend:      %s\n", backend)
+
 	if appConfig.Cache.Dir != "" {
 		fmt.Printf("  Persist Dir:  %s\n", appConfig.Cache.Dir)
 
 		// Check if cache file exists
-		cachePath := appConfig.Cache.Dir + "/cache.gob"
+		cachePath := filepath.Join(appConfig.Cache.Dir, "cache.gob")
+		if backend == "disk" {
+			cachePath = filepath.Join(appConfig.Cache.Dir, "cache.log")
+		}
 		if info, err := os.Stat(cachePath); err == nil {
 			fmt.Printf("  Cache File:   %.2f KB\n", float64(info.Size())/1024)
 		}
@@ -139,6 +883,32 @@ func displayCacheStats(stats *ai.CacheStats) {
 	}
 }
 
+// printSemanticHit tells the user an answer was cached for a similar prompt.
+// It goes to stderr so piped output holds only the answer.
+func printSemanticHit(match *ai.SemanticMatch) {
+	if match == nil {
+		return
+	}
+	notice := fmt.Sprintf("≈ Semantic cache hit: %.0f%% similar to %q (--no-semantic-cache to ask again)",
+		match.Similarity*100, ai.PromptPreview(match.Prompt, 60))
+	fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(ui.GetCurrentTheme().Info).Render(notice))
+}
+
+// printStaleHit warns that an answer came from an expired cache entry,
+// because the run is offline or the API call failed. It writes to stderr.
+func printStaleHit(stale *ai.StaleHit) {
+	if stale == nil {
+		return
+	}
+	notice := fmt.Sprintf("⚠ Stale from %s (%s old)", stale.CachedAt.Local().Format("2006-01-02 15:04"), formatAge(time.Since(stale.CachedAt)))
+	if stale.Reason == "offline" {
+		notice += ", answered offline"
+	} else {
+		notice += "; the API call failed: " + stale.Reason
+	}
+	fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(ui.GetCurrentTheme().Warning).Render(notice))
+}
+
 func calculateAverageSaving(stats *ai.CacheStats) float64 {
 	// Estimate average API call latency vs cache retrieval
 	avgAPILatency := 1.5     // seconds (rough estimate)
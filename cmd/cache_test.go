@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/config"
+)
+
+func TestWarmRequestFor_MatchesQuery(t *testing.T) {
+	previous := appConfig
+	appConfig = &config.Config{OpenAI: config.OpenAIConfig{
+		Model:        "gpt-5-mini",
+		Temperature:  0.7,
+		MaxTokens:    500,
+		TopP:         1,
+		SystemPrompt: "Answer in one sentence.",
+	}}
+	t.Cleanup(func() { appConfig = previous })
+
+	cache := ai.NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1})
+	defer cache.Close()
+
+	tests := []struct {
+		name   string
+		prompt warmPrompt
+		system string // the query command's --system
+		model  string // the query command's --model
+	}{
+		{"Defaults", warmPrompt{Prompt: "How do I undo the last git commit?"}, "", ""},
+		{"System", warmPrompt{Prompt: "Where are the docs?", System: "You know the handbook."}, "You know the handbook.", ""},
+		{"Model", warmPrompt{Prompt: "What is a rebase?", Model: "gpt-5"}, "", "gpt-5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := warmRequestFor(&warmFile{}, tt.prompt)
+			require.NoError(t, err)
+			assert.Equal(t, ai.ModeQuery, request.Mode)
+
+			// What 'terminal-ai query' sends for the same prompt and flags
+			queryModel = tt.model
+			defer func() { queryModel = "" }()
+			messages := queryMessages(appConfig, tt.system, tt.prompt.Prompt)
+			options := queryOptions(appConfig)
+
+			assert.Equal(t, cache.GenerateChatKey(messages, options),
+				cache.GenerateChatKey(request.Messages, request.Options))
+		})
+	}
+}
+
+func TestWarmRequestFor_MatchesQuickQuery(t *testing.T) {
+	previous := appConfig
+	appConfig = &config.Config{OpenAI: config.OpenAIConfig{
+		Model:        "gpt-5-mini",
+		Temperature:  1,
+		MaxTokens:    2000,
+		TopP:         1,
+		SystemPrompt: "Answer in one sentence.",
+	}}
+	t.Cleanup(func() { appConfig = previous })
+
+	cache := ai.NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1})
+	defer cache.Close()
+
+	prompt := warmPrompt{Prompt: "What is a rebase?", Mode: ai.ModeQuick}
+	request, err := warmRequestFor(&warmFile{}, prompt)
+	require.NoError(t, err)
+	assert.Equal(t, ai.ModeQuick, request.Mode)
+	assert.True(t, request.Stream, "-q streams by default")
+	require.NoError(t, cache.Set(cache.GenerateChatKey(request.Messages, request.Options),
+		&ai.CacheEntry{Response: &ai.Response{Content: "Replaying commits onto another base."}}, 0))
+
+	// What 'terminal-ai -q' sends for the same prompt finds the warmed answer
+	messages, options := quickRequest(appConfig, "", prompt.Prompt)
+	entry, found := cache.Get(cache.GenerateChatKey(messages, options))
+	require.True(t, found)
+	assert.Equal(t, "Replaying commits onto another base.", entry.Response.Content)
+
+	// The query command's shape is a different request
+	query, err := warmRequestFor(&warmFile{Mode: ai.ModeQuery}, warmPrompt{Prompt: prompt.Prompt})
+	require.NoError(t, err)
+	_, found = cache.Get(cache.GenerateChatKey(query.Messages, query.Options))
+	assert.False(t, found)
+}
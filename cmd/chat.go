@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bufio"
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,7 +14,8 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/user/terminal-ai/internal/ai"
-	// "github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/storage"
 	"github.com/user/terminal-ai/internal/ui"
 )
 
@@ -53,6 +53,7 @@ Commands during chat:
   /load      - Load conversation from file
   /export    - Export conversation as markdown
   /model     - Change the AI model
+  /preset    - List presets or switch to one
   /system    - Set system prompt
   /multiline - Toggle multiline input mode
   /cache     - Show cache statistics
@@ -61,6 +62,7 @@ Commands during chat:
 Example:
   terminal-ai chat
   terminal-ai chat --model gpt-5
+  terminal-ai chat --preset fast
   terminal-ai chat --system "You are a helpful coding assistant"
   terminal-ai chat --load previous-chat.json`,
 	RunE: RunChat,
@@ -82,6 +84,7 @@ func init() {
 
 	// Bind flags to viper
 	viper.BindPFlag("chat.model", chatCmd.Flags().Lookup("model"))
+	chatCmd.RegisterFlagCompletionFunc("model", completeModels)
 	viper.BindPFlag("chat.temperature", chatCmd.Flags().Lookup("temperature"))
 	viper.BindPFlag("chat.max_tokens", chatCmd.Flags().Lookup("max-tokens"))
 	viper.BindPFlag("chat.stream", chatCmd.Flags().Lookup("stream"))
@@ -89,8 +92,40 @@ func init() {
 	viper.BindPFlag("chat.multiline", chatCmd.Flags().Lookup("multiline"))
 }
 
+// chatOptions returns the chat options a session sends: the chat flags, with
+// cfg for those not set
+func chatOptions(cfg *config.Config) ai.ChatOptions {
+	options := ai.ChatOptions{
+		Model:           chatModel,
+		Temperature:     chatTemperature,
+		MaxTokens:       chatMaxTokens,
+		ReasoningEffort: cfg.OpenAI.ReasoningEffort,
+		ServiceTier:     cfg.OpenAI.ServiceTier,
+	}
+
+	// Use defaults from config if not specified
+	if options.Model == "" {
+		options.Model = cfg.OpenAI.Model
+	}
+	if options.Temperature < 0 {
+		options.Temperature = cfg.OpenAI.Temperature
+	}
+	if options.MaxTokens == 0 {
+		options.MaxTokens = cfg.OpenAI.MaxTokens
+	}
+	return options
+}
+
 // RunChat runs the chat command - exported for use in simple mode
 func RunChat(cmd *cobra.Command, args []string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+	enforceRetention()
+	if err := applyPresets(&chatModel); err != nil {
+		return err
+	}
+
 	// Get AI client
 	client := GetAIClient()
 	if client == nil {
@@ -104,22 +139,7 @@ func RunChat(cmd *cobra.Command, args []string) error {
 	}
 
 	// Initialize chat options
-	options := ai.ChatOptions{
-		Model:       chatModel,
-		Temperature: chatTemperature,
-		MaxTokens:   chatMaxTokens,
-	}
-
-	// Use defaults from config if not specified
-	if options.Model == "" {
-		options.Model = cfg.OpenAI.Model
-	}
-	if options.Temperature < 0 {
-		options.Temperature = cfg.OpenAI.Temperature
-	}
-	if options.MaxTokens == 0 {
-		options.MaxTokens = cfg.OpenAI.MaxTokens
-	}
+	options := chatOptions(cfg)
 
 	// Initialize conversation history
 	var messages []ai.Message
@@ -161,7 +181,7 @@ func RunChat(cmd *cobra.Command, args []string) error {
 
 	// Chat loop
 	reader := bufio.NewReader(os.Stdin)
-	ctx := context.Background()
+	ctx := ai.WithMode(commandContext(), ai.ModeChat)
 
 	for {
 		// Get user input
@@ -227,11 +247,19 @@ func RunChat(cmd *cobra.Command, args []string) error {
 
 			// Collect and display response
 			var responseBuilder strings.Builder
+			var semantic *ai.SemanticMatch
+			var stale *ai.StaleHit
 			for chunk := range chunks {
 				if chunk.Error != nil {
 					fmt.Printf("❌ Stream error: %v\n", chunk.Error)
 					break
 				}
+				if chunk.Semantic != nil {
+					semantic = chunk.Semantic
+				}
+				if chunk.Stale != nil {
+					stale = chunk.Stale
+				}
 				if chunk.Done {
 					break
 				}
@@ -239,6 +267,8 @@ func RunChat(cmd *cobra.Command, args []string) error {
 				fmt.Print(aiStyle.Render(chunk.Content))
 			}
 			fmt.Println()
+			printSemanticHit(semantic)
+			printStaleHit(stale)
 
 			// Add assistant response to history
 			messages = append(messages, ai.Message{
@@ -264,6 +294,8 @@ func RunChat(cmd *cobra.Command, args []string) error {
 
 			// Display response
 			fmt.Printf("%s %s\n", aiStyle.Render("AI:"), aiStyle.Render(resp.Content))
+			printSemanticHit(resp.Semantic)
+			printStaleHit(resp.Stale)
 			fmt.Println()
 
 			// Show token usage if cache is enabled
@@ -357,11 +389,20 @@ func handleSimpleChatCommand(command string, messages *[]ai.Message, options *ai
 	case "/model":
 		if len(parts) < 2 {
 			fmt.Printf("Current model: %s\n", options.Model)
+		} else if _, ok := GetConfig().Preset(parts[1]); ok {
+			switchPreset(parts[1], messages, options)
 		} else {
 			options.Model = parts[1]
 			fmt.Printf("✓ Model changed to: %s\n", options.Model)
 		}
 
+	case "/preset":
+		if len(parts) < 2 {
+			printPresets(options)
+		} else {
+			switchPreset(parts[1], messages, options)
+		}
+
 	case "/system":
 		if len(parts) < 2 {
 			fmt.Println("⚠️  Usage: /system <prompt>")
@@ -442,6 +483,57 @@ func handleSimpleChatCommand(command string, messages *[]ai.Message, options *ai
 	return false
 }
 
+// switchPreset applies a preset's settings to the chat options mid-session,
+// replacing the system prompt when the preset sets one
+func switchPreset(name string, messages *[]ai.Message, options *ai.ChatOptions) {
+	presetOpts, err := presetOptions(name)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	options.Model = presetOpts.Model
+	options.Temperature = presetOpts.Temperature
+	options.MaxTokens = presetOpts.MaxTokens
+	options.ReasoningEffort = presetOpts.ReasoningEffort
+	options.ServiceTier = presetOpts.ServiceTier
+
+	if preset, _ := GetConfig().Preset(name); preset.SystemPrompt != "" {
+		if len(*messages) > 0 && (*messages)[0].Role == "system" {
+			(*messages)[0].Content = preset.SystemPrompt
+		} else {
+			*messages = append([]ai.Message{{Role: "system", Content: preset.SystemPrompt}}, *messages...)
+		}
+	}
+	fmt.Printf("✓ Preset %s: %s\n", strings.ToLower(name), describeChatOptions(*options))
+}
+
+// printPresets lists the configured presets and the current settings
+func printPresets(options *ai.ChatOptions) {
+	cfg := GetConfig()
+	names := cfg.PresetNames()
+	if len(names) == 0 {
+		fmt.Println("No presets defined. Add them under presets in the config file.")
+	} else {
+		fmt.Println("\n=== Presets ===")
+		for _, name := range names {
+			fmt.Printf("  %-12s %s\n", name, describePreset(cfg.Presets[name]))
+		}
+	}
+	fmt.Printf("\nCurrent: %s\n\n", describeChatOptions(*options))
+}
+
+// describeChatOptions summarizes the settings a preset controls
+func describeChatOptions(options ai.ChatOptions) string {
+	summary := fmt.Sprintf("model=%s max_tokens=%d", options.Model, options.MaxTokens)
+	if options.ReasoningEffort != "" {
+		summary += " reasoning_effort=" + options.ReasoningEffort
+	}
+	if options.ServiceTier != "" {
+		summary += " service_tier=" + options.ServiceTier
+	}
+	return summary
+}
+
 func printSimpleChatHelp() {
 	fmt.Println("\n=== Chat Commands ===")
 	commands := [][]string{
@@ -451,6 +543,7 @@ func printSimpleChatHelp() {
 		{"/load <file>", "Load conversation from file"},
 		{"/export [file]", "Export conversation as markdown"},
 		{"/model [name]", "Show or change AI model"},
+		{"/preset [name]", "List presets or switch to one"},
 		{"/system <prompt>", "Set system prompt"},
 		{"/multiline", "Toggle multiline input mode"},
 		{"/history", "Show conversation history"},
@@ -465,7 +558,9 @@ func printSimpleChatHelp() {
 }
 
 func exportSimpleConversation(messages []ai.Message, filename string) error {
-	file, err := os.Create(filename)
+	// Exports are meant to be read, so they are not encrypted, but they are
+	// kept private like saved sessions
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
@@ -518,11 +613,11 @@ func saveConversationHistory(messages []ai.Message, filename string, model strin
 		return err
 	}
 
-	return os.WriteFile(filename, data, 0644)
+	return storage.Default().WriteFile(filename, data)
 }
 
 func loadConversationHistory(filename string) (*ConversationHistory, error) {
-	data, err := os.ReadFile(filename)
+	data, err := storage.Default().ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
@@ -535,15 +630,25 @@ func loadConversationHistory(filename string) (*ConversationHistory, error) {
 	return &history, nil
 }
 
-func saveConversationToCache(messages []ai.Message, model string) {
-	// Save to a default cache location
+// chatHistoryDir returns where chat sessions are saved automatically, or ""
+// without a home directory
+func chatHistoryDir() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
-		return
+		return ""
 	}
+	return filepath.Join(home, ".terminal-ai", "chat-history")
+}
 
-	cacheDir := filepath.Join(home, ".terminal-ai", "chat-history")
-	os.MkdirAll(cacheDir, 0755)
+func saveConversationToCache(messages []ai.Message, model string) {
+	// Save to a default cache location
+	cacheDir := chatHistoryDir()
+	if cacheDir == "" {
+		return
+	}
+	if err := storage.MkdirPrivate(cacheDir); err != nil {
+		return
+	}
 
 	filename := filepath.Join(cacheDir, "last-chat.json")
 	saveConversationHistory(messages, filename, model)
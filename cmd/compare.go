@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/ui"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+var (
+	compareModels      []string
+	compareSystem      string
+	compareTemperature float32
+	compareMaxTokens   int
+	compareJSON        bool
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare [prompt]",
+	Short: "Compare the answers of several models side by side",
+	Long: `Send the same prompt to several models at once and compare their answers.
+
+On a terminal the answers stream in parallel columns; otherwise each model's
+answer is printed as its own section. A summary of latency, time to first
+token, tokens and estimated cost follows. Model names are passed to the
+configured endpoint as given, so gateway routes such as local/llama work too.
+Names of presets compare the preset's model with its settings. Requests skip
+the response cache and deduplication, so every answer and timing is live.
+
+Examples:
+  terminal-ai compare -m gpt-5,gpt-4.1 "Explain the CAP theorem in two sentences"
+  terminal-ai compare -m gpt-5,gpt-4.1,local/llama "Write a haiku about Go" --json
+  terminal-ai compare -m fast,smart "Summarize the plot of Hamlet"`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCompare(strings.Join(args, " "))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().StringSliceVarP(&compareModels, "models", "m", nil, "comma-separated models to compare (at least two)")
+	compareCmd.Flags().StringVar(&compareSystem, "system", "", "system prompt sent to every model")
+	compareCmd.Flags().Float32VarP(&compareTemperature, "temperature", "t", -1, "temperature for every model (default from config)")
+	compareCmd.Flags().IntVar(&compareMaxTokens, "max-tokens", 0, "maximum tokens per answer (default from config)")
+	compareCmd.Flags().BoolVar(&compareJSON, "json", false, "print results as JSON")
+	compareCmd.MarkFlagRequired("models")
+	compareCmd.RegisterFlagCompletionFunc("models", completeModels)
+}
+
+// compareJSONResult is the --json form of a comparison result
+type compareJSONResult struct {
+	Model              string   `json:"model"`
+	Preset             string   `json:"preset,omitempty"`
+	Content            string   `json:"content"`
+	LatencyMs          int64    `json:"latency_ms"`
+	TimeToFirstTokenMs int64    `json:"time_to_first_token_ms"`
+	Usage              ai.Usage `json:"usage"`
+	CostUSD            *float64 `json:"cost_usd"`
+	Error              string   `json:"error,omitempty"`
+}
+
+func runCompare(prompt string) error {
+	models := make([]string, 0, len(compareModels))
+	for _, model := range compareModels {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	if len(models) < 2 {
+		return utils.NewValidationError("compare needs at least two models", "models").
+			WithHint("Pass them comma-separated, e.g. -m gpt-5,gpt-4.1")
+	}
+
+	if err := ensureApp(); err != nil {
+		return err
+	}
+	if err := applyPresets(nil); err != nil {
+		return err
+	}
+	client := GetAIClient()
+	config := GetConfig()
+
+	var messages []ai.Message
+	if compareSystem != "" {
+		messages = append(messages, ai.Message{Role: "system", Content: compareSystem})
+	}
+	messages = append(messages, ai.Message{Role: "user", Content: prompt})
+
+	// Each entry is a model, or a preset with its own settings. Flags given
+	// on the command line apply to all of them.
+	options := make([]ai.ChatOptions, len(models))
+	labels := make([]string, len(models))
+	presets := make([]string, len(models))
+	for i, model := range models {
+		options[i] = ai.ChatOptions{
+			Model:           model,
+			Temperature:     config.OpenAI.Temperature,
+			MaxTokens:       config.OpenAI.MaxTokens,
+			TopP:            config.OpenAI.TopP,
+			ReasoningEffort: config.OpenAI.ReasoningEffort,
+			ServiceTier:     config.OpenAI.ServiceTier,
+		}
+		labels[i] = model
+		if _, ok := config.Preset(model); ok {
+			presetOpts, err := presetOptions(model)
+			if err != nil {
+				return err
+			}
+			options[i] = presetOpts
+			presets[i] = strings.ToLower(model)
+			labels[i] = fmt.Sprintf("%s (%s)", presets[i], presetOpts.Model)
+		}
+		if compareTemperature >= 0 {
+			options[i].Temperature = compareTemperature
+		}
+		if compareMaxTokens > 0 {
+			options[i].MaxTokens = compareMaxTokens
+		}
+	}
+
+	ctx := commandContext()
+	var results []ai.ComparisonResult
+	switch {
+	case compareJSON:
+		results = ai.CompareModels(ctx, client, messages, options, ai.CompareObserver{})
+		if err := printCompareJSON(results, presets); err != nil {
+			return err
+		}
+	case isatty.IsTerminal(os.Stdout.Fd()):
+		results = compareInColumns(ctx, client, messages, options, labels)
+	default:
+		results = compareInSections(ctx, client, messages, options, labels)
+	}
+
+	if !compareJSON {
+		printCompareSummary(results, labels, config.UI.ColorOutput)
+	}
+
+	// The comparison only fails when no model answered
+	for _, result := range results {
+		if result.Err == nil {
+			return nil
+		}
+	}
+	return results[0].Err
+}
+
+// compareInColumns streams the answers into columns, redrawing them in place
+// as text arrives
+func compareInColumns(ctx context.Context, client ai.Client, messages []ai.Message, options []ai.ChatOptions, labels []string) []ai.ComparisonResult {
+	var mu sync.Mutex
+	texts := make([]string, len(labels))
+	titles := make([]string, len(labels))
+	copy(titles, labels)
+	changed := true
+
+	width := ui.GetTerminalWidth()
+	maxLines := ui.GetTerminalHeight() - 4
+	if maxLines < 5 {
+		maxLines = 5
+	}
+
+	drawn := 0
+	draw := func(limit int) {
+		mu.Lock()
+		output := ui.Columns(titles, texts, width, limit)
+		changed = false
+		mu.Unlock()
+
+		if drawn > 0 {
+			// Move back to the top of the previous frame and clear it
+			fmt.Printf("\x1b[%dA\x1b[J", drawn)
+		}
+		fmt.Print(output)
+		drawn = strings.Count(output, "\n")
+	}
+
+	done := make(chan []ai.ComparisonResult)
+	go func() {
+		done <- ai.CompareModels(ctx, client, messages, options, ai.CompareObserver{
+			OnChunk: func(index int, content string) {
+				mu.Lock()
+				texts[index] += content
+				changed = true
+				mu.Unlock()
+			},
+			OnDone: func(index int, result ai.ComparisonResult) {
+				mu.Lock()
+				if result.Err != nil {
+					texts[index] += fmt.Sprintf("\n[error: %v]", result.Err)
+				}
+				titles[index] = fmt.Sprintf("%s (%s)", labels[index], formatSeconds(result.Latency))
+				changed = true
+				mu.Unlock()
+			},
+		})
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	draw(maxLines)
+	for {
+		select {
+		case results := <-done:
+			// The final frame shows the complete answers
+			draw(0)
+			return results
+		case <-ticker.C:
+			mu.Lock()
+			redraw := changed
+			mu.Unlock()
+			if redraw {
+				draw(maxLines)
+			}
+		}
+	}
+}
+
+// compareInSections prints each model's answer as a section, in the order
+// the models were given, as soon as it and the ones before it are complete
+func compareInSections(ctx context.Context, client ai.Client, messages []ai.Message, options []ai.ChatOptions, labels []string) []ai.ComparisonResult {
+	finished := make([]chan ai.ComparisonResult, len(labels))
+	for i := range finished {
+		finished[i] = make(chan ai.ComparisonResult, 1)
+	}
+
+	done := make(chan []ai.ComparisonResult)
+	go func() {
+		done <- ai.CompareModels(ctx, client, messages, options, ai.CompareObserver{
+			OnDone: func(index int, result ai.ComparisonResult) {
+				finished[index] <- result
+			},
+		})
+	}()
+
+	for i, label := range labels {
+		result := <-finished[i]
+		fmt.Printf("=== %s ===\n", label)
+		if result.Content != "" {
+			fmt.Println(strings.TrimRight(result.Content, "\n"))
+		}
+		if result.Err != nil {
+			fmt.Printf("[error: %v]\n", result.Err)
+		}
+		fmt.Println()
+	}
+	return <-done
+}
+
+// printCompareSummary prints latency, time to first token, tokens and cost
+// per model, under the labels the models were shown with
+func printCompareSummary(results []ai.ComparisonResult, labels []string, color bool) {
+	formatter := ui.NewFormatter(ui.FormatterOptions{
+		ColorEnabled: color,
+		Width:        ui.GetTerminalWidth(),
+	})
+
+	headers := []string{"Model", "Latency", "First token", "Prompt", "Completion", "Cost", "Status"}
+	rows := make([][]string, 0, len(results))
+	for i, result := range results {
+		ttft := "-"
+		if result.TimeToFirstToken > 0 {
+			ttft = formatSeconds(result.TimeToFirstToken)
+		}
+		cost := "-"
+		if result.CostKnown {
+			cost = fmt.Sprintf("$%.4f", result.Cost)
+		}
+		status := "ok"
+		if result.Err != nil {
+			status = "error"
+			if appErr := utils.GetAppError(result.Err); appErr != nil {
+				status = appErr.Code
+			}
+		}
+		rows = append(rows, []string{
+			labels[i],
+			formatSeconds(result.Latency),
+			ttft,
+			fmt.Sprintf("%d", result.Usage.PromptTokens),
+			fmt.Sprintf("%d", result.Usage.CompletionTokens),
+			cost,
+			status,
+		})
+	}
+	fmt.Print(formatter.Table(headers, rows))
+}
+
+// printCompareJSON prints the results as a JSON document, naming the preset
+// each model came from, if any
+func printCompareJSON(results []ai.ComparisonResult, presets []string) error {
+	out := make([]compareJSONResult, len(results))
+	for i, result := range results {
+		out[i] = compareJSONResult{
+			Model:              result.Model,
+			Preset:             presets[i],
+			Content:            result.Content,
+			LatencyMs:          result.Latency.Milliseconds(),
+			TimeToFirstTokenMs: result.TimeToFirstToken.Milliseconds(),
+			Usage:              result.Usage,
+		}
+		if result.CostKnown {
+			cost := result.Cost
+			out[i].CostUSD = &cost
+		}
+		if result.Err != nil {
+			out[i].Error = result.Err.Error()
+		}
+	}
+
+	return printJSON(map[string]interface{}{"results": out})
+}
+
+// formatSeconds formats a duration as seconds with two decimals
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
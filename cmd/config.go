@@ -367,8 +367,9 @@ func validateConfig() error {
 
 	formatter.PrintSuccess("Configuration is valid")
 
-	// Warnings are handled internally by the validator
-	// Additional warnings could be added here if needed
+	for _, warning := range validator.GetWarnings() {
+		formatter.PrintWarning(warning)
+	}
 
 	return nil
 }
@@ -406,7 +407,7 @@ func testAPIConnectionWithConfig(cfg *config.Config) error {
 	defer client.Close()
 
 	// Test with a simple query
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(commandContext(), 10*time.Second)
 	defer cancel()
 
 	response, err := client.Query(ctx, "Say 'Hello, Terminal AI!' if you can hear me.")
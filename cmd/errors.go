@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/spf13/cobra"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// PrintError prints err to stderr. Classified errors are shown with their
+// user message, remediation hint and request ID.
+func PrintError(err error) {
+	if err == nil {
+		return
+	}
+
+	appErr := utils.GetAppError(err)
+	if appErr == nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	message := appErr.GetUserMessage()
+	if appErr.Message != "" && !strings.Contains(message, appErr.Message) {
+		message = fmt.Sprintf("%s (%s)", message, appErr.Message)
+	}
+	fmt.Fprintf(os.Stderr, "Error: %s\n", message)
+
+	// Provider errors already carry their cause in the message
+	var apiErr *openai.Error
+	if appErr.Err != nil && !errors.As(appErr.Err, &apiErr) && appErr.Code != utils.ErrCodeCanceled {
+		fmt.Fprintf(os.Stderr, "Cause: %v\n", appErr.Err)
+	}
+	if appErr.StatusCode != 0 && apiErr != nil {
+		fmt.Fprintf(os.Stderr, "Status: %d\n", appErr.StatusCode)
+	}
+	if appErr.Hint != "" {
+		fmt.Fprintf(os.Stderr, "Hint: %s\n", appErr.Hint)
+	}
+	if appErr.RequestID != "" {
+		fmt.Fprintf(os.Stderr, "Request ID: %s\n", appErr.RequestID)
+	}
+}
+
+// exitWithError prints err and exits with the code for its error class
+func exitWithError(err error) {
+	PrintError(err)
+	finishTracing(err)
+	Cleanup()
+	os.Exit(utils.ExitCode(err))
+}
+
+// usageError marks err as invalid command-line usage
+func usageError(err error) error {
+	var appErr *utils.AppError
+	if errors.As(err, &appErr) {
+		return err
+	}
+	return utils.NewAppError(utils.ErrCodeInvalidInput, err.Error(), nil)
+}
+
+// flagError reports flag parsing failures as usage errors, pointing at the
+// help for the command that was run
+func flagError(cmd *cobra.Command, err error) error {
+	return utils.NewAppError(utils.ErrCodeInvalidInput, err.Error(), nil).
+		WithHint(fmt.Sprintf("Run '%s --help' for usage", cmd.CommandPath()))
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/ui"
+)
+
+var (
+	imageModel   string
+	imageSize    string
+	imageQuality string
+	imageCount   int
+	imageOutput  string
+	imageInput   string
+	imageMask    string
+)
+
+// imageCmd represents the image command
+var imageCmd = &cobra.Command{
+	Use:   "image [prompt]",
+	Short: "Generate or edit images",
+	Long: `Generate images from a prompt with the images endpoint and save them as
+files. With --image, the given picture is edited instead; --mask limits the
+edit to the mask's transparent areas.
+
+Examples:
+  terminal-ai image "diagram of a three-tier web architecture" --size 1536x1024
+  terminal-ai image "a lighthouse at dusk, watercolor" --n 2 -o out/
+  terminal-ai image "add a red scarf" --image photo.png --mask scarf-mask.png`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runImage(strings.Join(args, " "))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(imageCmd)
+
+	imageCmd.Flags().StringVarP(&imageModel, "model", "m", "", "image model (default from image.model)")
+	imageCmd.Flags().StringVar(&imageSize, "size", "", "image size, e.g. 1024x1024, 1536x1024, auto (default from image.size)")
+	imageCmd.Flags().StringVar(&imageQuality, "quality", "", "image quality (default from image.quality)")
+	imageCmd.Flags().IntVarP(&imageCount, "n", "n", 1, "number of images to generate")
+	imageCmd.Flags().StringVarP(&imageOutput, "output", "o", ".", "directory to save images in")
+	imageCmd.Flags().StringVar(&imageInput, "image", "", "image file to edit")
+	imageCmd.Flags().StringVar(&imageMask, "mask", "", "PNG mask; transparent areas mark where --image is edited")
+}
+
+func runImage(prompt string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+
+	images, ok := GetAIClient().(ai.ImageClient)
+	if !ok {
+		return fmt.Errorf("the configured client does not support image generation")
+	}
+
+	config := GetConfig()
+	options := ai.ImageOptions{
+		Prompt:  prompt,
+		Model:   imageModel,
+		Size:    imageSize,
+		Quality: imageQuality,
+		N:       imageCount,
+		Image:   imageInput,
+		Mask:    imageMask,
+	}
+	if options.Model == "" {
+		options.Model = config.Image.Model
+	}
+	if options.Size == "" {
+		options.Size = config.Image.Size
+	}
+	if options.Quality == "" {
+		options.Quality = config.Image.Quality
+	}
+
+	message := "Generating image..."
+	if imageInput != "" {
+		message = "Editing image..."
+	}
+	spinner := ui.NewSimpleSpinner(message)
+	spinner.Start()
+
+	result, err := images.GenerateImages(commandContext(), options)
+	if err != nil {
+		spinner.StopWithError("Image request failed")
+		return err
+	}
+	spinner.Stop()
+
+	if err := os.MkdirAll(imageOutput, 0700); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	formatter := ui.NewFormatter(ui.FormatterOptions{
+		ColorEnabled: config.UI.ColorOutput,
+		Width:        ui.GetTerminalWidth(),
+	})
+
+	base := imageFileBase(prompt, time.Now())
+	revised := ""
+	for i, image := range result.Images {
+		if image.RevisedPrompt != "" {
+			revised = image.RevisedPrompt
+		}
+		if len(image.Data) == 0 {
+			fmt.Println(image.URL)
+			continue
+		}
+
+		name := fmt.Sprintf("%s.%s", base, result.Format)
+		if len(result.Images) > 1 {
+			name = fmt.Sprintf("%s-%d.%s", base, i+1, result.Format)
+		}
+		path := filepath.Join(imageOutput, name)
+		if err := os.WriteFile(path, image.Data, 0600); err != nil {
+			return fmt.Errorf("failed to write image: %w", err)
+		}
+		fmt.Println(path)
+	}
+
+	if revised != "" && revised != prompt {
+		formatter.PrintInfo(fmt.Sprintf("Revised prompt: %s", revised))
+	}
+	return nil
+}
+
+// imageFileBase names image files after the first words of the prompt and
+// the time, e.g. diagram-of-a-three-tier-20260102-150405
+func imageFileBase(prompt string, now time.Time) string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(prompt), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words = append(words, word)
+		if len(words) == 5 {
+			break
+		}
+	}
+
+	slug := strings.Join(words, "-")
+	if runes := []rune(slug); len(runes) > 40 {
+		slug = strings.TrimRight(string(runes[:40]), "-")
+	}
+	if slug == "" {
+		slug = "image"
+	}
+	return fmt.Sprintf("%s-%s", slug, now.Format("20060102-150405"))
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/user/terminal-ai/internal/mock"
+)
+
+var (
+	mockPort   int
+	mockHost   string
+	mockScript string
+)
+
+// mockServerCmd represents the mock-server command
+var mockServerCmd = &cobra.Command{
+	Use:   "mock-server",
+	Short: "Run a local OpenAI-compatible mock server",
+	Long: `Run a local server implementing /v1/chat/completions (streaming and
+non-streaming) and /v1/models. Responses and failures are driven by a YAML
+script, so retry, rate-limit, cache and streaming paths can be exercised
+without network access.
+
+Point the client at it with:
+  TERMINAL_AI_OPENAI_BASE_URL=http://localhost:8089/v1 OPENAI_API_KEY=sk-mock00000000000000000000000000000000000000000000 terminal-ai -q "hello"
+
+Script format:
+  models: [gpt-5-mini, gpt-4o]
+  default: "Fallback answer"
+  responses:
+    - match: "docker"          # substring of the last user message
+      content: "docker ps -a"
+    - match: "retry"
+      times: 2                 # apply twice, then fall through
+      error: {status: 429, retry_after: 1}
+    - match: "slow"
+      content: "a slow streamed answer"
+      chunk_delay: 250ms
+    - match: "cut"
+      content: "this stream is cut short"
+      truncate_after: 2
+
+Examples:
+  terminal-ai mock-server
+  terminal-ai mock-server --port 8089 --script responses.yaml`,
+	RunE: runMockServer,
+}
+
+func init() {
+	rootCmd.AddCommand(mockServerCmd)
+
+	mockServerCmd.Flags().IntVarP(&mockPort, "port", "p", 8089, "port to listen on")
+	mockServerCmd.Flags().StringVar(&mockHost, "host", "127.0.0.1", "host to bind to")
+	mockServerCmd.Flags().StringVar(&mockScript, "script", "", "YAML script describing responses and injected errors")
+}
+
+func runMockServer(cmd *cobra.Command, args []string) error {
+	level := zerolog.InfoLevel
+	if verbose {
+		level = zerolog.DebugLevel
+	}
+	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).Level(level).With().Timestamp().Logger()
+
+	script := mock.DefaultScript()
+	if mockScript != "" {
+		var err error
+		script, err = mock.LoadScript(mockScript)
+		if err != nil {
+			return err
+		}
+	}
+
+	addr := fmt.Sprintf("%s:%d", mockHost, mockPort)
+	fmt.Fprintf(os.Stderr, "Mock server listening on http://%s/v1 (%d scripted responses)\n", addr, len(script.Responses))
+
+	return mock.NewServer(script).ListenAndServe(addr)
+}
@@ -0,0 +1,475 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/ui"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+var (
+	modelsProvider     string
+	modelsFilter       string
+	modelsJSON         bool
+	modelsNoCache      bool
+	modelsFromRegistry bool
+	modelsRefreshPrune bool
+)
+
+// modelsCmd represents the models command
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List the available models and their capabilities",
+	Long: `List the models served by the configured endpoint together with what the
+model registry knows about them: context window, maximum output, reasoning
+support and pricing.
+
+The registry records each model's family, context window, maximum output,
+reasoning support, accepted parameters, pricing and aliases. It is built from
+the models shipped with terminal-ai, the registry file (models.file) and
+models.custom in the config file, later sources taking priority. Dated
+snapshots such as gpt-5-2025-08-07 resolve to their base model.
+
+The endpoint's list is cached for models.cache_ttl, which also serves shell
+completion of --model.
+
+Filters (comma-separated, all must match):
+  reasoning, non-reasoning   reasoning support
+  known, unknown             whether the registry describes the model
+  priced                     the registry has a price
+  <text>                     substring of the model id or family
+
+Examples:
+  terminal-ai models
+  terminal-ai models --filter reasoning
+  terminal-ai models --provider local --json
+  terminal-ai models --registry --filter gpt-4.1
+  terminal-ai models show gpt-5-2025-08-07`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runModelsList()
+	},
+}
+
+// modelsShowCmd represents the models show command
+var modelsShowCmd = &cobra.Command{
+	Use:   "show [model]",
+	Short: "Show the capabilities of a model",
+	Long: `Show what the model registry knows about a model and whether the configured
+endpoint serves it. Aliases and snapshot names are resolved to the model they
+refer to.
+
+Examples:
+  terminal-ai models show gpt-5
+  terminal-ai models show gpt-4o-2024-08-06 --json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeModels,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runModelsShow(args[0])
+	},
+}
+
+// modelsRefreshCmd represents the models refresh command
+var modelsRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Add the models served by the endpoint to the registry file",
+	Long: `List the models served by the configured endpoint and add those the
+registry does not know yet to the registry file (models.file), so they pass
+validation and can be described further by editing the file.
+
+Examples:
+  terminal-ai models refresh
+  terminal-ai models refresh --prune  # also drop models it added that are no longer served`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runModelsRefresh()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(modelsCmd)
+	modelsCmd.AddCommand(modelsShowCmd)
+	modelsCmd.AddCommand(modelsRefreshCmd)
+
+	modelsCmd.Flags().StringVar(&modelsProvider, "provider", "", "only models from this provider (e.g. openai, or the prefix of local/llama)")
+	modelsCmd.Flags().StringVar(&modelsFilter, "filter", "", "only models matching the filters (see above)")
+	modelsCmd.Flags().BoolVar(&modelsFromRegistry, "registry", false, "list the registry instead of the endpoint's models (no network)")
+	modelsCmd.PersistentFlags().BoolVar(&modelsJSON, "json", false, "print as JSON")
+	modelsCmd.PersistentFlags().BoolVar(&modelsNoCache, "no-cache", false, "fetch the endpoint's models even when the cached list is fresh")
+
+	modelsRefreshCmd.Flags().BoolVar(&modelsRefreshPrune, "prune", false, "remove models added by earlier refreshes that the endpoint no longer lists")
+}
+
+// modelListing is a model with what the registry knows about it
+type modelListing struct {
+	ID       string            `json:"id"`
+	Provider string            `json:"provider"`
+	Known    bool              `json:"known"`
+	Served   *bool             `json:"served,omitempty"` // only set by models show
+	Registry *config.ModelInfo `json:"registry,omitempty"`
+}
+
+// newModelListing looks a model up in the registry
+func newModelListing(id string) modelListing {
+	listing := modelListing{ID: id, Provider: config.ModelProvider(id)}
+	if info, ok := config.LookupModel(id); ok {
+		listing.Known = true
+		listing.Registry = &info
+	}
+	return listing
+}
+
+// modelListCache returns the cache of the endpoint's model list
+func modelListCache(cfg *config.Config) ai.ModelListCache {
+	cache := ai.ModelListCache{BaseURL: cfg.OpenAI.BaseURL, TTL: cfg.Models.CacheTTL}
+	if cfg.Cache.Dir != "" {
+		cache.Path = filepath.Join(cfg.Cache.Dir, "models.json")
+	}
+	return cache
+}
+
+// servedModels returns the models the endpoint serves, cached for
+// models.cache_ttl
+func servedModels(ctx context.Context, refresh bool) ([]string, error) {
+	return ai.ListModelsCached(ctx, GetAIClient(), modelListCache(GetConfig()), refresh)
+}
+
+func runModelsList() error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+
+	var ids []string
+	if modelsFromRegistry {
+		for _, info := range config.Models().All() {
+			ids = append(ids, info.ID)
+		}
+	} else {
+		served, err := servedModels(commandContext(), modelsNoCache)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, served...)
+		sort.Strings(ids)
+	}
+
+	var listings []modelListing
+	for _, id := range ids {
+		listing := newModelListing(id)
+		if modelsProvider != "" && !strings.EqualFold(listing.Provider, modelsProvider) {
+			continue
+		}
+		if !matchesModelFilter(listing, modelsFilter) {
+			continue
+		}
+		listings = append(listings, listing)
+	}
+
+	if modelsJSON {
+		if listings == nil {
+			listings = []modelListing{}
+		}
+		return printJSON(map[string]interface{}{"models": listings})
+	}
+
+	formatter := ui.NewFormatter(ui.FormatterOptions{
+		ColorEnabled: GetConfig().UI.ColorOutput,
+		Width:        ui.GetTerminalWidth(),
+	})
+	if len(listings) == 0 {
+		formatter.PrintInfo("No models match")
+		return nil
+	}
+
+	headers := []string{"Model", "Provider", "Family", "Context", "Max output", "Reasoning", "Input $/1M", "Output $/1M"}
+	rows := make([][]string, 0, len(listings))
+	for _, listing := range listings {
+		row := []string{listing.ID, listing.Provider, "-", "-", "-", "-", "-", "-"}
+		if info := listing.Registry; info != nil {
+			row[2] = valueOrDash(info.Family)
+			row[3] = formatTokenCount(info.ContextWindow)
+			row[4] = formatTokenCount(info.MaxOutput)
+			row[5] = yesNo(info.Reasoning)
+			if info.Pricing != nil {
+				row[6] = fmt.Sprintf("%.2f", info.Pricing.Input)
+				row[7] = fmt.Sprintf("%.2f", info.Pricing.Output)
+			}
+		}
+		rows = append(rows, row)
+	}
+	fmt.Print(formatter.Table(headers, rows))
+	return nil
+}
+
+// matchesModelFilter reports whether a model matches every comma-separated
+// filter term
+func matchesModelFilter(listing modelListing, filter string) bool {
+	for _, term := range strings.Split(filter, ",") {
+		term = strings.ToLower(strings.TrimSpace(term))
+		info := listing.Registry
+		var ok bool
+		switch term {
+		case "":
+			ok = true
+		case "reasoning":
+			ok = info != nil && info.Reasoning
+		case "non-reasoning":
+			ok = info != nil && !info.Reasoning
+		case "known":
+			ok = listing.Known
+		case "unknown":
+			ok = !listing.Known
+		case "priced":
+			ok = info != nil && info.Pricing != nil
+		default:
+			ok = strings.Contains(strings.ToLower(listing.ID), term) ||
+				(info != nil && strings.Contains(strings.ToLower(info.Family), term))
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func runModelsShow(id string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+
+	listing := newModelListing(id)
+
+	// Whether the endpoint serves the model is extra information; the
+	// registry answer stands on its own when the list is unavailable
+	served, err := servedModels(commandContext(), modelsNoCache)
+	if err == nil {
+		isServed := false
+		for _, model := range served {
+			if model == id {
+				isServed = true
+				break
+			}
+		}
+		listing.Served = &isServed
+	}
+
+	if !listing.Known && (listing.Served == nil || !*listing.Served) {
+		return utils.NewAppError(utils.ErrCodeNotFound, fmt.Sprintf("unknown model: %s", id), err).
+			WithHint("Run 'terminal-ai models' to list available models, or describe it under models.custom")
+	}
+
+	if modelsJSON {
+		return printJSON(listing)
+	}
+
+	formatter := ui.NewFormatter(ui.FormatterOptions{
+		ColorEnabled: GetConfig().UI.ColorOutput,
+		Width:        ui.GetTerminalWidth(),
+	})
+	formatter.PrintSection(id)
+
+	field := func(name, value string) {
+		fmt.Printf("  %-16s %s\n", name+":", value)
+	}
+	field("Provider", listing.Provider)
+	switch {
+	case listing.Served == nil:
+		field("Served", "unknown (could not list the endpoint's models)")
+	default:
+		field("Served", yesNo(*listing.Served))
+	}
+
+	info := listing.Registry
+	if info == nil {
+		field("Registry", "not described; add it under models.custom or run 'terminal-ai models refresh'")
+		return nil
+	}
+	if info.ID != id {
+		field("Resolves to", info.ID)
+	}
+	field("Family", valueOrDash(info.Family))
+	field("Context window", formatTokenLimit(info.ContextWindow))
+	field("Max output", formatTokenLimit(info.MaxOutput))
+	field("Reasoning", yesNo(info.Reasoning))
+	if len(info.Parameters) > 0 {
+		field("Parameters", strings.Join(info.Parameters, ", "))
+	} else {
+		field("Parameters", "all")
+	}
+	if info.Pricing != nil {
+		field("Pricing", fmt.Sprintf("$%.2f input, $%.2f output per 1M tokens", info.Pricing.Input, info.Pricing.Output))
+	} else {
+		field("Pricing", "-")
+	}
+	if len(info.Aliases) > 0 {
+		field("Aliases", strings.Join(info.Aliases, ", "))
+	}
+	return nil
+}
+
+func runModelsRefresh() error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+	cfg := GetConfig()
+	if cfg.Models.File == "" {
+		return utils.NewValidationError("no registry file configured", "models.file").
+			WithHint("Set models.file in the config file, e.g. ~/.terminal-ai/models.yaml")
+	}
+
+	formatter := ui.NewFormatter(ui.FormatterOptions{
+		ColorEnabled: cfg.UI.ColorOutput,
+	})
+
+	live, err := servedModels(commandContext(), true)
+	if err != nil {
+		return err
+	}
+
+	saved, err := config.ReadModelFile(cfg.Models.File)
+	if err != nil {
+		return err
+	}
+
+	// Keep what the file already has, dropping models that are gone when
+	// pruning, and add the live models nothing else describes
+	merged, removed := saved, []string(nil)
+	if modelsRefreshPrune {
+		merged, removed = pruneModels(saved, live)
+	}
+
+	added := config.Models().Unknown(live)
+	sort.Strings(added)
+	for _, id := range added {
+		merged = append(merged, config.ModelInfo{ID: id, Discovered: true})
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		formatter.PrintSuccess(fmt.Sprintf("The registry already knows all %d models served by the endpoint", len(live)))
+		return nil
+	}
+
+	if err := config.WriteModelFile(cfg.Models.File, merged); err != nil {
+		return err
+	}
+	for _, id := range added {
+		fmt.Printf("  + %s\n", id)
+	}
+	for _, id := range removed {
+		fmt.Printf("  - %s\n", id)
+	}
+	message := fmt.Sprintf("Added %d of %d served models to %s", len(added), len(live), cfg.Models.File)
+	if len(removed) > 0 {
+		message += fmt.Sprintf(", removed %d", len(removed))
+	}
+	formatter.PrintSuccess(message)
+	return nil
+}
+
+// pruneModels drops the saved models an earlier refresh added that the
+// endpoint no longer lists, returning the rest and the IDs dropped. Models
+// described by hand, and those of providers the endpoint does not serve,
+// such as other gateway routes, are kept. A model is still listed if its
+// ID or any of its aliases is.
+func pruneModels(saved []config.ModelInfo, live []string) ([]config.ModelInfo, []string) {
+	served := make(map[string]bool, len(live))
+	providers := make(map[string]bool)
+	for _, id := range live {
+		served[id] = true
+		providers[config.ModelProvider(id)] = true
+	}
+
+	var kept []config.ModelInfo
+	var removed []string
+	for _, model := range saved {
+		listed := served[model.ID]
+		for _, alias := range model.Aliases {
+			listed = listed || served[alias]
+		}
+		if model.Discovered && !listed && providers[config.ModelProvider(model.ID)] {
+			removed = append(removed, model.ID)
+			continue
+		}
+		kept = append(kept, model)
+	}
+	return kept, removed
+}
+
+// completeModels completes model names for --model flags from the cached
+// list of the endpoint's models, falling back to the registry. The list is
+// never fetched, so completion does not wait on the network. Values of
+// comma-separated flags such as compare's --models complete after the last
+// comma.
+func completeModels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+
+	var names []string
+	if cfg := completionConfig(); cfg != nil {
+		names, _ = modelListCache(cfg).Load()
+	}
+	if len(names) == 0 {
+		for _, info := range config.Models().All() {
+			names = append(names, info.ID)
+			names = append(names, info.Aliases...)
+		}
+	}
+	// Presets are accepted wherever a model is
+	names = append(names, presetNames()...)
+
+	var completions []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
+			completions = append(completions, prefix+name)
+		}
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// formatTokenCount formats a token count compactly, e.g. 128K or 1.0M
+func formatTokenCount(n int) string {
+	switch {
+	case n <= 0:
+		return "-"
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%dK", (n+500)/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// formatTokenLimit formats a token limit in full
+func formatTokenLimit(n int) string {
+	if n <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d tokens", n)
+}
+
+// valueOrDash returns s, or "-" when it is empty
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// yesNo formats a flag as yes or no
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/terminal-ai/internal/config"
+)
+
+func TestPruneModels(t *testing.T) {
+	saved := []config.ModelInfo{
+		{ID: "retired-model", Discovered: true},
+		{ID: "served-model", Discovered: true},
+		{ID: "my-reasoner", Reasoning: true},                             // described by hand
+		{ID: "local/llama", Discovered: true},                            // another gateway route
+		{ID: "renamed", Discovered: true, Aliases: []string{"new-name"}}, // served under its alias
+	}
+	live := []string{"served-model", "new-name", "gpt-4o"}
+
+	kept, removed := pruneModels(saved, live)
+	assert.Equal(t, []string{"retired-model"}, removed)
+	var ids []string
+	for _, model := range kept {
+		ids = append(ids, model.ID)
+	}
+	assert.Equal(t, []string{"served-model", "my-reasoner", "local/llama", "renamed"}, ids)
+}
@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// printJSON prints v to stdout as indented JSON, for the --json output of
+// any command
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
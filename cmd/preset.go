@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+var presetFlag string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&presetFlag, "preset", "", "apply a named preset from the presets config section")
+	rootCmd.RegisterFlagCompletionFunc("preset", completePresets)
+}
+
+// applyPresets applies --preset, and a preset named in place of a model, to
+// the loaded config. A model value that names a preset is cleared so the
+// preset's model is used.
+func applyPresets(model *string) error {
+	if presetFlag != "" {
+		if err := applyPreset(presetFlag); err != nil {
+			return err
+		}
+	}
+	if model != nil && *model != "" {
+		if _, ok := appConfig.Preset(*model); ok {
+			if err := applyPreset(*model); err != nil {
+				return err
+			}
+			*model = ""
+		}
+	}
+	return nil
+}
+
+// applyPreset applies a preset to the loaded config in place, so the AI
+// client sees the new settings as well
+func applyPreset(name string) error {
+	applied, err := appConfig.WithPreset(name)
+	if err != nil {
+		return unknownPresetError(name)
+	}
+	*appConfig = *applied
+	return nil
+}
+
+// presetOptions returns the chat options for a preset over the loaded config
+func presetOptions(name string) (ai.ChatOptions, error) {
+	applied, err := GetConfig().WithPreset(name)
+	if err != nil {
+		return ai.ChatOptions{}, unknownPresetError(name)
+	}
+	return ai.ChatOptions{
+		Model:           applied.OpenAI.Model,
+		Temperature:     applied.OpenAI.Temperature,
+		MaxTokens:       applied.OpenAI.MaxTokens,
+		TopP:            applied.OpenAI.TopP,
+		ReasoningEffort: applied.OpenAI.ReasoningEffort,
+		ServiceTier:     applied.OpenAI.ServiceTier,
+	}, nil
+}
+
+// unknownPresetError reports a preset that is not configured
+func unknownPresetError(name string) error {
+	hint := "Define presets under the presets section of the config file"
+	if names := GetConfig().PresetNames(); len(names) > 0 {
+		hint = "Available presets: " + strings.Join(names, ", ")
+	}
+	return utils.NewValidationError(fmt.Sprintf("unknown preset: %s", name), "preset").WithHint(hint)
+}
+
+// describePreset summarizes a preset for listings
+func describePreset(preset config.Preset) string {
+	var parts []string
+	if preset.Model != "" {
+		parts = append(parts, "model="+preset.Model)
+	}
+	if preset.ReasoningEffort != "" {
+		parts = append(parts, "reasoning_effort="+preset.ReasoningEffort)
+	}
+	if preset.ServiceTier != "" {
+		parts = append(parts, "service_tier="+preset.ServiceTier)
+	}
+	if preset.MaxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("max_tokens=%d", preset.MaxTokens))
+	}
+	if preset.Temperature != nil {
+		parts = append(parts, fmt.Sprintf("temperature=%.1f", *preset.Temperature))
+	}
+	if preset.SystemPrompt != "" {
+		parts = append(parts, "system_prompt")
+	}
+	return strings.Join(parts, " ")
+}
+
+// presetNames returns the configured preset names for completion, loading
+// only the config if needed
+func presetNames() []string {
+	cfg := completionConfig()
+	if cfg == nil {
+		return nil
+	}
+	return cfg.PresetNames()
+}
+
+// completePresets completes --preset with the configured preset names
+func completePresets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var matches []string
+	for _, name := range presetNames() {
+		if strings.HasPrefix(name, toComplete) {
+			matches = append(matches, name)
+		}
+	}
+	return matches, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/retention"
+)
+
+var (
+	purgeDryRun  bool
+	purgeHistory bool
+	purgeCache   bool
+	purgeLogs    bool
+)
+
+// purgeCmd represents the purge command
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete data older or larger than the retention policy allows",
+	Long: `Delete the persisted data that the retention policy no longer allows and print
+what was deleted. Each kind of data has a max_age and a max_size (in MB) under
+retention in the config file, 0 meaning no limit. No limits are set by
+default; max_age: 720h keeps 30 days of data.
+
+  --history   sessions saved automatically in ~/.terminal-ai/chat-history
+  --cache     cached responses, including expired ones
+  --logs      lines of logging.file
+
+Without a kind, all are purged. The policy is also enforced when query, chat,
+shell and serve start, at most once an hour.
+
+Examples:
+  terminal-ai purge --dry-run        # Show what would be deleted
+  terminal-ai purge                  # Enforce the policy on everything
+  terminal-ai purge --cache --logs`,
+	Args: cobra.NoArgs,
+	RunE: runPurge,
+}
+
+func init() {
+	rootCmd.AddCommand(purgeCmd)
+
+	purgeCmd.Flags().BoolVar(&purgeDryRun, "dry-run", false, "show what would be deleted without deleting it")
+	purgeCmd.Flags().BoolVar(&purgeHistory, "history", false, "purge saved chat history")
+	purgeCmd.Flags().BoolVar(&purgeCache, "cache", false, "purge cached responses")
+	purgeCmd.Flags().BoolVar(&purgeLogs, "logs", false, "purge the log file")
+}
+
+func runPurge(cmd *cobra.Command, args []string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+
+	var classes []string
+	if purgeHistory {
+		classes = append(classes, retention.History)
+	}
+	if purgeCache {
+		classes = append(classes, retention.Cache)
+	}
+	if purgeLogs {
+		classes = append(classes, retention.Logs)
+	}
+
+	now := time.Now()
+	results, err := retention.Purge(appConfig.Retention, retentionTargets(), classes, purgeDryRun, now)
+	printPurgeResults(results, purgeDryRun)
+	if err != nil {
+		return err
+	}
+	if !purgeDryRun && len(classes) == 0 {
+		if err := retention.MarkRun(retentionStamp(), now); err != nil {
+			log.Debug().Err(err).Msg("Failed to record retention run")
+		}
+	}
+	return nil
+}
+
+// printPurgeResults prints what each kind of data lost
+func printPurgeResults(results []retention.Result, dryRun bool) {
+	verb := "deleted"
+	if dryRun {
+		verb = "would delete"
+		fmt.Println("Dry run, nothing was deleted:")
+	} else {
+		fmt.Println("✓ Purged data past the retention policy:")
+	}
+	for _, result := range results {
+		if result.Skipped != "" {
+			fmt.Printf("  %-8s skipped (%s)\n", result.Class, result.Skipped)
+			continue
+		}
+		fmt.Printf("  %-8s %s %d %s (%s)\n", result.Class, verb, result.Items, result.Unit, formatBytes(result.Bytes))
+	}
+}
+
+// enforceRetention enforces the retention policy when it has not been for
+// retention.StartupInterval. Only the commands that write persisted data
+// (query, chat, shell and serve) call it, after initializing the app. What
+// it deletes is summed up on stderr, so data never disappears silently.
+// Failures are logged, never returned, so they do not stop the command
+// being run.
+func enforceRetention() {
+	if completing() {
+		return
+	}
+	stamp := retentionStamp()
+	now := time.Now()
+	if stamp == "" || !retention.Due(stamp, now) {
+		return
+	}
+	// Recorded first, so a failing purge is not retried by every command
+	if err := retention.MarkRun(stamp, now); err != nil {
+		log.Debug().Err(err).Msg("Failed to record retention run")
+		return
+	}
+
+	results, err := retention.Purge(appConfig.Retention, retentionTargets(), nil, false, now)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to enforce retention policy")
+	}
+	var purged []string
+	for _, result := range results {
+		if result.Items > 0 {
+			log.Info().
+				Str("class", result.Class).
+				Int(result.Unit, result.Items).
+				Int64("bytes", result.Bytes).
+				Msg("Purged data past the retention policy")
+			purged = append(purged, fmt.Sprintf("%s %d %s (%s)", result.Class, result.Items, result.Unit, formatBytes(result.Bytes)))
+		}
+	}
+	if len(purged) > 0 {
+		fmt.Fprintf(os.Stderr, "Deleted data past the retention policy: %s\n", strings.Join(purged, ", "))
+	}
+}
+
+// completing reports whether the process is answering a shell completion
+// request, which must never delete data
+func completing() bool {
+	return len(os.Args) > 1 &&
+		(os.Args[1] == cobra.ShellCompRequestCmd || os.Args[1] == cobra.ShellCompNoDescRequestCmd)
+}
+
+// retentionTargets locates the data the retention policy applies to
+func retentionTargets() retention.Targets {
+	targets := retention.Targets{
+		HistoryDir: chatHistoryDir(),
+		LogFile:    appConfig.Logging.File,
+	}
+	if client, ok := aiClient.(*ai.OpenAIClient); ok && appConfig.Cache.Enabled {
+		targets.Cache = client
+	}
+	return targets
+}
+
+// retentionStamp returns the file recording when the retention policy was
+// last enforced, or "" without a home directory
+func retentionStamp() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".terminal-ai", "retention.stamp")
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+)
+
+// captureStderr returns what fn writes to stderr
+func captureStderr(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	fn()
+	require.NoError(t, w.Close())
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestEnforceRetention(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	previousConfig, previousClient := appConfig, aiClient
+	t.Cleanup(func() { appConfig, aiClient = previousConfig, previousClient })
+	aiClient = nil
+
+	historyDir := filepath.Join(home, ".terminal-ai", "chat-history")
+	require.NoError(t, os.MkdirAll(historyDir, 0700))
+	old := filepath.Join(historyDir, "old.json")
+	recent := filepath.Join(historyDir, "last-chat.json")
+	for _, path := range []string{old, recent} {
+		require.NoError(t, os.WriteFile(path, []byte("{}"), 0600))
+	}
+	aged := time.Now().Add(-40 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(old, aged, aged))
+
+	// The defaults set no limits, so nothing is deleted
+	configFile := filepath.Join(home, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("openai:\n  api_key: sk-test00000000000000000000000000000000000000000000\n"), 0600))
+	defaults, err := config.Load(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, config.RetentionConfig{}, defaults.Retention)
+	appConfig = defaults
+	assert.Empty(t, captureStderr(t, enforceRetention))
+	assert.FileExists(t, old)
+
+	// With a limit set, the deletion is reported rather than silent
+	require.NoError(t, os.Remove(retentionStamp()))
+	appConfig = &config.Config{Retention: config.RetentionConfig{
+		History: config.RetentionPolicy{MaxAge: 30 * 24 * time.Hour},
+	}}
+	out := captureStderr(t, enforceRetention)
+	assert.Contains(t, out, "Deleted data past the retention policy: history 1 files")
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, recent)
+
+	// It runs at most once per interval
+	require.NoError(t, os.WriteFile(old, []byte("{}"), 0600))
+	require.NoError(t, os.Chtimes(old, aged, aged))
+	assert.Empty(t, captureStderr(t, enforceRetention))
+	assert.FileExists(t, old)
+}
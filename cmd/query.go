@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +9,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/config"
 	"github.com/user/terminal-ai/internal/ui"
 )
 
@@ -38,6 +38,7 @@ Examples:
   terminal-ai query "Explain quantum computing" --model gpt-5
   terminal-ai query "Write a Python function to sort a list" --output result.txt
   terminal-ai query "Translate to Spanish: Hello world" --format plain
+  terminal-ai query "Tell me a short story" --audio-out story.mp3
   terminal-ai query "Code review this function" --system "You are a code reviewer" --context "def add(a,b): return a+b"`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -60,9 +61,12 @@ func init() {
 	queryCmd.Flags().StringVarP(&queryFormat, "format", "f", "markdown", "Output format (plain, markdown, json)")
 	queryCmd.Flags().BoolVar(&queryShowTokens, "tokens", false, "Show token usage information")
 	queryCmd.Flags().Float32Var(&queryTopP, "top-p", -1, "Top-p sampling parameter")
+	queryCmd.Flags().BoolVar(&speakFlag, "speak", false, "Read the answer aloud with audio.player")
+	queryCmd.Flags().StringVar(&audioOutFlag, "audio-out", "", "Save the answer as speech to an audio file")
 
 	// Bind flags to viper
 	viper.BindPFlag("query.model", queryCmd.Flags().Lookup("model"))
+	queryCmd.RegisterFlagCompletionFunc("model", completeModels)
 	viper.BindPFlag("query.stream", queryCmd.Flags().Lookup("stream"))
 	viper.BindPFlag("query.context", queryCmd.Flags().Lookup("context"))
 	viper.BindPFlag("query.output", queryCmd.Flags().Lookup("output"))
@@ -71,31 +75,19 @@ func init() {
 	viper.BindPFlag("query.format", queryCmd.Flags().Lookup("format"))
 }
 
-func runQuery(question string) error {
-	// Get AI client
-	client := GetAIClient()
-	if client == nil {
-		return fmt.Errorf("AI client not initialized. Please check your configuration")
-	}
-
-	// Get configuration
-	config := GetConfig()
-	if config == nil {
-		return fmt.Errorf("configuration not loaded")
-	}
-
-	// Prepare messages
+// queryMessages returns the messages the query command sends for question,
+// after system or, without one, the configured system prompt
+func queryMessages(cfg *config.Config, system, question string) []ai.Message {
 	var messages []ai.Message
 
 	// Add system message (use specified or default from config)
-	systemPrompt := querySystem
-	if systemPrompt == "" && config.OpenAI.SystemPrompt != "" {
-		systemPrompt = config.OpenAI.SystemPrompt
+	if system == "" {
+		system = cfg.OpenAI.SystemPrompt
 	}
-	if systemPrompt != "" {
+	if system != "" {
 		messages = append(messages, ai.Message{
 			Role:    "system",
-			Content: systemPrompt,
+			Content: system,
 		})
 	}
 
@@ -105,33 +97,67 @@ func runQuery(question string) error {
 		userContent = fmt.Sprintf("Context:\n%s\n\nQuestion: %s", queryContext, question)
 	}
 
-	messages = append(messages, ai.Message{
+	return append(messages, ai.Message{
 		Role:    "user",
 		Content: userContent,
 	})
+}
 
-	// Prepare chat options
+// queryOptions returns the options the query command sends: its flags, with
+// the config's values for those not set
+func queryOptions(cfg *config.Config) ai.ChatOptions {
 	options := ai.ChatOptions{
-		Model:       queryModel,
-		Temperature: queryTemperature,
-		MaxTokens:   queryMaxTokens,
-		TopP:        queryTopP,
+		Model:           queryModel,
+		Temperature:     queryTemperature,
+		MaxTokens:       queryMaxTokens,
+		TopP:            queryTopP,
+		ReasoningEffort: cfg.OpenAI.ReasoningEffort,
+		ServiceTier:     cfg.OpenAI.ServiceTier,
 	}
 
 	// Use defaults from config if not specified
 	if options.Model == "" {
-		options.Model = config.OpenAI.Model
+		options.Model = cfg.OpenAI.Model
 	}
 	if options.Temperature < 0 {
-		options.Temperature = config.OpenAI.Temperature
+		options.Temperature = cfg.OpenAI.Temperature
 	}
 	if options.MaxTokens == 0 {
-		options.MaxTokens = config.OpenAI.MaxTokens
+		options.MaxTokens = cfg.OpenAI.MaxTokens
 	}
 	if options.TopP < 0 {
-		options.TopP = config.OpenAI.TopP
+		options.TopP = cfg.OpenAI.TopP
+	}
+	return options
+}
+
+func runQuery(question string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+	enforceRetention()
+	if err := applyPresets(&queryModel); err != nil {
+		return err
 	}
 
+	// Get AI client
+	client := GetAIClient()
+	if client == nil {
+		return fmt.Errorf("AI client not initialized. Please check your configuration")
+	}
+
+	// Get configuration
+	config := GetConfig()
+	if config == nil {
+		return fmt.Errorf("configuration not loaded")
+	}
+	if err := checkSpeechFlags(); err != nil {
+		return err
+	}
+
+	messages := queryMessages(config, querySystem, question)
+	options := queryOptions(config)
+
 	// Create UI components
 	formatter := ui.NewFormatter(ui.FormatterOptions{
 		ColorEnabled:       config.UI.ColorOutput,
@@ -151,7 +177,7 @@ func runQuery(question string) error {
 		})
 	}
 
-	ctx := context.Background()
+	ctx := ai.WithMode(commandContext(), ai.ModeQuery)
 	var response string
 	var usage ai.Usage
 
@@ -171,10 +197,18 @@ func runQuery(question string) error {
 
 		// Collect response chunks
 		var responseBuilder strings.Builder
+		var semantic *ai.SemanticMatch
+		var stale *ai.StaleHit
 		for chunk := range chunks {
 			if chunk.Error != nil {
 				return fmt.Errorf("stream error: %w", chunk.Error)
 			}
+			if chunk.Semantic != nil {
+				semantic = chunk.Semantic
+			}
+			if chunk.Stale != nil {
+				stale = chunk.Stale
+			}
 			if chunk.Done {
 				break
 			}
@@ -184,6 +218,8 @@ func runQuery(question string) error {
 		}
 		response = responseBuilder.String()
 		fmt.Println() // Final newline
+		printSemanticHit(semantic)
+		printStaleHit(stale)
 
 	} else {
 		// Non-streaming response
@@ -211,6 +247,8 @@ func runQuery(question string) error {
 			formatted := formatter.FormatMarkdown(response)
 			fmt.Println(formatted)
 		}
+		printSemanticHit(resp.Semantic)
+		printStaleHit(resp.Stale)
 	}
 
 	// Show token usage if requested
@@ -229,6 +267,10 @@ func runQuery(question string) error {
 		formatter.PrintSuccess(fmt.Sprintf("Response saved to: %s", queryOutput))
 	}
 
+	if speechRequested() {
+		return speakAnswer(ctx, response)
+	}
+
 	return nil
 }
 
@@ -236,13 +278,13 @@ func saveResponseToFile(content, filename string) error {
 	// Ensure directory exists
 	dir := filepath.Dir(filename)
 	if dir != "." && dir != "" {
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		if err := os.MkdirAll(dir, 0700); err != nil {
 			return fmt.Errorf("failed to create directory: %w", err)
 		}
 	}
 
 	// Write file
-	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -1,25 +1,43 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/user/terminal-ai/internal/ai"
 	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/storage"
+	"github.com/user/terminal-ai/internal/tracing"
 	"github.com/user/terminal-ai/internal/utils"
 )
 
 var (
-	cfgFile   string
-	verbose   bool
-	noColor   bool
-	profile   string
-	aiClient  ai.Client
-	appConfig *config.Config
-	logger    *utils.Logger
+	cfgFile       string
+	verbose       bool
+	noColor       bool
+	profile       string
+	recordDir     string
+	replayDir     string
+	metricsAddr   string
+	metricsServer *http.Server
+	traceFile     string
+	traceEndpoint string
+	noSemantic    bool
+	offline       bool
+	commandSpan   *tracing.Span
+	commandCtx    context.Context
+	aiClient      ai.Client
+	appConfig     *config.Config
+	logger        *utils.Logger
+	cleanupOnce   sync.Once
 )
 
 const version = "0.1.0"
@@ -39,26 +57,41 @@ Quick Start:
 	Version: version,
 	DisableFlagParsing: false,
 	TraverseChildren: true,
+	PersistentPreRunE: startTracing,
+	SilenceErrors: true, // main prints errors with hints via PrintError
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
-func Execute() error {
-	return rootCmd.Execute()
+// Commands stop when ctx is done.
+func Execute(ctx context.Context) error {
+	err := rootCmd.ExecuteContext(ctx)
+	finishTracing(err)
+	return err
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	rootCmd.SetFlagErrorFunc(flagError)
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.opt/terminal-ai-config.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable colored output")
 	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "config profile to use (dev, prod)")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "record API traffic to cassette files in dir")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "replay API traffic from cassette files in dir (no network)")
+	rootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "expose Prometheus metrics on this address (e.g. :9090)")
+	rootCmd.PersistentFlags().StringVar(&traceFile, "trace-file", "", "append OTLP/JSON traces to this file")
+	rootCmd.PersistentFlags().StringVar(&traceEndpoint, "trace-endpoint", "", "export OTLP traces to a collector (e.g. http://localhost:4318)")
+	rootCmd.PersistentFlags().BoolVar(&noSemantic, "no-semantic-cache", false, "only serve cached responses for identical prompts")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "answer only from the cache, including entries expired within cache.stale_grace")
 
 	// Bind flags to viper
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("ui.color_output", rootCmd.PersistentFlags().Lookup("no-color"))
 	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	viper.BindPFlag("tracing.file", rootCmd.PersistentFlags().Lookup("trace-file"))
+	viper.BindPFlag("tracing.endpoint", rootCmd.PersistentFlags().Lookup("trace-endpoint"))
 
 	// Add version command
 	rootCmd.AddCommand(&cobra.Command{
@@ -106,6 +139,11 @@ func initConfig() {
 	// Support OPENAI_API_KEY environment variable
 	viper.BindEnv("openai.api_key", "OPENAI_API_KEY")
 
+	// Nested keys need explicit bindings on the global viper
+	viper.BindEnv("tracing.file", "TERMINAL_AI_TRACING_FILE")
+	viper.BindEnv("tracing.endpoint", "TERMINAL_AI_TRACING_ENDPOINT")
+	viper.BindEnv("tracing.service_name", "TERMINAL_AI_TRACING_SERVICE_NAME")
+
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
 		if verbose {
@@ -114,25 +152,49 @@ func initConfig() {
 	}
 }
 
+// applyOfflineFlags sets the cassette and offline settings from their flags.
+// They apply before validation, as replay and offline runs need no API key.
+func applyOfflineFlags(cfg *config.Config) {
+	if recordDir != "" {
+		cfg.Cassette.Mode = ai.CassetteRecord
+		cfg.Cassette.Dir = recordDir
+	}
+	if replayDir != "" {
+		cfg.Cassette.Mode = ai.CassetteReplay
+		cfg.Cassette.Dir = replayDir
+	}
+	if offline {
+		cfg.Cache.Offline = true
+	}
+}
+
 // initializeApp initializes the application configuration and AI client
 func initializeApp() error {
 	var err error
 
+	if recordDir != "" && replayDir != "" {
+		return usageError(fmt.Errorf("--record and --replay cannot be used together"))
+	}
+
 	// Load configuration
 	if profile != "" {
-		appConfig, err = config.LoadWithProfile(cfgFile, profile)
+		appConfig, err = config.LoadWithProfile(cfgFile, profile, applyOfflineFlags)
 	} else {
-		appConfig, err = config.Load(cfgFile)
+		appConfig, err = config.Load(cfgFile, applyOfflineFlags)
 	}
 
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return utils.NewConfigError("failed to load configuration", err).
+			WithHint("Run 'terminal-ai config' to review settings, or set OPENAI_API_KEY")
 	}
 
 	// Apply command-line flag overrides
 	if noColor {
 		appConfig.UI.ColorOutput = false
 	}
+	if noSemantic {
+		appConfig.Cache.Semantic.Enabled = false
+	}
 
 	// Initialize logger
 	logLevel := appConfig.Logging.Level
@@ -144,6 +206,7 @@ func initializeApp() error {
 		Level:         logLevel,
 		Output:        appConfig.Logging.Format, // "console", "json", or "both"
 		FilePath:      appConfig.Logging.File,
+		WrapFile:      storage.LineWriter,
 		Pretty:        appConfig.Logging.Format == "pretty" || appConfig.Logging.Format == "text",
 		MaskSensitive: appConfig.Logging.NoAPI,
 		StackTrace:    verbose || logLevel == "debug",
@@ -152,23 +215,147 @@ func initializeApp() error {
 	// Set global logger
 	utils.SetLogger(logger)
 
+	// Unlock encrypted storage before the cache loads from it
+	store, err := storage.Unlock(appConfig.Storage)
+	if err != nil {
+		return err
+	}
+	storage.SetDefault(store)
+
 	// Initialize AI client
 	aiClient, err = ai.NewOpenAIClient(appConfig)
 	if err != nil {
 		return fmt.Errorf("failed to initialize AI client: %w", err)
 	}
 
+	// Expose metrics for scraping if requested
+	if metricsAddr != "" {
+		metricsServer, err = utils.GetMetrics().StartMetricsServer(metricsAddr)
+		if err != nil {
+			return fmt.Errorf("failed to start metrics server: %w", err)
+		}
+	}
+
 	return nil
 }
 
-// Cleanup performs cleanup operations
-func Cleanup() {
-	if aiClient != nil {
-		aiClient.Close()
+// startTracing installs the tracer when trace export is configured and
+// starts the span covering the whole command
+func startTracing(cmd *cobra.Command, args []string) error {
+	file := viper.GetString("tracing.file")
+	endpoint := viper.GetString("tracing.endpoint")
+	if file == "" && endpoint == "" {
+		return nil
 	}
-	if logger != nil {
-		logger.Close()
+	if file != "" && endpoint != "" {
+		return usageError(fmt.Errorf("--trace-file and --trace-endpoint cannot be used together"))
+	}
+
+	serviceName := viper.GetString("tracing.service_name")
+	if serviceName == "" {
+		serviceName = "terminal-ai"
+	}
+
+	var exporter tracing.Exporter
+	if file != "" {
+		fileExporter, err := tracing.NewFileExporter(os.ExpandEnv(file), serviceName, version, storage.LineWriter)
+		if err != nil {
+			return err
+		}
+		exporter = fileExporter
+	} else {
+		exporter = tracing.NewHTTPExporter(endpoint, serviceName, version)
 	}
+
+	tracing.Setup(tracing.Config{
+		ServiceName:   serviceName,
+		Version:       version,
+		Exporter:      exporter,
+		FlushInterval: 5 * time.Second, // keeps long-running commands like serve exporting
+	})
+
+	commandCtx, commandSpan = tracing.Start(cmd.Context(), "command "+cmd.CommandPath())
+	commandSpan.SetAttribute("cli.command", cmd.CommandPath())
+	commandSpan.SetAttribute("cli.args", len(args))
+	cmd.SetContext(commandCtx)
+	return nil
+}
+
+// finishTracing ends the command span and exports pending spans
+func finishTracing(err error) {
+	if commandSpan != nil {
+		var appErr *utils.AppError
+		if errors.As(err, &appErr) {
+			appErr.WithTraceContext(commandCtx)
+		}
+		commandSpan.RecordError(err)
+		commandSpan.End()
+		commandSpan = nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracing.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to export traces: %v\n", err)
+	}
+}
+
+// commandContext returns the context of the running command, carrying its
+// trace span when tracing is enabled
+func commandContext() context.Context {
+	if commandCtx != nil {
+		return commandCtx
+	}
+	if ctx := rootCmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// ensureApp initializes the application unless it is already initialized
+func ensureApp() error {
+	if appConfig != nil {
+		return nil
+	}
+	return initializeApp()
+}
+
+// completionConfig returns the configuration for shell completion, loading
+// only the config file. Completion must not unlock storage, purge data or
+// reach the network, so it never initializes the app. It returns nil when
+// the configuration cannot be loaded.
+func completionConfig() *config.Config {
+	if appConfig != nil {
+		return appConfig
+	}
+	var cfg *config.Config
+	var err error
+	if profile != "" {
+		cfg, err = config.LoadWithProfile(cfgFile, profile)
+	} else {
+		cfg, err = config.Load(cfgFile)
+	}
+	if err != nil {
+		return nil
+	}
+	return cfg
+}
+
+// Cleanup performs cleanup operations. Only the first call does anything,
+// so an interrupt and the normal exit path don't both save the cache.
+func Cleanup() {
+	cleanupOnce.Do(func() {
+		finishTracing(nil)
+		if metricsServer != nil {
+			metricsServer.Close()
+		}
+		if aiClient != nil {
+			aiClient.Close()
+		}
+		if logger != nil {
+			logger.Close()
+		}
+	})
 }
 
 // GetAIClient returns the initialized AI client
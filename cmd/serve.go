@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/gateway"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+var serveAddr string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an OpenAI-compatible gateway with Prometheus metrics",
+	Long: `Run terminal-ai as a long-lived OpenAI-compatible gateway.
+
+Requests to /v1/chat/completions (streaming and non-streaming) and /v1/models
+go through the configured client, so they share its cache, rate limiter and
+retries. Concurrent identical requests share one upstream call, streaming
+ones included, unless cache.dedup is off. Prometheus metrics are served at /metrics, and /healthz reports
+liveness. Use --metrics-addr to expose metrics on a separate address.
+
+Examples:
+  terminal-ai serve
+  terminal-ai serve --addr :8080
+  terminal-ai serve --addr 127.0.0.1:8080 --metrics-addr :9090`,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "address to listen on")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+	enforceRetention()
+
+	// Clients of a shared gateway often send the same prompt at once, so
+	// identical streams are fanned out from one upstream stream
+	if client, ok := aiClient.(*ai.OpenAIClient); ok && appConfig.Cache.Dedup {
+		client.SetStreamDedup(true)
+	}
+
+	// Metrics share the gateway address unless a separate one was requested
+	var metrics *utils.MetricsCollector
+	if metricsAddr == "" {
+		metrics = utils.GetMetrics()
+	}
+
+	fmt.Fprintf(os.Stderr, "Gateway listening on http://%s/v1\n", serveAddr)
+	if metricsAddr != "" {
+		fmt.Fprintf(os.Stderr, "Metrics available at http://%s/metrics\n", metricsAddr)
+	} else {
+		fmt.Fprintf(os.Stderr, "Metrics available at http://%s/metrics\n", serveAddr)
+	}
+
+	server := &http.Server{
+		Addr:    serveAddr,
+		Handler: gateway.New(aiClient, metrics).Handler(),
+	}
+	return server.ListenAndServe()
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bufio"
-	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,7 +11,9 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/config"
 	"github.com/user/terminal-ai/internal/ui"
+	"github.com/user/terminal-ai/internal/utils"
 )
 
 var (
@@ -37,8 +38,11 @@ func init() {
 	rootCmd.Flags().BoolVarP(&shellFlag, "shell", "s", false, "Shell command mode (default) - generate and optionally execute shell commands")
 	rootCmd.Flags().BoolVarP(&chatFlag, "chat", "c", false, "Interactive chat mode with the AI assistant")
 	rootCmd.Flags().StringVarP(&modelFlag, "model", "m", "", "Override default model")
+	rootCmd.RegisterFlagCompletionFunc("model", completeModels)
 	rootCmd.Flags().BoolVar(&streamFlag, "stream", true, "Enable streaming responses")
 	rootCmd.Flags().StringVar(&serviceTierFlag, "service-tier", "", "Service tier (auto, default, priority, flex, scale)")
+	rootCmd.Flags().BoolVar(&speakFlag, "speak", false, "Read the answer aloud with audio.player (-q mode)")
+	rootCmd.Flags().StringVar(&audioOutFlag, "audio-out", "", "Save the answer as speech to an audio file (-q mode)")
 
 	// Set the Run function for root command and allow unknown args
 	rootCmd.Run = runSimpleMode
@@ -60,8 +64,7 @@ func runSimpleMode(cmd *cobra.Command, args []string) {
 
 	// If multiple modes selected, show error
 	if modeCount > 1 {
-		fmt.Println("Error: Please specify only one mode: -q (query), -s (shell), or -c (chat)")
-		os.Exit(1)
+		exitWithError(utils.NewAppError(utils.ErrCodeInvalidInput, "Please specify only one mode: -q (query), -s (shell), or -c (chat)", nil))
 	}
 
 	// If no mode selected and no subcommand, handle as shell if args provided
@@ -84,10 +87,13 @@ func runSimpleMode(cmd *cobra.Command, args []string) {
 			os.Setenv("TERMINAL_AI_LOGGING_LEVEL", "fatal")
 		}
 		if err := initializeApp(); err != nil {
-			fmt.Printf("Error initializing: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 	}
+	enforceRetention()
+	if err := applyPresets(&modelFlag); err != nil {
+		exitWithError(err)
+	}
 
 	// Get the question/prompt from args
 	var prompt string
@@ -108,12 +114,14 @@ func runSimpleMode(cmd *cobra.Command, args []string) {
 
 func runQueryMode(prompt string) {
 	if prompt == "" {
-		fmt.Println("Error: Please provide a question")
-		fmt.Println("Usage: terminal-ai -q \"Your question here\"")
-		os.Exit(1)
+		exitWithError(utils.NewAppError(utils.ErrCodeInvalidInput, "Please provide a question", nil).
+			WithHint(`Usage: terminal-ai -q "Your question here"`))
+	}
+	if err := checkSpeechFlags(); err != nil {
+		exitWithError(err)
 	}
 
-	ctx := context.Background()
+	ctx := ai.WithMode(commandContext(), ai.ModeQuick)
 	client := GetAIClient()
 	config := GetConfig()
 
@@ -126,27 +134,8 @@ func runQueryMode(prompt string) {
 	fmt.Println(userStyle.Render(prompt))
 	fmt.Println()
 
-	// Prepare messages with helpful assistant prompt
-	messages := []ai.Message{
-		{
-			Role:    "system",
-			Content: helpfulAssistantPrompt,
-		},
-		{
-			Role:    "user",
-			Content: prompt,
-		},
-	}
-
-	// Prepare options
-	options := ai.ChatOptions{
-		Model:           config.OpenAI.Model,
-		Temperature:     config.OpenAI.Temperature,
-		MaxTokens:       config.OpenAI.MaxTokens,
-		TopP:            config.OpenAI.TopP,
-		ReasoningEffort: config.OpenAI.ReasoningEffort,
-		ServiceTier:     config.OpenAI.ServiceTier,
-	}
+	// Prepare messages with helpful assistant prompt, and options
+	messages, options := quickRequest(config, "", prompt)
 
 	// Override model if specified
 	if modelFlag != "" {
@@ -160,32 +149,51 @@ func runQueryMode(prompt string) {
 
 	// Start AI response (no label)
 
+	var answer strings.Builder
 	if streamFlag {
 		// Stream response
 		chunks, err := client.ChatStream(ctx, messages, options)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
+		var semantic *ai.SemanticMatch
+		var stale *ai.StaleHit
 		for chunk := range chunks {
 			if chunk.Error != nil {
-				fmt.Printf("\nError: %v\n", chunk.Error)
-				os.Exit(1)
+				fmt.Println()
+				exitWithError(chunk.Error)
+			}
+			if chunk.Semantic != nil {
+				semantic = chunk.Semantic
+			}
+			if chunk.Stale != nil {
+				stale = chunk.Stale
 			}
 			if chunk.Content != "" {
+				answer.WriteString(chunk.Content)
 				fmt.Print(aiStyle.Render(chunk.Content))
 			}
 		}
 		fmt.Println()
+		printSemanticHit(semantic)
+		printStaleHit(stale)
 	} else {
 		// Non-streaming response
 		resp, err := client.Chat(ctx, messages, options)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
+		answer.WriteString(resp.Content)
 		fmt.Println(aiStyle.Render(resp.Content))
+		printSemanticHit(resp.Semantic)
+		printStaleHit(resp.Stale)
+	}
+
+	if speechRequested() {
+		if err := speakAnswer(ctx, answer.String()); err != nil {
+			exitWithError(err)
+		}
 	}
 }
 
@@ -210,7 +218,7 @@ func runShellMode(prompt string) {
 	// Display user input (highlighted, no label)
 	fmt.Println(userStyle.Render(prompt))
 
-	ctx := context.Background()
+	ctx := ai.WithMode(commandContext(), ai.ModeShell)
 	client := GetAIClient()
 	config := GetConfig()
 
@@ -228,14 +236,7 @@ func runShellMode(prompt string) {
 		}
 
 		// Prepare options
-		options := ai.ChatOptions{
-			Model:           config.OpenAI.Model,
-			Temperature:     config.OpenAI.Temperature,
-			MaxTokens:       config.OpenAI.MaxTokens,
-			TopP:            config.OpenAI.TopP,
-			ReasoningEffort: config.OpenAI.ReasoningEffort,
-			ServiceTier:     config.OpenAI.ServiceTier,
-		}
+		options := simpleOptions(config)
 
 		// Override model if specified
 		if modelFlag != "" {
@@ -245,13 +246,14 @@ func runShellMode(prompt string) {
 		// Get the command suggestion
 		resp, err := client.Chat(ctx, messages, options)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
 		command := strings.TrimSpace(resp.Content)
 		// Display AI command (highlighted, no label)
 		fmt.Printf("\n%s\n", aiStyle.Render(command))
+		printSemanticHit(resp.Semantic)
+		printStaleHit(resp.Stale)
 
 		// Ask for confirmation
 		fmt.Print("\n🔸 Execute? [Enter/E=Execute, N=No, Q=Quit]: ")
@@ -297,9 +299,36 @@ func runChatMode() {
 	// This will call the existing chat command implementation
 	// but with the helpful assistant system prompt
 	chatSystemPrompt = helpfulAssistantPrompt
+	if chatModel == "" {
+		chatModel = modelFlag
+	}
 	if err := RunChat(&cobra.Command{}, []string{}); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		exitWithError(err)
+	}
+}
+
+// quickRequest returns the messages and options query mode (-q) sends for
+// question with cfg. system replaces the helpful assistant prompt if set.
+func quickRequest(cfg *config.Config, system, question string) ([]ai.Message, ai.ChatOptions) {
+	if system == "" {
+		system = helpfulAssistantPrompt
+	}
+	messages := []ai.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: question},
+	}
+	return messages, simpleOptions(cfg)
+}
+
+// simpleOptions returns the chat options query and shell mode send with cfg
+func simpleOptions(cfg *config.Config) ai.ChatOptions {
+	return ai.ChatOptions{
+		Model:           cfg.OpenAI.Model,
+		Temperature:     cfg.OpenAI.Temperature,
+		MaxTokens:       cfg.OpenAI.MaxTokens,
+		TopP:            cfg.OpenAI.TopP,
+		ReasoningEffort: cfg.OpenAI.ReasoningEffort,
+		ServiceTier:     cfg.OpenAI.ServiceTier,
 	}
 }
 
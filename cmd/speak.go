@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/ui"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+var (
+	speakFlag    bool
+	audioOutFlag string
+)
+
+// speechRequested reports whether --speak or --audio-out was given
+func speechRequested() bool {
+	return speakFlag || audioOutFlag != ""
+}
+
+// checkSpeechFlags reports a missing player before the question is sent
+func checkSpeechFlags() error {
+	if speakFlag && audioOutFlag == "" && strings.TrimSpace(GetConfig().Audio.Player) == "" {
+		return utils.NewValidationError("no audio player configured", "audio.player").
+			WithHint(`Set audio.player to a command that reads audio from stdin (e.g. "mpv --no-video -"), or use --audio-out file.mp3`)
+	}
+	return nil
+}
+
+// speakAnswer converts an answer to audio, writes it to --audio-out and, with
+// --speak, pipes it to the configured player
+func speakAnswer(ctx context.Context, answer string) error {
+	config := GetConfig()
+	player := strings.Fields(config.Audio.Player)
+
+	audio, ok := GetAIClient().(ai.AudioClient)
+	if !ok {
+		return fmt.Errorf("the configured client does not support speech output")
+	}
+
+	options := ai.SpeechOptions{
+		Model:  config.Audio.SpeechModel,
+		Voice:  config.Audio.Voice,
+		Format: config.Audio.Format,
+		Speed:  config.Audio.Speed,
+	}
+	// An audio file extension picks the format, e.g. --audio-out answer.wav
+	if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(audioOutFlag)), "."); ext != "" {
+		switch ext {
+		case "mp3", "opus", "aac", "flac", "wav", "pcm":
+			options.Format = ext
+		case "ogg":
+			options.Format = "opus"
+		}
+	}
+
+	spinner := ui.NewSimpleSpinner("Generating audio...")
+	spinner.Start()
+	data, err := audio.Speak(ctx, answer, options)
+	if err != nil {
+		spinner.StopWithError("Speech generation failed")
+		return err
+	}
+	spinner.Stop()
+
+	if audioOutFlag != "" {
+		if err := saveResponseToFile(string(data), audioOutFlag); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Audio saved to: %s\n", audioOutFlag)
+	}
+
+	if speakFlag && len(player) > 0 {
+		// Player output goes to stderr so stdout keeps only the answer
+		cmd := exec.CommandContext(ctx, player[0], player[1:]...)
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return utils.NewAppError(utils.ErrCodeInternal, fmt.Sprintf("audio player %q failed", player[0]), err).
+				WithHint("Check audio.player; the command must read audio from stdin")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/storage"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+var (
+	rekeyNewKeyEnv string
+	rekeyRotate    bool
+)
+
+// storageCmd represents the storage command
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage encryption of stored files",
+	Long: `Manage encryption of the files terminal-ai stores: the response cache, chat
+history, saved sessions, cassettes, and the log and trace files.
+
+With storage.encrypt set, files are encrypted with AES-256-GCM under a data key
+kept in storage.key_file, wrapped by a secret from storage.key_source: the OS
+keyring, a passphrase, or the environment variable named by storage.key_env.`,
+}
+
+// storageRekeyCmd represents the storage rekey command
+var storageRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Wrap the storage key with a new secret, or rotate the key with --rotate",
+	Long: `Wrap the storage key with a new secret from storage.key_source, replacing the
+one it was wrapped with. The current secret unlocks the key first.
+
+  keyring      a new random secret is saved in the OS keyring
+  passphrase   the new passphrase is asked for twice
+  env          the new secret is read from --new-key-env
+
+Without --rotate the data key itself does not change: stored files are not
+rewritten and all stay readable, including sessions saved outside
+~/.terminal-ai. To switch sources, change storage.key_source and run rekey.
+
+With --rotate a new data key is created as well, and the response cache, chat
+history, cassettes, log file and trace file are re-encrypted under it. The old key is dropped once they are,
+so sessions saved elsewhere with --save can no longer be read; load and save
+them again first. Run it while no other terminal-ai command is running. An
+interrupted rotation keeps the old key, and can be run again.
+
+Examples:
+  terminal-ai storage rekey
+  terminal-ai storage rekey --rotate
+  TERMINAL_AI_STORAGE_NEW_KEY=... terminal-ai storage rekey`,
+	Args: cobra.NoArgs,
+	RunE: runStorageRekey,
+}
+
+// storageCatCmd represents the storage cat command
+var storageCatCmd = &cobra.Command{
+	Use:   "cat <file>...",
+	Short: "Print stored files decrypted",
+	Long: `Print files terminal-ai stored, decrypted with the storage key: chat history,
+saved sessions, cassettes, and the log and trace files, which are encrypted a
+line at a time. Files that are not encrypted are printed as they are.
+
+Examples:
+  terminal-ai storage cat ~/.terminal-ai/terminal-ai.log
+  terminal-ai storage cat cassettes/*.json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runStorageCat,
+}
+
+func init() {
+	rootCmd.AddCommand(storageCmd)
+	storageCmd.AddCommand(storageRekeyCmd)
+	storageCmd.AddCommand(storageCatCmd)
+
+	storageRekeyCmd.Flags().StringVar(&rekeyNewKeyEnv, "new-key-env", "TERMINAL_AI_STORAGE_NEW_KEY", "environment variable holding the new secret for the env source")
+	storageRekeyCmd.Flags().BoolVar(&rekeyRotate, "rotate", false, "also replace the data key and re-encrypt the cache and chat history")
+}
+
+func runStorageRekey(cmd *cobra.Command, args []string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+
+	cfg := appConfig.Storage
+	newSecret := ""
+	if cfg.KeySource == storage.SourceEnv {
+		newSecret = os.Getenv(rekeyNewKeyEnv)
+		if newSecret == "" {
+			return utils.NewValidationError(fmt.Sprintf("%s is not set", rekeyNewKeyEnv), "new-key-env").
+				WithHint("Export the new secret in " + rekeyNewKeyEnv + ", then set " + cfg.KeyEnv + " to it after rekeying")
+		}
+	}
+
+	if rekeyRotate {
+		client, ok := aiClient.(*ai.OpenAIClient)
+		if !ok {
+			return fmt.Errorf("key rotation not supported for this client type")
+		}
+		resealed := 0
+		rotated, err := storage.Default().Rotate(cfg, storage.DefaultSecrets(), newSecret, func(store *storage.Store) error {
+			if err := client.ResealCache(store); err != nil {
+				return err
+			}
+			var err error
+			resealed, err = store.ResealDir(chatHistoryDir())
+			if err != nil {
+				return err
+			}
+			if appConfig.Cassette.Dir != "" {
+				if _, err := store.ResealDir(os.ExpandEnv(appConfig.Cassette.Dir)); err != nil {
+					return err
+				}
+			}
+			// Logs and traces are sealed a line at a time
+			for _, file := range []string{appConfig.Logging.File, viper.GetString("tracing.file")} {
+				if file == "" {
+					continue
+				}
+				if _, err := store.ResealLines(os.ExpandEnv(file)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		storage.SetDefault(rotated)
+		fmt.Printf("✓ Storage key rotated and wrapped with a new %s secret\n", cfg.KeySource)
+		fmt.Printf("  Re-encrypted the cache, cassettes, logs and %d chat history files\n", resealed)
+	} else {
+		if err := storage.Default().Rekey(cfg, storage.DefaultSecrets(), newSecret); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Storage key rewrapped with a new %s secret\n", cfg.KeySource)
+	}
+	if cfg.KeySource == storage.SourceEnv {
+		fmt.Printf("  Set %s to the value of %s from now on\n", cfg.KeyEnv, rekeyNewKeyEnv)
+	}
+	return nil
+}
+
+func runStorageCat(cmd *cobra.Command, args []string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+
+	store := storage.Default()
+	for _, path := range args {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if storage.IsEncrypted(data) {
+			data, err = store.Open(data)
+		} else {
+			data, err = store.OpenLines(data)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		os.Stdout.Write(data)
+	}
+	return nil
+}
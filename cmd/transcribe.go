@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/ui"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+var (
+	transcribeModel      string
+	transcribeLanguage   string
+	transcribeFormat     string
+	transcribeTimestamps []string
+	transcribePrompt     string
+	transcribeOutput     string
+	transcribeThenQuery  string
+)
+
+// transcribeCmd represents the transcribe command
+var transcribeCmd = &cobra.Command{
+	Use:   "transcribe [audio file]",
+	Short: "Transcribe an audio file to text",
+	Long: `Transcribe an audio file (mp3, mp4, m4a, wav, webm, ogg, flac) with the
+audio transcription endpoint.
+
+Files above the 25 MB upload limit are split with ffmpeg and transcribed in
+parts; timestamps are adjusted so srt and vtt output covers the whole file.
+Use --then-query to send the transcript to the model as context for a question.
+
+Examples:
+  terminal-ai transcribe meeting.m4a
+  terminal-ai transcribe meeting.m4a --format srt --output meeting.srt
+  terminal-ai transcribe interview.mp3 --language de --timestamps word --format json
+  terminal-ai transcribe standup.m4a --then-query "summarize action items"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTranscribe,
+}
+
+func init() {
+	rootCmd.AddCommand(transcribeCmd)
+
+	transcribeCmd.Flags().StringVarP(&transcribeModel, "model", "m", "", "transcription model (default from audio.transcription_model)")
+	transcribeCmd.Flags().StringVarP(&transcribeLanguage, "language", "l", "", "spoken language as ISO-639-1 code, e.g. en")
+	transcribeCmd.Flags().StringVarP(&transcribeFormat, "format", "f", "text", "output format (text, srt, vtt, json)")
+	transcribeCmd.Flags().StringSliceVar(&transcribeTimestamps, "timestamps", nil, "timestamp granularity: segment, word (whisper-1 only)")
+	transcribeCmd.Flags().StringVar(&transcribePrompt, "prompt", "", "hint with names or vocabulary used in the audio")
+	transcribeCmd.Flags().StringVarP(&transcribeOutput, "output", "o", "", "save the transcript to a file")
+	transcribeCmd.Flags().StringVar(&transcribeThenQuery, "then-query", "", "ask a question about the transcript")
+}
+
+func runTranscribe(cmd *cobra.Command, args []string) error {
+	if err := ensureApp(); err != nil {
+		return err
+	}
+
+	for _, granularity := range transcribeTimestamps {
+		if granularity != "segment" && granularity != "word" {
+			return utils.NewValidationError(fmt.Sprintf("invalid timestamp granularity %q (segment, word)", granularity), "timestamps")
+		}
+	}
+
+	audio, ok := GetAIClient().(ai.AudioClient)
+	if !ok {
+		return fmt.Errorf("the configured client does not support audio transcription")
+	}
+
+	config := GetConfig()
+	options := ai.TranscriptionOptions{
+		Model:      transcribeModel,
+		Language:   transcribeLanguage,
+		Prompt:     transcribePrompt,
+		Timestamps: transcribeTimestamps,
+	}
+	if options.Model == "" {
+		options.Model = config.Audio.TranscriptionModel
+	}
+	if options.Language == "" {
+		options.Language = config.Audio.Language
+	}
+
+	spinner := ui.NewSimpleSpinner("Transcribing...")
+	spinner.Start()
+
+	transcript, err := audio.Transcribe(commandContext(), args[0], options)
+	if err != nil {
+		spinner.StopWithError("Transcription failed")
+		return err
+	}
+	spinner.Stop()
+
+	output, err := transcript.Format(transcribeFormat)
+	if err != nil {
+		return err
+	}
+
+	formatter := ui.NewFormatter(ui.FormatterOptions{
+		ColorEnabled: config.UI.ColorOutput,
+		Width:        ui.GetTerminalWidth(),
+	})
+
+	if transcribeOutput != "" {
+		if err := saveResponseToFile(output, transcribeOutput); err != nil {
+			return err
+		}
+		formatter.PrintSuccess(fmt.Sprintf("Transcript saved to: %s", transcribeOutput))
+	} else if transcribeThenQuery == "" {
+		fmt.Println(strings.TrimRight(output, "\n"))
+	}
+
+	if transcribeThenQuery != "" {
+		queryContext = transcript.Text
+		return runQuery(transcribeThenQuery)
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/tracing"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// MaxAudioUploadBytes is the upload limit of the transcription endpoint
+const MaxAudioUploadBytes = 25 << 20
+
+// DefaultTranscriptionModel is used when no model is configured
+const DefaultTranscriptionModel = "whisper-1"
+
+const endpointTranscriptions = "/v1/audio/transcriptions"
+
+// Transcript output formats
+const (
+	TranscriptText = "text"
+	TranscriptSRT  = "srt"
+	TranscriptVTT  = "vtt"
+	TranscriptJSON = "json"
+)
+
+// AudioClient is implemented by clients that support the audio endpoints
+type AudioClient interface {
+	// Transcribe converts an audio file to text, splitting files above the upload limit
+	Transcribe(ctx context.Context, path string, options TranscriptionOptions) (*Transcript, error)
+
+	// Speak converts text to audio, splitting text above the input limit
+	Speak(ctx context.Context, text string, options SpeechOptions) ([]byte, error)
+}
+
+// TranscriptionOptions configures a transcription
+type TranscriptionOptions struct {
+	Model          string   // whisper-1, gpt-4o-transcribe, gpt-4o-mini-transcribe
+	Language       string   // ISO-639-1 hint, e.g. "en"
+	Prompt         string   // vocabulary or style hint
+	Timestamps     []string // segment, word (whisper-1 only)
+	MaxUploadBytes int64    // split files above this size (0 = MaxAudioUploadBytes)
+}
+
+// Transcript is the result of a transcription. Segments and words are only
+// present for models that return timestamps (whisper-1).
+type Transcript struct {
+	Text     string              `json:"text"`
+	Language string              `json:"language,omitempty"`
+	Duration float64             `json:"duration,omitempty"` // seconds
+	Segments []TranscriptSegment `json:"segments,omitempty"`
+	Words    []TranscriptWord    `json:"words,omitempty"`
+}
+
+// TranscriptSegment is a timed span of the transcript
+type TranscriptSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// TranscriptWord is a timed word
+type TranscriptWord struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Word  string  `json:"word"`
+}
+
+// supportsTimestamps reports whether a transcription model returns verbose
+// JSON with segment and word timestamps
+func supportsTimestamps(model string) bool {
+	return model == DefaultTranscriptionModel
+}
+
+// Transcribe converts an audio file to text. Files above the upload limit are
+// split with ffmpeg and the pieces transcribed in order. The prompt is
+// checked against the safety policy, and so is the transcript when it
+// checks responses.
+func (c *OpenAIClient) Transcribe(ctx context.Context, path string, options TranscriptionOptions) (*Transcript, error) {
+	if err := c.safety.guard(ctx, options.Prompt, SafetyPhasePrompt); err != nil {
+		return nil, err
+	}
+	transcript, err := c.transcribe(ctx, path, options)
+	if err != nil || !c.safety.checksResponses() {
+		return transcript, err
+	}
+	if err := c.safety.guard(ctx, transcript.Text, SafetyPhaseResponse); err != nil {
+		return nil, err
+	}
+	return transcript, nil
+}
+
+// transcribe converts an audio file to text, see Transcribe
+func (c *OpenAIClient) transcribe(ctx context.Context, path string, options TranscriptionOptions) (*Transcript, error) {
+	if options.Model == "" {
+		options.Model = DefaultTranscriptionModel
+	}
+	if len(options.Timestamps) > 0 && !supportsTimestamps(options.Model) {
+		return nil, utils.NewValidationError(
+			fmt.Sprintf("timestamps are not supported by %s", options.Model), "timestamps").
+			WithHint("Use --model whisper-1 for timestamps, srt or vtt output")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, utils.NewAppError(utils.ErrCodeInvalidInput, "cannot read audio file", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "ai.transcribe")
+	defer span.End()
+	span.SetAttribute("ai.model", options.Model)
+	span.SetAttribute("audio.bytes", info.Size())
+
+	limit := options.MaxUploadBytes
+	if limit <= 0 {
+		limit = MaxAudioUploadBytes
+	}
+	if info.Size() <= limit {
+		transcript, err := c.transcribeFile(ctx, path, options)
+		span.RecordError(err)
+		return transcript, err
+	}
+
+	pieces, cleanup, err := splitAudio(ctx, path, info.Size(), limit)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	defer cleanup()
+	span.SetAttribute("audio.pieces", len(pieces))
+
+	parts := make([]*Transcript, 0, len(pieces))
+	for i, piece := range pieces {
+		log.Debug().Ctx(ctx).Int("piece", i+1).Int("pieces", len(pieces)).Msg("Transcribing audio piece")
+		part, err := c.transcribeFile(ctx, piece.path, options)
+		if err != nil {
+			span.RecordError(err)
+			return nil, fmt.Errorf("failed to transcribe part %d of %d: %w", i+1, len(pieces), err)
+		}
+		parts = append(parts, part)
+	}
+
+	offsets := make([]float64, len(pieces))
+	for i, piece := range pieces {
+		offsets[i] = piece.offset
+	}
+	return mergeTranscripts(parts, offsets), nil
+}
+
+// transcribeFile transcribes a single file within the upload limit
+func (c *OpenAIClient) transcribeFile(ctx context.Context, path string, options TranscriptionOptions) (*Transcript, error) {
+	params := openai.AudioTranscriptionNewParams{
+		Model:          openai.AudioModel(options.Model),
+		ResponseFormat: openai.AudioResponseFormatJSON,
+	}
+	if supportsTimestamps(options.Model) {
+		params.ResponseFormat = openai.AudioResponseFormatVerboseJSON
+		params.TimestampGranularities = []string{"segment"}
+		if len(options.Timestamps) > 0 {
+			params.TimestampGranularities = options.Timestamps
+		}
+	}
+	if options.Language != "" {
+		params.Language = openai.String(options.Language)
+	}
+	if options.Prompt != "" {
+		params.Prompt = openai.String(options.Prompt)
+	}
+
+	var raw string
+	err := c.limited(ctx, "transcription", func(ctx context.Context) error {
+		// The upload is consumed by each attempt, so reopen the file
+		file, err := os.Open(path)
+		if err != nil {
+			return utils.NewAppError(utils.ErrCodeInvalidInput, "cannot read audio file", err)
+		}
+		defer file.Close()
+		params.File = file
+
+		start := time.Now()
+		resp, err := c.client.Audio.Transcriptions.New(ctx, params)
+		c.metrics.RecordAPICall(endpointTranscriptions, time.Since(start), statusCode(err), err)
+		if err != nil {
+			return classifyError(err)
+		}
+		raw = resp.RawJSON()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var transcript Transcript
+	if err := json.Unmarshal([]byte(raw), &transcript); err != nil {
+		return nil, utils.NewAppError(utils.ErrCodeInvalidResponse, "failed to decode transcription", err)
+	}
+	return &transcript, nil
+}
+
+// mergeTranscripts joins transcripts of consecutive pieces, shifting their
+// timestamps by the start offset of each piece
+func mergeTranscripts(parts []*Transcript, offsets []float64) *Transcript {
+	merged := &Transcript{}
+	var texts []string
+	for i, part := range parts {
+		offset := offsets[i]
+		if text := strings.TrimSpace(part.Text); text != "" {
+			texts = append(texts, text)
+		}
+		if merged.Language == "" {
+			merged.Language = part.Language
+		}
+		for _, seg := range part.Segments {
+			seg.Start += offset
+			seg.End += offset
+			merged.Segments = append(merged.Segments, seg)
+		}
+		for _, word := range part.Words {
+			word.Start += offset
+			word.End += offset
+			merged.Words = append(merged.Words, word)
+		}
+		if end := offset + part.Duration; end > merged.Duration {
+			merged.Duration = end
+		}
+	}
+	merged.Text = strings.Join(texts, " ")
+	return merged
+}
+
+// Format renders the transcript as text, srt, vtt or json
+func (t *Transcript) Format(format string) (string, error) {
+	switch format {
+	case "", TranscriptText:
+		return t.Text, nil
+	case TranscriptJSON:
+		data, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case TranscriptSRT, TranscriptVTT:
+		if len(t.Segments) == 0 {
+			return "", utils.NewValidationError(
+				fmt.Sprintf("%s output needs segment timestamps", format), "format").
+				WithHint("Use --model whisper-1 for srt or vtt output")
+		}
+		return t.subtitles(format), nil
+	default:
+		return "", utils.NewValidationError(
+			fmt.Sprintf("unknown transcript format %q (text, srt, vtt, json)", format), "format")
+	}
+}
+
+// subtitles renders segments as SRT or WebVTT cues
+func (t *Transcript) subtitles(format string) string {
+	var sb strings.Builder
+	separator := ","
+	if format == TranscriptVTT {
+		sb.WriteString("WEBVTT\n\n")
+		separator = "."
+	}
+	for i, seg := range t.Segments {
+		if format == TranscriptSRT {
+			fmt.Fprintf(&sb, "%d\n", i+1)
+		}
+		fmt.Fprintf(&sb, "%s --> %s\n%s\n\n",
+			subtitleTime(seg.Start, separator), subtitleTime(seg.End, separator), strings.TrimSpace(seg.Text))
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+// subtitleTime formats seconds as HH:MM:SS,mmm (or with "." for WebVTT)
+func subtitleTime(seconds float64, separator string) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
+
+// audioPiece is one file produced by splitAudio
+type audioPiece struct {
+	path   string
+	offset float64 // start time within the original file, in seconds
+}
+
+// planPieces returns how many pieces of what length (seconds) keep each piece
+// under maxBytes, leaving headroom for uneven bitrates
+func planPieces(size, maxBytes int64, duration float64) (int, float64) {
+	count := int(math.Ceil(float64(size) / (float64(maxBytes) * 0.9)))
+	if count < 2 {
+		count = 2
+	}
+	return count, duration / float64(count)
+}
+
+// splitAudio cuts path into pieces below maxBytes using ffmpeg. The returned
+// cleanup function removes the pieces.
+func splitAudio(ctx context.Context, path string, size, maxBytes int64) ([]audioPiece, func(), error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, nil, utils.NewValidationError(
+			fmt.Sprintf("audio file is %s, above the %s upload limit", formatBytes(size), formatBytes(maxBytes)), "file").
+			WithHint("Install ffmpeg so large files can be split automatically, or compress the audio")
+	}
+
+	duration, err := probeDuration(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	count, length := planPieces(size, maxBytes, duration)
+
+	dir, err := os.MkdirTemp("", "terminal-ai-audio-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	ext := filepath.Ext(path)
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "error", "-i", path,
+		"-f", "segment", "-segment_time", strconv.FormatFloat(length, 'f', 3, 64),
+		"-c", "copy", filepath.Join(dir, "part%03d"+ext))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to split audio: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "part*"+ext))
+	if err != nil || len(files) == 0 {
+		cleanup()
+		return nil, nil, errors.New("failed to split audio: no pieces written")
+	}
+	sort.Strings(files)
+
+	// Stream copies cut at packet boundaries, so measure each piece
+	pieces := make([]audioPiece, 0, len(files))
+	offset := 0.0
+	for _, file := range files {
+		pieces = append(pieces, audioPiece{path: file, offset: offset})
+		pieceDuration, err := probeDuration(ctx, file)
+		if err != nil {
+			pieceDuration = length
+		}
+		offset += pieceDuration
+	}
+
+	log.Debug().Ctx(ctx).Int("planned", count).Int("pieces", len(pieces)).Msg("Split audio for upload")
+	return pieces, cleanup, nil
+}
+
+// probeDuration returns the duration of an audio file in seconds using ffprobe
+func probeDuration(ctx context.Context, path string) (float64, error) {
+	output, err := exec.CommandContext(ctx, "ffprobe", "-v", "error",
+		"-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read audio duration: %w", err)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+}
+
+// formatBytes formats a size in megabytes
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
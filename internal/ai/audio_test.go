@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/mock"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+func sampleTranscript() *Transcript {
+	return &Transcript{
+		Text:     "Hello there. General Kenobi.",
+		Language: "english",
+		Duration: 3.5,
+		Segments: []TranscriptSegment{
+			{Start: 0, End: 1.25, Text: " Hello there."},
+			{Start: 1.25, End: 3661.5, Text: " General Kenobi."},
+		},
+	}
+}
+
+func TestTranscript_Format(t *testing.T) {
+	transcript := sampleTranscript()
+
+	text, err := transcript.Format(TranscriptText)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there. General Kenobi.", text)
+
+	srt, err := transcript.Format(TranscriptSRT)
+	require.NoError(t, err)
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:01,250\nHello there.\n\n"+
+		"2\n00:00:01,250 --> 01:01:01,500\nGeneral Kenobi.\n", srt)
+
+	vtt, err := transcript.Format(TranscriptVTT)
+	require.NoError(t, err)
+	assert.Equal(t, "WEBVTT\n\n00:00:00.000 --> 00:00:01.250\nHello there.\n\n"+
+		"00:00:01.250 --> 01:01:01.500\nGeneral Kenobi.\n", vtt)
+
+	data, err := transcript.Format(TranscriptJSON)
+	require.NoError(t, err)
+	var decoded Transcript
+	require.NoError(t, json.Unmarshal([]byte(data), &decoded))
+	assert.Equal(t, *transcript, decoded)
+
+	_, err = transcript.Format("docx")
+	assert.Equal(t, utils.ExitUsage, utils.ExitCode(err))
+}
+
+func TestTranscript_FormatWithoutSegments(t *testing.T) {
+	transcript := &Transcript{Text: "no timestamps"}
+
+	_, err := transcript.Format(TranscriptSRT)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "segment timestamps")
+}
+
+func TestMergeTranscripts(t *testing.T) {
+	parts := []*Transcript{
+		{Text: "first part", Language: "english", Duration: 600,
+			Segments: []TranscriptSegment{{Start: 0, End: 600, Text: "first part"}},
+			Words:    []TranscriptWord{{Start: 1, End: 2, Word: "first"}}},
+		{Text: " second part ", Duration: 300,
+			Segments: []TranscriptSegment{{Start: 0, End: 300, Text: "second part"}},
+			Words:    []TranscriptWord{{Start: 1, End: 2, Word: "second"}}},
+	}
+
+	merged := mergeTranscripts(parts, []float64{0, 600})
+	assert.Equal(t, "first part second part", merged.Text)
+	assert.Equal(t, "english", merged.Language)
+	assert.Equal(t, 900.0, merged.Duration)
+	require.Len(t, merged.Segments, 2)
+	assert.Equal(t, 600.0, merged.Segments[1].Start)
+	assert.Equal(t, 900.0, merged.Segments[1].End)
+	require.Len(t, merged.Words, 2)
+	assert.Equal(t, 601.0, merged.Words[1].Start)
+
+	// The pieces are left untouched
+	assert.Equal(t, 0.0, parts[1].Segments[0].Start)
+}
+
+func TestPlanPieces(t *testing.T) {
+	count, length := planPieces(60<<20, MaxAudioUploadBytes, 3600)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 1200.0, length)
+
+	// Files just above the limit still get split in two
+	count, length = planPieces(MaxAudioUploadBytes+1, MaxAudioUploadBytes, 100)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 50.0, length)
+}
+
+func newMockClient(t *testing.T, script string) *OpenAIClient {
+	parsed, err := mock.ParseScript([]byte(script))
+	require.NoError(t, err)
+	server := httptest.NewServer(mock.NewServer(parsed).Handler())
+	t.Cleanup(server.Close)
+
+	client, err := NewOpenAIClient(&config.Config{
+		OpenAI: config.OpenAIConfig{APIKey: "test-key", Model: "gpt-4o", BaseURL: server.URL + "/v1", Timeout: 5 * time.Second},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	client.rateLimiter.minInterval = 0
+	return client
+}
+
+func writeAudioFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "meeting.m4a")
+	require.NoError(t, os.WriteFile(path, []byte("not really audio"), 0644))
+	return path
+}
+
+func TestOpenAIClient_Transcribe(t *testing.T) {
+	client := newMockClient(t, `transcript: "Welcome everyone. Let's start with the roadmap."`)
+	path := writeAudioFile(t)
+
+	transcript, err := client.Transcribe(context.Background(), path, TranscriptionOptions{Model: "whisper-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome everyone. Let's start with the roadmap.", transcript.Text)
+	assert.Equal(t, "english", transcript.Language)
+	require.Len(t, transcript.Segments, 2)
+	assert.Equal(t, "Let's start with the roadmap.", transcript.Segments[1].Text)
+	assert.Equal(t, transcript.Segments[0].End, transcript.Segments[1].Start)
+
+	// Models without timestamps return plain JSON
+	transcript, err = client.Transcribe(context.Background(), path, TranscriptionOptions{Model: "gpt-4o-transcribe"})
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome everyone. Let's start with the roadmap.", transcript.Text)
+	assert.Empty(t, transcript.Segments)
+}
+
+func TestOpenAIClient_TranscribeValidation(t *testing.T) {
+	client := newMockClient(t, ``)
+
+	_, err := client.Transcribe(context.Background(), writeAudioFile(t),
+		TranscriptionOptions{Model: "gpt-4o-transcribe", Timestamps: []string{"word"}})
+	assert.Equal(t, utils.ExitUsage, utils.ExitCode(err))
+
+	_, err = client.Transcribe(context.Background(), filepath.Join(t.TempDir(), "missing.mp3"), TranscriptionOptions{})
+	assert.Error(t, err)
+}
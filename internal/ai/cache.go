@@ -1,11 +1,8 @@
 package ai
 
 import (
-	"container/list"
-	"crypto/sha256"
+	"bytes"
 	"encoding/gob"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -15,11 +12,14 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/storage"
+	"github.com/user/terminal-ai/internal/utils"
 )
 
 // Cache defines the interface for caching implementations
 type Cache interface {
-	// Get retrieves a cached response by key
+	// Get retrieves a cached entry by key. The entry is a copy, safe to read
+	// while other calls update the cache.
 	Get(key string) (*CacheEntry, bool)
 	// Set stores a response in cache with TTL
 	Set(key string, entry *CacheEntry, ttl time.Duration) error
@@ -47,6 +47,27 @@ type CacheEntry struct {
 	ExpiresAt      time.Time `json:"expires_at"`
 	AccessCount    int64     `json:"access_count"`
 	SizeBytes      int64     `json:"size_bytes"`
+	// Chunks holds the pieces of a streamed response as they arrived, so
+	// cache hits on streams replay it the same way
+	Chunks []CachedChunk `json:"chunks,omitempty"`
+	// Prompt is the last user message of the request
+	Prompt string `json:"prompt,omitempty"`
+	// Model and Mode are the model the request asked for and the mode it
+	// was made in (shell, query, quick or chat)
+	Model string `json:"model,omitempty"`
+	Mode  string `json:"mode,omitempty"`
+	// Scope and Embedding let semantic lookups find the entry by a similar
+	// prompt; see semanticScope
+	Scope     string `json:"scope,omitempty"`
+	Embedding Vector `json:"embedding,omitempty"`
+	// KeyVersion is the CacheKeyVersion the entry was stored under
+	KeyVersion int `json:"key_version,omitempty"`
+}
+
+// CachedChunk is one piece of content of a cached stream
+type CachedChunk struct {
+	Content string        `json:"content"`
+	Delay   time.Duration `json:"delay"` // since the previous chunk; zero for the first
 }
 
 // CacheStats represents cache statistics
@@ -59,50 +80,69 @@ type CacheStats struct {
 	MaxSizeBytes int64     `json:"max_size_bytes"`
 	HitRate      float64   `json:"hit_rate"`
 	LastCleanup  time.Time `json:"last_cleanup"`
+	// EvictionsByPolicy counts evictions by the strategy that chose them
+	EvictionsByPolicy map[string]int64 `json:"evictions_by_policy,omitempty"`
 }
 
-// lruNode represents a node in the LRU list
-type lruNode struct {
+// cacheNode is a cached entry and its size
+type cacheNode struct {
 	key       string
 	entry     *CacheEntry
 	sizeBytes int64
 }
 
-// InMemoryCache implements an LRU cache with TTL support
+// InMemoryCache implements a size-limited cache with TTL support, evicting
+// entries according to the configured strategy
 type InMemoryCache struct {
 	mu           sync.RWMutex
-	entries      map[string]*list.Element // map of key to LRU list element
-	lruList      *list.List               // LRU list
-	maxSizeBytes int64                    // maximum cache size in bytes
-	currentSize  int64                    // current cache size in bytes
-	ttl          time.Duration            // default TTL
-	stats        *CacheStats              // cache statistics
-	config       *config.CacheConfig      // cache configuration
-	persistPath  string                   // path for persistence
-	stopCleanup  chan struct{}            // signal to stop cleanup goroutine
-	wg           sync.WaitGroup           // wait group for goroutines
-}
-
-// NewInMemoryCache creates a new in-memory LRU cache
+	entries      map[string]*cacheNode   // map of key to cached entry
+	policy       EvictionPolicy          // chooses entries to evict
+	maxSizeBytes int64                   // maximum cache size in bytes
+	currentSize  int64                   // current cache size in bytes
+	ttl          time.Duration           // default TTL
+	staleGrace   time.Duration           // how long expired entries are kept for stale serving
+	stats        *CacheStats             // cache statistics
+	config       *config.CacheConfig     // cache configuration
+	persistPath  string                  // path for persistence
+	store        *storage.Store          // encrypts the persisted cache
+	stopCleanup  chan struct{}           // signal to stop cleanup goroutine
+	wg           sync.WaitGroup          // wait group for goroutines
+	metrics      *utils.MetricsCollector // process-wide metrics
+}
+
+// NewCache creates the cache backend selected by cfg.Backend. The disk
+// backend needs a cache directory and falls back to memory without one.
+func NewCache(cfg *config.CacheConfig) (Cache, error) {
+	if cfg.Backend == "memory" || cfg.Dir == "" {
+		return NewInMemoryCache(cfg), nil
+	}
+	return NewDiskCache(cfg)
+}
+
+// NewInMemoryCache creates a new in-memory cache
 func NewInMemoryCache(cfg *config.CacheConfig) *InMemoryCache {
 	maxSizeBytes := int64(cfg.MaxSize * 1024 * 1024) // Convert MB to bytes
 
 	cache := &InMemoryCache{
-		entries:      make(map[string]*list.Element),
-		lruList:      list.New(),
+		entries:      make(map[string]*cacheNode),
+		policy:       evictionPolicyFor(cfg.Strategy),
 		maxSizeBytes: maxSizeBytes,
 		currentSize:  0,
 		ttl:          cfg.TTL,
+		staleGrace:   cfg.StaleGrace,
 		config:       cfg,
+		store:        storage.Default(),
 		stopCleanup:  make(chan struct{}),
+		metrics:      utils.GetMetrics(),
 		stats: &CacheStats{
-			MaxSizeBytes: maxSizeBytes,
+			MaxSizeBytes:      maxSizeBytes,
+			EvictionsByPolicy: make(map[string]int64),
 		},
 	}
 
 	// Set persistence path if configured
 	if cfg.Dir != "" {
-		cache.persistPath = filepath.Join(cfg.Dir, "cache.gob")
+		cache.persistPath = filepath.Join(cfg.Dir, memoryCacheFile)
 		// Try to load existing cache
 		if err := cache.Load(); err != nil {
 			log.Debug().Err(err).Msg("Failed to load cache from disk")
@@ -116,24 +156,53 @@ func NewInMemoryCache(cfg *config.CacheConfig) *InMemoryCache {
 	return cache
 }
 
-// Get retrieves a cached entry and updates LRU
+// Get retrieves a cached entry and records the hit with the eviction policy
 func (c *InMemoryCache) Get(key string) (*CacheEntry, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	elem, exists := c.entries[key]
-	if !exists {
+	entry, found := c.lookupLocked(key)
+	if !found {
 		c.stats.Misses++
+		c.metrics.RecordCacheMiss()
+	}
+	return entry, found
+}
+
+// Lookup is Get without counting a miss, see SemanticIndex
+func (c *InMemoryCache) Lookup(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lookupLocked(key)
+}
+
+// RecordMiss counts a miss for lookups Lookup did not answer
+func (c *InMemoryCache) RecordMiss() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Misses++
+	c.metrics.RecordCacheMiss()
+}
+
+// lookupLocked returns a copy of the unexpired entry for key, counting the
+// hit and recording it with the eviction policy. Misses are left to the
+// caller.
+func (c *InMemoryCache) lookupLocked(key string) (*CacheEntry, bool) {
+	node, exists := c.entries[key]
+	if !exists {
 		return nil, false
 	}
 
-	node := elem.Value.(*lruNode)
 	entry := node.entry
 
-	// Check if entry has expired
-	if time.Now().After(entry.ExpiresAt) {
-		c.removeLocked(key)
-		c.stats.Misses++
+	// Check if entry has expired; it is kept for stale serving during the
+	// grace period
+	if now := time.Now(); now.After(entry.ExpiresAt) {
+		if pastGrace(entry, c.staleGrace, now) {
+			c.removeLocked(key)
+		}
 		return nil, false
 	}
 
@@ -141,11 +210,13 @@ func (c *InMemoryCache) Get(key string) (*CacheEntry, bool) {
 	entry.LastAccessedAt = time.Now()
 	entry.AccessCount++
 
-	// Move to front of LRU list
-	c.lruList.MoveToFront(elem)
+	c.policy.Touch(key, entry)
 
 	c.stats.Hits++
-	return entry, true
+	c.metrics.RecordCacheHit()
+	// A copy, so callers read its access metadata without holding the lock
+	copied := *entry
+	return &copied, true
 }
 
 // Set stores an entry in cache with TTL
@@ -158,7 +229,7 @@ func (c *InMemoryCache) Set(key string, entry *CacheEntry, ttl time.Duration) er
 	defer c.mu.Unlock()
 
 	// Calculate entry size
-	entrySize := c.calculateEntrySize(entry)
+	entrySize := estimateEntrySize(entry)
 	entry.SizeBytes = entrySize
 
 	// Check if entry exceeds max cache size
@@ -167,13 +238,15 @@ func (c *InMemoryCache) Set(key string, entry *CacheEntry, ttl time.Duration) er
 	}
 
 	// If key already exists, remove old entry
-	if elem, exists := c.entries[key]; exists {
-		c.removeElementLocked(elem)
-	}
+	c.removeLocked(key)
 
-	// Evict entries until we have enough space
-	for c.currentSize+entrySize > c.maxSizeBytes && c.lruList.Len() > 0 {
-		c.evictLRULocked()
+	// Drop expired entries kept for stale serving, then evict entries until
+	// we have enough space
+	if c.currentSize+entrySize > c.maxSizeBytes {
+		c.removeExpiredLocked(time.Now(), 0)
+	}
+	for c.currentSize+entrySize > c.maxSizeBytes && c.policy.Len() > 0 {
+		c.evictLocked()
 	}
 
 	// Set TTL
@@ -182,20 +255,21 @@ func (c *InMemoryCache) Set(key string, entry *CacheEntry, ttl time.Duration) er
 	}
 	entry.ExpiresAt = time.Now().Add(ttl)
 	entry.PromptHash = key
+	stampEntry(entry)
 
 	// Add to cache
-	node := &lruNode{
+	c.entries[key] = &cacheNode{
 		key:       key,
 		entry:     entry,
 		sizeBytes: entrySize,
 	}
-	elem := c.lruList.PushFront(node)
-	c.entries[key] = elem
+	c.policy.Add(key, entry)
 	c.currentSize += entrySize
 
 	// Update stats
 	c.stats.Entries = len(c.entries)
 	c.stats.SizeBytes = c.currentSize
+	c.metrics.RecordCacheWrite()
 
 	return nil
 }
@@ -217,14 +291,15 @@ func (c *InMemoryCache) Clear() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.entries = make(map[string]*list.Element)
-	c.lruList.Init()
+	c.entries = make(map[string]*cacheNode)
+	c.policy = evictionPolicyFor(c.policy.Name())
 	c.currentSize = 0
 
 	// Reset stats
 	c.stats.Entries = 0
 	c.stats.SizeBytes = 0
 	c.stats.Evictions = 0
+	c.stats.EvictionsByPolicy = make(map[string]int64)
 
 	// Clear persistent cache if configured
 	if c.persistPath != "" {
@@ -240,6 +315,7 @@ func (c *InMemoryCache) Stats() *CacheStats {
 	defer c.mu.RUnlock()
 
 	stats := *c.stats
+	stats.EvictionsByPolicy = copyEvictions(c.stats.EvictionsByPolicy)
 	stats.Entries = len(c.entries)
 	stats.SizeBytes = c.currentSize
 
@@ -252,23 +328,12 @@ func (c *InMemoryCache) Stats() *CacheStats {
 
 // GenerateKey generates a cache key from prompt
 func (c *InMemoryCache) GenerateKey(prompt string) string {
-	hash := sha256.Sum256([]byte(prompt))
-	return hex.EncodeToString(hash[:])
+	return promptKey(prompt)
 }
 
 // GenerateChatKey generates a cache key from messages and options
 func (c *InMemoryCache) GenerateChatKey(messages []Message, options ChatOptions) string {
-	data := struct {
-		Messages []Message
-		Options  ChatOptions
-	}{
-		Messages: messages,
-		Options:  options,
-	}
-
-	jsonData, _ := json.Marshal(data)
-	hash := sha256.Sum256(jsonData)
-	return hex.EncodeToString(hash[:])
+	return chatKey(messages, options)
 }
 
 // Close gracefully shuts down the cache
@@ -296,34 +361,23 @@ func (c *InMemoryCache) Save() error {
 
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(c.persistPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := storage.MkdirPrivate(dir); err != nil {
 		return fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	// Create temporary file
-	tempFile := c.persistPath + ".tmp"
-	file, err := os.Create(tempFile)
-	if err != nil {
-		return fmt.Errorf("failed to create cache file: %w", err)
-	}
-	defer file.Close()
-
 	// Encode cache data
 	data := make(map[string]*CacheEntry)
-	for key, elem := range c.entries {
-		node := elem.Value.(*lruNode)
+	for key, node := range c.entries {
 		data[key] = node.entry
 	}
 
-	encoder := gob.NewEncoder(file)
-	if err := encoder.Encode(data); err != nil {
-		os.Remove(tempFile)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
 		return fmt.Errorf("failed to encode cache data: %w", err)
 	}
 
-	// Atomic rename
-	if err := os.Rename(tempFile, c.persistPath); err != nil {
-		os.Remove(tempFile)
+	// Written atomically, and encrypted when storage encryption is on
+	if err := c.store.WriteFile(c.persistPath, buf.Bytes()); err != nil {
 		return fmt.Errorf("failed to save cache file: %w", err)
 	}
 
@@ -336,17 +390,16 @@ func (c *InMemoryCache) Load() error {
 		return nil
 	}
 
-	file, err := os.Open(c.persistPath)
+	raw, err := c.store.ReadFile(c.persistPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // No cache file exists
 		}
 		return fmt.Errorf("failed to open cache file: %w", err)
 	}
-	defer file.Close()
 
 	var data map[string]*CacheEntry
-	decoder := gob.NewDecoder(file)
+	decoder := gob.NewDecoder(bytes.NewReader(raw))
 	if err := decoder.Decode(&data); err != nil {
 		return fmt.Errorf("failed to decode cache data: %w", err)
 	}
@@ -354,17 +407,17 @@ func (c *InMemoryCache) Load() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Load entries that haven't expired
+	// Load entries that haven't expired, or can still be served stale, and
+	// were stored under the current key format
 	now := time.Now()
 	for key, entry := range data {
-		if now.Before(entry.ExpiresAt) {
-			node := &lruNode{
+		if currentKeyVersion(entry) && !pastGrace(entry, c.staleGrace, now) {
+			c.entries[key] = &cacheNode{
 				key:       key,
 				entry:     entry,
 				sizeBytes: entry.SizeBytes,
 			}
-			elem := c.lruList.PushBack(node) // Add to back (oldest)
-			c.entries[key] = elem
+			c.policy.Add(key, entry)
 			c.currentSize += entry.SizeBytes
 		}
 	}
@@ -380,6 +433,61 @@ func (c *InMemoryCache) Load() error {
 	return nil
 }
 
+// Nearest returns the unexpired entry in scope most similar to embedding
+func (c *InMemoryCache) Nearest(scope string, embedding Vector) (string, *CacheEntry, float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return nearestEntry(func(yield func(string, *CacheEntry) bool) {
+		for key, node := range c.entries {
+			if !yield(key, node.entry) {
+				return
+			}
+		}
+	}, scope, embedding)
+}
+
+// Entries returns the unexpired entries, oldest first
+func (c *InMemoryCache) Entries() []CacheItem {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	items := make([]CacheItem, 0, len(c.entries))
+	for key, node := range c.entries {
+		if !now.After(node.entry.ExpiresAt) {
+			items = append(items, CacheItem{Key: key, Entry: node.entry})
+		}
+	}
+	sortItems(items)
+	return items
+}
+
+// Peek returns an unexpired entry without counting a hit
+func (c *InMemoryCache) Peek(key string) (*CacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	node, ok := c.entries[key]
+	if !ok || time.Now().After(node.entry.ExpiresAt) {
+		return nil, false
+	}
+	return node.entry, true
+}
+
+// GetStale returns an entry, even if it has expired, unless it is past the
+// stale grace period. It does not count a hit.
+func (c *InMemoryCache) GetStale(key string) (*CacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	node, ok := c.entries[key]
+	if !ok || pastGrace(node.entry, c.staleGrace, time.Now()) {
+		return nil, false
+	}
+	return node.entry, true
+}
+
 // Warm preloads the cache with specified entries
 func (c *InMemoryCache) Warm(entries map[string]*CacheEntry) error {
 	for key, entry := range entries {
@@ -415,34 +523,29 @@ func (c *InMemoryCache) InvalidatePattern(pattern string) (int, error) {
 
 // removeLocked removes an entry (must be called with lock held)
 func (c *InMemoryCache) removeLocked(key string) bool {
-	elem, exists := c.entries[key]
+	node, exists := c.entries[key]
 	if !exists {
 		return false
 	}
 
-	c.removeElementLocked(elem)
-	return true
-}
-
-// removeElementLocked removes an element from cache (must be called with lock held)
-func (c *InMemoryCache) removeElementLocked(elem *list.Element) {
-	node := elem.Value.(*lruNode)
-	c.lruList.Remove(elem)
-	delete(c.entries, node.key)
+	c.policy.Remove(key)
+	delete(c.entries, key)
 	c.currentSize -= node.sizeBytes
+	return true
 }
 
-// evictLRULocked evicts the least recently used entry (must be called with lock held)
-func (c *InMemoryCache) evictLRULocked() {
-	elem := c.lruList.Back()
-	if elem != nil {
-		c.removeElementLocked(elem)
+// evictLocked evicts the entry chosen by the eviction policy (must be called with lock held)
+func (c *InMemoryCache) evictLocked() {
+	if key, ok := c.policy.Victim(); ok {
+		c.removeLocked(key)
 		c.stats.Evictions++
+		c.stats.EvictionsByPolicy[c.policy.Name()]++
+		c.metrics.RecordCacheEviction()
 	}
 }
 
-// calculateEntrySize estimates the size of a cache entry in bytes
-func (c *InMemoryCache) calculateEntrySize(entry *CacheEntry) int64 {
+// estimateEntrySize estimates the size of a cache entry in bytes
+func estimateEntrySize(entry *CacheEntry) int64 {
 	// Estimate based on response content and metadata
 	size := int64(0)
 
@@ -452,6 +555,11 @@ func (c *InMemoryCache) calculateEntrySize(entry *CacheEntry) int64 {
 		size += int64(len(entry.Response.ID))
 		size += 100 // Overhead for other fields
 	}
+	for _, chunk := range entry.Chunks {
+		size += int64(len(chunk.Content)) + 8
+	}
+	size += int64(len(entry.Prompt)) + int64(len(entry.Scope)) + 4*int64(len(entry.Embedding))
+	size += int64(len(entry.Model)) + int64(len(entry.Mode))
 
 	// Add metadata overhead
 	size += int64(len(entry.PromptHash))
@@ -477,18 +585,14 @@ func (c *InMemoryCache) cleanupRoutine() {
 	}
 }
 
-// cleanup removes expired entries
-func (c *InMemoryCache) cleanup() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	now := time.Now()
+// removeExpiredLocked removes entries that expired longer than grace before
+// now and returns how many it removed (must be called with lock held)
+func (c *InMemoryCache) removeExpiredLocked(now time.Time, grace time.Duration) int {
 	expiredKeys := []string{}
 
 	// Find expired entries
-	for key, elem := range c.entries {
-		node := elem.Value.(*lruNode)
-		if now.After(node.entry.ExpiresAt) {
+	for key, node := range c.entries {
+		if pastGrace(node.entry, grace, now) {
 			expiredKeys = append(expiredKeys, key)
 		}
 	}
@@ -497,20 +601,67 @@ func (c *InMemoryCache) cleanup() {
 	for _, key := range expiredKeys {
 		c.removeLocked(key)
 	}
+	return len(expiredKeys)
+}
+
+// cleanup removes expired entries, keeping them for the stale grace period
+func (c *InMemoryCache) cleanup() {
+	c.mu.Lock()
+
+	now := time.Now()
+	expired := c.removeExpiredLocked(now, c.staleGrace)
 
 	c.stats.LastCleanup = now
 
-	if len(expiredKeys) > 0 {
+	if expired > 0 {
 		log.Debug().
-			Int("expired_entries", len(expiredKeys)).
+			Int("expired_entries", expired).
 			Int("remaining_entries", len(c.entries)).
 			Msg("Cache cleanup completed")
 	}
 
-	// Save to disk periodically if configured
-	if c.persistPath != "" && len(c.entries) > 0 {
+	remaining := len(c.entries)
+	c.mu.Unlock()
+
+	// Save to disk periodically if configured. Save takes the lock itself.
+	if c.persistPath != "" && remaining > 0 {
 		if err := c.Save(); err != nil {
 			log.Error().Err(err).Msg("Failed to save cache to disk")
 		}
 	}
 }
+
+// evictionPolicyFor creates the policy for strategy, falling back to LRU for
+// strategies the validator would have rejected
+func evictionPolicyFor(strategy string) EvictionPolicy {
+	policy, err := NewEvictionPolicy(strategy)
+	if err != nil {
+		log.Warn().Err(err).Msg("Using LRU eviction")
+		policy, _ = NewEvictionPolicy(EvictLRU)
+	}
+	return policy
+}
+
+// stampEntry fills in the creation and access times policies order by, for
+// entries stored without them, and the key version for new entries
+func stampEntry(entry *CacheEntry) {
+	if entry.KeyVersion == 0 {
+		entry.KeyVersion = CacheKeyVersion
+	}
+	now := time.Now()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+	if entry.LastAccessedAt.IsZero() {
+		entry.LastAccessedAt = now
+	}
+}
+
+// copyEvictions copies per-policy eviction counts for a stats snapshot
+func copyEvictions(counts map[string]int64) map[string]int64 {
+	copied := make(map[string]int64, len(counts))
+	for name, count := range counts {
+		copied[name] = count
+	}
+	return copied
+}
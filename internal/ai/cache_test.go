@@ -263,10 +263,7 @@ func TestInMemoryCache_InvalidatePattern(t *testing.T) {
 		}
 		// Use a simple key for pattern matching
 		key := prefix + string(rune(i))
-		cache.entries[key] = cache.lruList.PushFront(&lruNode{
-			key:   key,
-			entry: entry,
-		})
+		require.NoError(t, cache.Set(key, entry, 0))
 	}
 
 	// Invalidate all entries starting with "chat_"
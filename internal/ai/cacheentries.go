@@ -0,0 +1,192 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Modes requests are made in, recorded on their cache entries
+const (
+	ModeShell = "shell" // terminal-ai -s
+	ModeQuery = "query" // terminal-ai query
+	ModeQuick = "quick" // terminal-ai -q
+	ModeChat  = "chat"
+)
+
+type modeKey struct{}
+
+// WithMode returns a context whose requests are recorded in the cache as
+// made in mode
+func WithMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, modeKey{}, mode)
+}
+
+// modeFrom returns the mode set with WithMode, or "" if there is none
+func modeFrom(ctx context.Context) string {
+	mode, _ := ctx.Value(modeKey{}).(string)
+	return mode
+}
+
+type cacheTTLKey struct{}
+
+// withCacheTTL returns a context whose responses are cached for ttl instead
+// of the configured TTL
+func withCacheTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, cacheTTLKey{}, ttl)
+}
+
+// cacheTTL returns the TTL set with withCacheTTL, or ttl if there is none
+func cacheTTL(ctx context.Context, ttl time.Duration) time.Duration {
+	if override, ok := ctx.Value(cacheTTLKey{}).(time.Duration); ok && override > 0 {
+		return override
+	}
+	return ttl
+}
+
+type noCacheKey struct{}
+
+// WithoutCache returns a context whose requests skip the response cache,
+// semantic lookups and deduplication: each one reaches the API on its own,
+// and its answer is not cached
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// cacheBypassed reports whether ctx was made by WithoutCache
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(noCacheKey{}).(bool)
+	return bypass
+}
+
+// RequestModel returns the model the entry's request asked for, or, for
+// entries cached before it was recorded, the model that answered
+func (e *CacheEntry) RequestModel() string {
+	if e.Model != "" || e.Response == nil {
+		return e.Model
+	}
+	return e.Response.Model
+}
+
+// PromptPreview returns prompt on one line, cut to at most n runes
+func PromptPreview(prompt string, n int) string {
+	prompt = strings.Join(strings.Fields(prompt), " ")
+	if runes := []rune(prompt); len(runes) > n {
+		prompt = string(runes[:n-3]) + "..."
+	}
+	return prompt
+}
+
+// CacheItem is a cache entry with its key
+type CacheItem struct {
+	Key   string      `json:"key"`
+	Entry *CacheEntry `json:"entry"`
+}
+
+// EntryLister is implemented by caches that can list their entries
+type EntryLister interface {
+	// Entries returns the unexpired entries, oldest first. Listing does not
+	// count as a hit.
+	Entries() []CacheItem
+	// Peek returns an unexpired entry without counting a hit
+	Peek(key string) (*CacheEntry, bool)
+}
+
+// CacheFilter selects cache entries; zero fields match everything
+type CacheFilter struct {
+	Model     string         // model the request asked for
+	Mode      string         // shell, query, quick or chat
+	Grep      string         // text in the prompt or response, ignoring case
+	Regex     *regexp.Regexp // matched against the prompt
+	OlderThan time.Duration  // minimum time since the entry was created
+}
+
+// IsZero reports whether the filter matches every entry
+func (f CacheFilter) IsZero() bool {
+	return f.Model == "" && f.Mode == "" && f.Grep == "" && f.Regex == nil && f.OlderThan == 0
+}
+
+// Matches reports whether entry passes every set field of the filter
+func (f CacheFilter) Matches(entry *CacheEntry, now time.Time) bool {
+	if f.Model != "" && entry.RequestModel() != f.Model {
+		return false
+	}
+	if f.Mode != "" && entry.Mode != f.Mode {
+		return false
+	}
+	if f.OlderThan > 0 && now.Sub(entry.CreatedAt) < f.OlderThan {
+		return false
+	}
+	if f.Regex != nil && !f.Regex.MatchString(entry.Prompt) {
+		return false
+	}
+	if f.Grep != "" {
+		text := strings.ToLower(f.Grep)
+		content := ""
+		if entry.Response != nil {
+			content = entry.Response.Content
+		}
+		if !strings.Contains(strings.ToLower(entry.Prompt), text) && !strings.Contains(strings.ToLower(content), text) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortItems orders items oldest first, then by key
+func sortItems(items []CacheItem) {
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i].Entry.CreatedAt, items[j].Entry.CreatedAt
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return items[i].Key < items[j].Key
+	})
+}
+
+// ExportEntries writes items to w as JSON lines, one per entry
+func ExportEntries(w io.Writer, items []CacheItem) error {
+	encoder := json.NewEncoder(w)
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			return fmt.Errorf("failed to export cache entry %s: %w", item.Key, err)
+		}
+	}
+	return nil
+}
+
+// ImportEntries stores entries read from JSON lines written by
+// ExportEntries in cache. Entries keep their creation time and expiry;
+// those that have expired, or were stored under an older key format, are
+// skipped.
+func ImportEntries(r io.Reader, cache Cache) (imported, skipped int, err error) {
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var item CacheItem
+		if err := decoder.Decode(&item); err != nil {
+			if errors.Is(err, io.EOF) {
+				return imported, skipped, nil
+			}
+			return imported, skipped, fmt.Errorf("invalid cache entry %d: %w", line, err)
+		}
+		if item.Key == "" || item.Entry == nil || item.Entry.Response == nil {
+			return imported, skipped, fmt.Errorf("invalid cache entry %d: missing key or response", line)
+		}
+
+		ttl := time.Until(item.Entry.ExpiresAt)
+		if ttl <= 0 || !currentKeyVersion(item.Entry) {
+			skipped++
+			continue
+		}
+		if err := cache.Set(item.Key, item.Entry, ttl); err != nil {
+			return imported, skipped, fmt.Errorf("failed to import cache entry %s: %w", item.Key, err)
+		}
+		imported++
+	}
+}
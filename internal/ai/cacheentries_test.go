@@ -0,0 +1,157 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+)
+
+func TestCacheFilter(t *testing.T) {
+	now := time.Now()
+	entry := &CacheEntry{
+		Response:  &Response{Content: "Use docker ps -a", Model: "gpt-4o-2024-08-06"},
+		Prompt:    "How do I list containers?",
+		Model:     "gpt-4o",
+		Mode:      ModeQuery,
+		CreatedAt: now.Add(-48 * time.Hour),
+	}
+
+	tests := []struct {
+		name   string
+		filter CacheFilter
+		want   bool
+	}{
+		{"Empty", CacheFilter{}, true},
+		{"Model", CacheFilter{Model: "gpt-4o"}, true},
+		{"AnsweringModel", CacheFilter{Model: "gpt-4o-2024-08-06"}, false},
+		{"Mode", CacheFilter{Mode: ModeShell}, false},
+		{"GrepPrompt", CacheFilter{Grep: "CONTAINERS"}, true},
+		{"GrepResponse", CacheFilter{Grep: "docker ps"}, true},
+		{"GrepMissing", CacheFilter{Grep: "kubectl"}, false},
+		{"Regex", CacheFilter{Regex: regexp.MustCompile(`^How do I`)}, true},
+		{"RegexResponseIgnored", CacheFilter{Regex: regexp.MustCompile(`docker`)}, false},
+		{"OlderThan", CacheFilter{OlderThan: 24 * time.Hour}, true},
+		{"NotOlderThan", CacheFilter{OlderThan: 72 * time.Hour}, false},
+		{"All", CacheFilter{Model: "gpt-4o", Mode: ModeQuery, Grep: "docker", OlderThan: time.Hour}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(entry, now))
+		})
+	}
+	assert.True(t, CacheFilter{}.IsZero())
+	assert.False(t, CacheFilter{Mode: ModeChat}.IsZero())
+
+	// Entries cached before the requested model was recorded match the
+	// model that answered
+	legacy := &CacheEntry{Response: &Response{Model: "gpt-4o-2024-08-06"}}
+	assert.True(t, CacheFilter{Model: "gpt-4o-2024-08-06"}.Matches(legacy, now))
+}
+
+func TestPromptPreview(t *testing.T) {
+	prompt := "  list\n all   docker containers  "
+	assert.Equal(t, "list all docker containers", PromptPreview(prompt, 50))
+	assert.Equal(t, "list al...", PromptPreview(prompt, 10))
+}
+
+func TestCacheInterceptor_RecordsMetadata(t *testing.T) {
+	cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU})
+	defer cache.Close()
+	handler := Chain(&fakeHandler{}, CacheInterceptor(cache, CacheOptions{TTL: time.Minute}))
+
+	ctx := WithMode(context.Background(), ModeShell)
+	_, err := handler.Chat(ctx, promptRequest("gpt-4o", "you write shell commands", "list docker containers"))
+	require.NoError(t, err)
+
+	items := cache.Entries()
+	require.Len(t, items, 1)
+	entry := items[0].Entry
+	assert.Equal(t, "gpt-4o", entry.Model)
+	assert.Equal(t, ModeShell, entry.Mode)
+	assert.Equal(t, "list docker containers", entry.Prompt)
+}
+
+func TestCacheEntries(t *testing.T) {
+	caches := map[string]func(t *testing.T) Cache{
+		"Memory": func(t *testing.T) Cache {
+			cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU})
+			t.Cleanup(func() { cache.Close() })
+			return cache
+		},
+		"Disk": func(t *testing.T) Cache {
+			return openDiskCache(t, diskCacheConfig(t))
+		},
+	}
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			cache := newCache(t)
+			base := time.Now().Add(-time.Hour)
+			for i, key := range []string{"second", "first", "expired"} {
+				entry := diskEntryFor(key)
+				entry.CreatedAt = base.Add(time.Duration(-i) * time.Minute)
+				ttl := time.Duration(0)
+				if key == "expired" {
+					ttl = time.Nanosecond
+				}
+				require.NoError(t, cache.Set(key, entry, ttl))
+			}
+			time.Sleep(time.Millisecond)
+
+			items := cache.(EntryLister).Entries()
+			require.Len(t, items, 2)
+			assert.Equal(t, "first", items[0].Key)
+			assert.Equal(t, "second", items[1].Key)
+
+			// Listing is not a hit
+			assert.Zero(t, cache.Stats().Hits)
+		})
+	}
+}
+
+func TestExportImportEntries(t *testing.T) {
+	source := openDiskCache(t, diskCacheConfig(t))
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	entry := diskEntryFor("docker ps")
+	entry.Prompt = "list docker containers"
+	entry.Model = "gpt-4o"
+	entry.Mode = ModeShell
+	entry.CreatedAt = created
+	entry.Embedding = Vector{0.5, 0.25}
+	require.NoError(t, source.Set("kept", entry, time.Hour))
+	require.NoError(t, source.Set("expired", diskEntryFor("old"), time.Nanosecond))
+
+	var buf bytes.Buffer
+	require.NoError(t, ExportEntries(&buf, []CacheItem{
+		{Key: "kept", Entry: entry},
+		{Key: "expired", Entry: source.entries["expired"].entry},
+	}))
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"), "one entry per line")
+
+	target := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU})
+	defer target.Close()
+	time.Sleep(time.Millisecond)
+	imported, skipped, err := ImportEntries(&buf, target)
+	require.NoError(t, err)
+	assert.Equal(t, 1, imported)
+	assert.Equal(t, 1, skipped)
+
+	got, found := target.Get("kept")
+	require.True(t, found)
+	assert.Equal(t, "docker ps", got.Response.Content)
+	assert.Equal(t, ModeShell, got.Mode)
+	assert.Equal(t, Vector{0.5, 0.25}, got.Embedding)
+	assert.True(t, created.Equal(got.CreatedAt), "creation time is kept")
+	assert.WithinDuration(t, entry.ExpiresAt, got.ExpiresAt, time.Second, "expiry is kept, not reset to the TTL")
+
+	_, _, err = ImportEntries(strings.NewReader(`{"key":"a","entry":{"response":{"content":"x"}}}`+"\n{not json"), target)
+	assert.ErrorContains(t, err, "invalid cache entry 2")
+	_, _, err = ImportEntries(strings.NewReader(`{"key":"a"}`), target)
+	assert.ErrorContains(t, err, "missing key or response")
+}
@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// CacheKeyVersion identifies the format of cache keys. It is hashed into
+// every key and recorded on every entry, so bumping it with a format change
+// leaves older entries unreachable, and the caches drop them when they load.
+// Version 1 hashed the whole ChatOptions, or the prompt alone.
+const CacheKeyVersion = 2
+
+// cacheKeyFields are the parts of a request that decide its answer, listed
+// explicitly so a new ChatOptions field does not silently change every key.
+// Options that do not change the answer, User and ServiceTier, are left out.
+// So is the mode a request is made in: modes differ by their system prompt,
+// which is one of the messages. Fields that change the answer, such as tools
+// or a response schema, must be added here with a new CacheKeyVersion.
+type cacheKeyFields struct {
+	Version          int               `json:"v"`
+	Model            string            `json:"model"`
+	Messages         []cacheKeyMessage `json:"messages"`
+	Temperature      float32           `json:"temperature"`
+	TopP             float32           `json:"top_p"`
+	MaxTokens        int               `json:"max_tokens"`
+	N                int               `json:"n"`
+	Stop             []string          `json:"stop,omitempty"`
+	PresencePenalty  float32           `json:"presence_penalty"`
+	FrequencyPenalty float32           `json:"frequency_penalty"`
+	ReasoningEffort  string            `json:"reasoning_effort,omitempty"`
+}
+
+// cacheKeyMessage is a message as it is hashed into a key
+type cacheKeyMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Name    string `json:"name,omitempty"`
+}
+
+// chatKey identifies a chat request by the messages and options that decide
+// its answer. It is the key used by both caches, and for de-duplicating
+// without a cache.
+func chatKey(messages []Message, options ChatOptions) string {
+	fields := cacheKeyFields{
+		Version:          CacheKeyVersion,
+		Model:            options.Model,
+		Messages:         make([]cacheKeyMessage, len(messages)),
+		Temperature:      options.Temperature,
+		TopP:             options.TopP,
+		MaxTokens:        options.MaxTokens,
+		N:                options.N,
+		Stop:             options.Stop,
+		PresencePenalty:  options.PresencePenalty,
+		FrequencyPenalty: options.FrequencyPenalty,
+		ReasoningEffort:  options.ReasoningEffort,
+	}
+	for i, message := range messages {
+		fields.Messages[i] = cacheKeyMessage(message)
+	}
+	// Unset means one choice, so Chat, which sets N from the config, and
+	// ChatStream, which does not, share entries
+	if fields.N == 0 {
+		fields.N = 1
+	}
+	return hashKey(fields)
+}
+
+// promptKey identifies a bare prompt, for callers without a request. It is
+// the key of the prompt sent as the only message with default options.
+func promptKey(prompt string) string {
+	return chatKey([]Message{{Role: "user", Content: prompt}}, ChatOptions{})
+}
+
+// hashKey returns the hex SHA-256 of fields encoded as JSON, whose field
+// order is fixed by the struct
+func hashKey(fields cacheKeyFields) string {
+	data, _ := json.Marshal(fields)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// currentKeyVersion reports whether entry was cached under the current key
+// format
+func currentKeyVersion(entry *CacheEntry) bool {
+	return entry.KeyVersion == CacheKeyVersion
+}
@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+)
+
+func keyMessages() []Message {
+	return []Message{
+		{Role: "system", Content: "You are a helpful assistant."},
+		{Role: "user", Content: "list docker containers"},
+	}
+}
+
+func keyOptions() ChatOptions {
+	return ChatOptions{Model: "gpt-4o", Temperature: 0.7, MaxTokens: 1000, TopP: 1, N: 1}
+}
+
+func TestChatKey_Stable(t *testing.T) {
+	// Keys must not change without a new CacheKeyVersion, or cached entries
+	// are silently lost. Update these only when bumping the version.
+	assert.Equal(t, 2, CacheKeyVersion)
+	assert.Equal(t, "4a2a043e2eebbffaee0de260ecb6d9de66b1aec69e3cc70b50aa401f0bcac5cd", chatKey(keyMessages(), keyOptions()))
+	assert.Equal(t, "115d7e4036449b71cd52defe8bee3ab658827c7e157d939899365708850257e6", promptKey("list docker containers"))
+}
+
+func TestChatKey_Fields(t *testing.T) {
+	base := chatKey(keyMessages(), keyOptions())
+
+	tests := []struct {
+		name    string
+		change  func(messages []Message, options *ChatOptions) []Message
+		changes bool
+	}{
+		{"Model", func(m []Message, o *ChatOptions) []Message { o.Model = "gpt-4o-mini"; return m }, true},
+		{"SystemPrompt", func(m []Message, o *ChatOptions) []Message { m[0].Content = "Be brief."; return m }, true},
+		{"Prompt", func(m []Message, o *ChatOptions) []Message { m[1].Content = "list docker images"; return m }, true},
+		{"History", func(m []Message, o *ChatOptions) []Message {
+			return append(m, Message{Role: "user", Content: "and images?"})
+		}, true},
+		{"Temperature", func(m []Message, o *ChatOptions) []Message { o.Temperature = 0.2; return m }, true},
+		{"TopP", func(m []Message, o *ChatOptions) []Message { o.TopP = 0.5; return m }, true},
+		{"MaxTokens", func(m []Message, o *ChatOptions) []Message { o.MaxTokens = 50; return m }, true},
+		{"Choices", func(m []Message, o *ChatOptions) []Message { o.N = 2; return m }, true},
+		{"Stop", func(m []Message, o *ChatOptions) []Message { o.Stop = []string{"\n"}; return m }, true},
+		{"Penalties", func(m []Message, o *ChatOptions) []Message { o.PresencePenalty = 0.5; return m }, true},
+		{"ReasoningEffort", func(m []Message, o *ChatOptions) []Message { o.ReasoningEffort = "high"; return m }, true},
+		{"User", func(m []Message, o *ChatOptions) []Message { o.User = "alice"; return m }, false},
+		{"ServiceTier", func(m []Message, o *ChatOptions) []Message { o.ServiceTier = "flex"; return m }, false},
+		{"UnsetChoices", func(m []Message, o *ChatOptions) []Message { o.N = 0; return m }, false},
+		{"EmptyStop", func(m []Message, o *ChatOptions) []Message { o.Stop = []string{}; return m }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := keyOptions()
+			messages := tt.change(keyMessages(), &options)
+			if tt.changes {
+				assert.NotEqual(t, base, chatKey(messages, options))
+			} else {
+				assert.Equal(t, base, chatKey(messages, options))
+			}
+		})
+	}
+
+	// A bare prompt is not served another model's answer
+	options := keyOptions()
+	assert.NotEqual(t, promptKey("list docker containers"), chatKey(keyMessages()[1:], options))
+}
+
+func TestCache_DropsOlderKeyVersions(t *testing.T) {
+	old := diskEntryFor("docker ps")
+	old.KeyVersion = CacheKeyVersion - 1
+
+	t.Run("Memory", func(t *testing.T) {
+		cfg := &config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU, Dir: t.TempDir()}
+		cache := NewInMemoryCache(cfg)
+		require.NoError(t, cache.Set("old", old, 0))
+		require.NoError(t, cache.Set("new", diskEntryFor("docker images"), 0))
+		require.NoError(t, cache.Close())
+		require.FileExists(t, filepath.Join(cfg.Dir, "cache.gob"))
+
+		reloaded := NewInMemoryCache(cfg)
+		defer reloaded.Close()
+		_, found := reloaded.Get("old")
+		assert.False(t, found)
+		entry, found := reloaded.Get("new")
+		require.True(t, found)
+		assert.Equal(t, CacheKeyVersion, entry.KeyVersion)
+	})
+
+	t.Run("Disk", func(t *testing.T) {
+		cfg := diskCacheConfig(t)
+		cache := openDiskCache(t, cfg)
+		require.NoError(t, cache.Set("old", old, 0))
+		require.NoError(t, cache.Set("new", diskEntryFor("docker images"), 0))
+
+		reopened := openDiskCache(t, cfg)
+		items := reopened.Entries()
+		require.Len(t, items, 1)
+		assert.Equal(t, "new", items[0].Key)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		exported := *old
+		exported.ExpiresAt = time.Now().Add(time.Hour)
+		var buf bytes.Buffer
+		require.NoError(t, ExportEntries(&buf, []CacheItem{{Key: "old", Entry: &exported}}))
+
+		cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU})
+		defer cache.Close()
+		imported, skipped, err := ImportEntries(&buf, cache)
+		require.NoError(t, err)
+		assert.Zero(t, imported)
+		assert.Equal(t, 1, skipped)
+	})
+}
@@ -0,0 +1,490 @@
+package ai
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/storage"
+)
+
+// Cassette modes
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// ErrCassetteMiss is returned in replay mode when no recorded interaction matches a request
+var ErrCassetteMiss = errors.New("no recorded interaction matches request")
+
+// redactedValue replaces credentials in recorded headers
+const redactedValue = "[REDACTED]"
+
+// cassetteInteraction is a single recorded request/response pair
+type cassetteInteraction struct {
+	Request    cassetteRequest  `json:"request"`
+	Response   cassetteResponse `json:"response"`
+	RecordedAt time.Time        `json:"recorded_at"`
+}
+
+// Bodies are stored as text when they are valid UTF-8, so cassettes stay
+// readable, and otherwise as base64 in the *Base64 field next to them, as
+// JSON strings cannot hold arbitrary bytes (audio, images, or a stream read
+// that splits a character).
+
+// cassetteRequest holds the recorded request with credentials redacted
+type cassetteRequest struct {
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Query      string            `json:"query,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	BodyBase64 []byte            `json:"body_base64,omitempty"`
+}
+
+// cassetteResponse holds the recorded response. Streamed (SSE) bodies are
+// stored as chunks with the delay observed before each one.
+type cassetteResponse struct {
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body,omitempty"`
+	BodyBase64 []byte              `json:"body_base64,omitempty"`
+	Chunks     []cassetteChunk     `json:"chunks,omitempty"`
+}
+
+// cassetteChunk is one read from a streamed response body
+type cassetteChunk struct {
+	DelayMs    int64  `json:"delay_ms"`
+	Data       string `json:"data,omitempty"`
+	DataBase64 []byte `json:"data_base64,omitempty"`
+}
+
+// encodeBody returns data as text, or as bytes for base64 when it is not
+// valid UTF-8
+func encodeBody(data []byte) (string, []byte) {
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	return "", data
+}
+
+// decodeBody returns the body stored by encodeBody
+func decodeBody(text string, binary []byte) []byte {
+	if binary != nil {
+		return binary
+	}
+	return []byte(text)
+}
+
+// CassetteTransport is an http.RoundTripper that records API traffic to
+// cassette files or replays previously recorded traffic without touching
+// the network.
+type CassetteTransport struct {
+	mode     string
+	dir      string
+	base     http.RoundTripper
+	pace     bool
+	store    *storage.Store // encrypts cassettes when storage encryption is on
+	mu       sync.Mutex
+	replayed map[string]int // next interaction index per cassette key
+}
+
+// NewCassetteTransport creates a transport for the given mode and directory.
+// In record mode requests are forwarded to base; in replay mode base is unused.
+// When pace is true, replayed streams honour the recorded chunk timing.
+func NewCassetteTransport(mode, dir string, base http.RoundTripper, pace bool) (*CassetteTransport, error) {
+	if mode != CassetteRecord && mode != CassetteReplay {
+		return nil, fmt.Errorf("invalid cassette mode: %s (must be record or replay)", mode)
+	}
+	if dir == "" {
+		return nil, errors.New("cassette directory is required")
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if mode == CassetteRecord {
+		// Cassettes hold whole prompts and responses, so they are private
+		if err := storage.MkdirPrivate(dir); err != nil {
+			return nil, fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+	} else if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("cassette directory not found: %w", err)
+	}
+
+	return &CassetteTransport{
+		mode:     mode,
+		dir:      dir,
+		base:     base,
+		pace:     pace,
+		store:    storage.Default(),
+		replayed: make(map[string]int),
+	}, nil
+}
+
+// Mode returns the cassette mode (record or replay)
+func (t *CassetteTransport) Mode() string {
+	return t.mode
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: failed to read request body: %w", err)
+	}
+
+	key := cassetteKey(req.Method, req.URL.Path, req.URL.RawQuery, req.Header.Get("Content-Type"), body)
+
+	if t.mode == CassetteReplay {
+		return t.replay(req, key)
+	}
+	return t.record(req, key, body)
+}
+
+// record forwards the request and stores the interaction once the response body is consumed
+func (t *CassetteTransport) record(req *http.Request, key string, body []byte) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := &cassetteInteraction{
+		Request: cassetteRequest{
+			Method:  req.Method,
+			Path:    req.URL.Path,
+			Query:   req.URL.RawQuery,
+			Headers: redactHeaders(req.Header),
+		},
+		Response: cassetteResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    responseHeaders(resp.Header),
+		},
+		RecordedAt: time.Now(),
+	}
+	interaction.Request.Body, interaction.Request.BodyBase64 = encodeBody(body)
+
+	if isEventStream(resp.Header) {
+		// Record chunks as the caller reads them so timing is preserved
+		resp.Body = &recordingBody{
+			body: resp.Body,
+			last: time.Now(),
+			onDone: func(chunks []cassetteChunk) {
+				interaction.Response.Chunks = chunks
+				t.save(key, interaction)
+			},
+		}
+		return resp, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cassette: failed to read response body: %w", err)
+	}
+	interaction.Response.Body, interaction.Response.BodyBase64 = encodeBody(data)
+	t.save(key, interaction)
+
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// replay serves the next recorded interaction for the request key
+func (t *CassetteTransport) replay(req *http.Request, key string) (*http.Response, error) {
+	interactions, err := t.load(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(interactions) == 0 {
+		return nil, fmt.Errorf("cassette: %w: %s %s (key %s)", ErrCassetteMiss, req.Method, req.URL.Path, key)
+	}
+
+	t.mu.Lock()
+	idx := t.replayed[key]
+	t.replayed[key] = idx + 1
+	t.mu.Unlock()
+
+	// Serve interactions in recorded order, repeating the last one once exhausted
+	if idx >= len(interactions) {
+		idx = len(interactions) - 1
+	}
+	recorded := interactions[idx].Response
+
+	header := make(http.Header, len(recorded.Headers))
+	for name, values := range recorded.Headers {
+		header[name] = append([]string(nil), values...)
+	}
+
+	var body io.ReadCloser
+	if len(recorded.Chunks) > 0 {
+		body = &replayBody{ctx: req.Context().Done(), chunks: recorded.Chunks, pace: t.pace}
+	} else {
+		body = io.NopCloser(bytes.NewReader(decodeBody(recorded.Body, recorded.BodyBase64)))
+	}
+
+	log.Debug().
+		Str("key", key).
+		Str("path", req.URL.Path).
+		Int("interaction", idx).
+		Msg("Replaying cassette interaction")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// path returns the cassette file for a key
+func (t *CassetteTransport) path(key string) string {
+	return filepath.Join(t.dir, key+".json")
+}
+
+// load reads all recorded interactions for a key
+func (t *CassetteTransport) load(key string) ([]cassetteInteraction, error) {
+	data, err := t.store.ReadFile(t.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cassette: failed to read %s: %w", t.path(key), err)
+	}
+
+	var interactions []cassetteInteraction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("cassette: failed to decode %s: %w", t.path(key), err)
+	}
+	return interactions, nil
+}
+
+// save appends an interaction to the cassette file for a key
+func (t *CassetteTransport) save(key string, interaction *cassetteInteraction) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	interactions, err := t.load(key)
+	if err != nil {
+		log.Warn().Err(err).Msg("Discarding unreadable cassette")
+	}
+	interactions = append(interactions, *interaction)
+
+	data, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode cassette")
+		return
+	}
+
+	if err := t.store.WriteFile(t.path(key), data); err != nil {
+		log.Error().Err(err).Msg("Failed to save cassette")
+		return
+	}
+
+	log.Debug().
+		Str("key", key).
+		Str("path", interaction.Request.Path).
+		Int("status", interaction.Response.StatusCode).
+		Msg("Recorded cassette interaction")
+}
+
+// recordingBody captures streamed reads along with their timing. The stream
+// is saved only once it has been read to the end: one cut short by a cancel
+// or a read error would otherwise replay as a complete answer.
+type recordingBody struct {
+	body   io.ReadCloser
+	chunks []cassetteChunk
+	last   time.Time
+	done   bool
+	onDone func(chunks []cassetteChunk)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		now := time.Now()
+		chunk := cassetteChunk{DelayMs: now.Sub(b.last).Milliseconds()}
+		chunk.Data, chunk.DataBase64 = encodeBody(append([]byte(nil), p[:n]...))
+		b.chunks = append(b.chunks, chunk)
+		b.last = now
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	if !b.done {
+		b.done = true
+		log.Debug().Int("chunks", len(b.chunks)).Msg("Discarding incomplete cassette stream")
+	}
+	return b.body.Close()
+}
+
+func (b *recordingBody) finish() {
+	if b.done {
+		return
+	}
+	b.done = true
+	b.onDone(b.chunks)
+}
+
+// replayBody serves recorded chunks, optionally sleeping for the recorded delays
+type replayBody struct {
+	ctx     <-chan struct{}
+	chunks  []cassetteChunk
+	pending []byte
+	pace    bool
+}
+
+func (b *replayBody) Read(p []byte) (int, error) {
+	if len(b.pending) == 0 {
+		if len(b.chunks) == 0 {
+			return 0, io.EOF
+		}
+		chunk := b.chunks[0]
+		b.chunks = b.chunks[1:]
+
+		if b.pace && chunk.DelayMs > 0 {
+			select {
+			case <-time.After(time.Duration(chunk.DelayMs) * time.Millisecond):
+			case <-b.ctx:
+				return 0, errors.New("cassette: request canceled")
+			}
+		}
+		b.pending = decodeBody(chunk.Data, chunk.DataBase64)
+	}
+
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *replayBody) Close() error {
+	return nil
+}
+
+// cassetteKey identifies a request by method, path, query and normalized body
+func cassetteKey(method, path, query, contentType string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	if query != "" {
+		h.Write([]byte("?" + query + "\n"))
+	}
+	if !hashMultipart(h, contentType, body) {
+		h.Write(normalizeBody(body))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// hashMultipart writes the parts of a multipart/form-data body to h,
+// without the boundary, which is random for every upload. It reports false
+// if the body is not multipart or cannot be parsed.
+func hashMultipart(h hash.Hash, contentType string, body []byte) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return false
+	}
+
+	var parts bytes.Buffer
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return false
+		}
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(&parts, "%q %q %q %x\n", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), sum)
+	}
+	h.Write(parts.Bytes())
+	return true
+}
+
+// normalizeBody re-encodes JSON bodies so key order does not affect matching
+func normalizeBody(body []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return normalized
+}
+
+// readRequestBody reads the request body and restores it for the next reader
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// redactHeaders flattens request headers and removes credentials
+func redactHeaders(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+	for name := range header {
+		switch strings.ToLower(name) {
+		case "authorization", "api-key", "openai-organization", "openai-project":
+			result[name] = redactedValue
+		default:
+			// SDK telemetry headers vary per machine and are not useful for replay
+			if strings.HasPrefix(strings.ToLower(name), "x-stainless") {
+				continue
+			}
+			result[name] = header.Get(name)
+		}
+	}
+	return result
+}
+
+// responseHeaders copies response headers worth replaying
+func responseHeaders(header http.Header) map[string][]string {
+	result := make(map[string][]string, len(header))
+	for name, values := range header {
+		if strings.EqualFold(name, "Set-Cookie") {
+			continue
+		}
+		result[name] = append([]string(nil), values...)
+	}
+	return result
+}
+
+// isEventStream reports whether a response is a Server-Sent Events stream
+func isEventStream(header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+}
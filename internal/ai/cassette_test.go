@@ -0,0 +1,320 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/storage"
+)
+
+const testCompletionJSON = `{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Recorded answer"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`
+
+func newCassetteTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), `"stream":true`) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			flusher := w.(http.Flusher)
+			for _, word := range []string{"Hello", " world"} {
+				fmt.Fprintf(w, "data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"created\":1700000000,\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", word)
+				flusher.Flush()
+				time.Sleep(20 * time.Millisecond)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testCompletionJSON))
+	}))
+}
+
+func TestCassetteTransport_RecordAndReplay(t *testing.T) {
+	server := newCassetteTestServer(t)
+	dir := filepath.Join(t.TempDir(), "cassettes")
+
+	recorder, err := NewCassetteTransport(CassetteRecord, dir, http.DefaultTransport, true)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: recorder}
+	req, _ := http.NewRequest("POST", server.URL+"/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","messages":[]}`))
+	req.Header.Set("Authorization", "Bearer sk-secret-key")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, testCompletionJSON, string(body))
+
+	// The API key must never reach the cassette
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.Len(t, files, 1)
+	data, _ := os.ReadFile(files[0])
+	assert.NotContains(t, string(data), "sk-secret-key")
+	assert.Contains(t, string(data), redactedValue)
+
+	// Prompts and responses are only readable by the user
+	info, err := os.Stat(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	server.Close()
+
+	replayer, err := NewCassetteTransport(CassetteReplay, dir, nil, true)
+	require.NoError(t, err)
+
+	// Key order in the body should not affect matching
+	client = &http.Client{Transport: replayer}
+	req, _ = http.NewRequest("POST", server.URL+"/v1/chat/completions", strings.NewReader(`{"messages":[],"model":"gpt-4o"}`))
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, testCompletionJSON, string(body))
+}
+
+// multipartRequest builds an upload like transcribe's, with a new random
+// boundary each time
+func multipartRequest(t *testing.T, url, audio string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("model", "whisper-1"))
+	file, err := writer.CreateFormFile("file", "speech.mp3")
+	require.NoError(t, err)
+	file.Write([]byte(audio))
+	require.NoError(t, writer.Close())
+
+	req, err := http.NewRequest("POST", url+"/v1/audio/transcriptions", &body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestCassetteTransport_Multipart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":"recorded transcript"}`))
+	}))
+	dir := t.TempDir()
+
+	recorder, err := NewCassetteTransport(CassetteRecord, dir, http.DefaultTransport, false)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: recorder}).Do(multipartRequest(t, server.URL, "audio bytes"))
+	require.NoError(t, err)
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	server.Close()
+
+	replayer, err := NewCassetteTransport(CassetteReplay, dir, nil, false)
+	require.NoError(t, err)
+	client := &http.Client{Transport: replayer}
+
+	// The boundary differs, but the same parts replay the recording
+	resp, err = client.Do(multipartRequest(t, server.URL, "audio bytes"))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, `{"text":"recorded transcript"}`, string(body))
+
+	// Different file contents are a different request
+	_, err = client.Do(multipartRequest(t, server.URL, "other audio"))
+	assert.ErrorIs(t, err, ErrCassetteMiss)
+}
+
+func TestCassetteTransport_BinaryBody(t *testing.T) {
+	// An MP3 frame header and bytes that are not valid UTF-8
+	audio := []byte{0xff, 0xfb, 0x90, 0x64, 0x00, 0x0f, 0xf0, 0x80, 0xc3, 0x28, 0xfe}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write(audio)
+	}))
+	dir := t.TempDir()
+
+	speech := func(transport http.RoundTripper) []byte {
+		req, err := http.NewRequest("POST", server.URL+"/v1/audio/speech", strings.NewReader(`{"model":"tts-1","input":"hi","voice":"alloy"}`))
+		require.NoError(t, err)
+		resp, err := (&http.Client{Transport: transport}).Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return body
+	}
+
+	recorder, err := NewCassetteTransport(CassetteRecord, dir, http.DefaultTransport, false)
+	require.NoError(t, err)
+	assert.Equal(t, audio, speech(recorder))
+	server.Close()
+
+	replayer, err := NewCassetteTransport(CassetteReplay, dir, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, audio, speech(replayer), "replayed audio is byte for byte what was recorded")
+}
+
+func TestCassetteTransport_Encrypted(t *testing.T) {
+	useEncryptedStorage(t)
+	server := newCassetteTestServer(t)
+	dir := t.TempDir()
+
+	complete := func(transport http.RoundTripper) string {
+		req, err := http.NewRequest("POST", server.URL+"/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"my secret prompt"}]}`))
+		require.NoError(t, err)
+		resp, err := (&http.Client{Transport: transport}).Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	recorder, err := NewCassetteTransport(CassetteRecord, dir, http.DefaultTransport, false)
+	require.NoError(t, err)
+	complete(recorder)
+	server.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.Len(t, files, 1)
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.True(t, storage.IsEncrypted(raw))
+	assert.NotContains(t, string(raw), "my secret prompt")
+	assert.NotContains(t, string(raw), "Recorded answer")
+
+	replayer, err := NewCassetteTransport(CassetteReplay, dir, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, testCompletionJSON, complete(replayer))
+}
+
+func TestCassetteTransport_DiscardsIncompleteStream(t *testing.T) {
+	server := newCassetteTestServer(t)
+	defer server.Close()
+	dir := t.TempDir()
+
+	recorder, err := NewCassetteTransport(CassetteRecord, dir, http.DefaultTransport, false)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: recorder}
+	req, _ := http.NewRequest("POST", server.URL+"/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","stream":true}`))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	// Stop after the first chunk, as a Ctrl-C would
+	_, err = resp.Body.Read(make([]byte, 16))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.Empty(t, files, "a partial stream must not be replayed as an answer")
+}
+
+func TestCassetteTransport_ReplayMiss(t *testing.T) {
+	replayer, err := NewCassetteTransport(CassetteReplay, t.TempDir(), nil, false)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: replayer}
+	req, _ := http.NewRequest("POST", "http://example.invalid/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o"}`))
+	_, err = client.Do(req)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrCassetteMiss)
+}
+
+func TestCassetteTransport_Query(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"object":"list","data":[],"after":%q}`, r.URL.Query().Get("after"))
+	}))
+	dir := t.TempDir()
+
+	list := func(transport http.RoundTripper, after string) (string, error) {
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/v1/models?after=" + after)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	recorder, err := NewCassetteTransport(CassetteRecord, dir, http.DefaultTransport, false)
+	require.NoError(t, err)
+	for _, page := range []string{"page1", "page2"} {
+		_, err := list(recorder, page)
+		require.NoError(t, err)
+	}
+	server.Close()
+
+	replayer, err := NewCassetteTransport(CassetteReplay, dir, nil, false)
+	require.NoError(t, err)
+	body, err := list(replayer, "page2")
+	require.NoError(t, err)
+	assert.Contains(t, body, `"after":"page2"`)
+
+	// A query that was never recorded is a miss, not another page's answer
+	_, err = list(replayer, "page3")
+	assert.ErrorIs(t, err, ErrCassetteMiss)
+}
+
+func TestCassetteTransport_InvalidMode(t *testing.T) {
+	_, err := NewCassetteTransport("bogus", t.TempDir(), nil, false)
+	assert.Error(t, err)
+
+	_, err = NewCassetteTransport(CassetteReplay, filepath.Join(t.TempDir(), "missing"), nil, false)
+	assert.Error(t, err)
+}
+
+func TestCassette_StreamingThroughClient(t *testing.T) {
+	server := newCassetteTestServer(t)
+	dir := t.TempDir()
+
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{
+			APIKey:  "sk-test-key",
+			Model:   "gpt-4o",
+			BaseURL: server.URL + "/v1",
+			Timeout: 10 * time.Second,
+		},
+		Cassette: config.CassetteConfig{Mode: CassetteRecord, Dir: dir, Pace: true},
+	}
+
+	collect := func(client *OpenAIClient) string {
+		chunks, err := client.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, ChatOptions{Model: "gpt-4o"})
+		require.NoError(t, err)
+		var sb strings.Builder
+		for chunk := range chunks {
+			require.NoError(t, chunk.Error)
+			sb.WriteString(chunk.Content)
+		}
+		return sb.String()
+	}
+
+	recordClient, err := NewOpenAIClient(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world", collect(recordClient))
+	recordClient.Close()
+	server.Close()
+
+	// Replay needs neither the network nor a real key
+	cfg.OpenAI.APIKey = ""
+	cfg.Cassette.Mode = CassetteReplay
+	replayClient, err := NewOpenAIClient(cfg)
+	require.NoError(t, err)
+	defer replayClient.Close()
+
+	start := time.Now()
+	assert.Equal(t, "Hello world", collect(replayClient))
+	assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond, "replay should honour recorded chunk timing")
+}
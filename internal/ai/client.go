@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"sync"
@@ -14,6 +15,8 @@ import (
 	"github.com/openai/openai-go/v2/shared"
 	"github.com/rs/zerolog/log"
 	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/tracing"
+	"github.com/user/terminal-ai/internal/utils"
 )
 
 // Client represents an AI client interface
@@ -63,6 +66,10 @@ type Response struct {
 	Created      time.Time `json:"created"`
 	ID           string    `json:"id,omitempty"`
 	Object       string    `json:"object,omitempty"`
+	// Semantic is set when the response was cached for a similar prompt
+	Semantic *SemanticMatch `json:"semantic,omitempty"`
+	// Stale is set when the response was served from an expired cache entry
+	Stale *StaleHit `json:"stale,omitempty"`
 }
 
 // Usage represents token usage information
@@ -74,9 +81,17 @@ type Usage struct {
 
 // StreamChunk represents a chunk of streamed response
 type StreamChunk struct {
-	Content string
-	Error   error
-	Done    bool
+	Content      string
+	Error        error
+	Done         bool
+	Usage        *Usage // Token usage, set on the final chunk when reported
+	FinishReason string // Set on the final chunk when reported
+	// Semantic is set on the first chunk of a stream replayed from the cache
+	// for a similar prompt
+	Semantic *SemanticMatch
+	// Stale is set on the first chunk of a stream replayed from an expired
+	// cache entry
+	Stale *StaleHit
 }
 
 // OpenAIClient implements Client interface for OpenAI
@@ -88,6 +103,12 @@ type OpenAIClient struct {
 	rateLimiter   *RateLimiter
 	retryConfig   RetryConfig
 	cache         Cache
+	flights       *FlightGroup
+	dedupStreams  bool
+	safety        *SafetyPolicy
+	metrics       *utils.MetricsCollector
+	interceptors  []Interceptor
+	handler       Handler
 	mu            sync.RWMutex
 	closed        bool
 }
@@ -113,25 +134,52 @@ type RateLimiter struct {
 
 // NewOpenAIClient creates a new OpenAI client with configuration
 func NewOpenAIClient(cfg *config.Config) (*OpenAIClient, error) {
-	if cfg.OpenAI.APIKey == "" {
+	apiKey := cfg.OpenAI.APIKey
+	if apiKey == "" && cfg.Cassette.Mode == CassetteReplay {
+		// Replayed runs never reach the API, so no real key is needed
+		apiKey = "replay"
+	}
+	if apiKey == "" && cfg.Cache.Offline {
+		// Neither do offline runs
+		apiKey = "offline"
+	}
+	if apiKey == "" {
 		return nil, errors.New("OpenAI API key is required")
 	}
 
 	// Create custom HTTP client with connection pooling
+	var transport http.RoundTripper = &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+		DisableCompression:  false,
+	}
+
+	// Wrap the transport for record/replay if configured
+	if cfg.Cassette.Mode != "" {
+		cassette, err := NewCassetteTransport(cfg.Cassette.Mode, cfg.Cassette.Dir, transport, cfg.Cassette.Pace)
+		if err != nil {
+			return nil, err
+		}
+		transport = cassette
+		log.Info().
+			Str("mode", cfg.Cassette.Mode).
+			Str("dir", cfg.Cassette.Dir).
+			Msg("Cassette transport enabled")
+	}
+
 	httpClient := &http.Client{
-		Timeout: cfg.OpenAI.Timeout,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-			DisableCompression:  false,
-		},
+		Timeout:   cfg.OpenAI.Timeout,
+		Transport: tracing.NewTransport(transport),
 	}
 
 	// Create OpenAI client with options
+	// Retries are handled by RetryInterceptor, which sees classified errors
+	// (quota exhaustion is not retried) and traces each attempt
 	opts := []option.RequestOption{
-		option.WithAPIKey(cfg.OpenAI.APIKey),
+		option.WithAPIKey(apiKey),
 		option.WithHTTPClient(httpClient),
+		option.WithMaxRetries(0),
 	}
 
 	if cfg.OpenAI.BaseURL != "" {
@@ -166,97 +214,189 @@ func NewOpenAIClient(cfg *config.Config) (*OpenAIClient, error) {
 		streamHandler: NewStreamHandler(openaiClient),
 		rateLimiter:   rateLimiter,
 		retryConfig:   retryConfig,
+		metrics:       utils.GetMetrics(),
+		dedupStreams:  cfg.Cache.DedupStreams,
 	}
 
 	// Initialize cache if enabled
 	if cfg.Cache.Enabled {
-		client.cache = NewInMemoryCache(&cfg.Cache)
+		cache, err := NewCache(&cfg.Cache)
+		if err != nil {
+			// A cache that cannot be opened should not stop requests
+			log.Warn().Err(err).Msg("Failed to open disk cache, using memory cache")
+			cache = NewInMemoryCache(&cfg.Cache)
+		}
+		client.cache = cache
 		log.Info().
 			Bool("enabled", true).
+			Str("backend", cfg.Cache.Backend).
 			Str("strategy", cfg.Cache.Strategy).
 			Int("max_size_mb", cfg.Cache.MaxSize).
 			Dur("ttl", cfg.Cache.TTL).
 			Msg("Cache initialized")
 	}
 
+	// Share API calls between concurrent identical requests if enabled
+	if cfg.Cache.Dedup {
+		client.flights = NewFlightGroup()
+	}
+
+	// Check prompts and responses against the safety policy if enabled
+	if cfg.Safety.Enabled {
+		moderate := ModerateFunc(client.moderate)
+		if cfg.Cache.Offline {
+			// The moderation endpoint cannot be reached offline
+			moderate = nil
+		}
+		policy, err := NewSafetyPolicy(cfg.Safety, moderate)
+		if err != nil {
+			return nil, err
+		}
+		client.safety = policy
+	}
+
+	client.buildHandler()
+
 	return client, nil
 }
 
 // Query sends a simple text query and returns the response
 func (c *OpenAIClient) Query(ctx context.Context, prompt string) (string, error) {
-	c.mu.RLock()
-	if c.closed {
-		c.mu.RUnlock()
-		return "", errors.New("client is closed")
+	messages := []Message{
+		{Role: "user", Content: prompt},
 	}
-	c.mu.RUnlock()
 
-	// Check cache first if enabled
-	if c.cache != nil {
-		cacheKey := c.cache.GenerateKey(prompt)
-		if cached, found := c.cache.Get(cacheKey); found {
-			log.Debug().
-				Str("key", cacheKey[:8]).
-				Int64("access_count", cached.AccessCount).
-				Msg("Cache hit for query")
-			return cached.Response.Content, nil
-		}
+	resp, err := c.Chat(ctx, messages, c.defaultOptions())
+	if err != nil {
+		return "", err
 	}
 
-	// Create messages for the query
+	return resp.Content, nil
+}
+
+// StreamQuery sends a query and streams the response token by token
+func (c *OpenAIClient) StreamQuery(ctx context.Context, prompt string, callback func(chunk string)) error {
 	messages := []Message{
 		{Role: "user", Content: prompt},
 	}
 
-	// Use default options from config
-	options := ChatOptions{
-		Model:           c.config.OpenAI.Model,
-		Temperature:     c.config.OpenAI.Temperature,
-		MaxTokens:       c.config.OpenAI.MaxTokens,
-		TopP:            c.config.OpenAI.TopP,
-		ReasoningEffort: c.config.OpenAI.ReasoningEffort,
-		ServiceTier:     c.config.OpenAI.ServiceTier,
+	chunks, err := c.ChatStream(ctx, messages, c.defaultOptions())
+	if err != nil {
+		return fmt.Errorf("failed to start stream: %w", err)
+	}
+
+	return processChunks(chunks, func(chunk string) error {
+		callback(chunk)
+		return nil
+	})
+}
+
+// Chat sends a chat request through the interceptor chain and returns the response
+func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, options ChatOptions) (*Response, error) {
+	handler, err := c.activeHandler()
+	if err != nil {
+		return nil, err
 	}
 
-	resp, err := c.Chat(ctx, messages, options)
+	return handler.Chat(ctx, c.chatRequest(messages, options, false))
+}
+
+// ChatStream sends a chat request through the interceptor chain and returns a stream of responses
+func (c *OpenAIClient) ChatStream(ctx context.Context, messages []Message, options ChatOptions) (<-chan StreamChunk, error) {
+	handler, err := c.activeHandler()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	return handler.ChatStream(ctx, c.chatRequest(messages, options, true))
+}
+
+// chatRequest builds the request Chat or ChatStream sends, applying defaults
+// for unset options. Streams leave N unset, as they return one choice.
+func (c *OpenAIClient) chatRequest(messages []Message, options ChatOptions, stream bool) *Request {
+	if options.Model == "" {
+		options.Model = c.config.OpenAI.Model
 	}
+	if !stream && options.N == 0 {
+		options.N = c.config.OpenAI.N
+	}
+	return &Request{Messages: messages, Options: options}
+}
+
+// Use adds interceptors around the client's built-in cache, rate limit and
+// retry interceptors. Interceptors run in the order given, outermost first,
+// inside the tracing span and after the safety policy for the call.
+func (c *OpenAIClient) Use(interceptors ...Interceptor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interceptors = append(c.interceptors, interceptors...)
+	c.buildHandler()
+}
 
-	// Cache the response if caching is enabled
-	if c.cache != nil && resp != nil {
-		cacheKey := c.cache.GenerateKey(prompt)
-		entry := &CacheEntry{
-			Response:       resp,
-			TokenUsage:     resp.Usage,
-			CreatedAt:      time.Now(),
-			LastAccessedAt: time.Now(),
-			AccessCount:    1,
+// SetStreamDedup sets whether concurrent identical streaming calls share one
+// upstream stream. Long-lived servers enable it; a single CLI invocation
+// rarely repeats a stream, and callers comparing samples may not want it.
+func (c *OpenAIClient) SetStreamDedup(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dedupStreams = enabled
+	c.buildHandler()
+}
+
+// buildHandler assembles the interceptor chain around the API handler.
+// The caller must hold c.mu for writing, or own c exclusively.
+func (c *OpenAIClient) buildHandler() {
+	interceptors := []Interceptor{TracingInterceptor()}
+	if c.safety != nil {
+		interceptors = append(interceptors, SafetyInterceptor(c.safety))
+	}
+	interceptors = append(interceptors, c.interceptors...)
+	interceptors = append(interceptors, LoggingInterceptor())
+	if c.cache != nil {
+		var semantic *SemanticLookup
+		if c.config.Cache.Semantic.Enabled && !c.config.Cache.Offline {
+			semantic = &SemanticLookup{Embed: c.embed, Threshold: c.config.Cache.Semantic.Threshold}
 		}
-		if err := c.cache.Set(cacheKey, entry, c.config.Cache.TTL); err != nil {
-			log.Warn().Err(err).Msg("Failed to cache response")
+		interceptors = append(interceptors, CacheInterceptor(c.cache, CacheOptions{
+			TTL:          c.config.Cache.TTL,
+			Pace:         c.config.Cache.PaceStreams,
+			Semantic:     semantic,
+			StaleOnError: c.config.Cache.StaleOnError,
+			Offline:      c.config.Cache.Offline,
+		}))
+	}
+	if c.flights != nil {
+		key := chatKey
+		if c.cache != nil {
+			key = c.cache.GenerateChatKey
 		}
+		interceptors = append(interceptors, DedupInterceptor(c.flights, key, c.dedupStreams))
 	}
+	interceptors = append(interceptors,
+		RateLimitInterceptor(c.rateLimiter),
+		RetryInterceptor(c.retryConfig),
+		MetricsInterceptor(c.metrics),
+	)
 
-	return resp.Content, nil
+	c.handler = Chain(&apiHandler{client: c}, interceptors...)
 }
 
-// StreamQuery sends a query and streams the response token by token
-func (c *OpenAIClient) StreamQuery(ctx context.Context, prompt string, callback func(chunk string)) error {
+// activeHandler returns the interceptor chain, or an error if the client is closed
+func (c *OpenAIClient) activeHandler() (Handler, error) {
 	c.mu.RLock()
-	if c.closed {
-		c.mu.RUnlock()
-		return errors.New("client is closed")
-	}
-	c.mu.RUnlock()
+	defer c.mu.RUnlock()
 
-	// Create messages for the query
-	messages := []Message{
-		{Role: "user", Content: prompt},
+	if c.closed {
+		return nil, errors.New("client is closed")
 	}
+	return c.handler, nil
+}
 
-	// Use default options from config
-	options := ChatOptions{
+// defaultOptions returns chat options populated from the configuration
+func (c *OpenAIClient) defaultOptions() ChatOptions {
+	return ChatOptions{
 		Model:           c.config.OpenAI.Model,
 		Temperature:     c.config.OpenAI.Temperature,
 		MaxTokens:       c.config.OpenAI.MaxTokens,
@@ -264,74 +404,88 @@ func (c *OpenAIClient) StreamQuery(ctx context.Context, prompt string, callback
 		ReasoningEffort: c.config.OpenAI.ReasoningEffort,
 		ServiceTier:     c.config.OpenAI.ServiceTier,
 	}
+}
 
-	// Convert to OpenAI messages
-	openaiMessages := c.convertMessages(messages)
-
-	// Process stream with callback
-	return c.streamHandler.ProcessStreamWithCallback(ctx, openaiMessages, options, func(chunk string) error {
-		callback(chunk)
-		return nil
-	})
+// apiHandler is the innermost handler, making a single call to the OpenAI API
+type apiHandler struct {
+	client *OpenAIClient
 }
 
-// Chat sends a chat request and returns the response with retry logic
-func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, options ChatOptions) (*Response, error) {
-	c.mu.RLock()
-	if c.closed {
-		c.mu.RUnlock()
-		return nil, errors.New("client is closed")
-	}
-	c.mu.RUnlock()
+// Chat creates a chat completion
+func (h *apiHandler) Chat(ctx context.Context, req *Request) (*Response, error) {
+	params := buildChatParams(h.client.convertMessages(req.Messages), req.Options)
 
-	// Check cache first if enabled
-	if c.cache != nil {
-		cacheKey := c.cache.GenerateChatKey(messages, options)
-		if cached, found := c.cache.Get(cacheKey); found {
-			log.Debug().
-				Str("key", cacheKey[:8]).
-				Int64("access_count", cached.AccessCount).
-				Msg("Cache hit for chat")
-			return cached.Response, nil
-		}
+	if config.IsReasoningModel(req.Options.Model) && req.Options.ReasoningEffort != "" {
+		log.Debug().
+			Str("model", req.Options.Model).
+			Str("reasoning_effort", req.Options.ReasoningEffort).
+			Str("service_tier", req.Options.ServiceTier).
+			Msg("Using reasoning model with effort level")
 	}
 
-	// Apply rate limiting
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		return nil, fmt.Errorf("rate limiting error: %w", err)
+	resp, err := h.client.client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return nil, classifyError(err)
 	}
 
-	// Convert messages to OpenAI format
-	openaiMessages := c.convertMessages(messages)
+	if len(resp.Choices) == 0 {
+		return nil, utils.NewAppError(utils.ErrCodeInvalidResponse, "no response choices returned", nil).
+			WithRequestID(resp.ID)
+	}
 
-	// Apply defaults if not specified
-	if options.Model == "" {
-		options.Model = c.config.OpenAI.Model
+	// Convert response
+	response := &Response{
+		Content:      resp.Choices[0].Message.Content,
+		Model:        resp.Model,
+		FinishReason: string(resp.Choices[0].FinishReason),
+		Created:      time.Unix(resp.Created, 0),
+		ID:           resp.ID,
+		Object:       string(resp.Object),
 	}
-	if options.N == 0 {
-		options.N = c.config.OpenAI.N
+
+	// Handle usage - it's a value, not a pointer
+	response.Usage = Usage{
+		PromptTokens:     int(resp.Usage.PromptTokens),
+		CompletionTokens: int(resp.Usage.CompletionTokens),
+		TotalTokens:      int(resp.Usage.TotalTokens),
 	}
 
-	// Create request parameters
+	return response, nil
+}
+
+// ChatStream opens a streaming chat completion
+func (h *apiHandler) ChatStream(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+	return h.client.streamHandler.HandleStream(ctx, h.client.convertMessages(req.Messages), req.Options)
+}
+
+// buildChatParams converts chat options into OpenAI request parameters
+func buildChatParams(messages []openai.ChatCompletionMessageParamUnion, options ChatOptions) openai.ChatCompletionNewParams {
 	params := openai.ChatCompletionNewParams{
 		Model:    shared.ChatModel(options.Model),
-		Messages: openaiMessages,
+		Messages: messages,
+	}
+
+	// Leave out parameters the model registry says the model rejects;
+	// models it does not know get everything
+	info, known := config.LookupModel(options.Model)
+	supports := func(parameter string) bool {
+		return !known || info.SupportsParameter(parameter)
 	}
 
 	// Add optional parameters
-	if options.Temperature > 0 {
+	if options.Temperature > 0 && supports("temperature") {
 		params.Temperature = openai.Float(float64(options.Temperature))
 	}
 	if options.MaxTokens > 0 {
 		params.MaxCompletionTokens = openai.Int(int64(options.MaxTokens))
 	}
-	if options.TopP > 0 {
+	if options.TopP > 0 && supports("top_p") {
 		params.TopP = openai.Float(float64(options.TopP))
 	}
-	if options.N > 0 {
+	if options.N > 0 && supports("n") {
 		params.N = openai.Int(int64(options.N))
 	}
-	if len(options.Stop) > 0 {
+	if len(options.Stop) > 0 && supports("stop") {
 		// Convert stop sequences to the union type
 		if len(options.Stop) == 1 {
 			params.Stop = openai.ChatCompletionNewParamsStopUnion{
@@ -343,10 +497,10 @@ func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, options Cha
 			}
 		}
 	}
-	if options.PresencePenalty != 0 {
+	if options.PresencePenalty != 0 && supports("presence_penalty") {
 		params.PresencePenalty = openai.Float(float64(options.PresencePenalty))
 	}
-	if options.FrequencyPenalty != 0 {
+	if options.FrequencyPenalty != 0 && supports("frequency_penalty") {
 		params.FrequencyPenalty = openai.Float(float64(options.FrequencyPenalty))
 	}
 	if options.User != "" {
@@ -376,7 +530,7 @@ func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, options Cha
 	}
 
 	// Handle ReasoningEffort for reasoning models
-	if config.IsReasoningModel(options.Model) && options.ReasoningEffort != "" {
+	if config.IsReasoningModel(options.Model) && options.ReasoningEffort != "" && supports("reasoning_effort") {
 		switch options.ReasoningEffort {
 		case "minimal":
 			params.ReasoningEffort = shared.ReasoningEffortMinimal
@@ -389,121 +543,9 @@ func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, options Cha
 		default:
 			params.ReasoningEffort = shared.ReasoningEffortMinimal
 		}
-		
-		log.Debug().
-			Str("model", options.Model).
-			Str("reasoning_effort", options.ReasoningEffort).
-			Str("service_tier", options.ServiceTier).
-			Msg("Using reasoning model with effort level")
 	}
 
-	var resp *openai.ChatCompletion
-	var err error
-
-	// Retry logic with exponential backoff
-	for attempt := 0; attempt <= c.retryConfig.MaxRetries; attempt++ {
-		resp, err = c.client.Chat.Completions.New(ctx, params)
-
-		if err == nil {
-			break // Success
-		}
-
-		// Check if error is retryable
-		if !c.isRetryableError(err) {
-			log.Error().Err(err).Msg("Non-retryable error in chat completion")
-			return nil, err
-		}
-
-		if attempt < c.retryConfig.MaxRetries {
-			delay := c.calculateBackoff(attempt)
-			log.Warn().
-				Err(err).
-				Int("attempt", attempt+1).
-				Dur("delay", delay).
-				Msg("Retrying chat completion")
-
-			select {
-			case <-time.After(delay):
-				// Continue to next attempt
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			}
-		}
-	}
-
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to create chat completion after retries")
-		return nil, err
-	}
-
-	if len(resp.Choices) == 0 {
-		return nil, errors.New("no response choices returned")
-	}
-
-	// Convert response
-	response := &Response{
-		Content:      resp.Choices[0].Message.Content,
-		Model:        resp.Model,
-		FinishReason: string(resp.Choices[0].FinishReason),
-		Created:      time.Unix(resp.Created, 0),
-		ID:           resp.ID,
-		Object:       string(resp.Object),
-	}
-	
-	// Handle usage - it's a value, not a pointer
-	response.Usage = Usage{
-		PromptTokens:     int(resp.Usage.PromptTokens),
-		CompletionTokens: int(resp.Usage.CompletionTokens),
-		TotalTokens:      int(resp.Usage.TotalTokens),
-	}
-
-	// Cache the response if caching is enabled
-	if c.cache != nil {
-		cacheKey := c.cache.GenerateChatKey(messages, options)
-		entry := &CacheEntry{
-			Response:       response,
-			TokenUsage:     response.Usage,
-			CreatedAt:      time.Now(),
-			LastAccessedAt: time.Now(),
-			AccessCount:    1,
-		}
-		if err := c.cache.Set(cacheKey, entry, c.config.Cache.TTL); err != nil {
-			log.Warn().Err(err).Msg("Failed to cache chat response")
-		}
-	}
-
-	return response, nil
-}
-
-// ChatStream sends a chat request and returns a stream of responses
-func (c *OpenAIClient) ChatStream(ctx context.Context, messages []Message, options ChatOptions) (<-chan StreamChunk, error) {
-	c.mu.RLock()
-	if c.closed {
-		c.mu.RUnlock()
-		return nil, errors.New("client is closed")
-	}
-	c.mu.RUnlock()
-
-	// Note: Streaming responses are not cached
-	if c.cache != nil {
-		log.Debug().Msg("Skipping cache for streaming response")
-	}
-
-	// Apply rate limiting
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		return nil, fmt.Errorf("rate limiting error: %w", err)
-	}
-
-	// Convert messages to OpenAI format
-	openaiMessages := c.convertMessages(messages)
-
-	// Apply defaults if not specified
-	if options.Model == "" {
-		options.Model = c.config.OpenAI.Model
-	}
-
-	// Delegate to stream handler
-	return c.streamHandler.HandleStream(ctx, openaiMessages, options)
+	return params
 }
 
 // ListModels lists available models
@@ -516,6 +558,7 @@ func (c *OpenAIClient) ListModels(ctx context.Context) ([]string, error) {
 	c.mu.RUnlock()
 
 	// Create a page iterator for models
+	start := time.Now()
 	iter := c.client.Models.ListAutoPaging(ctx)
 	
 	var modelNames []string
@@ -524,8 +567,10 @@ func (c *OpenAIClient) ListModels(ctx context.Context) ([]string, error) {
 		modelNames = append(modelNames, model.ID)
 	}
 	
-	if err := iter.Err(); err != nil {
-		return nil, err
+	err := iter.Err()
+	c.metrics.RecordAPICall(endpointModels, time.Since(start), statusCode(err), err)
+	if err != nil {
+		return nil, classifyError(err)
 	}
 
 	return modelNames, nil
@@ -587,12 +632,22 @@ func (c *OpenAIClient) convertMessages(messages []Message) []openai.ChatCompleti
 	return openaiMessages
 }
 
-// isRetryableError checks if an error is retryable
-func (c *OpenAIClient) isRetryableError(err error) bool {
+// calculateBackoff calculates the backoff duration for a retry attempt
+func (c *OpenAIClient) calculateBackoff(attempt int) time.Duration {
+	return c.retryConfig.Backoff(attempt)
+}
+
+// IsRetryable checks if an error is retryable
+func (r RetryConfig) IsRetryable(err error) bool {
 	if err == nil {
 		return false
 	}
 
+	// Quota errors share status 429 with rate limits but never clear on retry
+	if appErr := utils.GetAppError(err); appErr != nil && appErr.Code == utils.ErrCodeQuotaExceeded {
+		return false
+	}
+
 	// Check for specific OpenAI API errors
 	var apiErr *openai.Error
 	if errors.As(err, &apiErr) {
@@ -601,7 +656,7 @@ func (c *OpenAIClient) isRetryableError(err error) bool {
 			return true
 		}
 		// Server errors are retryable
-		for _, code := range c.retryConfig.RetryableHTTPCodes {
+		for _, code := range r.RetryableHTTPCodes {
 			if apiErr.StatusCode == code {
 				return true
 			}
@@ -616,11 +671,11 @@ func (c *OpenAIClient) isRetryableError(err error) bool {
 	return false
 }
 
-// calculateBackoff calculates the backoff duration for a retry attempt
-func (c *OpenAIClient) calculateBackoff(attempt int) time.Duration {
-	delay := float64(c.retryConfig.InitialDelay) * math.Pow(c.retryConfig.Multiplier, float64(attempt))
-	if delay > float64(c.retryConfig.MaxDelay) {
-		delay = float64(c.retryConfig.MaxDelay)
+// Backoff calculates the backoff duration for a retry attempt
+func (r RetryConfig) Backoff(attempt int) time.Duration {
+	delay := float64(r.InitialDelay) * math.Pow(r.Multiplier, float64(attempt))
+	if delay > float64(r.MaxDelay) {
+		delay = float64(r.MaxDelay)
 	}
 	return time.Duration(delay)
 }
@@ -644,53 +699,120 @@ func (c *OpenAIClient) ClearCache() error {
 // InvalidateCachePattern invalidates cache entries matching a pattern
 func (c *OpenAIClient) InvalidateCachePattern(pattern string) (int, error) {
 	if c.cache != nil {
-		if inMemCache, ok := c.cache.(*InMemoryCache); ok {
-			return inMemCache.InvalidatePattern(pattern)
+		if invalidator, ok := c.cache.(interface {
+			InvalidatePattern(pattern string) (int, error)
+		}); ok {
+			return invalidator.InvalidatePattern(pattern)
 		}
 	}
 	return 0, nil
 }
 
-// Wait implements rate limiting
-func (r *RateLimiter) Wait(ctx context.Context) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// CacheEntries returns the unexpired cache entries matching filter, oldest
+// first
+func (c *OpenAIClient) CacheEntries(filter CacheFilter) ([]CacheItem, error) {
+	if c.cache == nil {
+		return nil, nil
+	}
+	lister, ok := c.cache.(EntryLister)
+	if !ok {
+		return nil, fmt.Errorf("the cache cannot list its entries")
+	}
 
 	now := time.Now()
+	var items []CacheItem
+	for _, item := range lister.Entries() {
+		if filter.Matches(item.Entry, now) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
 
-	// Reset window if needed
-	if now.Sub(r.windowStart) >= time.Minute {
-		r.windowStart = now
-		r.requestCount = 0
+// RemoveCacheEntries removes the cache entries with the given keys and
+// returns how many were removed
+func (c *OpenAIClient) RemoveCacheEntries(keys []string) (int, error) {
+	if c.cache == nil {
+		return 0, nil
+	}
+	count := 0
+	for _, key := range keys {
+		if err := c.cache.Delete(key); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// ImportCache stores the entries exported to r by ExportEntries, returning
+// how many were imported and how many had expired
+func (c *OpenAIClient) ImportCache(r io.Reader) (int, int, error) {
+	if c.cache == nil {
+		return 0, 0, nil
 	}
+	return ImportEntries(r, c.cache)
+}
 
-	// Check rate limit
-	if r.requestCount >= r.requestsPerMin {
-		// Calculate wait time until next window
-		waitTime := time.Minute - now.Sub(r.windowStart)
-		select {
-		case <-time.After(waitTime):
-			r.windowStart = time.Now()
-			r.requestCount = 0
-		case <-ctx.Done():
-			return ctx.Err()
+// limited makes an API call with the client's retries, waiting on the rate
+// limiter before each attempt. Calls outside the chat interceptor chain,
+// such as images, audio and embeddings, all go through it.
+func (c *OpenAIClient) limited(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+	return retry(ctx, c.retryConfig, operation, func(ctx context.Context) error {
+		if err := waitTraced(ctx, c.rateLimiter); err != nil {
+			return err
 		}
+		return call(ctx)
+	})
+}
+
+// Wait implements rate limiting. Each caller reserves the next free slot
+// under the lock and sleeps until that slot outside the lock, so concurrent
+// callers wait side by side and each stops waiting as soon as its context is
+// done. A cancelled caller's slot is not handed back.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
+	r.mu.Lock()
+	now := time.Now()
+	slot := now
+
 	// Enforce minimum interval between requests
 	if !r.lastRequestTime.IsZero() {
-		elapsed := now.Sub(r.lastRequestTime)
-		if elapsed < r.minInterval {
-			waitTime := r.minInterval - elapsed
-			select {
-			case <-time.After(waitTime):
-			case <-ctx.Done():
-				return ctx.Err()
-			}
+		if next := r.lastRequestTime.Add(r.minInterval); next.After(slot) {
+			slot = next
 		}
 	}
 
-	r.lastRequestTime = time.Now()
+	// Reset window if needed
+	if slot.Sub(r.windowStart) >= time.Minute {
+		r.windowStart = slot
+		r.requestCount = 0
+	}
+
+	// Check rate limit, moving to the next window when this one is full
+	if r.requestCount >= r.requestsPerMin {
+		slot = r.windowStart.Add(time.Minute)
+		r.windowStart = slot
+		r.requestCount = 0
+	}
+
+	r.lastRequestTime = slot
 	r.requestCount++
-	return nil
+	r.mu.Unlock()
+
+	wait := slot.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
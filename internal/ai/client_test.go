@@ -156,6 +156,39 @@ func TestRateLimiterContextCancellation(t *testing.T) {
 	}
 }
 
+func TestRateLimiterConcurrentWaiters(t *testing.T) {
+	limiter := &RateLimiter{
+		minInterval:    time.Second,
+		requestsPerMin: 10,
+		windowStart:    time.Now(),
+	}
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("First Wait() failed: %v", err)
+	}
+
+	// One caller sleeps until its slot...
+	done := make(chan error, 1)
+	go func() { done <- limiter.Wait(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+
+	// ...without holding up another whose context ends first
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := limiter.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Wait() blocked behind another caller for %v", elapsed)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Concurrent Wait() failed: %v", err)
+	}
+}
+
 func TestConvertMessages(t *testing.T) {
 	client := &OpenAIClient{}
 
@@ -209,3 +242,33 @@ func TestClientClosed(t *testing.T) {
 		t.Errorf("Closing already closed client returned error: %v", err)
 	}
 }
+
+func TestBuildChatParamsSupportedParameters(t *testing.T) {
+	options := ChatOptions{Temperature: 0.5, TopP: 0.9, N: 1, ReasoningEffort: "high", Stop: []string{"END"}}
+
+	// gpt-5 snapshots take reasoning_effort but not sampling parameters
+	options.Model = "gpt-5-2025-08-07"
+	params := buildChatParams(nil, options)
+	if params.Temperature.Valid() || params.TopP.Valid() || len(params.Stop.OfStringArray) > 0 || params.Stop.OfString.Valid() {
+		t.Error("gpt-5 should not be sent temperature, top_p or stop")
+	}
+	if params.ReasoningEffort != "high" || !params.N.Valid() {
+		t.Errorf("gpt-5 should be sent reasoning_effort and n, got %q", params.ReasoningEffort)
+	}
+
+	options.Model = "gpt-4o"
+	params = buildChatParams(nil, options)
+	if !params.Temperature.Valid() || !params.TopP.Valid() || !params.Stop.OfString.Valid() {
+		t.Error("gpt-4o should be sent temperature, top_p and stop")
+	}
+	if params.ReasoningEffort != "" {
+		t.Errorf("gpt-4o should not be sent reasoning_effort, got %q", params.ReasoningEffort)
+	}
+
+	// Models the registry does not know get everything
+	options.Model = "local/llama"
+	params = buildChatParams(nil, options)
+	if !params.Temperature.Valid() || !params.TopP.Valid() {
+		t.Error("unknown models should be sent temperature and top_p")
+	}
+}
@@ -0,0 +1,111 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/terminal-ai/internal/tracing"
+)
+
+// ComparisonResult is the answer and measurements of one model in a comparison
+type ComparisonResult struct {
+	Model            string
+	Content          string
+	Latency          time.Duration // from sending the request to the end of the stream
+	TimeToFirstToken time.Duration // zero when no content arrived
+	Usage            Usage
+	Cost             float64
+	CostKnown        bool
+	Err              error
+}
+
+// CompareObserver receives progress while models are compared. Callbacks run
+// on the goroutine of each model and may be called concurrently.
+type CompareObserver struct {
+	OnChunk func(index int, content string)
+	OnDone  func(index int, result ComparisonResult)
+}
+
+// CompareModels sends the same messages with each set of options
+// concurrently through ChatStream and returns the results in the order of
+// options, each naming its model. Timings start
+// when a request passes the rate limiter, so queueing behind the other
+// models does not count as latency. Requests are made WithoutCache, so every
+// answer and measurement is live.
+func CompareModels(ctx context.Context, client Client, messages []Message, options []ChatOptions, observer CompareObserver) []ComparisonResult {
+	ctx = WithoutCache(ctx)
+	ctx, span := tracing.Start(ctx, "ai.compare")
+	defer span.End()
+	models := make([]string, len(options))
+	for i, modelOptions := range options {
+		models[i] = modelOptions.Model
+	}
+	span.SetAttribute("compare.models", strings.Join(models, ","))
+
+	results := make([]ComparisonResult, len(options))
+	var wg sync.WaitGroup
+	for i, modelOptions := range options {
+		wg.Add(1)
+		go func(i int, modelOptions ChatOptions) {
+			defer wg.Done()
+
+			results[i] = compareModel(ctx, client, messages, modelOptions, func(content string) {
+				if observer.OnChunk != nil {
+					observer.OnChunk(i, content)
+				}
+			})
+			if observer.OnDone != nil {
+				observer.OnDone(i, results[i])
+			}
+		}(i, modelOptions)
+	}
+	wg.Wait()
+	return results
+}
+
+// compareModel streams one model's answer and measures it
+func compareModel(ctx context.Context, client Client, messages []Message, options ChatOptions, onChunk func(string)) ComparisonResult {
+	result := ComparisonResult{Model: options.Model}
+
+	ctx, sentAt := WithSentAt(ctx)
+	start := time.Now()
+	since := func(now time.Time) time.Duration {
+		// Clients without a rate limiter never mark the request as sent
+		if sent := sentAt(); !sent.IsZero() {
+			return now.Sub(sent)
+		}
+		return now.Sub(start)
+	}
+
+	chunks, err := client.ChatStream(ctx, messages, options)
+	if err != nil {
+		result.Err = err
+		result.Latency = since(time.Now())
+		return result
+	}
+
+	var content strings.Builder
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			result.Err = chunk.Error
+			continue
+		}
+		if chunk.Content != "" {
+			if content.Len() == 0 {
+				result.TimeToFirstToken = since(time.Now())
+			}
+			content.WriteString(chunk.Content)
+			onChunk(chunk.Content)
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+	}
+
+	result.Latency = since(time.Now())
+	result.Content = content.String()
+	result.Cost, result.CostKnown = EstimateCost(options.Model, result.Usage)
+	return result
+}
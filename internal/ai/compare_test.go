@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/mock"
+)
+
+func TestCompareModels(t *testing.T) {
+	script, err := mock.ParseScript([]byte(`
+responses:
+  - model: gpt-4o
+    content: "answer from four o"
+  - model: local/llama
+    content: "llama says hi"
+  - model: broken
+    error: {status: 400, message: "unknown model"}
+`))
+	require.NoError(t, err)
+	server := httptest.NewServer(mock.NewServer(script).Handler())
+	defer server.Close()
+
+	// The default rate limiter spaces requests a second apart
+	client, err := NewOpenAIClient(&config.Config{
+		OpenAI: config.OpenAIConfig{APIKey: "test-key", Model: "gpt-4o", BaseURL: server.URL + "/v1", Timeout: 5 * time.Second},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	var mu sync.Mutex
+	streamed := make(map[int]string)
+	done := make(map[int]bool)
+	options := []ChatOptions{
+		{Model: "gpt-4o", MaxTokens: 100},
+		{Model: "local/llama", MaxTokens: 100},
+		{Model: "broken", MaxTokens: 100},
+	}
+	results := CompareModels(context.Background(), client, []Message{{Role: "user", Content: "hello"}}, options, CompareObserver{
+		OnChunk: func(index int, content string) {
+			mu.Lock()
+			defer mu.Unlock()
+			streamed[index] += content
+		},
+		OnDone: func(index int, result ComparisonResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			done[index] = true
+		},
+	})
+
+	require.Len(t, results, 3)
+	assert.Len(t, done, 3)
+
+	assert.Equal(t, "gpt-4o", results[0].Model)
+	assert.Equal(t, "answer from four o", results[0].Content)
+	assert.Equal(t, results[0].Content, streamed[0])
+	assert.Positive(t, results[0].Usage.CompletionTokens)
+	assert.True(t, results[0].CostKnown)
+	assert.Positive(t, results[0].Cost)
+	assert.Positive(t, results[0].TimeToFirstToken)
+	assert.LessOrEqual(t, results[0].TimeToFirstToken, results[0].Latency)
+
+	assert.Equal(t, "llama says hi", results[1].Content)
+	assert.False(t, results[1].CostKnown)
+
+	assert.Error(t, results[2].Err)
+	assert.Empty(t, results[2].Content)
+
+	// Time spent queued behind the rate limiter is not latency
+	for _, result := range results {
+		assert.Less(t, result.Latency, 900*time.Millisecond, result.Model)
+	}
+}
+
+func TestCompareModels_SkipsCache(t *testing.T) {
+	script, err := mock.ParseScript([]byte(`
+responses:
+  - content: "live answer"
+`))
+	require.NoError(t, err)
+	var requests atomic.Int32
+	mockHandler := mock.NewServer(script).Handler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/chat/completions" {
+			requests.Add(1)
+		}
+		mockHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client, err := NewOpenAIClient(&config.Config{
+		OpenAI: config.OpenAIConfig{APIKey: "test-key", Model: "gpt-4o", BaseURL: server.URL + "/v1", Timeout: 5 * time.Second},
+		Cache:  config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Dir: t.TempDir()},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	messages := []Message{{Role: "user", Content: "hello"}}
+	options := ChatOptions{Model: "gpt-4o", MaxTokens: 100}
+	_, err = client.Chat(context.Background(), messages, options)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), requests.Load())
+
+	// A cached answer would have no latency or usage worth comparing
+	results := CompareModels(context.Background(), client, messages, []ChatOptions{options}, CompareObserver{})
+	require.Len(t, results, 1)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "live answer", results[0].Content)
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Zero(t, client.cache.Stats().Hits)
+}
+
+func TestEstimateCost(t *testing.T) {
+	cost, ok := EstimateCost("gpt-4o", Usage{PromptTokens: 1000, CompletionTokens: 500})
+	require.True(t, ok)
+	assert.InDelta(t, 0.0075, cost, 1e-9)
+
+	// Snapshots use their base model's price
+	cost, ok = EstimateCost("gpt-4o-mini-2024-07-18", Usage{PromptTokens: 1000, CompletionTokens: 500})
+	require.True(t, ok)
+	assert.InDelta(t, 0.00045, cost, 1e-9)
+
+	_, ok = EstimateCost("local/llama", Usage{PromptTokens: 10})
+	assert.False(t, ok)
+	_, ok = EstimateCost("gpt-4oo", Usage{PromptTokens: 10})
+	assert.False(t, ok)
+}
@@ -0,0 +1,282 @@
+package ai
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/tracing"
+)
+
+// FlightGroup de-duplicates identical requests that are in flight at the
+// same time: the first caller makes the upstream call and later callers wait
+// for its result. Upstream calls run detached from the caller that started
+// them and are cancelled only when every waiting caller has gone.
+type FlightGroup struct {
+	mu      sync.Mutex
+	calls   map[string]*flightCall
+	streams map[string]*streamFlight
+}
+
+// NewFlightGroup creates an empty flight group
+func NewFlightGroup() *FlightGroup {
+	return &FlightGroup{
+		calls:   make(map[string]*flightCall),
+		streams: make(map[string]*streamFlight),
+	}
+}
+
+// flightCall is a unary call shared by its waiters
+type flightCall struct {
+	done    chan struct{}
+	resp    *Response
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Chat returns the result of fn for key, calling it only if no call for key
+// is in flight. shared reports whether the result came from another
+// caller's call.
+func (g *FlightGroup) Chat(ctx context.Context, key string, fn func(ctx context.Context) (*Response, error)) (resp *Response, shared bool, err error) {
+	g.mu.Lock()
+	call, shared := g.calls[key]
+	if shared {
+		call.waiters++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			defer cancel()
+			call.resp, call.err = fn(callCtx)
+
+			g.mu.Lock()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, shared, call.err
+		}
+		// Each caller gets its own copy of the response
+		copied := *call.resp
+		return &copied, shared, nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, shared, classifyError(ctx.Err())
+	}
+}
+
+// streamFlight is a stream shared by its subscribers. Chunks are kept so
+// subscribers that join late, or read slowly, see the whole stream.
+type streamFlight struct {
+	opened  chan struct{}
+	openErr error
+
+	mu          sync.Mutex
+	chunks      []StreamChunk
+	finished    bool
+	changed     chan struct{} // closed and replaced whenever chunks are added
+	subscribers int
+	cancel      context.CancelFunc
+}
+
+// ChatStream subscribes to the stream for key, opening it with fn only if
+// no stream for key is in flight. shared reports whether the stream was
+// opened by another caller.
+func (g *FlightGroup) ChatStream(ctx context.Context, key string, fn func(ctx context.Context) (<-chan StreamChunk, error)) (chunks <-chan StreamChunk, shared bool, err error) {
+	g.mu.Lock()
+	flight, shared := g.streams[key]
+	if shared {
+		flight.mu.Lock()
+		flight.subscribers++
+		flight.mu.Unlock()
+	} else {
+		streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		flight = &streamFlight{
+			opened:      make(chan struct{}),
+			changed:     make(chan struct{}),
+			subscribers: 1,
+			cancel:      cancel,
+		}
+		g.streams[key] = flight
+		go g.broadcast(streamCtx, key, flight, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-flight.opened:
+	case <-ctx.Done():
+		g.leave(key, flight)
+		return nil, shared, classifyError(ctx.Err())
+	}
+	if flight.openErr != nil {
+		return nil, shared, flight.openErr
+	}
+
+	out := make(chan StreamChunk, 100)
+	go g.subscribe(ctx, key, flight, out)
+	return out, shared, nil
+}
+
+// broadcast opens the upstream stream and records its chunks for the
+// subscribers
+func (g *FlightGroup) broadcast(ctx context.Context, key string, flight *streamFlight, fn func(ctx context.Context) (<-chan StreamChunk, error)) {
+	defer flight.cancel()
+
+	upstream, err := fn(ctx)
+	flight.openErr = err
+	close(flight.opened)
+	if err != nil {
+		g.forget(key, flight)
+		return
+	}
+
+	for chunk := range upstream {
+		flight.mu.Lock()
+		flight.chunks = append(flight.chunks, chunk)
+		close(flight.changed)
+		flight.changed = make(chan struct{})
+		flight.mu.Unlock()
+	}
+
+	// Later identical requests start a new call
+	g.forget(key, flight)
+	flight.mu.Lock()
+	flight.finished = true
+	close(flight.changed)
+	flight.mu.Unlock()
+}
+
+// subscribe copies the recorded chunks to out, waiting for new ones until
+// the stream finishes or the subscriber's context is done
+func (g *FlightGroup) subscribe(ctx context.Context, key string, flight *streamFlight, out chan<- StreamChunk) {
+	defer close(out)
+	defer g.leave(key, flight)
+
+	next := 0
+	for {
+		flight.mu.Lock()
+		pending := flight.chunks[next:]
+		finished := flight.finished
+		changed := flight.changed
+		flight.mu.Unlock()
+
+		for _, chunk := range pending {
+			select {
+			case out <- chunk:
+				next++
+			case <-ctx.Done():
+				g.cancelled(ctx, out)
+				return
+			}
+		}
+		if finished && len(pending) == 0 {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			g.cancelled(ctx, out)
+			return
+		}
+	}
+}
+
+// cancelled tells a subscriber that stopped early why, if it is still
+// listening
+func (g *FlightGroup) cancelled(ctx context.Context, out chan<- StreamChunk) {
+	select {
+	case out <- StreamChunk{Error: classifyError(ctx.Err()), Done: true}:
+	default:
+	}
+}
+
+// leave drops a subscriber, cancelling the upstream stream when it was the
+// last one and the stream has not finished
+func (g *FlightGroup) leave(key string, flight *streamFlight) {
+	// Holding the group lock keeps new subscribers from joining a stream
+	// that is being abandoned
+	g.mu.Lock()
+	flight.mu.Lock()
+	flight.subscribers--
+	abandoned := flight.subscribers == 0 && !flight.finished
+	flight.mu.Unlock()
+	if abandoned && g.streams[key] == flight {
+		delete(g.streams, key)
+	}
+	g.mu.Unlock()
+
+	if abandoned {
+		flight.cancel()
+	}
+}
+
+// forget removes a stream so later requests for key start a new one
+func (g *FlightGroup) forget(key string, flight *streamFlight) {
+	g.mu.Lock()
+	if g.streams[key] == flight {
+		delete(g.streams, key)
+	}
+	g.mu.Unlock()
+}
+
+// DedupInterceptor lets concurrent identical unary calls, and streaming
+// calls if streams is set, share one upstream call. Requests are identical
+// when key returns the same value for them. Calls made WithoutCache are
+// never shared.
+func DedupInterceptor(group *FlightGroup, key func(messages []Message, options ChatOptions) string, streams bool) Interceptor {
+	return func(next Handler) Handler {
+		handler := HandlerFuncs{
+			ChatFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				if cacheBypassed(ctx) {
+					return next.Chat(ctx, req)
+				}
+				flightKey := key(req.Messages, req.Options)
+				resp, shared, err := group.Chat(ctx, flightKey, func(ctx context.Context) (*Response, error) {
+					return next.Chat(ctx, req)
+				})
+				if shared {
+					tracing.SpanFromContext(ctx).SetAttribute("dedup.shared", true)
+					log.Debug().Ctx(ctx).Str("key", flightKey[:8]).Msg("Shared in-flight chat request")
+				}
+				return resp, err
+			},
+			ChatStreamFunc: next.ChatStream,
+		}
+		if streams {
+			handler.ChatStreamFunc = func(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+				if cacheBypassed(ctx) {
+					return next.ChatStream(ctx, req)
+				}
+				flightKey := key(req.Messages, req.Options)
+				chunks, shared, err := group.ChatStream(ctx, flightKey, func(ctx context.Context) (<-chan StreamChunk, error) {
+					return next.ChatStream(ctx, req)
+				})
+				if shared {
+					tracing.SpanFromContext(ctx).SetAttribute("dedup.shared", true)
+					log.Debug().Ctx(ctx).Str("key", flightKey[:8]).Msg("Joined in-flight chat stream")
+				}
+				return chunks, err
+			}
+		}
+		return handler
+	}
+}
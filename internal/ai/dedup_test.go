@@ -0,0 +1,263 @@
+package ai
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHandler holds every call until release is closed, counting calls
+// and recording whether their contexts were cancelled
+type blockingHandler struct {
+	calls     atomic.Int32
+	cancelled atomic.Int32
+	release   chan struct{}
+	chunks    chan StreamChunk
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{release: make(chan struct{}), chunks: make(chan StreamChunk)}
+}
+
+func (h *blockingHandler) Chat(ctx context.Context, req *Request) (*Response, error) {
+	h.calls.Add(1)
+	select {
+	case <-h.release:
+		return &Response{Content: "answer", Model: req.Options.Model}, nil
+	case <-ctx.Done():
+		h.cancelled.Add(1)
+		return nil, ctx.Err()
+	}
+}
+
+func (h *blockingHandler) ChatStream(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+	h.calls.Add(1)
+	return h.chunks, nil
+}
+
+// waitForWaiters waits until n callers share the unary call for key
+func waitForWaiters(t *testing.T, group *FlightGroup, key string, n int) {
+	require.Eventually(t, func() bool {
+		group.mu.Lock()
+		defer group.mu.Unlock()
+		call, ok := group.calls[key]
+		return ok && call.waiters == n
+	}, time.Second, time.Millisecond)
+}
+
+// waitForSubscribers waits until n callers subscribe to the stream for key
+func waitForSubscribers(t *testing.T, group *FlightGroup, key string, n int) {
+	require.Eventually(t, func() bool {
+		group.mu.Lock()
+		defer group.mu.Unlock()
+		flight, ok := group.streams[key]
+		if !ok {
+			return false
+		}
+		flight.mu.Lock()
+		defer flight.mu.Unlock()
+		return flight.subscribers == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestDedupInterceptor_SharesConcurrentCalls(t *testing.T) {
+	upstream := newBlockingHandler()
+	group := NewFlightGroup()
+	handler := Chain(upstream, DedupInterceptor(group, chatKey, false))
+	req := testRequest()
+
+	const callers = 5
+	var wg sync.WaitGroup
+	responses := make([]*Response, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := handler.Chat(context.Background(), req)
+			assert.NoError(t, err)
+			responses[i] = resp
+		}(i)
+	}
+
+	waitForWaiters(t, group, chatKey(req.Messages, req.Options), callers)
+	close(upstream.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), upstream.calls.Load())
+	for _, resp := range responses {
+		require.NotNil(t, resp)
+		assert.Equal(t, "answer", resp.Content)
+	}
+	assert.NotSame(t, responses[0], responses[1], "each caller should get its own response")
+
+	// Finished calls are not reused
+	_, err := handler.Chat(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), upstream.calls.Load())
+}
+
+func TestDedupInterceptor_DifferentRequests(t *testing.T) {
+	upstream := &fakeHandler{}
+	handler := Chain(upstream, DedupInterceptor(NewFlightGroup(), chatKey, false))
+
+	other := testRequest()
+	other.Options.Model = "gpt-5"
+	_, err := handler.Chat(context.Background(), testRequest())
+	require.NoError(t, err)
+	_, err = handler.Chat(context.Background(), other)
+	require.NoError(t, err)
+	assert.Equal(t, 2, upstream.calls)
+}
+
+func TestDedupInterceptor_WithoutCache(t *testing.T) {
+	upstream := newBlockingHandler()
+	handler := Chain(upstream, DedupInterceptor(NewFlightGroup(), chatKey, true))
+	ctx := WithoutCache(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := handler.Chat(ctx, testRequest())
+			assert.NoError(t, err)
+		}()
+	}
+	// Identical calls in flight at once each reach upstream
+	require.Eventually(t, func() bool { return upstream.calls.Load() == 2 }, time.Second, time.Millisecond)
+	close(upstream.release)
+	wg.Wait()
+}
+
+func TestDedupInterceptor_Cancellation(t *testing.T) {
+	upstream := newBlockingHandler()
+	group := NewFlightGroup()
+	handler := Chain(upstream, DedupInterceptor(group, chatKey, false))
+	req := testRequest()
+	key := chatKey(req.Messages, req.Options)
+
+	// The caller that started the call leaves; the other still gets the answer
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := handler.Chat(leaderCtx, req)
+		leaderErr <- err
+	}()
+	waitForWaiters(t, group, key, 1)
+
+	followerResp := make(chan *Response, 1)
+	go func() {
+		resp, _ := handler.Chat(context.Background(), req)
+		followerResp <- resp
+	}()
+	waitForWaiters(t, group, key, 2)
+
+	cancelLeader()
+	assert.Error(t, <-leaderErr)
+	close(upstream.release)
+	resp := <-followerResp
+	require.NotNil(t, resp)
+	assert.Equal(t, "answer", resp.Content)
+	assert.Equal(t, int32(0), upstream.cancelled.Load())
+
+	// When every caller leaves, the upstream call is cancelled
+	upstream = newBlockingHandler()
+	handler = Chain(upstream, DedupInterceptor(group, chatKey, false))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := handler.Chat(ctx, req)
+		done <- err
+	}()
+	waitForWaiters(t, group, key, 1)
+	cancel()
+	assert.Error(t, <-done)
+	assert.Eventually(t, func() bool { return upstream.cancelled.Load() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestDedupInterceptor_FansOutStreams(t *testing.T) {
+	upstream := newBlockingHandler()
+	group := NewFlightGroup()
+	handler := Chain(upstream, DedupInterceptor(group, chatKey, true))
+	req := testRequest()
+
+	first, err := handler.ChatStream(context.Background(), req)
+	require.NoError(t, err)
+	upstream.chunks <- StreamChunk{Content: "Hello"}
+
+	// A subscriber joining mid-stream still sees the whole answer
+	waitForSubscribers(t, group, chatKey(req.Messages, req.Options), 1)
+	second, err := handler.ChatStream(context.Background(), req)
+	require.NoError(t, err)
+	upstream.chunks <- StreamChunk{Content: " world"}
+	upstream.chunks <- StreamChunk{Done: true, Usage: &Usage{TotalTokens: 3}}
+	close(upstream.chunks)
+
+	collect := func(chunks <-chan StreamChunk) (string, *Usage) {
+		var content string
+		var usage *Usage
+		for chunk := range chunks {
+			assert.NoError(t, chunk.Error)
+			content += chunk.Content
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+		}
+		return content, usage
+	}
+	for _, chunks := range []<-chan StreamChunk{first, second} {
+		content, usage := collect(chunks)
+		assert.Equal(t, "Hello world", content)
+		require.NotNil(t, usage)
+		assert.Equal(t, 3, usage.TotalTokens)
+	}
+	assert.Equal(t, int32(1), upstream.calls.Load())
+}
+
+func TestDedupInterceptor_StreamsDisabled(t *testing.T) {
+	upstream := &fakeHandler{}
+	handler := Chain(upstream, DedupInterceptor(NewFlightGroup(), chatKey, false))
+
+	for i := 0; i < 2; i++ {
+		chunks, err := handler.ChatStream(context.Background(), testRequest())
+		require.NoError(t, err)
+		for range chunks {
+		}
+	}
+	assert.Equal(t, 2, upstream.calls)
+}
+
+func TestDedupInterceptor_StreamSubscriberLeaves(t *testing.T) {
+	upstream := newBlockingHandler()
+	group := NewFlightGroup()
+	handler := Chain(upstream, DedupInterceptor(group, chatKey, true))
+	req := testRequest()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaving, err := handler.ChatStream(ctx, req)
+	require.NoError(t, err)
+	staying, err := handler.ChatStream(context.Background(), req)
+	require.NoError(t, err)
+	waitForSubscribers(t, group, chatKey(req.Messages, req.Options), 2)
+
+	cancel()
+	var last StreamChunk
+	for chunk := range leaving {
+		last = chunk
+	}
+	assert.Error(t, last.Error)
+
+	// The remaining subscriber keeps receiving the stream
+	upstream.chunks <- StreamChunk{Content: "still here"}
+	close(upstream.chunks)
+	var content string
+	for chunk := range staying {
+		content += chunk.Content
+	}
+	assert.Equal(t, "still here", content)
+}
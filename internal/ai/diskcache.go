@@ -0,0 +1,818 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/storage"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// Disk cache files, inside the cache directory
+const (
+	diskCacheLog  = "cache.log"
+	diskCacheLock = "cache.lock"
+	// memoryCacheFile is the memory backend's file, imported by the disk
+	// cache the first time it opens the directory
+	memoryCacheFile = "cache.gob"
+)
+
+// maxPendingTouches is how many hits a cache records in memory before it
+// writes them to the log
+const maxPendingTouches = 64
+
+// compactMinBytes is the log size below which the log is never compacted
+const compactMinBytes = 1 << 20
+
+// DiskCache is a persistent cache shared by every process using the same
+// directory. Changes are appended to a log under an exclusive file lock and
+// synced before the call returns, so entries survive crashes and concurrent
+// processes see each other's writes. Before each operation the cache reads
+// what other processes appended since. The log is rewritten without stale
+// records when it grows well beyond its live entries.
+type DiskCache struct {
+	mu           sync.Mutex
+	logPath      string
+	lock         *os.File              // lock file, held while reading or writing the log
+	file         *os.File              // log, opened for appending
+	offset       int64                 // how far the log has been read
+	entries      map[string]*diskEntry // live entries by key
+	liveBytes    int64                 // log bytes of the live entries' records
+	currentSize  int64                 // estimated size of the live entries
+	maxSizeBytes int64
+	ttl          time.Duration
+	staleGrace   time.Duration  // how long expired entries are kept for stale serving
+	strategy     string         // eviction strategy
+	store        *storage.Store // encrypts records when storage encryption is on
+	plainRecords int            // unencrypted records read while encryption is on
+	touches      []diskRecord   // hits not yet written to the log
+	stats        CacheStats     // hits and misses are counted per process
+	metrics      *utils.MetricsCollector
+}
+
+// diskEntry is a live entry and the size of the record that stored it
+type diskEntry struct {
+	entry       *CacheEntry
+	recordBytes int64
+}
+
+// diskRecord is one line of the log
+type diskRecord struct {
+	Op    string      `json:"op"` // set, touch, delete or clear
+	Key   string      `json:"key,omitempty"`
+	Entry *CacheEntry `json:"entry,omitempty"`
+	At    int64       `json:"at,omitempty"` // when a touched entry was hit, in Unix nanoseconds
+}
+
+// NewDiskCache opens the disk cache in cfg.Dir, creating it if needed
+func NewDiskCache(cfg *config.CacheConfig) (*DiskCache, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("disk cache requires a cache directory")
+	}
+	if err := storage.MkdirPrivate(cfg.Dir); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(filepath.Join(cfg.Dir, diskCacheLock), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache lock: %w", err)
+	}
+
+	maxSizeBytes := int64(cfg.MaxSize) * 1024 * 1024
+	c := &DiskCache{
+		logPath:      filepath.Join(cfg.Dir, diskCacheLog),
+		lock:         lock,
+		entries:      make(map[string]*diskEntry),
+		maxSizeBytes: maxSizeBytes,
+		ttl:          cfg.TTL,
+		staleGrace:   cfg.StaleGrace,
+		strategy:     evictionPolicyFor(cfg.Strategy).Name(),
+		store:        storage.Default(),
+		stats: CacheStats{
+			MaxSizeBytes:      maxSizeBytes,
+			EvictionsByPolicy: make(map[string]int64),
+		},
+		metrics: utils.GetMetrics(),
+	}
+
+	err = c.withLock(false, func() error { return nil })
+	if err == nil && c.plainRecords > 0 {
+		// Encryption was turned on since the log was written
+		err = c.withLock(true, c.rewrite)
+	}
+	if _, statErr := os.Stat(filepath.Join(cfg.Dir, memoryCacheFile)); err == nil && statErr == nil {
+		err = c.withLock(true, func() error { return c.importMemoryCache(cfg.Dir) })
+	}
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	log.Debug().
+		Str("path", c.logPath).
+		Int("entries", len(c.entries)).
+		Int64("size_bytes", c.currentSize).
+		Msg("Disk cache opened")
+	return c, nil
+}
+
+// Get retrieves a cached entry, holding the lock shared so processes can
+// read at once. Hits are written to the log in batches, with the next write
+// or at most maxPendingTouches later, so eviction sees them in every process.
+func (c *DiskCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.lookupLocked(key)
+	if !found {
+		c.stats.Misses++
+		c.metrics.RecordCacheMiss()
+	}
+	return entry, found
+}
+
+// Lookup is Get without counting a miss, see SemanticIndex
+func (c *DiskCache) Lookup(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lookupLocked(key)
+}
+
+// RecordMiss counts a miss for lookups Lookup did not answer
+func (c *DiskCache) RecordMiss() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Misses++
+	c.metrics.RecordCacheMiss()
+}
+
+// lookupLocked returns a copy of the unexpired entry for key, counting the
+// hit and queueing it for the log. Misses are left to the caller.
+func (c *DiskCache) lookupLocked(key string) (*CacheEntry, bool) {
+	var found *diskEntry
+	err := c.withLock(false, func() error {
+		found = c.entries[key]
+		if found != nil && time.Now().After(found.entry.ExpiresAt) {
+			found = nil
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read disk cache")
+	}
+	if found == nil {
+		return nil, false
+	}
+
+	c.touches = append(c.touches, diskRecord{Op: "touch", Key: key, At: time.Now().UnixNano()})
+	if len(c.touches) >= maxPendingTouches {
+		if err := c.withLock(true, func() error { return nil }); err != nil {
+			log.Warn().Err(err).Msg("Failed to record cache hits")
+		}
+	}
+
+	c.stats.Hits++
+	c.metrics.RecordCacheHit()
+	// A copy, so callers read its access metadata without holding the lock
+	copied := *found.entry
+	return &copied, true
+}
+
+// Set stores an entry with ttl, or the default TTL when ttl is zero
+func (c *DiskCache) Set(key string, entry *CacheEntry, ttl time.Duration) error {
+	if entry == nil {
+		return errors.New("cannot cache nil entry")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.SizeBytes = estimateEntrySize(entry)
+	if entry.SizeBytes > c.maxSizeBytes {
+		return fmt.Errorf("entry size %d exceeds max cache size %d", entry.SizeBytes, c.maxSizeBytes)
+	}
+	if ttl == 0 {
+		ttl = c.ttl
+	}
+	entry.ExpiresAt = time.Now().Add(ttl)
+	entry.PromptHash = key
+	stampEntry(entry)
+
+	return c.withLock(true, func() error {
+		records := c.evictionsFor(key, entry.SizeBytes)
+		records = append(records, diskRecord{Op: "set", Key: key, Entry: entry})
+		if err := c.append(records...); err != nil {
+			return err
+		}
+		c.metrics.RecordCacheWrite()
+		return c.compactIfNeeded()
+	})
+}
+
+// Delete removes a specific cache entry
+func (c *DiskCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.withLock(true, func() error {
+		if _, ok := c.entries[key]; !ok {
+			return fmt.Errorf("key %s not found in cache", key)
+		}
+		return c.append(diskRecord{Op: "delete", Key: key})
+	})
+}
+
+// Clear removes all entries from the cache
+func (c *DiskCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.withLock(true, func() error {
+		c.reset()
+		c.stats.Evictions = 0
+		c.stats.EvictionsByPolicy = make(map[string]int64)
+		return c.rewrite()
+	})
+}
+
+// InvalidatePattern removes entries whose keys start with pattern
+func (c *DiskCache) InvalidatePattern(pattern string) (int, error) {
+	if pattern == "" {
+		return 0, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+	err := c.withLock(true, func() error {
+		var records []diskRecord
+		for key := range c.entries {
+			if strings.HasPrefix(key, pattern) {
+				records = append(records, diskRecord{Op: "delete", Key: key})
+			}
+		}
+		count = len(records)
+		return c.append(records...)
+	})
+	return count, err
+}
+
+// Nearest returns the unexpired entry in scope most similar to embedding,
+// across every process's entries
+func (c *DiskCache) Nearest(scope string, embedding Vector) (string, *CacheEntry, float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.withLock(false, func() error { return nil }); err != nil {
+		log.Warn().Err(err).Msg("Failed to read disk cache")
+	}
+	return nearestEntry(func(yield func(string, *CacheEntry) bool) {
+		for key, e := range c.entries {
+			if !yield(key, e.entry) {
+				return
+			}
+		}
+	}, scope, embedding)
+}
+
+// Entries returns every process's unexpired entries, oldest first
+func (c *DiskCache) Entries() []CacheItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.withLock(false, func() error { return nil }); err != nil {
+		log.Warn().Err(err).Msg("Failed to read disk cache")
+	}
+
+	now := time.Now()
+	items := make([]CacheItem, 0, len(c.entries))
+	for key, e := range c.entries {
+		if !now.After(e.entry.ExpiresAt) {
+			items = append(items, CacheItem{Key: key, Entry: e.entry})
+		}
+	}
+	sortItems(items)
+	return items
+}
+
+// Peek returns an unexpired entry, possibly stored by another process,
+// without counting a hit
+func (c *DiskCache) Peek(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.withLock(false, func() error { return nil }); err != nil {
+		log.Warn().Err(err).Msg("Failed to read disk cache")
+	}
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.entry.ExpiresAt) {
+		return nil, false
+	}
+	return e.entry, true
+}
+
+// GetStale returns an entry, possibly stored by another process, even if it
+// has expired, unless it is past the stale grace period. It does not count a
+// hit.
+func (c *DiskCache) GetStale(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.withLock(false, func() error { return nil }); err != nil {
+		log.Warn().Err(err).Msg("Failed to read disk cache")
+	}
+	e, ok := c.entries[key]
+	if !ok || pastGrace(e.entry, c.staleGrace, time.Now()) {
+		return nil, false
+	}
+	return e.entry, true
+}
+
+// Stats returns cache statistics. Entries and size cover every process;
+// hits, misses and evictions only this one.
+func (c *DiskCache) Stats() *CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.withLock(false, func() error { return nil }); err != nil {
+		log.Warn().Err(err).Msg("Failed to read disk cache")
+	}
+
+	stats := c.stats
+	stats.EvictionsByPolicy = copyEvictions(c.stats.EvictionsByPolicy)
+	stats.Entries = len(c.entries)
+	stats.SizeBytes = c.currentSize
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return &stats
+}
+
+// GenerateKey generates a cache key from prompt
+func (c *DiskCache) GenerateKey(prompt string) string {
+	return promptKey(prompt)
+}
+
+// GenerateChatKey generates a cache key from messages and options
+func (c *DiskCache) GenerateChatKey(messages []Message, options ChatOptions) string {
+	return chatKey(messages, options)
+}
+
+// Close closes the cache files. Entries are already on disk.
+func (c *DiskCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.touches) > 0 {
+		if err := c.withLock(true, func() error { return nil }); err != nil {
+			log.Warn().Err(err).Msg("Failed to record cache hits")
+		}
+	}
+
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+	return c.lock.Close()
+}
+
+// withLock runs fn holding the file lock, after catching up with the log.
+// Holding it exclusively, pending hits are written first. The caller must
+// hold c.mu.
+func (c *DiskCache) withLock(exclusive bool, fn func() error) error {
+	if err := lockFile(c.lock, exclusive); err != nil {
+		return fmt.Errorf("failed to lock cache: %w", err)
+	}
+	defer unlockFile(c.lock)
+
+	if err := c.refresh(); err != nil {
+		return err
+	}
+	if exclusive && len(c.touches) > 0 {
+		// Hits are bookkeeping, so they are not synced on their own
+		touches := c.touches
+		c.touches = nil
+		if err := c.write(false, touches...); err != nil {
+			return err
+		}
+	}
+	return fn()
+}
+
+// refresh applies records appended to the log since it was last read,
+// reloading it when another process has rewritten it
+func (c *DiskCache) refresh() error {
+	info, err := os.Stat(c.logPath)
+	if os.IsNotExist(err) {
+		// Nothing written yet, or the directory was removed
+		if c.file != nil {
+			c.file.Close()
+			c.file = nil
+			c.reset()
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	if c.file != nil {
+		if opened, err := c.file.Stat(); err != nil || !os.SameFile(info, opened) || info.Size() < c.offset {
+			c.file.Close()
+			c.file = nil
+		}
+	}
+	if c.file == nil {
+		file, err := os.OpenFile(c.logPath, os.O_RDWR|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open cache: %w", err)
+		}
+		c.file = file
+		c.reset()
+	}
+
+	if info.Size() > c.offset {
+		return c.readFrom(info.Size())
+	}
+	return nil
+}
+
+// readFrom applies the complete records between the read offset and size.
+// Records that fail their checksum, such as one torn by a crash, are skipped.
+func (c *DiskCache) readFrom(size int64) error {
+	reader := bufio.NewReader(io.NewSectionReader(c.file, c.offset, size-c.offset))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// An incomplete last line is left for when it is finished, or
+			// for a writer to terminate
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read cache: %w", err)
+		}
+		c.offset += int64(len(line))
+
+		record, sealed, err := decodeRecord(line, c.store)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			log.Debug().Int64("offset", c.offset).Msg("Skipping corrupt cache record")
+			continue
+		}
+		if !sealed && c.store.Encrypted() {
+			c.plainRecords++
+		}
+		c.apply(*record, int64(len(line)))
+	}
+}
+
+// apply updates the live entries with a record read from or written to the log
+func (c *DiskCache) apply(record diskRecord, recordBytes int64) {
+	switch record.Op {
+	case "set":
+		if record.Entry == nil {
+			return
+		}
+		c.remove(record.Key)
+		if !currentKeyVersion(record.Entry) {
+			// Stored under an older key format, so no key reaches it. The
+			// record is left for compaction to drop.
+			return
+		}
+		c.entries[record.Key] = &diskEntry{entry: record.Entry, recordBytes: recordBytes}
+		c.currentSize += record.Entry.SizeBytes
+		c.liveBytes += recordBytes
+	case "touch":
+		if existing, ok := c.entries[record.Key]; ok {
+			existing.entry.LastAccessedAt = time.Unix(0, record.At)
+			existing.entry.AccessCount++
+		}
+	case "delete":
+		c.remove(record.Key)
+	case "clear":
+		c.reset()
+	}
+}
+
+// remove drops a live entry
+func (c *DiskCache) remove(key string) {
+	if existing, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.currentSize -= existing.entry.SizeBytes
+		c.liveBytes -= existing.recordBytes
+	}
+}
+
+// reset forgets every live entry
+func (c *DiskCache) reset() {
+	c.entries = make(map[string]*diskEntry)
+	c.offset = 0
+	c.liveBytes = 0
+	c.currentSize = 0
+	c.plainRecords = 0
+}
+
+// evictionsFor returns delete records for the entries past the stale grace
+// period and, when room is needed for an entry of size stored under key, for
+// the other expired entries and then, in the order the eviction policy
+// chooses, for the live entries that must go
+func (c *DiskCache) evictionsFor(key string, size int64) []diskRecord {
+	now := time.Now()
+	var records []diskRecord
+	freed := int64(0)
+	if existing, ok := c.entries[key]; ok {
+		freed += existing.entry.SizeBytes
+	}
+
+	var stale, candidates []string
+	for k, e := range c.entries {
+		switch {
+		case k == key:
+		case pastGrace(e.entry, c.staleGrace, now):
+			records = append(records, diskRecord{Op: "delete", Key: k})
+			freed += e.entry.SizeBytes
+		case now.After(e.entry.ExpiresAt):
+			stale = append(stale, k)
+		default:
+			candidates = append(candidates, k)
+		}
+	}
+
+	if c.currentSize-freed+size > c.maxSizeBytes {
+		for _, k := range stale {
+			records = append(records, diskRecord{Op: "delete", Key: k})
+			freed += c.entries[k].entry.SizeBytes
+		}
+	}
+	if c.currentSize-freed+size > c.maxSizeBytes {
+		// The policy is rebuilt from the log for each eviction. Adding the
+		// least recently used entries first breaks ties the LRU way.
+		sort.Slice(candidates, func(i, j int) bool {
+			return c.entries[candidates[i]].entry.LastAccessedAt.Before(c.entries[candidates[j]].entry.LastAccessedAt)
+		})
+		policy := evictionPolicyFor(c.strategy)
+		for _, k := range candidates {
+			policy.Add(k, c.entries[k].entry)
+		}
+		for c.currentSize-freed+size > c.maxSizeBytes {
+			k, ok := policy.Victim()
+			if !ok {
+				break
+			}
+			records = append(records, diskRecord{Op: "delete", Key: k})
+			freed += c.entries[k].entry.SizeBytes
+			c.stats.Evictions++
+			c.stats.EvictionsByPolicy[policy.Name()]++
+			c.metrics.RecordCacheEviction()
+		}
+	}
+	return records
+}
+
+// append writes records to the end of the log and syncs it. The caller must
+// hold the exclusive lock and have caught up with the log.
+func (c *DiskCache) append(records ...diskRecord) error {
+	return c.write(true, records...)
+}
+
+// write writes records to the end of the log, syncing it if sync is set
+func (c *DiskCache) write(sync bool, records ...diskRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if c.file == nil {
+		file, err := os.OpenFile(c.logPath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open cache: %w", err)
+		}
+		c.file = file
+		c.reset()
+	}
+
+	var buf bytes.Buffer
+	// Terminate a record torn by a crash so it does not swallow the next one
+	if info, err := c.file.Stat(); err == nil && info.Size() > c.offset {
+		buf.WriteByte('\n')
+	}
+	lines := make([][]byte, len(records))
+	for i, record := range records {
+		line, err := encodeRecord(record, c.store)
+		if err != nil {
+			return err
+		}
+		lines[i] = line
+		buf.Write(line)
+	}
+
+	if _, err := c.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if sync {
+		if err := c.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync cache: %w", err)
+		}
+	}
+
+	info, err := c.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+	for i, record := range records {
+		c.apply(record, int64(len(lines[i])))
+	}
+	c.offset = info.Size()
+	return nil
+}
+
+// importMemoryCache moves the entries of the memory backend's file in dir
+// into the log, unless the log already has an entry for their key, and
+// removes the file, so switching backends keeps the cache. Entries that no
+// longer fit are left out. The caller must hold the exclusive lock.
+func (c *DiskCache) importMemoryCache(dir string) error {
+	path := filepath.Join(dir, memoryCacheFile)
+	raw, err := c.store.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	var data map[string]*CacheEntry
+	if err == nil {
+		err = gob.NewDecoder(bytes.NewReader(raw)).Decode(&data)
+	}
+	if err != nil {
+		// Left in place for the memory backend, or to be removed by hand
+		log.Warn().Err(err).Str("path", path).Msg("Failed to import memory cache")
+		return nil
+	}
+
+	// Newest first, so a full cache keeps the most recent answers
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return data[keys[i]].CreatedAt.After(data[keys[j]].CreatedAt) })
+
+	now := time.Now()
+	size := c.currentSize
+	var records []diskRecord
+	for _, key := range keys {
+		entry := data[key]
+		if _, ok := c.entries[key]; ok || entry == nil || entry.Response == nil ||
+			!currentKeyVersion(entry) || pastGrace(entry, c.staleGrace, now) || size+entry.SizeBytes > c.maxSizeBytes {
+			continue
+		}
+		records = append(records, diskRecord{Op: "set", Key: key, Entry: entry})
+		size += entry.SizeBytes
+	}
+	if err := c.append(records...); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove imported cache file: %w", err)
+	}
+	log.Info().
+		Int("entries", len(records)).
+		Str("path", path).
+		Msg("Imported memory cache into disk cache")
+	return nil
+}
+
+// compactIfNeeded rewrites the log when stale records make up most of it
+func (c *DiskCache) compactIfNeeded() error {
+	if c.offset < compactMinBytes || c.offset < 2*c.liveBytes {
+		return nil
+	}
+	log.Debug().
+		Int64("log_bytes", c.offset).
+		Int64("live_bytes", c.liveBytes).
+		Msg("Compacting disk cache")
+	return c.rewrite()
+}
+
+// rewrite replaces the log with one holding only the live entries that are
+// unexpired or within the stale grace period. The new log is written to a temporary file and renamed into
+// place, so readers see either the old log or the new one.
+func (c *DiskCache) rewrite() error {
+	tempPath := c.logPath + ".tmp"
+	temp, err := os.OpenFile(tempPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to compact cache: %w", err)
+	}
+
+	now := time.Now()
+	writer := bufio.NewWriter(temp)
+	for key, e := range c.entries {
+		if pastGrace(e.entry, c.staleGrace, now) {
+			continue
+		}
+		line, err := encodeRecord(diskRecord{Op: "set", Key: key, Entry: e.entry}, c.store)
+		if err == nil {
+			_, err = writer.Write(line)
+		}
+		if err != nil {
+			temp.Close()
+			os.Remove(tempPath)
+			return fmt.Errorf("failed to compact cache: %w", err)
+		}
+	}
+	if err := writer.Flush(); err == nil {
+		err = temp.Sync()
+	}
+	temp.Close()
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to compact cache: %w", err)
+	}
+	if err := os.Rename(tempPath, c.logPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to compact cache: %w", err)
+	}
+
+	// Read the new log back in
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+	return c.refresh()
+}
+
+// encodeRecord encodes a record as a log line: a CRC-32 of the payload, the
+// payload, and a newline. The payload is the record's JSON or, when store
+// encrypts, the sealed JSON in base64.
+func encodeRecord(record diskRecord, store *storage.Store) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cache record: %w", err)
+	}
+	if store.Encrypted() {
+		sealed, err := store.Seal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt cache record: %w", err)
+		}
+		data = base64.StdEncoding.AppendEncode(nil, sealed)
+	}
+	line := make([]byte, 0, len(data)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.ChecksumIEEE(data))
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+// decodeRecord decodes a log line, reporting whether it was encrypted. A
+// corrupt line decodes to nil. An encrypted line is an error when store does
+// not encrypt, rather than skipped, so the records are not compacted away.
+func decodeRecord(line []byte, store *storage.Store) (*diskRecord, bool, error) {
+	line = bytes.TrimRight(line, "\n")
+	if len(line) < 10 || line[8] != ' ' {
+		return nil, false, nil
+	}
+	var sum uint32
+	if _, err := fmt.Sscanf(string(line[:8]), "%08x", &sum); err != nil {
+		return nil, false, nil
+	}
+	data := line[9:]
+	if crc32.ChecksumIEEE(data) != sum {
+		return nil, false, nil
+	}
+
+	// JSON payloads start with a brace, which base64 never contains
+	sealed := data[0] != '{'
+	if sealed {
+		decoded, err := base64.StdEncoding.AppendDecode(nil, data)
+		if err != nil {
+			return nil, false, nil
+		}
+		if !store.Encrypted() {
+			// Open reports that encryption is off
+			_, err := store.Open(decoded)
+			return nil, true, err
+		}
+		if data, err = store.Open(decoded); err != nil {
+			return nil, true, nil
+		}
+	}
+
+	var record diskRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, sealed, nil
+	}
+	return &record, sealed, nil
+}
@@ -0,0 +1,436 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/storage"
+)
+
+func diskCacheConfig(t *testing.T) *config.CacheConfig {
+	return &config.CacheConfig{
+		Enabled: true,
+		TTL:     5 * time.Minute,
+		MaxSize: 10, // 10 MB
+		Dir:     t.TempDir(),
+		Backend: "disk",
+	}
+}
+
+func openDiskCache(t *testing.T, cfg *config.CacheConfig) *DiskCache {
+	cache, err := NewDiskCache(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func diskEntryFor(content string) *CacheEntry {
+	return &CacheEntry{
+		Response:       &Response{Content: content, Model: "gpt-5-mini", Usage: Usage{TotalTokens: 3}},
+		Chunks:         []CachedChunk{{Content: content}},
+		CreatedAt:      time.Now(),
+		LastAccessedAt: time.Now(),
+	}
+}
+
+func TestDiskCache_BasicOperations(t *testing.T) {
+	cache := openDiskCache(t, diskCacheConfig(t))
+
+	key := cache.GenerateKey("test prompt")
+	require.NoError(t, cache.Set(key, diskEntryFor("Test response"), 0))
+
+	cached, found := cache.Get(key)
+	require.True(t, found)
+	assert.Equal(t, "Test response", cached.Response.Content)
+	assert.Equal(t, 3, cached.Response.Usage.TotalTokens)
+	require.Len(t, cached.Chunks, 1)
+
+	_, found = cache.Get("missing")
+	assert.False(t, found)
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Positive(t, stats.SizeBytes)
+
+	require.NoError(t, cache.Delete(key))
+	_, found = cache.Get(key)
+	assert.False(t, found)
+	assert.Error(t, cache.Delete(key))
+}
+
+func TestDiskCache_SharedBetweenInstances(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	first := openDiskCache(t, cfg)
+	second := openDiskCache(t, cfg)
+
+	require.NoError(t, first.Set("a", diskEntryFor("from first"), 0))
+	cached, found := second.Get("a")
+	require.True(t, found, "writes should be visible to other instances")
+	assert.Equal(t, "from first", cached.Response.Content)
+
+	require.NoError(t, second.Set("b", diskEntryFor("from second"), 0))
+	require.NoError(t, second.Delete("a"))
+	_, found = first.Get("a")
+	assert.False(t, found)
+	_, found = first.Get("b")
+	assert.True(t, found)
+
+	require.NoError(t, first.Clear())
+	_, found = second.Get("b")
+	assert.False(t, found)
+	assert.Equal(t, 0, second.Stats().Entries)
+}
+
+func TestDiskCache_Persistence(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cache, err := NewDiskCache(cfg)
+	require.NoError(t, err)
+	require.NoError(t, cache.Set("kept", diskEntryFor("kept"), 0))
+	require.NoError(t, cache.Set("expired", diskEntryFor("expired"), time.Millisecond))
+	// No Close: entries are written through, so a crash loses nothing
+
+	time.Sleep(5 * time.Millisecond)
+	reopened := openDiskCache(t, cfg)
+	cached, found := reopened.Get("kept")
+	require.True(t, found)
+	assert.Equal(t, "kept", cached.Response.Content)
+	_, found = reopened.Get("expired")
+	assert.False(t, found)
+	cache.Close()
+}
+
+func TestDiskCache_TornRecord(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cache := openDiskCache(t, cfg)
+	require.NoError(t, cache.Set("a", diskEntryFor("first"), 0))
+
+	// Simulate a process dying halfway through appending a record
+	logPath := filepath.Join(cfg.Dir, diskCacheLog)
+	f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`1234abcd {"op":"set","key":"torn","entry":{"Resp`)
+	require.NoError(t, err)
+	f.Close()
+
+	// Records after the torn one are still read
+	require.NoError(t, cache.Set("b", diskEntryFor("second"), 0))
+	reopened := openDiskCache(t, cfg)
+	for _, key := range []string{"a", "b"} {
+		_, found := reopened.Get(key)
+		assert.True(t, found, key)
+	}
+	_, found := reopened.Get("torn")
+	assert.False(t, found)
+
+	// A record with a bad checksum is skipped
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	corrupted := strings.Replace(string(data), "second", "sec0nd", 1)
+	require.NoError(t, os.WriteFile(logPath, []byte(corrupted), 0600))
+	reopened = openDiskCache(t, cfg)
+	_, found = reopened.Get("b")
+	assert.False(t, found)
+	_, found = reopened.Get("a")
+	assert.True(t, found)
+}
+
+func TestDiskCache_Eviction(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cfg.MaxSize = 1 // 1 MB
+	cache := openDiskCache(t, cfg)
+
+	large := strings.Repeat("x", 200*1024) // counted twice, as response and chunk
+	for i := 0; i < 3; i++ {
+		require.NoError(t, cache.Set(fmt.Sprintf("key%d", i), diskEntryFor(large), 0))
+	}
+
+	_, found := cache.Get("key0")
+	assert.False(t, found, "oldest entry should be evicted")
+	_, found = cache.Get("key2")
+	assert.True(t, found)
+	stats := cache.Stats()
+	assert.LessOrEqual(t, stats.SizeBytes, stats.MaxSizeBytes)
+	assert.Equal(t, int64(1), stats.Evictions)
+}
+
+func TestDiskCache_Compaction(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cache := openDiskCache(t, cfg)
+	other := openDiskCache(t, cfg)
+
+	// Overwriting one key leaves stale records until the log is compacted
+	content := strings.Repeat("y", 64*1024)
+	for i := 0; i < 40; i++ {
+		require.NoError(t, cache.Set("same", diskEntryFor(fmt.Sprintf("%s%d", content, i)), 0))
+	}
+
+	info, err := os.Stat(filepath.Join(cfg.Dir, diskCacheLog))
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(compactMinBytes)*2)
+
+	// Other instances pick up the rewritten log
+	cached, found := other.Get("same")
+	require.True(t, found)
+	assert.True(t, strings.HasSuffix(cached.Response.Content, "39"))
+	assert.Equal(t, 1, other.Stats().Entries)
+}
+
+func TestDiskCache_InvalidatePattern(t *testing.T) {
+	cache := openDiskCache(t, diskCacheConfig(t))
+
+	for _, key := range []string{"user:1", "user:2", "session:1"} {
+		require.NoError(t, cache.Set(key, diskEntryFor(key), 0))
+	}
+
+	count, err := cache.InvalidatePattern("user:")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	_, found := cache.Get("session:1")
+	assert.True(t, found)
+	assert.Equal(t, 1, cache.Stats().Entries)
+}
+
+func TestDiskCache_ConcurrentInstances(t *testing.T) {
+	cfg := diskCacheConfig(t)
+
+	// Each writer has its own instance, like separate processes would
+	const writers, perWriter = 4, 10
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		cache := openDiskCache(t, cfg)
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				assert.NoError(t, cache.Set(fmt.Sprintf("w%d-%d", w, i), diskEntryFor("value"), 0))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	reader := openDiskCache(t, cfg)
+	assert.Equal(t, writers*perWriter, reader.Stats().Entries)
+}
+
+func TestDiskCache_GetSharesLock(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cache := openDiskCache(t, cfg)
+	require.NoError(t, cache.Set("key", diskEntryFor("value"), 0))
+
+	// Another process reading the log holds the lock shared
+	reader, err := os.Open(filepath.Join(cfg.Dir, diskCacheLock))
+	require.NoError(t, err)
+	defer reader.Close()
+	require.NoError(t, lockFile(reader, false))
+	defer unlockFile(reader)
+
+	done := make(chan bool)
+	go func() {
+		_, found := cache.Get("key")
+		done <- found
+	}()
+	select {
+	case found := <-done:
+		assert.True(t, found)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Get waited for a shared lock to be released")
+	}
+}
+
+func TestDiskCache_BatchesHits(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cache := openDiskCache(t, cfg)
+	require.NoError(t, cache.Set("key", diskEntryFor("value"), 0))
+	logPath := filepath.Join(cfg.Dir, diskCacheLog)
+	size := func() int64 {
+		info, err := os.Stat(logPath)
+		require.NoError(t, err)
+		return info.Size()
+	}
+	before := size()
+
+	// Hits are not written one by one
+	for i := 0; i < maxPendingTouches-1; i++ {
+		_, found := cache.Get("key")
+		require.True(t, found)
+	}
+	assert.Equal(t, before, size())
+
+	// but once enough have piled up
+	cache.Get("key")
+	assert.Greater(t, size(), before)
+	other := openDiskCache(t, cfg)
+	entry, found := other.Peek("key")
+	require.True(t, found)
+	assert.Equal(t, int64(maxPendingTouches), entry.AccessCount)
+
+	// and when the cache is closed
+	cache.Get("key")
+	require.NoError(t, cache.Close())
+	entry, found = openDiskCache(t, cfg).Peek("key")
+	require.True(t, found)
+	assert.Equal(t, int64(maxPendingTouches+1), entry.AccessCount)
+}
+
+func TestDiskCache_ImportsMemoryCache(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cfg.Backend = "memory"
+	memory := NewInMemoryCache(cfg)
+	require.NoError(t, memory.Set("remembered", diskEntryFor("docker ps"), 0))
+	require.NoError(t, memory.Close())
+	require.FileExists(t, filepath.Join(cfg.Dir, memoryCacheFile))
+
+	// Switching to the disk backend keeps the cache, importing it once
+	cfg.Backend = "disk"
+	cache := openDiskCache(t, cfg)
+	entry, found := cache.Get("remembered")
+	require.True(t, found)
+	assert.Equal(t, "docker ps", entry.Response.Content)
+	assert.NoFileExists(t, filepath.Join(cfg.Dir, memoryCacheFile))
+
+	require.NoError(t, cache.Delete("remembered"))
+	_, found = openDiskCache(t, cfg).Get("remembered")
+	assert.False(t, found, "the import is not repeated")
+}
+
+func TestNewCache(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cache, err := NewCache(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &DiskCache{}, cache)
+	cache.Close()
+
+	cfg.Backend = "memory"
+	cache, err = NewCache(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &InMemoryCache{}, cache)
+	cache.Close()
+
+	// Without a directory there is nowhere to keep a disk cache
+	cfg = diskCacheConfig(t)
+	cfg.Dir = ""
+	cache, err = NewCache(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &InMemoryCache{}, cache)
+	cache.Close()
+}
+
+// useEncryptedStorage makes the caches opened by the test encrypt what they
+// write, and returns the storage config
+func useEncryptedStorage(t *testing.T) config.StorageConfig {
+	cfg := config.StorageConfig{
+		Encrypt:   true,
+		KeySource: storage.SourceEnv,
+		KeyEnv:    "TERMINAL_AI_STORAGE_KEY",
+		KeyFile:   filepath.Join(t.TempDir(), "storage.key"),
+	}
+	store, err := storage.UnlockWith(cfg, storage.Secrets{Getenv: func(string) string { return "test secret" }})
+	require.NoError(t, err)
+
+	previous := storage.Default()
+	storage.SetDefault(store)
+	t.Cleanup(func() { storage.SetDefault(previous) })
+	return cfg
+}
+
+func TestDiskCache_Encrypted(t *testing.T) {
+	cfg := diskCacheConfig(t)
+
+	// A log written before encryption was turned on
+	plain := openDiskCache(t, cfg)
+	require.NoError(t, plain.Set("before", diskEntryFor("docker ps --all"), 0))
+	plain.Close()
+
+	useEncryptedStorage(t)
+	cache := openDiskCache(t, cfg)
+	entry, found := cache.Get("before")
+	require.True(t, found)
+	assert.Equal(t, "docker ps --all", entry.Response.Content)
+	require.NoError(t, cache.Set("after", diskEntryFor("kubectl get pods"), 0))
+
+	// Opening rewrote the plaintext records, and new ones are encrypted
+	raw, err := os.ReadFile(filepath.Join(cfg.Dir, diskCacheLog))
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "docker ps")
+	assert.NotContains(t, string(raw), "kubectl")
+
+	info, err := os.Stat(filepath.Join(cfg.Dir, diskCacheLog))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	reopened := openDiskCache(t, cfg)
+	assert.Len(t, reopened.Entries(), 2)
+
+	// Without the key the cache refuses to open rather than dropping entries
+	storage.SetDefault(&storage.Store{})
+	_, err = NewDiskCache(cfg)
+	assert.ErrorContains(t, err, "encryption is off")
+}
+
+func TestCache_Reseal(t *testing.T) {
+	secrets := storage.Secrets{Getenv: func(string) string { return "test secret" }}
+
+	t.Run("Disk", func(t *testing.T) {
+		storageCfg := useEncryptedStorage(t)
+		cfg := diskCacheConfig(t)
+		cache := openDiskCache(t, cfg)
+		require.NoError(t, cache.Set("key", diskEntryFor("docker ps --all"), 0))
+
+		rotated, err := storage.Default().Rotate(storageCfg, secrets, "", cache.Reseal)
+		require.NoError(t, err)
+
+		// Records under the old key would be skipped by the new one
+		storage.SetDefault(rotated)
+		reopened := openDiskCache(t, cfg)
+		entry, found := reopened.Get("key")
+		require.True(t, found)
+		assert.Equal(t, "docker ps --all", entry.Response.Content)
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		storageCfg := useEncryptedStorage(t)
+		cfg := &config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU, Dir: t.TempDir()}
+		cache := NewInMemoryCache(cfg)
+		require.NoError(t, cache.Set("key", diskEntryFor("git log --oneline"), 0))
+
+		rotated, err := storage.Default().Rotate(storageCfg, secrets, "", cache.Reseal)
+		require.NoError(t, err)
+		require.NoError(t, cache.Close())
+
+		_, err = rotated.ReadFile(filepath.Join(cfg.Dir, "cache.gob"))
+		assert.NoError(t, err)
+	})
+}
+
+func TestInMemoryCache_Encrypted(t *testing.T) {
+	useEncryptedStorage(t)
+	cfg := &config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU, Dir: t.TempDir()}
+
+	cache := NewInMemoryCache(cfg)
+	require.NoError(t, cache.Set("key", diskEntryFor("git log --oneline"), 0))
+	require.NoError(t, cache.Close())
+
+	path := filepath.Join(cfg.Dir, "cache.gob")
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, storage.IsEncrypted(raw))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	reloaded := NewInMemoryCache(cfg)
+	defer reloaded.Close()
+	entry, found := reloaded.Get("key")
+	require.True(t, found)
+	assert.Equal(t, "git log --oneline", entry.Response.Content)
+}
@@ -0,0 +1,132 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// classifyError converts errors from the API and transport into an
+// *utils.AppError carrying the status code, the provider request ID and a
+// remediation hint. The original error stays wrapped, so errors.As and
+// errors.Is keep working on the result. Nil and AppErrors pass through.
+func classifyError(err error) error {
+	if err == nil || utils.IsAppError(err) {
+		return err
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return classifyAPIError(apiErr, err)
+	}
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return utils.NewAppError(utils.ErrCodeCanceled, "Request was canceled", err).
+			SetRetryable(false)
+	case errors.Is(err, context.DeadlineExceeded):
+		return utils.NewAPIError(utils.ErrCodeTimeout, "Request timed out", http.StatusRequestTimeout, err).
+			WithHint("Increase openai.timeout or try again; long answers may need --stream")
+	case errors.As(err, &dnsErr):
+		return utils.NewAppError(utils.ErrCodeDNS, "Could not resolve the API host", err).
+			WithHint("Check your network connection and openai.base_url")
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return utils.NewAPIError(utils.ErrCodeTimeout, "Request timed out", http.StatusRequestTimeout, err).
+				WithHint("Increase openai.timeout or try again")
+		}
+		return utils.NewNetworkError("Could not reach the API", err).
+			WithHint("Check your network connection, proxy settings and openai.base_url")
+	}
+
+	return err
+}
+
+// classifyAPIError maps an error response from the API by status code and
+// the provider's error code
+func classifyAPIError(apiErr *openai.Error, err error) *utils.AppError {
+	status := apiErr.StatusCode
+	message := apiErr.Message
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	var appErr *utils.AppError
+	switch {
+	case status == http.StatusUnauthorized:
+		appErr = utils.NewAPIError(utils.ErrCodeAuthentication, message, status, err).
+			WithHint("Check OPENAI_API_KEY (or openai.api_key in your config file)")
+	case status == http.StatusForbidden:
+		appErr = utils.NewAPIError(utils.ErrCodePermissionDenied, message, status, err).
+			WithHint("Your API key or organization lacks access; check openai.org_id and the key's project permissions")
+	case status == http.StatusNotFound && apiErr.Code == "model_not_found":
+		appErr = utils.NewAPIError(utils.ErrCodeNotFound, message, status, err).
+			WithHint("The model is not available to your organization; run 'terminal-ai models' to list available models")
+	case status == http.StatusNotFound:
+		appErr = utils.NewAPIError(utils.ErrCodeNotFound, message, status, err).
+			WithHint("Check openai.base_url and the model name")
+	case status == http.StatusTooManyRequests && apiErr.Code == "insufficient_quota":
+		appErr = utils.NewAPIError(utils.ErrCodeQuotaExceeded, message, status, err).
+			WithHint("Check your plan and billing details on the provider dashboard").
+			SetRetryable(false)
+	case status == http.StatusTooManyRequests:
+		appErr = utils.NewAPIError(utils.ErrCodeRateLimit, message, status, err).
+			WithHint("Wait a moment and retry, or lower the request rate")
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		appErr = utils.NewAPIError(utils.ErrCodeTimeout, message, status, err).
+			WithHint("Try again; increase openai.timeout if this keeps happening")
+	case status == http.StatusServiceUnavailable || status == http.StatusBadGateway:
+		appErr = utils.NewAPIError(utils.ErrCodeServiceDown, message, status, err).
+			WithHint("The provider is temporarily unavailable; try again shortly")
+	case status >= 500:
+		appErr = utils.NewAPIError(utils.ErrCodeAPIFailure, message, status, err).
+			WithHint("The provider returned a server error; try again shortly")
+	default:
+		appErr = utils.NewAPIError(utils.ErrCodeInvalidInput, message, status, err).
+			WithHint(invalidRequestHint(apiErr))
+	}
+
+	appErr.WithContextMap(map[string]interface{}{
+		"provider_code": apiErr.Code,
+		"provider_type": apiErr.Type,
+	})
+	if apiErr.Param != "" {
+		appErr.WithContext("param", apiErr.Param)
+	}
+	if apiErr.Response != nil {
+		if requestID := apiErr.Response.Header.Get("x-request-id"); requestID != "" {
+			appErr.WithRequestID(requestID)
+		}
+		if retryAfter := apiErr.Response.Header.Get("retry-after"); retryAfter != "" {
+			appErr.WithContext("retry_after", retryAfter)
+		}
+	}
+	return appErr
+}
+
+// invalidRequestHint suggests a fix for a rejected request
+func invalidRequestHint(apiErr *openai.Error) string {
+	switch {
+	case apiErr.Code == "context_length_exceeded":
+		return "Shorten the conversation or lower max_tokens"
+	case apiErr.Code == "unsupported_parameter" || apiErr.Code == "unsupported_value":
+		if apiErr.Param != "" {
+			return fmt.Sprintf("The model does not support %s; remove it from your config or flags", apiErr.Param)
+		}
+		return "The model does not support one of the request parameters"
+	case apiErr.Param != "":
+		return fmt.Sprintf("Check the value of %s", apiErr.Param)
+	case strings.Contains(strings.ToLower(apiErr.Message), "model"):
+		return "Check the model name"
+	default:
+		return "Check the request parameters"
+	}
+}
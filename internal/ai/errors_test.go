@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// providerError builds an API error as returned by the SDK
+func providerError(status int, code, message string) *openai.Error {
+	header := http.Header{}
+	header.Set("x-request-id", "req_abc123")
+	return &openai.Error{
+		StatusCode: status,
+		Code:       code,
+		Message:    message,
+		Request:    httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil),
+		Response:   &http.Response{StatusCode: status, Header: header},
+	}
+}
+
+func TestClassifyError_APIErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      string
+		exit      int
+		hint      string
+		retryable bool
+	}{
+		{"Unauthorized", providerError(401, "invalid_api_key", "Incorrect API key"), utils.ErrCodeAuthentication, utils.ExitAuth, "OPENAI_API_KEY", false},
+		{"Forbidden", providerError(403, "", "Not allowed"), utils.ErrCodePermissionDenied, utils.ExitPermission, "org_id", false},
+		{"ModelNotFound", providerError(404, "model_not_found", "The model does not exist"), utils.ErrCodeNotFound, utils.ExitNotFound, "not available to your organization", false},
+		{"Quota", providerError(429, "insufficient_quota", "Quota exceeded"), utils.ErrCodeQuotaExceeded, utils.ExitQuota, "billing", false},
+		{"RateLimit", providerError(429, "rate_limit_exceeded", "Slow down"), utils.ErrCodeRateLimit, utils.ExitRateLimit, "retry", true},
+		{"ContextLength", providerError(400, "context_length_exceeded", "Too long"), utils.ErrCodeInvalidInput, utils.ExitUsage, "max_tokens", false},
+		{"ServiceDown", providerError(503, "", ""), utils.ErrCodeServiceDown, utils.ExitUnavailable, "try again", true},
+		{"ServerError", providerError(500, "", "Internal"), utils.ErrCodeAPIFailure, utils.ExitUnavailable, "try again", true},
+		{"Wrapped", fmt.Errorf("failed to create stream: %w", providerError(401, "", "bad key")), utils.ErrCodeAuthentication, utils.ExitAuth, "OPENAI_API_KEY", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError(tt.err)
+			appErr := utils.GetAppError(err)
+			require.NotNil(t, appErr)
+
+			assert.Equal(t, tt.code, appErr.Code)
+			assert.Equal(t, tt.exit, utils.ExitCode(err))
+			assert.Contains(t, appErr.Hint, tt.hint)
+			assert.Equal(t, tt.retryable, appErr.Retryable)
+			assert.Equal(t, "req_abc123", appErr.RequestID)
+			assert.NotZero(t, appErr.StatusCode)
+
+			// The provider error stays reachable for retry and metrics decisions
+			var apiErr *openai.Error
+			assert.True(t, errors.As(err, &apiErr))
+		})
+	}
+}
+
+func TestClassifyError_TransportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{"Canceled", context.Canceled, utils.ErrCodeCanceled},
+		{"Deadline", fmt.Errorf("post: %w", context.DeadlineExceeded), utils.ErrCodeTimeout},
+		{"DNS", &net.DNSError{Err: "no such host", Name: "api.example"}, utils.ErrCodeDNS},
+		{"Connection", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, utils.ErrCodeNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := utils.GetAppError(classifyError(tt.err))
+			require.NotNil(t, appErr)
+			assert.Equal(t, tt.code, appErr.Code)
+			assert.ErrorIs(t, appErr, tt.err)
+		})
+	}
+
+	assert.Nil(t, classifyError(nil))
+	plain := errors.New("something else")
+	assert.Equal(t, plain, classifyError(plain))
+}
+
+func TestRetryConfig_DoesNotRetryQuota(t *testing.T) {
+	cfg := RetryConfig{RetryableHTTPCodes: []int{429, 500}}
+	assert.False(t, cfg.IsRetryable(classifyError(providerError(429, "insufficient_quota", "Quota exceeded"))))
+	assert.True(t, cfg.IsRetryable(classifyError(providerError(429, "rate_limit_exceeded", "Slow down"))))
+}
@@ -0,0 +1,177 @@
+package ai
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// Eviction strategies, as set by cache.strategy
+const (
+	EvictLRU  = "lru"  // least recently used
+	EvictLFU  = "lfu"  // least frequently used, with aging
+	EvictFIFO = "fifo" // oldest entry first
+	EvictSize = "size" // fewest hits per byte, with aging
+)
+
+// EvictionStrategies lists the supported eviction strategies
+var EvictionStrategies = []string{EvictLRU, EvictLFU, EvictFIFO, EvictSize}
+
+// EvictionPolicy decides which entry a full cache evicts next. Caches report
+// every entry they add, hit and remove, and ask for a victim when they need
+// room. Policies are not safe for concurrent use; the cache's lock covers
+// them.
+type EvictionPolicy interface {
+	// Name returns the strategy name
+	Name() string
+	// Add tracks a new entry
+	Add(key string, entry *CacheEntry)
+	// Touch records a hit on an entry, after its access metadata is updated
+	Touch(key string, entry *CacheEntry)
+	// Remove stops tracking an entry
+	Remove(key string)
+	// Victim removes and returns the entry to evict next
+	Victim() (string, bool)
+	// Len returns the number of tracked entries
+	Len() int
+}
+
+// NewEvictionPolicy creates the policy for a strategy; an empty strategy
+// means LRU
+func NewEvictionPolicy(strategy string) (EvictionPolicy, error) {
+	switch strategy {
+	case EvictLRU, "":
+		return newScoredPolicy(EvictLRU, false, func(entry *CacheEntry, clock float64) float64 {
+			return float64(entry.LastAccessedAt.UnixNano())
+		}), nil
+	case EvictFIFO:
+		return newScoredPolicy(EvictFIFO, false, func(entry *CacheEntry, clock float64) float64 {
+			return float64(entry.CreatedAt.UnixNano())
+		}), nil
+	case EvictLFU:
+		// LFU with dynamic aging: the clock rises to each victim's score, so
+		// entries that were popular long ago do not stay forever
+		return newScoredPolicy(EvictLFU, true, func(entry *CacheEntry, clock float64) float64 {
+			return clock + float64(entry.AccessCount)
+		}), nil
+	case EvictSize:
+		// Greedy-dual-size-frequency: hits per KB, aged like LFU, so large
+		// entries must be hit more often to stay
+		return newScoredPolicy(EvictSize, true, func(entry *CacheEntry, clock float64) float64 {
+			size := float64(entry.SizeBytes)
+			if size < 1 {
+				size = 1
+			}
+			return clock + float64(entry.AccessCount+1)*1024/size
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown eviction strategy: %s", strategy)
+	}
+}
+
+// scoredPolicy evicts the entry with the lowest score, breaking ties by the
+// order entries were added or touched
+type scoredPolicy struct {
+	name  string
+	score func(entry *CacheEntry, clock float64) float64
+	aging bool // whether the clock advances to each victim's score
+	clock float64
+	seq   uint64
+	items map[string]*policyItem
+	queue policyQueue
+}
+
+func newScoredPolicy(name string, aging bool, score func(entry *CacheEntry, clock float64) float64) *scoredPolicy {
+	return &scoredPolicy{
+		name:  name,
+		score: score,
+		aging: aging,
+		items: make(map[string]*policyItem),
+	}
+}
+
+func (p *scoredPolicy) Name() string { return p.name }
+
+func (p *scoredPolicy) Len() int { return len(p.items) }
+
+func (p *scoredPolicy) Add(key string, entry *CacheEntry) {
+	if item, ok := p.items[key]; ok {
+		p.update(item, entry)
+		return
+	}
+	p.seq++
+	item := &policyItem{key: key, score: p.score(entry, p.clock), seq: p.seq}
+	p.items[key] = item
+	heap.Push(&p.queue, item)
+}
+
+func (p *scoredPolicy) Touch(key string, entry *CacheEntry) {
+	if item, ok := p.items[key]; ok {
+		p.update(item, entry)
+	}
+}
+
+func (p *scoredPolicy) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		heap.Remove(&p.queue, item.index)
+		delete(p.items, key)
+	}
+}
+
+func (p *scoredPolicy) Victim() (string, bool) {
+	if len(p.items) == 0 {
+		return "", false
+	}
+	item := heap.Pop(&p.queue).(*policyItem)
+	delete(p.items, item.key)
+	if p.aging {
+		p.clock = item.score
+	}
+	return item.key, true
+}
+
+func (p *scoredPolicy) update(item *policyItem, entry *CacheEntry) {
+	p.seq++
+	item.score = p.score(entry, p.clock)
+	item.seq = p.seq
+	heap.Fix(&p.queue, item.index)
+}
+
+// policyItem is an entry's place in a policyQueue
+type policyItem struct {
+	key   string
+	score float64
+	seq   uint64
+	index int
+}
+
+// policyQueue is a min-heap of entries by score
+type policyQueue []*policyItem
+
+func (q policyQueue) Len() int { return len(q) }
+
+func (q policyQueue) Less(i, j int) bool {
+	if q[i].score != q[j].score {
+		return q[i].score < q[j].score
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q policyQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *policyQueue) Push(x any) {
+	item := x.(*policyItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *policyQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return item
+}
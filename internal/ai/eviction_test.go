@@ -0,0 +1,272 @@
+package ai
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+)
+
+// policyEntry builds an entry with the metadata policies order by
+func policyEntry(created, accessed time.Time, hits, size int64) *CacheEntry {
+	return &CacheEntry{CreatedAt: created, LastAccessedAt: accessed, AccessCount: hits, SizeBytes: size}
+}
+
+// victims drains a policy, returning its keys in eviction order
+func victims(policy EvictionPolicy) []string {
+	var keys []string
+	for {
+		key, ok := policy.Victim()
+		if !ok {
+			return keys
+		}
+		keys = append(keys, key)
+	}
+}
+
+func TestEvictionPolicies(t *testing.T) {
+	base := time.Now()
+	at := func(seconds int) time.Time { return base.Add(time.Duration(seconds) * time.Second) }
+
+	tests := []struct {
+		strategy string
+		entries  map[string]*CacheEntry
+		touch    string
+		want     []string
+	}{
+		{
+			strategy: EvictLRU,
+			entries: map[string]*CacheEntry{
+				"old":    policyEntry(at(0), at(1), 5, 100),
+				"recent": policyEntry(at(1), at(3), 0, 100),
+				"middle": policyEntry(at(2), at(2), 0, 100),
+			},
+			touch: "old",
+			want:  []string{"middle", "recent", "old"},
+		},
+		{
+			strategy: EvictFIFO,
+			entries: map[string]*CacheEntry{
+				"first":  policyEntry(at(0), at(9), 9, 100),
+				"second": policyEntry(at(1), at(1), 0, 100),
+				"third":  policyEntry(at(2), at(2), 0, 100),
+			},
+			touch: "first",
+			want:  []string{"first", "second", "third"},
+		},
+		{
+			strategy: EvictLFU,
+			entries: map[string]*CacheEntry{
+				"popular": policyEntry(at(0), at(0), 10, 100),
+				"rare":    policyEntry(at(1), at(1), 1, 100),
+				"some":    policyEntry(at(2), at(2), 4, 100),
+			},
+			touch: "rare",
+			want:  []string{"rare", "some", "popular"},
+		},
+		{
+			strategy: EvictSize,
+			entries: map[string]*CacheEntry{
+				"large": policyEntry(at(0), at(0), 3, 100_000),
+				"small": policyEntry(at(1), at(1), 0, 500),
+				"mid":   policyEntry(at(2), at(2), 3, 10_000),
+			},
+			want: []string{"large", "mid", "small"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			policy, err := NewEvictionPolicy(tt.strategy)
+			require.NoError(t, err)
+			assert.Equal(t, tt.strategy, policy.Name())
+
+			// Add in a fixed order so ties do not depend on map iteration
+			for _, key := range []string{"old", "recent", "middle", "first", "second", "third", "popular", "rare", "some", "large", "small", "mid"} {
+				if entry, ok := tt.entries[key]; ok {
+					policy.Add(key, entry)
+				}
+			}
+			assert.Equal(t, len(tt.entries), policy.Len())
+
+			if tt.touch != "" {
+				entry := tt.entries[tt.touch]
+				entry.LastAccessedAt = at(10)
+				entry.AccessCount++
+				policy.Touch(tt.touch, entry)
+			}
+			assert.Equal(t, tt.want, victims(policy))
+			assert.Equal(t, 0, policy.Len())
+		})
+	}
+
+	_, err := NewEvictionPolicy("random")
+	assert.Error(t, err)
+}
+
+func TestEvictionPolicy_Remove(t *testing.T) {
+	policy, err := NewEvictionPolicy(EvictLRU)
+	require.NoError(t, err)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		at := now.Add(time.Duration(i) * time.Second)
+		policy.Add(fmt.Sprintf("k%d", i), policyEntry(at, at, 0, 1))
+	}
+	policy.Remove("k0")
+	policy.Remove("k3")
+	policy.Remove("missing")
+	assert.Equal(t, []string{"k1", "k2", "k4"}, victims(policy))
+}
+
+func TestEvictionPolicy_LFUAging(t *testing.T) {
+	policy, err := NewEvictionPolicy(EvictLFU)
+	require.NoError(t, err)
+	now := time.Now()
+
+	// An entry that was popular once must not outlive everything that
+	// arrives after it: each eviction raises the score new entries start at
+	policy.Add("once-popular", policyEntry(now, now, 5, 1))
+	for i := 0; i < 10; i++ {
+		policy.Add(fmt.Sprintf("new%d", i), policyEntry(now, now, 1, 1))
+		if i > 0 {
+			key, _ := policy.Victim()
+			if key == "once-popular" {
+				return
+			}
+		}
+	}
+	t.Fatal("aging should eventually evict the formerly popular entry")
+}
+
+func TestInMemoryCache_Strategies(t *testing.T) {
+	for _, strategy := range EvictionStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			cache := NewInMemoryCache(&config.CacheConfig{TTL: time.Minute, MaxSize: 1, Strategy: strategy})
+			defer cache.Close()
+
+			content := strings.Repeat("x", 300*1024)
+			for i := 0; i < 4; i++ {
+				require.NoError(t, cache.Set(fmt.Sprintf("key%d", i), &CacheEntry{Response: &Response{Content: content}}, 0))
+			}
+			stats := cache.Stats()
+			assert.Equal(t, 3, stats.Entries)
+			assert.Equal(t, int64(1), stats.Evictions)
+			assert.Equal(t, map[string]int64{strategy: 1}, stats.EvictionsByPolicy)
+		})
+	}
+}
+
+func TestDiskCache_Strategy(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cfg.MaxSize = 1
+	cfg.Strategy = EvictLFU
+	cache := openDiskCache(t, cfg)
+	other := openDiskCache(t, cfg)
+
+	content := strings.Repeat("x", 200*1024) // counted twice, as response and chunk
+	require.NoError(t, cache.Set("hot", diskEntryFor(content), 0))
+	require.NoError(t, cache.Set("cold", diskEntryFor(content), 0))
+
+	// Hits in another process count towards eviction once it writes them,
+	// at the latest when it closes
+	for i := 0; i < 3; i++ {
+		_, found := other.Get("hot")
+		require.True(t, found)
+	}
+	require.NoError(t, other.Close())
+	require.NoError(t, cache.Set("new", diskEntryFor(content), 0))
+
+	_, found := cache.Get("cold")
+	assert.False(t, found)
+	_, found = cache.Get("hot")
+	assert.True(t, found)
+	assert.Equal(t, map[string]int64{EvictLFU: 1}, cache.Stats().EvictionsByPolicy)
+}
+
+// cacheTrace is a replayable sequence of cache lookups
+type cacheTrace struct {
+	keys     []string
+	contents map[string]string
+}
+
+// newCacheTrace builds a trace of n lookups: keys drawn from a Zipf
+// distribution with sizes from 1 to 32 KB, interrupted by scans of keys that
+// are looked up only once
+func newCacheTrace(n int, seed int64) *cacheTrace {
+	rng := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(rng, 1.1, 1, 999)
+	trace := &cacheTrace{contents: make(map[string]string)}
+	content := func(key string) {
+		if _, ok := trace.contents[key]; !ok {
+			trace.contents[key] = strings.Repeat("x", 1024*(1+rng.Intn(32)))
+		}
+	}
+
+	scans := 0
+	for len(trace.keys) < n {
+		if len(trace.keys)%2000 == 1999 {
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("scan%d-%d", scans, i)
+				content(key)
+				trace.keys = append(trace.keys, key)
+			}
+			scans++
+			continue
+		}
+		key := fmt.Sprintf("key%d", zipf.Uint64())
+		content(key)
+		trace.keys = append(trace.keys, key)
+	}
+	return trace
+}
+
+// replayTrace replays a trace against a 1 MB cache, storing every miss, and
+// returns the hit rate
+func replayTrace(tb testing.TB, strategy string, trace *cacheTrace) float64 {
+	cache := NewInMemoryCache(&config.CacheConfig{TTL: time.Hour, MaxSize: 1, Strategy: strategy})
+	defer cache.Close()
+
+	for _, key := range trace.keys {
+		if _, found := cache.Get(key); found {
+			continue
+		}
+		entry := &CacheEntry{Response: &Response{Content: trace.contents[key]}}
+		if err := cache.Set(key, entry, 0); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return cache.Stats().HitRate
+}
+
+func TestEvictionPolicies_Trace(t *testing.T) {
+	trace := newCacheTrace(20000, 1)
+	rates := make(map[string]float64)
+	for _, strategy := range EvictionStrategies {
+		rates[strategy] = replayTrace(t, strategy, trace)
+		assert.Positive(t, rates[strategy], strategy)
+	}
+
+	// On a skewed trace with scans, frequency beats recency and recency
+	// beats arrival order
+	assert.Greater(t, rates[EvictLFU], rates[EvictLRU])
+	assert.Greater(t, rates[EvictLRU], rates[EvictFIFO])
+	assert.Greater(t, rates[EvictSize], rates[EvictLRU])
+}
+
+func BenchmarkEvictionPolicies(b *testing.B) {
+	trace := newCacheTrace(20000, 1)
+	for _, strategy := range EvictionStrategies {
+		b.Run(strategy, func(b *testing.B) {
+			var hitRate float64
+			for i := 0; i < b.N; i++ {
+				hitRate = replayTrace(b, strategy, trace)
+			}
+			b.ReportMetric(hitRate*100, "hit%")
+		})
+	}
+}
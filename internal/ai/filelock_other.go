@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package ai
+
+import "os"
+
+// lockFile is a no-op on platforms without file locking; the disk cache is
+// then only safe for one process at a time
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without file locking
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,255 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/user/terminal-ai/internal/tracing"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// DefaultImageModel is used when no image model is configured
+const DefaultImageModel = "gpt-image-1"
+
+// MaxImages is the most images a single request can return
+const MaxImages = 10
+
+const (
+	endpointImageGenerations = "/v1/images/generations"
+	endpointImageEdits       = "/v1/images/edits"
+)
+
+// ImageClient is implemented by clients that support the images endpoints
+type ImageClient interface {
+	// GenerateImages creates images from a prompt, or edits ImageOptions.Image
+	GenerateImages(ctx context.Context, options ImageOptions) (*ImageResult, error)
+}
+
+// ImageOptions configures an image generation or edit
+type ImageOptions struct {
+	Prompt  string
+	Model   string // gpt-image-1, dall-e-3, dall-e-2
+	Size    string // e.g. 1024x1024, 1536x1024, auto
+	Quality string // auto, low, medium, high (gpt-image-1); standard, hd (dall-e-3)
+	N       int    // number of images (0 = 1)
+	Image   string // image file to edit
+	Mask    string // PNG whose transparent areas mark where Image is edited
+}
+
+// ImageResult holds the images returned for a request
+type ImageResult struct {
+	Images []GeneratedImage
+	Format string // file extension of the image data: png, jpeg or webp
+	Usage  Usage
+}
+
+// GeneratedImage is a decoded image. URL is set instead of Data when the
+// provider returns links.
+type GeneratedImage struct {
+	Data          []byte
+	URL           string
+	RevisedPrompt string
+}
+
+// GenerateImages creates images from a prompt, or edits options.Image when
+// set. The prompt is checked against the safety policy, requests go through
+// the client's rate limiter and retries, and token usage is recorded with the
+// chat usage.
+func (c *OpenAIClient) GenerateImages(ctx context.Context, options ImageOptions) (*ImageResult, error) {
+	if options.Model == "" {
+		options.Model = DefaultImageModel
+	}
+	if options.N == 0 {
+		options.N = 1
+	}
+	if err := validateImageOptions(options); err != nil {
+		return nil, err
+	}
+	if err := c.safety.guard(ctx, options.Prompt, SafetyPhasePrompt); err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Start(ctx, "ai.image")
+	defer span.End()
+	span.SetAttribute("ai.model", options.Model)
+	span.SetAttribute("image.n", options.N)
+	span.SetAttribute("image.edit", options.Image != "")
+
+	endpoint := endpointImageGenerations
+	if options.Image != "" {
+		endpoint = endpointImageEdits
+	}
+
+	var resp *openai.ImagesResponse
+	err := c.limited(ctx, "image", func(ctx context.Context) error {
+		start := time.Now()
+		var err error
+		if options.Image != "" {
+			resp, err = c.editImage(ctx, options)
+		} else {
+			resp, err = c.client.Images.Generate(ctx, imageGenerateParams(options))
+		}
+		c.metrics.RecordAPICall(endpoint, time.Since(start), statusCode(err), err)
+		return classifyError(err)
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	result, err := decodeImages(resp)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if result.Usage.TotalTokens > 0 {
+		c.metrics.RecordTokenUsage(utils.TokenUsage{
+			Model:            options.Model,
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+			TotalTokens:      result.Usage.TotalTokens,
+		})
+	}
+	span.SetAttribute("ai.usage.total_tokens", result.Usage.TotalTokens)
+	return result, nil
+}
+
+// validateImageOptions checks limits the API would otherwise reject
+func validateImageOptions(options ImageOptions) error {
+	switch {
+	case strings.TrimSpace(options.Prompt) == "":
+		return utils.NewValidationError("image prompt is empty", "prompt")
+	case options.N < 1 || options.N > MaxImages:
+		return utils.NewValidationError(fmt.Sprintf("n must be between 1 and %d, got %d", MaxImages, options.N), "n")
+	case options.Model == string(openai.ImageModelDallE3) && options.N > 1:
+		return utils.NewValidationError("dall-e-3 generates one image per request", "n").
+			WithHint("Use --n 1, or gpt-image-1 for several images")
+	case options.Mask != "" && options.Image == "":
+		return utils.NewValidationError("a mask needs an image to edit", "mask").
+			WithHint("Pass the image to edit with --image")
+	case options.Image != "" && options.Model == string(openai.ImageModelDallE3):
+		return utils.NewValidationError("dall-e-3 does not support image edits", "model").
+			WithHint("Use gpt-image-1 or dall-e-2 with --image")
+	}
+	return nil
+}
+
+// usesImageURLs reports whether a model returns URLs unless base64 output is
+// requested. gpt-image-1 always returns base64 and rejects response_format.
+func usesImageURLs(model string) bool {
+	return strings.HasPrefix(model, "dall-e")
+}
+
+// imageGenerateParams builds the request for a new image
+func imageGenerateParams(options ImageOptions) openai.ImageGenerateParams {
+	params := openai.ImageGenerateParams{
+		Prompt: options.Prompt,
+		Model:  openai.ImageModel(options.Model),
+		N:      openai.Int(int64(options.N)),
+	}
+	if options.Size != "" {
+		params.Size = openai.ImageGenerateParamsSize(options.Size)
+	}
+	if options.Quality != "" {
+		params.Quality = openai.ImageGenerateParamsQuality(options.Quality)
+	}
+	if usesImageURLs(options.Model) {
+		params.ResponseFormat = openai.ImageGenerateParamsResponseFormatB64JSON
+	}
+	return params
+}
+
+// editImage sends an edit request. The files are opened per attempt because
+// each upload consumes them.
+func (c *OpenAIClient) editImage(ctx context.Context, options ImageOptions) (*openai.ImagesResponse, error) {
+	image, err := openImageFile(options.Image)
+	if err != nil {
+		return nil, err
+	}
+	defer image.Close()
+
+	params := openai.ImageEditParams{
+		Image:  openai.ImageEditParamsImageUnion{OfFile: openai.File(image, filepath.Base(options.Image), imageContentType(options.Image))},
+		Prompt: options.Prompt,
+		Model:  openai.ImageModel(options.Model),
+		N:      openai.Int(int64(options.N)),
+	}
+	if options.Mask != "" {
+		mask, err := openImageFile(options.Mask)
+		if err != nil {
+			return nil, err
+		}
+		defer mask.Close()
+		params.Mask = openai.File(mask, filepath.Base(options.Mask), imageContentType(options.Mask))
+	}
+	if options.Size != "" {
+		params.Size = openai.ImageEditParamsSize(options.Size)
+	}
+	if options.Quality != "" {
+		params.Quality = openai.ImageEditParamsQuality(options.Quality)
+	}
+	if usesImageURLs(options.Model) {
+		params.ResponseFormat = openai.ImageEditParamsResponseFormatB64JSON
+	}
+
+	return c.client.Images.Edit(ctx, params)
+}
+
+// openImageFile opens an input image, reporting a missing file as invalid input
+func openImageFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, utils.NewAppError(utils.ErrCodeInvalidInput, "cannot read image file", err).
+			WithContext("path", path)
+	}
+	return file, nil
+}
+
+// imageContentType returns the MIME type of an image file from its extension
+func imageContentType(path string) string {
+	if contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); contentType != "" {
+		return contentType
+	}
+	return "image/png"
+}
+
+// decodeImages decodes the base64 image data of a response
+func decodeImages(resp *openai.ImagesResponse) (*ImageResult, error) {
+	result := &ImageResult{
+		Format: string(resp.OutputFormat),
+		Usage: Usage{
+			PromptTokens:     int(resp.Usage.InputTokens),
+			CompletionTokens: int(resp.Usage.OutputTokens),
+			TotalTokens:      int(resp.Usage.TotalTokens),
+		},
+	}
+	if result.Format == "" {
+		result.Format = "png"
+	}
+
+	for i, image := range resp.Data {
+		generated := GeneratedImage{URL: image.URL, RevisedPrompt: image.RevisedPrompt}
+		if image.B64JSON != "" {
+			data, err := base64.StdEncoding.DecodeString(image.B64JSON)
+			if err != nil {
+				return nil, utils.NewAppError(utils.ErrCodeInvalidResponse,
+					fmt.Sprintf("failed to decode image %d", i+1), err)
+			}
+			generated.Data = data
+		}
+		result.Images = append(result.Images, generated)
+	}
+
+	if len(result.Images) == 0 {
+		return nil, utils.NewAppError(utils.ErrCodeInvalidResponse, "the response contained no images", nil)
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+func TestOpenAIClient_GenerateImages(t *testing.T) {
+	metrics := utils.InitMetrics()
+	metrics.Reset()
+	defer metrics.Reset()
+
+	client := newMockClient(t, ``)
+
+	result, err := client.GenerateImages(context.Background(), ImageOptions{Prompt: "diagram of a queue", N: 2, Size: "1024x1024"})
+	require.NoError(t, err)
+	assert.Equal(t, "png", result.Format)
+	require.Len(t, result.Images, 2)
+	_, err = png.Decode(bytes.NewReader(result.Images[1].Data))
+	assert.NoError(t, err)
+	assert.Equal(t, 4+2*272, result.Usage.TotalTokens)
+
+	// Image usage is recorded with the chat usage
+	var sb strings.Builder
+	require.NoError(t, metrics.WritePrometheus(&sb))
+	assert.Contains(t, sb.String(), `terminal_ai_api_requests_total{endpoint="/v1/images/generations",status="200"} 1`)
+	assert.Equal(t, int64(548), metrics.GetTokenStats()["total_tokens"])
+
+	// dall-e models are asked for base64 output and may revise the prompt
+	result, err = client.GenerateImages(context.Background(), ImageOptions{Prompt: "a lighthouse", Model: "dall-e-3"})
+	require.NoError(t, err)
+	require.Len(t, result.Images, 1)
+	assert.NotEmpty(t, result.Images[0].Data)
+	assert.Equal(t, "A detailed rendering of a lighthouse", result.Images[0].RevisedPrompt)
+}
+
+func TestOpenAIClient_EditImage(t *testing.T) {
+	client := newMockClient(t, ``)
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "photo.png")
+	mask := filepath.Join(dir, "mask.png")
+	require.NoError(t, os.WriteFile(input, []byte("png"), 0644))
+	require.NoError(t, os.WriteFile(mask, []byte("png"), 0644))
+
+	result, err := client.GenerateImages(context.Background(), ImageOptions{Prompt: "add a scarf", Image: input, Mask: mask})
+	require.NoError(t, err)
+	require.Len(t, result.Images, 1)
+	assert.NotEmpty(t, result.Images[0].Data)
+
+	_, err = client.GenerateImages(context.Background(), ImageOptions{Prompt: "add a scarf", Image: filepath.Join(dir, "missing.png")})
+	assert.Equal(t, utils.ExitUsage, utils.ExitCode(err))
+}
+
+func TestValidateImageOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ImageOptions
+		field   string
+	}{
+		{"empty prompt", ImageOptions{Prompt: " ", N: 1}, "prompt"},
+		{"too many images", ImageOptions{Prompt: "cat", N: 11}, "n"},
+		{"dall-e-3 batch", ImageOptions{Prompt: "cat", N: 2, Model: "dall-e-3"}, "n"},
+		{"mask without image", ImageOptions{Prompt: "cat", N: 1, Mask: "mask.png"}, "mask"},
+		{"dall-e-3 edit", ImageOptions{Prompt: "cat", N: 1, Model: "dall-e-3", Image: "cat.png"}, "model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateImageOptions(tt.options)
+			require.Error(t, err)
+			appErr, ok := err.(*utils.AppError)
+			require.True(t, ok)
+			assert.Equal(t, tt.field, appErr.Context["field"])
+		})
+	}
+
+	assert.NoError(t, validateImageOptions(ImageOptions{Prompt: "cat", N: 4, Model: "gpt-image-1"}))
+}
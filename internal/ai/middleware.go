@@ -0,0 +1,671 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/tracing"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// Endpoint labels used when recording API metrics
+const (
+	endpointChat       = "/v1/chat/completions"
+	endpointChatStream = "/v1/chat/completions:stream"
+	endpointModels     = "/v1/models"
+)
+
+// Request is a chat call flowing through the interceptor chain
+type Request struct {
+	Messages []Message
+	Options  ChatOptions
+}
+
+// Handler processes unary and streaming chat requests
+type Handler interface {
+	// Chat sends a chat request and returns the complete response
+	Chat(ctx context.Context, req *Request) (*Response, error)
+	// ChatStream sends a chat request and streams the response
+	ChatStream(ctx context.Context, req *Request) (<-chan StreamChunk, error)
+}
+
+// Interceptor wraps a Handler with cross-cutting behaviour such as caching,
+// rate limiting, retries, redaction or auditing
+type Interceptor func(next Handler) Handler
+
+// HandlerFuncs adapts a pair of functions to the Handler interface
+type HandlerFuncs struct {
+	ChatFunc       func(ctx context.Context, req *Request) (*Response, error)
+	ChatStreamFunc func(ctx context.Context, req *Request) (<-chan StreamChunk, error)
+}
+
+// Chat calls ChatFunc
+func (h HandlerFuncs) Chat(ctx context.Context, req *Request) (*Response, error) {
+	return h.ChatFunc(ctx, req)
+}
+
+// ChatStream calls ChatStreamFunc
+func (h HandlerFuncs) ChatStream(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+	return h.ChatStreamFunc(ctx, req)
+}
+
+// Chain wraps handler with interceptors. The first interceptor is the
+// outermost, so it sees the request first and the response last.
+func Chain(handler Handler, interceptors ...Interceptor) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		handler = interceptors[i](handler)
+	}
+	return handler
+}
+
+// UnaryInterceptor builds an interceptor that wraps only Chat calls;
+// streaming calls pass through unchanged
+func UnaryInterceptor(fn func(ctx context.Context, req *Request, next Handler) (*Response, error)) Interceptor {
+	return func(next Handler) Handler {
+		return HandlerFuncs{
+			ChatFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				return fn(ctx, req, next)
+			},
+			ChatStreamFunc: next.ChatStream,
+		}
+	}
+}
+
+// StreamInterceptor builds an interceptor that wraps only ChatStream calls;
+// unary calls pass through unchanged
+func StreamInterceptor(fn func(ctx context.Context, req *Request, next Handler) (<-chan StreamChunk, error)) Interceptor {
+	return func(next Handler) Handler {
+		return HandlerFuncs{
+			ChatFunc: next.Chat,
+			ChatStreamFunc: func(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+				return fn(ctx, req, next)
+			},
+		}
+	}
+}
+
+// TracingInterceptor wraps every call in a span carrying the model, token
+// usage and outcome. Streaming spans stay open until the stream completes.
+// AppErrors returned through the chain get the trace ID as their request ID.
+func TracingInterceptor() Interceptor {
+	return func(next Handler) Handler {
+		return HandlerFuncs{
+			ChatFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				ctx, span := tracing.Start(ctx, "ai.chat")
+				defer span.End()
+				setRequestAttributes(span, req)
+
+				resp, err := next.Chat(ctx, req)
+				if err != nil {
+					span.RecordError(err)
+					return nil, attachTrace(ctx, err)
+				}
+
+				span.SetAttribute("ai.response.model", resp.Model)
+				span.SetAttribute("ai.response.finish_reason", resp.FinishReason)
+				setUsageAttributes(span, resp.Usage)
+				return resp, nil
+			},
+			ChatStreamFunc: func(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+				ctx, span := tracing.Start(ctx, "ai.chat_stream")
+				setRequestAttributes(span, req)
+
+				chunks, err := next.ChatStream(ctx, req)
+				if err != nil || span == nil {
+					span.RecordError(err)
+					span.End()
+					return chunks, attachTrace(ctx, err)
+				}
+
+				// The receive phase runs from the stream opening until it completes
+				_, receive := tracing.Start(ctx, "stream.receive")
+				out := make(chan StreamChunk, cap(chunks))
+				go func() {
+					defer close(out)
+					defer span.End()
+					defer receive.End()
+
+					received := 0
+					for chunk := range chunks {
+						if chunk.Content != "" {
+							if received == 0 {
+								receive.AddEvent("first_token", nil)
+							}
+							received++
+						}
+						if chunk.Error != nil {
+							receive.RecordError(chunk.Error)
+							span.RecordError(chunk.Error)
+						}
+						if chunk.Usage != nil {
+							setUsageAttributes(span, *chunk.Usage)
+						}
+						select {
+						case out <- chunk:
+						case <-ctx.Done():
+							return
+						}
+					}
+					receive.SetAttribute("stream.chunks", received)
+				}()
+				return out, nil
+			},
+		}
+	}
+}
+
+// setRequestAttributes records request details on a span
+func setRequestAttributes(span *tracing.Span, req *Request) {
+	span.SetAttribute("ai.model", req.Options.Model)
+	span.SetAttribute("ai.messages", len(req.Messages))
+	if req.Options.ReasoningEffort != "" {
+		span.SetAttribute("ai.reasoning_effort", req.Options.ReasoningEffort)
+	}
+	if req.Options.ServiceTier != "" {
+		span.SetAttribute("ai.service_tier", req.Options.ServiceTier)
+	}
+}
+
+// setUsageAttributes records token usage on a span
+func setUsageAttributes(span *tracing.Span, usage Usage) {
+	span.SetAttribute("ai.usage.prompt_tokens", usage.PromptTokens)
+	span.SetAttribute("ai.usage.completion_tokens", usage.CompletionTokens)
+	span.SetAttribute("ai.usage.total_tokens", usage.TotalTokens)
+}
+
+// attachTrace sets the trace ID as the request ID of AppErrors
+func attachTrace(ctx context.Context, err error) error {
+	var appErr *utils.AppError
+	if errors.As(err, &appErr) {
+		appErr.WithTraceContext(ctx)
+	}
+	return err
+}
+
+// LoggingInterceptor logs the model, duration and outcome of every call
+func LoggingInterceptor() Interceptor {
+	return func(next Handler) Handler {
+		return HandlerFuncs{
+			ChatFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				start := time.Now()
+				resp, err := next.Chat(ctx, req)
+				event := log.Debug().Ctx(ctx)
+				if err != nil {
+					event = event.Err(err)
+				} else {
+					event = event.Int("total_tokens", resp.Usage.TotalTokens)
+				}
+				event.
+					Str("model", req.Options.Model).
+					Int("messages", len(req.Messages)).
+					Dur("duration", time.Since(start)).
+					Msg("Chat request completed")
+				return resp, err
+			},
+			ChatStreamFunc: func(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+				start := time.Now()
+				chunks, err := next.ChatStream(ctx, req)
+				log.Debug().
+					Ctx(ctx).
+					Err(err).
+					Str("model", req.Options.Model).
+					Int("messages", len(req.Messages)).
+					Dur("duration", time.Since(start)).
+					Msg("Chat stream opened")
+				return chunks, err
+			},
+		}
+	}
+}
+
+// CacheOptions configures CacheInterceptor
+type CacheOptions struct {
+	TTL      time.Duration
+	Pace     bool            // replay cached streams with their original chunk timing
+	Semantic *SemanticLookup // may be nil
+	// StaleOnError serves the expired entry for a request, if the cache
+	// still has it, when the call fails
+	StaleOnError bool
+	// Offline serves requests only from the cache, expired entries included,
+	// and fails the rest without calling next. Semantic lookups are skipped.
+	Offline bool
+}
+
+// CacheInterceptor serves calls from the cache and stores successful
+// responses for options.TTL. Completed streams are cached too, and replayed
+// as streams on later hits, with their original chunk timing if Pace is set.
+// Unary and streaming calls for the same request share an entry.
+//
+// With Semantic set, on an exact miss the last user message is embedded and
+// the cached response for the most similar prompt, with the same model,
+// options and earlier conversation, is served if it is similar enough. Such responses
+// carry a SemanticMatch.
+//
+// Expired entries are served from caches that implement StaleReader when
+// StaleOnError or Offline allows it, and carry a StaleHit. Calls made
+// WithoutCache go straight to next, or fail when Offline.
+func CacheInterceptor(cache Cache, options CacheOptions) Interceptor {
+	semantic := options.Semantic
+	if options.Offline {
+		semantic = nil
+	}
+	return func(next Handler) Handler {
+		return HandlerFuncs{
+			ChatFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				if cacheBypassed(ctx) {
+					if options.Offline {
+						return nil, errOfflineBypass()
+					}
+					return next.Chat(ctx, req)
+				}
+
+				lookup := lookupCache(ctx, cache, semantic, req)
+				if lookup.entry != nil {
+					logCacheHit(ctx, lookup, "Cache hit for chat")
+					// A copy, so callers can't change what the cache holds
+					resp := *lookup.entry.Response
+					resp.Semantic = lookup.match
+					return &resp, nil
+				}
+
+				if options.Offline {
+					entry, stale, err := serveOffline(ctx, cache, lookup.key)
+					if err != nil {
+						return nil, err
+					}
+					resp := *entry.Response
+					resp.Stale = stale
+					return &resp, nil
+				}
+
+				resp, err := next.Chat(ctx, req)
+				if err != nil {
+					if entry, stale := serveStale(ctx, cache, lookup.key, err, options.StaleOnError); entry != nil {
+						resp := *entry.Response
+						resp.Stale = stale
+						return &resp, nil
+					}
+					return nil, err
+				}
+
+				cached := *resp
+				entry := &CacheEntry{
+					Response:       &cached,
+					TokenUsage:     resp.Usage,
+					CreatedAt:      time.Now(),
+					LastAccessedAt: time.Now(),
+					AccessCount:    1,
+				}
+				lookup.annotate(entry)
+				if err := cache.Set(lookup.key, entry, cacheTTL(ctx, options.TTL)); err != nil {
+					log.Warn().Ctx(ctx).Err(err).Msg("Failed to cache chat response")
+				}
+				return resp, nil
+			},
+			ChatStreamFunc: func(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+				if cacheBypassed(ctx) {
+					if options.Offline {
+						return nil, errOfflineBypass()
+					}
+					return next.ChatStream(ctx, req)
+				}
+
+				lookup := lookupCache(ctx, cache, semantic, req)
+				if lookup.entry != nil {
+					logCacheHit(ctx, lookup, "Cache hit for chat stream")
+					return replayStream(ctx, lookup.entry, options.Pace, lookup.match, nil), nil
+				}
+
+				if options.Offline {
+					entry, stale, err := serveOffline(ctx, cache, lookup.key)
+					if err != nil {
+						return nil, err
+					}
+					return replayStream(ctx, entry, options.Pace, nil, stale), nil
+				}
+
+				chunks, err := next.ChatStream(ctx, req)
+				if err != nil {
+					if entry, stale := serveStale(ctx, cache, lookup.key, err, options.StaleOnError); entry != nil {
+						return replayStream(ctx, entry, options.Pace, nil, stale), nil
+					}
+					return nil, err
+				}
+				return recordStream(ctx, chunks, req, func(entry *CacheEntry) {
+					lookup.annotate(entry)
+					if err := cache.Set(lookup.key, entry, cacheTTL(ctx, options.TTL)); err != nil {
+						log.Warn().Ctx(ctx).Err(err).Msg("Failed to cache chat stream")
+					}
+				}), nil
+			},
+		}
+	}
+}
+
+// logCacheHit logs a cache hit, with the similarity for semantic hits
+func logCacheHit(ctx context.Context, lookup *cacheLookup, msg string) {
+	event := log.Debug().
+		Ctx(ctx).
+		Str("key", lookup.key[:8]).
+		Int64("access_count", lookup.entry.AccessCount)
+	if lookup.match != nil {
+		event = event.Bool("semantic", true).Float64("similarity", lookup.match.Similarity)
+	}
+	event.Msg(msg)
+}
+
+// recordStream passes chunks through and, when the stream completes
+// successfully, hands store the assembled entry before the final chunk is
+// delivered, so callers that stop reading at the final chunk still find it
+// cached. Streams that fail, are cancelled or end without a final chunk are
+// not stored. It stops when ctx is done, so a caller that stops reading does
+// not leave it blocked.
+func recordStream(ctx context.Context, chunks <-chan StreamChunk, req *Request, store func(entry *CacheEntry)) <-chan StreamChunk {
+	out := make(chan StreamChunk, cap(chunks))
+	go func() {
+		defer close(out)
+
+		var content strings.Builder
+		var recorded []CachedChunk
+		failed := false
+		last := time.Now()
+		for chunk := range chunks {
+			if chunk.Error != nil {
+				failed = true
+			}
+			if chunk.Content != "" {
+				now := time.Now()
+				delay := now.Sub(last)
+				if len(recorded) == 0 {
+					// Time to first token is upstream latency, not pacing
+					delay = 0
+				}
+				recorded = append(recorded, CachedChunk{Content: chunk.Content, Delay: delay})
+				content.WriteString(chunk.Content)
+				last = now
+			}
+			if chunk.Done && !failed {
+				store(streamEntry(req, content.String(), recorded, chunk))
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// streamEntry assembles the cache entry for a completed stream
+func streamEntry(req *Request, content string, chunks []CachedChunk, final StreamChunk) *CacheEntry {
+	now := time.Now()
+	resp := &Response{
+		Content:      content,
+		Model:        req.Options.Model,
+		FinishReason: final.FinishReason,
+		Created:      now,
+	}
+	if final.Usage != nil {
+		resp.Usage = *final.Usage
+	}
+	return &CacheEntry{
+		Response:       resp,
+		TokenUsage:     resp.Usage,
+		CreatedAt:      now,
+		LastAccessedAt: now,
+		AccessCount:    1,
+		Chunks:         chunks,
+	}
+}
+
+// replayStream streams a cached entry, marking the first chunk with match
+// and stale if set. Entries cached from unary calls are sent as a single
+// chunk.
+func replayStream(ctx context.Context, entry *CacheEntry, pace bool, match *SemanticMatch, stale *StaleHit) <-chan StreamChunk {
+	pieces := entry.Chunks
+	if len(pieces) == 0 && entry.Response != nil && entry.Response.Content != "" {
+		pieces = []CachedChunk{{Content: entry.Response.Content}}
+	}
+
+	out := make(chan StreamChunk, len(pieces)+1)
+	go func() {
+		defer close(out)
+
+		for _, piece := range pieces {
+			if pace && piece.Delay > 0 {
+				select {
+				case <-time.After(piece.Delay):
+				case <-ctx.Done():
+					out <- StreamChunk{Error: classifyError(ctx.Err()), Done: true}
+					return
+				}
+			}
+			out <- StreamChunk{Content: piece.Content, Semantic: match, Stale: stale}
+			match, stale = nil, nil
+		}
+
+		final := StreamChunk{Done: true, Semantic: match, Stale: stale}
+		if entry.Response != nil {
+			usage := entry.Response.Usage
+			final.Usage = &usage
+			final.FinishReason = entry.Response.FinishReason
+		}
+		out <- final
+	}()
+	return out
+}
+
+// RateLimitInterceptor waits on the rate limiter before every unary and streaming call
+func RateLimitInterceptor(limiter *RateLimiter) Interceptor {
+	return func(next Handler) Handler {
+		return HandlerFuncs{
+			ChatFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				if err := waitTraced(ctx, limiter); err != nil {
+					return nil, err
+				}
+				return next.Chat(ctx, req)
+			},
+			ChatStreamFunc: func(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+				if err := waitTraced(ctx, limiter); err != nil {
+					return nil, err
+				}
+				return next.ChatStream(ctx, req)
+			},
+		}
+	}
+}
+
+// waitTraced waits on the rate limiter inside a span
+func waitTraced(ctx context.Context, limiter *RateLimiter) error {
+	ctx, span := tracing.Start(ctx, "ratelimit.wait")
+	defer span.End()
+
+	if err := limiter.Wait(ctx); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("rate limiting error: %w", err)
+	}
+	if sent, ok := ctx.Value(sentAtKey{}).(*atomic.Int64); ok {
+		sent.Store(time.Now().UnixNano())
+	}
+	return nil
+}
+
+// sentAtKey holds the time a request passed the rate limiter
+type sentAtKey struct{}
+
+// WithSentAt returns a context that records when a request passes the rate
+// limiter, and a function returning that time (zero before it has passed).
+// Callers timing requests use it to leave out time spent queued.
+func WithSentAt(ctx context.Context) (context.Context, func() time.Time) {
+	sent := new(atomic.Int64)
+	return context.WithValue(ctx, sentAtKey{}, sent), func() time.Time {
+		if n := sent.Load(); n != 0 {
+			return time.Unix(0, n)
+		}
+		return time.Time{}
+	}
+}
+
+// MetricsInterceptor records latency, status codes and token usage for every
+// API attempt, and time to first token for streams
+func MetricsInterceptor(metrics *utils.MetricsCollector) Interceptor {
+	return func(next Handler) Handler {
+		return HandlerFuncs{
+			ChatFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				start := time.Now()
+				resp, err := next.Chat(ctx, req)
+				metrics.RecordAPICall(endpointChat, time.Since(start), statusCode(err), err)
+				if err == nil {
+					metrics.RecordTokenUsage(utils.TokenUsage{
+						Model:            req.Options.Model,
+						PromptTokens:     resp.Usage.PromptTokens,
+						CompletionTokens: resp.Usage.CompletionTokens,
+						TotalTokens:      resp.Usage.TotalTokens,
+					})
+				}
+				return resp, err
+			},
+			ChatStreamFunc: func(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+				start := time.Now()
+				chunks, err := next.ChatStream(ctx, req)
+				if err != nil {
+					metrics.RecordAPICall(endpointChatStream, time.Since(start), statusCode(err), err)
+					return chunks, err
+				}
+
+				out := make(chan StreamChunk, cap(chunks))
+				go func() {
+					defer close(out)
+
+					firstToken := true
+					var streamErr error
+					// The stream was accepted, so the status is 200 even if it later failed
+					defer func() {
+						metrics.RecordAPICall(endpointChatStream, time.Since(start), 200, streamErr)
+					}()
+					for chunk := range chunks {
+						if firstToken && chunk.Content != "" {
+							metrics.RecordTimeToFirstToken(req.Options.Model, time.Since(start))
+							firstToken = false
+						}
+						if chunk.Error != nil {
+							streamErr = chunk.Error
+						}
+						if chunk.Usage != nil {
+							metrics.RecordTokenUsage(utils.TokenUsage{
+								Model:            req.Options.Model,
+								PromptTokens:     chunk.Usage.PromptTokens,
+								CompletionTokens: chunk.Usage.CompletionTokens,
+								TotalTokens:      chunk.Usage.TotalTokens,
+							})
+						}
+						select {
+						case out <- chunk:
+						case <-ctx.Done():
+							streamErr = ctx.Err()
+							return
+						}
+					}
+				}()
+				return out, nil
+			},
+		}
+	}
+}
+
+// statusCode extracts the HTTP status code from an API error.
+// Successful calls report 200; transport failures report 0.
+func statusCode(err error) int {
+	if err == nil {
+		return 200
+	}
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// RetryInterceptor retries retryable failures with exponential backoff.
+// Streaming calls are retried only while opening the stream; once chunks
+// are flowing, errors are delivered on the channel.
+func RetryInterceptor(cfg RetryConfig) Interceptor {
+	return func(next Handler) Handler {
+		return HandlerFuncs{
+			ChatFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				var resp *Response
+				err := retry(ctx, cfg, "chat completion", func(ctx context.Context) error {
+					var err error
+					resp, err = next.Chat(ctx, req)
+					return err
+				})
+				return resp, err
+			},
+			ChatStreamFunc: func(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+				var chunks <-chan StreamChunk
+				err := retry(ctx, cfg, "chat stream", func(ctx context.Context) error {
+					var err error
+					chunks, err = next.ChatStream(ctx, req)
+					return err
+				})
+				return chunks, err
+			},
+		}
+	}
+}
+
+// retry runs call until it succeeds, fails with a non-retryable error or
+// runs out of attempts. Each attempt runs in its own span.
+func retry(ctx context.Context, cfg RetryConfig, operation string, call func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
+		attemptCtx, span := tracing.Start(ctx, "retry.attempt")
+		span.SetAttribute("retry.attempt", attempt+1)
+		span.SetAttribute("retry.operation", operation)
+		err = call(attemptCtx)
+		span.SetAttribute("http.response.status_code", statusCode(err))
+		span.RecordError(err)
+		span.End()
+		if err == nil {
+			return nil
+		}
+
+		if !cfg.IsRetryable(err) {
+			if !errors.Is(err, context.Canceled) {
+				log.Error().Ctx(ctx).Err(err).Msgf("Non-retryable error in %s", operation)
+			}
+			return err
+		}
+
+		if attempt < cfg.MaxRetries {
+			delay := cfg.Backoff(attempt)
+			tracing.SpanFromContext(ctx).AddEvent("retry.backoff", map[string]interface{}{
+				"retry.attempt":  attempt + 1,
+				"retry.delay_ms": delay.Milliseconds(),
+			})
+			log.Warn().
+				Ctx(ctx).
+				Err(err).
+				Int("attempt", attempt+1).
+				Dur("delay", delay).
+				Msgf("Retrying %s", operation)
+
+			select {
+			case <-time.After(delay):
+				// Continue to next attempt
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	log.Error().Ctx(ctx).Err(err).Msgf("Failed to create %s after retries", operation)
+	return err
+}
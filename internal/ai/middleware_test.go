@@ -0,0 +1,514 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/tracing"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// fakeHandler answers every call with a fixed response and counts calls
+type fakeHandler struct {
+	calls  int
+	errors []error // returned in order before succeeding
+}
+
+func (f *fakeHandler) next() error {
+	f.calls++
+	if len(f.errors) > 0 {
+		err := f.errors[0]
+		f.errors = f.errors[1:]
+		return err
+	}
+	return nil
+}
+
+func (f *fakeHandler) Chat(ctx context.Context, req *Request) (*Response, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &Response{Content: "answer", Model: req.Options.Model}, nil
+}
+
+func (f *fakeHandler) ChatStream(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	chunks := make(chan StreamChunk, 2)
+	chunks <- StreamChunk{Content: "answer"}
+	chunks <- StreamChunk{Done: true}
+	close(chunks)
+	return chunks, nil
+}
+
+func apiError(status int) error {
+	return &openai.Error{
+		StatusCode: status,
+		Request:    httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil),
+		Response:   &http.Response{StatusCode: status},
+	}
+}
+
+func testRequest() *Request {
+	return &Request{
+		Messages: []Message{{Role: "user", Content: "hello"}},
+		Options:  ChatOptions{Model: "gpt-4o"},
+	}
+}
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	tag := func(name string) Interceptor {
+		return UnaryInterceptor(func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+			order = append(order, name+">")
+			resp, err := next.Chat(ctx, req)
+			order = append(order, "<"+name)
+			return resp, err
+		})
+	}
+
+	handler := Chain(&fakeHandler{}, tag("a"), tag("b"))
+	_, err := handler.Chat(context.Background(), testRequest())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a>", "b>", "<b", "<a"}, order)
+}
+
+func TestUnaryAndStreamInterceptors_PassThrough(t *testing.T) {
+	unaryCalls, streamCalls := 0, 0
+	handler := Chain(&fakeHandler{},
+		UnaryInterceptor(func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+			unaryCalls++
+			return next.Chat(ctx, req)
+		}),
+		StreamInterceptor(func(ctx context.Context, req *Request, next Handler) (<-chan StreamChunk, error) {
+			streamCalls++
+			return next.ChatStream(ctx, req)
+		}),
+	)
+
+	_, err := handler.Chat(context.Background(), testRequest())
+	require.NoError(t, err)
+	_, err = handler.ChatStream(context.Background(), testRequest())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, unaryCalls)
+	assert.Equal(t, 1, streamCalls)
+}
+
+func TestCacheInterceptor(t *testing.T) {
+	cache := NewInMemoryCache(&config.CacheConfig{
+		Enabled:  true,
+		TTL:      time.Minute,
+		MaxSize:  1,
+		Strategy: "lru",
+		Dir:      t.TempDir(),
+	})
+	defer cache.Close()
+
+	base := &fakeHandler{}
+	handler := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute}))
+
+	for i := 0; i < 3; i++ {
+		resp, err := handler.Chat(context.Background(), testRequest())
+		require.NoError(t, err)
+		assert.Equal(t, "answer", resp.Content)
+	}
+	assert.Equal(t, 1, base.calls, "repeated requests should be served from cache")
+
+	// Streams for the same request replay the cached response
+	chunks, err := handler.ChatStream(context.Background(), testRequest())
+	require.NoError(t, err)
+	content, final := drainStream(t, chunks)
+	assert.Equal(t, "answer", content)
+	assert.True(t, final.Done)
+	assert.Equal(t, 1, base.calls)
+}
+
+func TestCacheInterceptor_ReturnsCopies(t *testing.T) {
+	cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Dir: t.TempDir()})
+	defer cache.Close()
+
+	base := &fakeHandler{}
+	handler := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute}))
+
+	// Neither the response that filled the cache nor a hit is the one cached
+	resp, err := handler.Chat(context.Background(), testRequest())
+	require.NoError(t, err)
+	resp.Content = "changed by the caller"
+	resp, err = handler.Chat(context.Background(), testRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "answer", resp.Content)
+	resp.Content = "changed again"
+
+	resp, err = handler.Chat(context.Background(), testRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "answer", resp.Content)
+	assert.Equal(t, 1, base.calls)
+}
+
+func TestCacheInterceptor_ConcurrentHits(t *testing.T) {
+	// Run with -race: hits read the entry's access count while other hits
+	// on the same key update it
+	cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1})
+	defer cache.Close()
+
+	base := &fakeHandler{}
+	handler := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute}))
+	_, err := handler.Chat(context.Background(), testRequest())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := handler.Chat(context.Background(), testRequest())
+			assert.NoError(t, err)
+			assert.Equal(t, "answer", resp.Content)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, base.calls)
+}
+
+func TestCacheInterceptor_WithoutCache(t *testing.T) {
+	cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Dir: t.TempDir()})
+	defer cache.Close()
+
+	base := &fakeHandler{}
+	handler := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute}))
+	_, err := handler.Chat(context.Background(), testRequest())
+	require.NoError(t, err)
+	require.Equal(t, 1, base.calls)
+
+	// Bypassing calls reach the API although the request is cached
+	ctx := WithoutCache(context.Background())
+	resp, err := handler.Chat(ctx, testRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "answer", resp.Content)
+	chunks, err := handler.ChatStream(ctx, testRequest())
+	require.NoError(t, err)
+	drainStream(t, chunks)
+	assert.Equal(t, 3, base.calls)
+	assert.Zero(t, cache.Stats().Hits)
+
+	// Offline there is no API to reach
+	offline := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute, Offline: true}))
+	_, err = offline.Chat(ctx, testRequest())
+	assert.ErrorContains(t, err, "offline")
+	assert.Equal(t, 3, base.calls)
+}
+
+// drainStream collects a stream's content and returns it with the last chunk
+func drainStream(t *testing.T, chunks <-chan StreamChunk) (string, StreamChunk) {
+	var content strings.Builder
+	var last StreamChunk
+	for chunk := range chunks {
+		content.WriteString(chunk.Content)
+		last = chunk
+	}
+	return content.String(), last
+}
+
+// scriptedStream streams fixed chunks, counting calls
+type scriptedStream struct {
+	calls  int
+	chunks []StreamChunk
+	delay  time.Duration // between chunks
+}
+
+func (s *scriptedStream) Chat(ctx context.Context, req *Request) (*Response, error) {
+	s.calls++
+	return &Response{Content: "unary", Model: req.Options.Model}, nil
+}
+
+func (s *scriptedStream) ChatStream(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+	s.calls++
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		for _, chunk := range s.chunks {
+			time.Sleep(s.delay)
+			out <- chunk
+		}
+	}()
+	return out, nil
+}
+
+// endlessStream streams chunks until its context is done
+type endlessStream struct{}
+
+func (endlessStream) Chat(ctx context.Context, req *Request) (*Response, error) {
+	return &Response{Content: "unary"}, nil
+}
+
+func (endlessStream) ChatStream(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case out <- StreamChunk{Content: "more"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func TestStreamInterceptors_StopWhenCancelled(t *testing.T) {
+	metrics := utils.InitMetrics()
+	metrics.Reset()
+	defer metrics.Reset()
+	cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: "lru"})
+	defer cache.Close()
+
+	interceptors := map[string]Interceptor{
+		"Tracing": TracingInterceptor(),
+		"Cache":   CacheInterceptor(cache, CacheOptions{TTL: time.Minute}),
+		"Metrics": MetricsInterceptor(metrics),
+	}
+	for name, interceptor := range interceptors {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			chunks, err := Chain(endlessStream{}, interceptor).ChatStream(ctx, testRequest())
+			require.NoError(t, err)
+			<-chunks
+
+			// The caller stops reading; the interceptor must not block
+			// sending it the next chunk
+			cancel()
+			time.Sleep(50 * time.Millisecond)
+			select {
+			case chunk, ok := <-chunks:
+				assert.False(t, ok, "stream still sending after cancellation: %+v", chunk)
+			case <-time.After(time.Second):
+				t.Fatal("stream not closed after cancellation")
+			}
+		})
+	}
+}
+
+func TestCacheInterceptor_Streams(t *testing.T) {
+	newCache := func() *InMemoryCache {
+		cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: "lru"})
+		t.Cleanup(func() { cache.Close() })
+		return cache
+	}
+	complete := []StreamChunk{
+		{Content: "Hello"},
+		{Content: ", world"},
+		{Done: true, Usage: &Usage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5}, FinishReason: "stop"},
+	}
+
+	t.Run("ReplaysCompletedStreams", func(t *testing.T) {
+		cache := newCache()
+		base := &scriptedStream{chunks: complete}
+		handler := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute}))
+
+		for i := 0; i < 2; i++ {
+			chunks, err := handler.ChatStream(context.Background(), testRequest())
+			require.NoError(t, err)
+			content, final := drainStream(t, chunks)
+			assert.Equal(t, "Hello, world", content)
+			assert.True(t, final.Done)
+			require.NotNil(t, final.Usage)
+			assert.Equal(t, 5, final.Usage.TotalTokens)
+			assert.Equal(t, "stop", final.FinishReason)
+		}
+		assert.Equal(t, 1, base.calls)
+
+		entry, found := cache.Get(cache.GenerateChatKey(testRequest().Messages, testRequest().Options))
+		require.True(t, found)
+		assert.Len(t, entry.Chunks, 2)
+		assert.Equal(t, "stop", entry.Response.FinishReason)
+
+		// Unary calls are served from the stream's entry
+		resp, err := handler.Chat(context.Background(), testRequest())
+		require.NoError(t, err)
+		assert.Equal(t, "Hello, world", resp.Content)
+		assert.Equal(t, 1, base.calls)
+	})
+
+	t.Run("SkipsFailedStreams", func(t *testing.T) {
+		failures := map[string][]StreamChunk{
+			"error":     {{Content: "Hel"}, {Error: errors.New("connection reset"), Done: true}},
+			"cancelled": {{Content: "Hel"}, {Error: context.Canceled, Done: true}},
+			"truncated": {{Content: "Hel"}},
+		}
+		for name, chunks := range failures {
+			cache := newCache()
+			base := &scriptedStream{chunks: chunks}
+			handler := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute}))
+			for i := 0; i < 2; i++ {
+				stream, err := handler.ChatStream(context.Background(), testRequest())
+				require.NoError(t, err)
+				drainStream(t, stream)
+			}
+			assert.Equal(t, 2, base.calls, "%s streams should not be cached", name)
+			assert.Zero(t, cache.Stats().Entries, name)
+		}
+	})
+
+	t.Run("Pacing", func(t *testing.T) {
+		cache := newCache()
+		base := &scriptedStream{chunks: complete, delay: 30 * time.Millisecond}
+		chunks, err := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute})).ChatStream(context.Background(), testRequest())
+		require.NoError(t, err)
+		drainStream(t, chunks)
+
+		start := time.Now()
+		chunks, err = Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute})).ChatStream(context.Background(), testRequest())
+		require.NoError(t, err)
+		drainStream(t, chunks)
+		assert.Less(t, time.Since(start), 20*time.Millisecond, "unpaced replay should be immediate")
+
+		start = time.Now()
+		chunks, err = Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute, Pace: true})).ChatStream(context.Background(), testRequest())
+		require.NoError(t, err)
+		content, _ := drainStream(t, chunks)
+		assert.Equal(t, "Hello, world", content)
+		assert.GreaterOrEqual(t, time.Since(start), 25*time.Millisecond, "paced replay should keep the gap between chunks")
+		assert.Equal(t, 1, base.calls)
+	})
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	cfg := RetryConfig{
+		MaxRetries:         2,
+		InitialDelay:       time.Millisecond,
+		MaxDelay:           10 * time.Millisecond,
+		Multiplier:         2,
+		RetryableHTTPCodes: []int{500, 503},
+	}
+
+	t.Run("RetriesRetryableErrors", func(t *testing.T) {
+		base := &fakeHandler{errors: []error{apiError(503), apiError(429)}}
+		resp, err := Chain(base, RetryInterceptor(cfg)).Chat(context.Background(), testRequest())
+		require.NoError(t, err)
+		assert.Equal(t, "answer", resp.Content)
+		assert.Equal(t, 3, base.calls)
+	})
+
+	t.Run("StopsOnNonRetryableError", func(t *testing.T) {
+		base := &fakeHandler{errors: []error{apiError(400)}}
+		_, err := Chain(base, RetryInterceptor(cfg)).Chat(context.Background(), testRequest())
+		require.Error(t, err)
+		assert.Equal(t, 1, base.calls)
+	})
+
+	t.Run("GivesUpAfterMaxRetries", func(t *testing.T) {
+		base := &fakeHandler{errors: []error{apiError(500), apiError(500), apiError(500), apiError(500)}}
+		_, err := Chain(base, RetryInterceptor(cfg)).Chat(context.Background(), testRequest())
+		require.Error(t, err)
+		assert.Equal(t, 3, base.calls)
+	})
+
+	t.Run("RetriesOpeningStreams", func(t *testing.T) {
+		base := &fakeHandler{errors: []error{apiError(503)}}
+		chunks, err := Chain(base, RetryInterceptor(cfg)).ChatStream(context.Background(), testRequest())
+		require.NoError(t, err)
+		assert.Equal(t, "answer", (<-chunks).Content)
+		assert.Equal(t, 2, base.calls)
+	})
+}
+
+func TestRateLimitInterceptor_ContextCancelled(t *testing.T) {
+	limiter := &RateLimiter{
+		minInterval:    time.Second,
+		requestsPerMin: 60,
+		windowStart:    time.Now(),
+		// Pretend a request was just made so the next one has to wait
+		lastRequestTime: time.Now(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	base := &fakeHandler{}
+	_, err := Chain(base, RateLimitInterceptor(limiter)).ChatStream(ctx, testRequest())
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 0, base.calls)
+}
+
+func TestOpenAIClient_Use(t *testing.T) {
+	client, err := NewOpenAIClient(&config.Config{
+		OpenAI: config.OpenAIConfig{APIKey: "test-key", Model: "gpt-4o", Timeout: time.Second},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// A policy interceptor can short-circuit calls before they reach the API
+	blocked := errors.New("blocked by policy")
+	client.Use(UnaryInterceptor(func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+		assert.Equal(t, "gpt-4o", req.Options.Model, "defaults should be applied before interceptors run")
+		return nil, blocked
+	}))
+
+	_, err = client.Query(context.Background(), "hello")
+	assert.ErrorIs(t, err, blocked)
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	metrics := utils.InitMetrics()
+	metrics.Reset()
+	defer metrics.Reset()
+
+	handler := Chain(&fakeHandler{}, MetricsInterceptor(metrics))
+
+	_, err := handler.Chat(context.Background(), testRequest())
+	require.NoError(t, err)
+
+	chunks, err := handler.ChatStream(context.Background(), testRequest())
+	require.NoError(t, err)
+	for range chunks {
+	}
+
+	var sb strings.Builder
+	require.NoError(t, metrics.WritePrometheus(&sb))
+	output := sb.String()
+
+	assert.Contains(t, output, `terminal_ai_api_requests_total{endpoint="/v1/chat/completions",status="200"} 1`)
+	assert.Contains(t, output, `terminal_ai_api_requests_total{endpoint="/v1/chat/completions:stream",status="200"} 1`)
+	assert.Contains(t, output, `terminal_ai_time_to_first_token_seconds_count{model="gpt-4o"} 1`)
+
+	// Failed attempts are counted by status code
+	_, err = Chain(&fakeHandler{errors: []error{apiError(503)}}, MetricsInterceptor(metrics)).Chat(context.Background(), testRequest())
+	require.Error(t, err)
+	sb.Reset()
+	require.NoError(t, metrics.WritePrometheus(&sb))
+	assert.Contains(t, sb.String(), `terminal_ai_api_requests_total{endpoint="/v1/chat/completions",status="503"} 1`)
+}
+
+// discardExporter drops exported spans
+type discardExporter struct{}
+
+func (discardExporter) Export(ctx context.Context, spans []*tracing.Span) error { return nil }
+func (discardExporter) Close() error                                            { return nil }
+
+func TestTracingInterceptor_AttachesTraceID(t *testing.T) {
+	tracing.Setup(tracing.Config{Exporter: discardExporter{}})
+	defer tracing.Shutdown(context.Background())
+
+	ctx, span := tracing.Start(context.Background(), "command")
+	defer span.End()
+
+	appErr := utils.NewAppError(utils.ErrCodeAPIFailure, "upstream failed", nil)
+	handler := Chain(&fakeHandler{errors: []error{appErr}}, TracingInterceptor())
+
+	_, err := handler.Chat(ctx, testRequest())
+	require.Error(t, err)
+	assert.Equal(t, span.TraceID(), appErr.RequestID)
+}
@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ModelListCache keeps the models an endpoint serves in a file for a while,
+// so shell completion and listings do not call the network each time
+type ModelListCache struct {
+	Path    string        // cache file; empty disables caching
+	BaseURL string        // endpoint the list belongs to
+	TTL     time.Duration // how long a list stays fresh; zero disables caching
+}
+
+// modelListFile is the cache file layout
+type modelListFile struct {
+	BaseURL   string    `json:"base_url"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Models    []string  `json:"models"`
+}
+
+// enabled reports whether lists are cached at all
+func (c ModelListCache) enabled() bool {
+	return c.Path != "" && c.TTL > 0
+}
+
+// Load returns the cached list when it is fresh and was fetched from the
+// same endpoint
+func (c ModelListCache) Load() ([]string, bool) {
+	if !c.enabled() {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return nil, false
+	}
+	var file modelListFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, false
+	}
+	if file.BaseURL != c.BaseURL || time.Since(file.FetchedAt) > c.TTL {
+		return nil, false
+	}
+	return file.Models, true
+}
+
+// Save stores a freshly fetched list
+func (c ModelListCache) Save(models []string) error {
+	if !c.enabled() {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.Marshal(modelListFile{BaseURL: c.BaseURL, FetchedAt: time.Now(), Models: models})
+	if err != nil {
+		return fmt.Errorf("failed to encode model list: %w", err)
+	}
+
+	// Write to a temporary file and rename so readers never see half a list
+	tempFile := c.Path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write model list cache: %w", err)
+	}
+	if err := os.Rename(tempFile, c.Path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to write model list cache: %w", err)
+	}
+	return nil
+}
+
+// ListModelsCached returns the models the endpoint serves, from the cache
+// while it is fresh unless refresh is set. A list that cannot be cached is
+// still returned.
+func ListModelsCached(ctx context.Context, client Client, cache ModelListCache, refresh bool) ([]string, error) {
+	if !refresh {
+		if models, ok := cache.Load(); ok {
+			return models, nil
+		}
+	}
+
+	models, err := client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Save(models); err != nil {
+		log.Debug().Err(err).Msg("Failed to cache model list")
+	}
+	return models, nil
+}
@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listingClient serves a fixed model list and counts the calls
+type listingClient struct {
+	Client
+	models []string
+	err    error
+	calls  int
+}
+
+func (c *listingClient) ListModels(ctx context.Context) ([]string, error) {
+	c.calls++
+	return c.models, c.err
+}
+
+func TestListModelsCached(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache", "models.json")
+	cache := ModelListCache{Path: path, BaseURL: "https://api.example.com/v1", TTL: time.Hour}
+	client := &listingClient{models: []string{"gpt-5", "local/llama"}}
+
+	models, err := ListModelsCached(ctx, client, cache, false)
+	require.NoError(t, err)
+	assert.Equal(t, client.models, models)
+	assert.FileExists(t, path)
+
+	// A fresh list is served from the cache
+	client.models = []string{"gpt-5"}
+	models, err = ListModelsCached(ctx, client, cache, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gpt-5", "local/llama"}, models)
+	assert.Equal(t, 1, client.calls)
+
+	// Refreshing fetches and replaces the cached list
+	models, err = ListModelsCached(ctx, client, cache, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gpt-5"}, models)
+	assert.Equal(t, 2, client.calls)
+	cached, ok := cache.Load()
+	require.True(t, ok)
+	assert.Equal(t, []string{"gpt-5"}, cached)
+
+	// Lists of another endpoint and stale lists are not used
+	_, ok = ModelListCache{Path: path, BaseURL: "http://localhost:8080/v1", TTL: time.Hour}.Load()
+	assert.False(t, ok)
+	old := time.Now().Add(-2 * time.Hour)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte(`{"base_url":"https://api.example.com/v1","fetched_at":"`+old.Format(time.RFC3339)+`","models":["stale"]}`), 0644))
+	_, ok = cache.Load()
+	assert.False(t, ok)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	// Errors are returned and leave the cache alone
+	client.err = errors.New("offline")
+	_, err = ListModelsCached(ctx, client, cache, true)
+	assert.Error(t, err)
+	cached, ok = cache.Load()
+	require.True(t, ok)
+	assert.Equal(t, []string{"gpt-5"}, cached)
+}
+
+func TestListModelsCachedDisabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	client := &listingClient{models: []string{"gpt-5"}}
+	cache := ModelListCache{Path: path, TTL: 0}
+
+	for i := 0; i < 2; i++ {
+		_, err := ListModelsCached(context.Background(), client, cache, false)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, client.calls)
+	assert.NoFileExists(t, path)
+}
@@ -0,0 +1,13 @@
+package ai
+
+import "github.com/user/terminal-ai/internal/config"
+
+// EstimateCost returns the cost of usage in USD from the model registry's
+// list prices, or false for models without a known price
+func EstimateCost(model string, usage Usage) (float64, bool) {
+	info, ok := config.LookupModel(model)
+	if !ok || info.Pricing == nil {
+		return 0, false
+	}
+	return (float64(usage.PromptTokens)*info.Pricing.Input + float64(usage.CompletionTokens)*info.Pricing.Output) / 1e6, true
+}
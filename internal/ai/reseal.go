@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"fmt"
+
+	"github.com/user/terminal-ai/internal/storage"
+)
+
+// Resealer is implemented by caches that can rewrite what they persist
+// under a new storage key
+type Resealer interface {
+	// Reseal rewrites the persisted entries sealed by store, and seals with
+	// store from then on
+	Reseal(store *storage.Store) error
+}
+
+// Reseal saves the cache file sealed by store
+func (c *InMemoryCache) Reseal(store *storage.Store) error {
+	c.mu.Lock()
+	c.store = store
+	c.mu.Unlock()
+	return c.Save()
+}
+
+// Reseal rewrites the log with every record sealed by store. Records other
+// processes append under the old key after this are unreadable once the
+// old key is dropped.
+func (c *DiskCache) Reseal(store *storage.Store) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.withLock(true, func() error {
+		c.store = store
+		return c.rewrite()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reseal cache: %w", err)
+	}
+	return nil
+}
+
+// ResealCache rewrites the persisted cache sealed by store. See Resealer. A
+// disabled cache that has a directory is opened for it, so its files are
+// not left under the old key.
+func (c *OpenAIClient) ResealCache(store *storage.Store) error {
+	cache := c.cache
+	if cache == nil {
+		if c.config.Cache.Dir == "" {
+			return nil
+		}
+		opened, err := NewCache(&c.config.Cache)
+		if err != nil {
+			return err
+		}
+		defer opened.Close()
+		cache = opened
+	}
+	resealer, ok := cache.(Resealer)
+	if !ok {
+		return fmt.Errorf("the cache cannot be resealed")
+	}
+	return resealer.Reseal(store)
+}
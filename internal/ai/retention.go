@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"fmt"
+	"time"
+)
+
+// PurgeResult is what a purge removed, or would remove on a dry run
+type PurgeResult struct {
+	Entries int
+	Bytes   int64
+}
+
+// Purger is implemented by caches that can enforce a retention policy
+type Purger interface {
+	// Purge removes the entries created more than maxAge ago, expired ones
+	// included, and then the oldest entries until the rest take at most
+	// maxBytes. Zero limits are not enforced. With dryRun nothing is removed.
+	Purge(maxAge time.Duration, maxBytes int64, dryRun bool) (PurgeResult, error)
+}
+
+// purgeVictims returns the items a purge removes. items must be sorted
+// oldest first.
+func purgeVictims(items []CacheItem, maxAge time.Duration, maxBytes int64, now time.Time) []CacheItem {
+	var total int64
+	for _, item := range items {
+		total += item.Entry.SizeBytes
+	}
+
+	var victims []CacheItem
+	for _, item := range items {
+		tooOld := maxAge > 0 && now.Sub(item.Entry.CreatedAt) > maxAge
+		tooBig := maxBytes > 0 && total > maxBytes
+		if !tooOld && !tooBig {
+			break
+		}
+		victims = append(victims, item)
+		total -= item.Entry.SizeBytes
+	}
+	return victims
+}
+
+// resultFor sums what removing victims frees
+func resultFor(victims []CacheItem) PurgeResult {
+	result := PurgeResult{Entries: len(victims)}
+	for _, item := range victims {
+		result.Bytes += item.Entry.SizeBytes
+	}
+	return result
+}
+
+// Purge removes entries by age and size, then saves the cache so the
+// removed entries leave the file too
+func (c *InMemoryCache) Purge(maxAge time.Duration, maxBytes int64, dryRun bool) (PurgeResult, error) {
+	c.mu.Lock()
+	items := make([]CacheItem, 0, len(c.entries))
+	for key, node := range c.entries {
+		items = append(items, CacheItem{Key: key, Entry: node.entry})
+	}
+	sortItems(items)
+	victims := purgeVictims(items, maxAge, maxBytes, time.Now())
+	if !dryRun {
+		for _, item := range victims {
+			c.removeLocked(item.Key)
+		}
+	}
+	c.mu.Unlock()
+
+	result := resultFor(victims)
+	if dryRun || result.Entries == 0 {
+		return result, nil
+	}
+	return result, c.Save()
+}
+
+// Purge removes entries by age and size across every process, then rewrites
+// the log so the removed entries leave the file too
+func (c *DiskCache) Purge(maxAge time.Duration, maxBytes int64, dryRun bool) (PurgeResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var result PurgeResult
+	err := c.withLock(!dryRun, func() error {
+		items := make([]CacheItem, 0, len(c.entries))
+		for key, e := range c.entries {
+			items = append(items, CacheItem{Key: key, Entry: e.entry})
+		}
+		sortItems(items)
+		victims := purgeVictims(items, maxAge, maxBytes, time.Now())
+		result = resultFor(victims)
+		if dryRun || len(victims) == 0 {
+			return nil
+		}
+
+		records := make([]diskRecord, len(victims))
+		for i, item := range victims {
+			records[i] = diskRecord{Op: "delete", Key: item.Key}
+		}
+		if err := c.append(records...); err != nil {
+			return err
+		}
+		return c.rewrite()
+	})
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("failed to purge cache: %w", err)
+	}
+	return result, nil
+}
+
+// PurgeCache enforces a retention policy on the cache. See Purger.
+func (c *OpenAIClient) PurgeCache(maxAge time.Duration, maxBytes int64, dryRun bool) (PurgeResult, error) {
+	if c.cache == nil {
+		return PurgeResult{}, nil
+	}
+	purger, ok := c.cache.(Purger)
+	if !ok {
+		return PurgeResult{}, fmt.Errorf("the cache cannot be purged")
+	}
+	return purger.Purge(maxAge, maxBytes, dryRun)
+}
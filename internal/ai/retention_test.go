@@ -0,0 +1,95 @@
+package ai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+)
+
+// agedEntry returns an entry created age ago
+func agedEntry(content string, age time.Duration) *CacheEntry {
+	entry := diskEntryFor(content)
+	entry.CreatedAt = time.Now().Add(-age)
+	return entry
+}
+
+func TestPurgeVictims(t *testing.T) {
+	now := time.Now()
+	items := []CacheItem{
+		{Key: "a", Entry: &CacheEntry{CreatedAt: now.Add(-40 * 24 * time.Hour), SizeBytes: 100}},
+		{Key: "b", Entry: &CacheEntry{CreatedAt: now.Add(-10 * 24 * time.Hour), SizeBytes: 100}},
+		{Key: "c", Entry: &CacheEntry{CreatedAt: now.Add(-time.Hour), SizeBytes: 100}},
+	}
+	keys := func(victims []CacheItem) []string {
+		var keys []string
+		for _, item := range victims {
+			keys = append(keys, item.Key)
+		}
+		return keys
+	}
+
+	assert.Equal(t, []string{"a"}, keys(purgeVictims(items, 30*24*time.Hour, 0, now)))
+	assert.Equal(t, []string{"a", "b"}, keys(purgeVictims(items, 30*24*time.Hour, 100, now)))
+	assert.Equal(t, []string{"a"}, keys(purgeVictims(items, 0, 250, now)))
+	assert.Empty(t, purgeVictims(items, 0, 0, now))
+}
+
+func TestCache_Purge(t *testing.T) {
+	month := 30 * 24 * time.Hour
+
+	t.Run("Memory", func(t *testing.T) {
+		cfg := &config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU, StaleOnError: true, StaleGrace: 365 * 24 * time.Hour, Dir: t.TempDir()}
+		cache := NewInMemoryCache(cfg)
+		defer cache.Close()
+		require.NoError(t, cache.Set("old", agedEntry("docker ps", 40*24*time.Hour), time.Hour))
+		require.NoError(t, cache.Set("new", diskEntryFor("docker images"), 0))
+
+		result, err := cache.Purge(month, 0, true)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Entries)
+		_, found := cache.Peek("old")
+		assert.True(t, found, "a dry run removes nothing")
+
+		result, err = cache.Purge(month, 0, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Entries)
+		_, found = cache.Peek("old")
+		assert.False(t, found)
+
+		raw, err := os.ReadFile(filepath.Join(cfg.Dir, "cache.gob"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(raw), "docker ps", "the saved cache is purged too")
+	})
+
+	t.Run("Disk", func(t *testing.T) {
+		cfg := diskCacheConfig(t)
+		cfg.StaleOnError = true
+		cfg.StaleGrace = 365 * 24 * time.Hour
+		cache := openDiskCache(t, cfg)
+		// Expired, but kept for stale serving
+		require.NoError(t, cache.Set("old", agedEntry("docker ps", 40*24*time.Hour), time.Nanosecond))
+		require.NoError(t, cache.Set("new", diskEntryFor("docker images"), 0))
+		_, found := cache.GetStale("old")
+		require.True(t, found)
+
+		result, err := cache.Purge(month, 0, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Entries)
+		assert.Positive(t, result.Bytes)
+
+		// The log is rewritten, so the prompt is gone from disk
+		raw, err := os.ReadFile(filepath.Join(cfg.Dir, diskCacheLog))
+		require.NoError(t, err)
+		assert.NotContains(t, string(raw), "docker ps")
+
+		reopened := openDiskCache(t, cfg)
+		_, found = reopened.GetStale("old")
+		assert.False(t, found)
+		assert.Len(t, reopened.Entries(), 1)
+	})
+}
@@ -0,0 +1,361 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/tracing"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// Safety actions, from least to most severe
+const (
+	SafetyAllow = "allow"
+	SafetyLog   = "log"
+	SafetyWarn  = "warn"
+	SafetyBlock = "block"
+)
+
+// Phases of a call that the safety policy checks
+const (
+	SafetyPhasePrompt   = "prompt"
+	SafetyPhaseResponse = "response"
+)
+
+// safetySeverity orders actions so the most severe one wins
+var safetySeverity = map[string]int{
+	SafetyAllow: 0,
+	SafetyLog:   1,
+	SafetyWarn:  2,
+	SafetyBlock: 3,
+}
+
+// ModerateFunc scores text per category, with scores between 0 and 1
+type ModerateFunc func(ctx context.Context, text string) (map[string]float64, error)
+
+// SafetyViolation is a category whose score reached its threshold
+type SafetyViolation struct {
+	Category string
+	Score    float64
+	Action   string
+	Source   string // moderation or policy
+	Phase    string // prompt or response
+}
+
+// SafetyPolicy checks text against local regex/keyword rules and, optionally,
+// the moderation endpoint, applying per-category thresholds and actions
+type SafetyPolicy struct {
+	cfg      config.SafetyConfig
+	rules    []safetyRule
+	moderate ModerateFunc
+}
+
+// safetyRule is a compiled config.SafetyRule
+type safetyRule struct {
+	category string
+	patterns []*regexp.Regexp
+	keywords []string
+}
+
+// NewSafetyPolicy compiles the configured rules. moderate may be nil when the
+// moderation endpoint is not used.
+func NewSafetyPolicy(cfg config.SafetyConfig, moderate ModerateFunc) (*SafetyPolicy, error) {
+	policy := &SafetyPolicy{cfg: cfg}
+	if cfg.Moderation {
+		policy.moderate = moderate
+	}
+
+	for _, rule := range cfg.Rules {
+		compiled := safetyRule{category: strings.ToLower(rule.Category)}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid safety pattern %q: %w", pattern, err)
+			}
+			compiled.patterns = append(compiled.patterns, re)
+		}
+		for _, keyword := range rule.Keywords {
+			compiled.keywords = append(compiled.keywords, strings.ToLower(keyword))
+		}
+		policy.rules = append(policy.rules, compiled)
+	}
+
+	return policy, nil
+}
+
+// Check returns the violations found in text, most severe first
+func (p *SafetyPolicy) Check(ctx context.Context, text, phase string) ([]SafetyViolation, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	var violations []SafetyViolation
+
+	lower := strings.ToLower(text)
+	for _, rule := range p.rules {
+		if rule.matches(text, lower) {
+			violations = p.appendViolation(violations, rule.category, 1, "policy", phase)
+		}
+	}
+
+	if p.moderate != nil {
+		scores, err := p.moderate(ctx, text)
+		if err != nil {
+			if !p.cfg.FailOpen {
+				return nil, err
+			}
+			log.Warn().Ctx(ctx).Err(err).Msg("Moderation check failed, continuing (fail_open)")
+		}
+		for category, score := range scores {
+			violations = p.appendViolation(violations, category, score, "moderation", phase)
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if safetySeverity[violations[i].Action] != safetySeverity[violations[j].Action] {
+			return safetySeverity[violations[i].Action] > safetySeverity[violations[j].Action]
+		}
+		return violations[i].Score > violations[j].Score
+	})
+	return violations, nil
+}
+
+// appendViolation adds a violation when score reaches the category threshold
+// and the category is not allowed
+func (p *SafetyPolicy) appendViolation(violations []SafetyViolation, category string, score float64, source, phase string) []SafetyViolation {
+	threshold, action := p.categorySettings(category)
+	if score < threshold || action == SafetyAllow {
+		return violations
+	}
+	return append(violations, SafetyViolation{
+		Category: category,
+		Score:    score,
+		Action:   action,
+		Source:   source,
+		Phase:    phase,
+	})
+}
+
+// categorySettings returns the threshold and action for a category. Settings
+// for a parent category (e.g. "self-harm") apply to its subcategories
+// (e.g. "self-harm/intent") unless they have their own.
+func (p *SafetyPolicy) categorySettings(category string) (float64, string) {
+	threshold, action := p.cfg.Threshold, p.cfg.Action
+
+	parent, _, _ := strings.Cut(category, "/")
+	for _, name := range []string{parent, category} {
+		if settings, ok := p.cfg.Categories[name]; ok {
+			if settings.Threshold > 0 {
+				threshold = settings.Threshold
+			}
+			if settings.Action != "" {
+				action = settings.Action
+			}
+		}
+	}
+
+	if action == "" {
+		action = SafetyBlock
+	}
+	return threshold, action
+}
+
+// enforce logs violations and returns a PERMISSION_DENIED error when one of
+// them blocks the call
+func (p *SafetyPolicy) enforce(ctx context.Context, violations []SafetyViolation) error {
+	span := tracing.SpanFromContext(ctx)
+	for _, v := range violations {
+		span.AddEvent("safety.violation", map[string]interface{}{
+			"safety.category": v.Category,
+			"safety.score":    v.Score,
+			"safety.action":   v.Action,
+			"safety.phase":    v.Phase,
+		})
+
+		event := log.Info()
+		if v.Action == SafetyWarn || v.Action == SafetyBlock {
+			event = log.Warn()
+		}
+		event.Ctx(ctx).
+			Str("category", v.Category).
+			Float64("score", v.Score).
+			Str("action", v.Action).
+			Str("source", v.Source).
+			Str("phase", v.Phase).
+			Msg("Safety policy matched")
+	}
+
+	if len(violations) == 0 || violations[0].Action != SafetyBlock {
+		return nil
+	}
+
+	v := violations[0]
+	return utils.NewAppError(utils.ErrCodePermissionDenied,
+		fmt.Sprintf("%s blocked by safety policy: %s", v.Phase, v.Category), nil).
+		WithStatusCode(403).
+		WithContextMap(map[string]interface{}{
+			"category": v.Category,
+			"score":    v.Score,
+			"source":   v.Source,
+			"phase":    v.Phase,
+		}).
+		WithHint("Rephrase the request; categories and thresholds are configured under safety: in the config file").
+		WithTraceContext(ctx)
+}
+
+// guard checks text and enforces the policy on what it finds, returning the
+// error that blocks the call if any. Calls outside the chat interceptor
+// chain, such as images, speech and transcription, are guarded with it too.
+// A nil policy allows everything.
+func (p *SafetyPolicy) guard(ctx context.Context, text, phase string) error {
+	if p == nil {
+		return nil
+	}
+	violations, err := p.Check(ctx, text, phase)
+	if err != nil {
+		return err
+	}
+	return p.enforce(ctx, violations)
+}
+
+// checksResponses reports whether model output is checked as well as prompts
+func (p *SafetyPolicy) checksResponses() bool {
+	return p != nil && p.cfg.CheckResponses
+}
+
+// matches reports whether text matches the rule. lower is text in lower case.
+func (r safetyRule) matches(text, lower string) bool {
+	for _, keyword := range r.keywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// promptText returns the outgoing text to check. Assistant messages are
+// model output and are checked as responses instead.
+func promptText(messages []Message) string {
+	var parts []string
+	for _, msg := range messages {
+		if msg.Role != "assistant" && msg.Content != "" {
+			parts = append(parts, msg.Content)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// SafetyInterceptor checks prompts, and responses when configured, before
+// they pass through. With response checks on, streamed output is held until
+// the complete response has been checked.
+func SafetyInterceptor(policy *SafetyPolicy) Interceptor {
+	check := policy.guard
+
+	return func(next Handler) Handler {
+		return HandlerFuncs{
+			ChatFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				if err := check(ctx, promptText(req.Messages), SafetyPhasePrompt); err != nil {
+					return nil, err
+				}
+
+				resp, err := next.Chat(ctx, req)
+				if err != nil || !policy.checksResponses() {
+					return resp, err
+				}
+				if err := check(ctx, resp.Content, SafetyPhaseResponse); err != nil {
+					return nil, err
+				}
+				return resp, nil
+			},
+			ChatStreamFunc: func(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
+				if err := check(ctx, promptText(req.Messages), SafetyPhasePrompt); err != nil {
+					return nil, err
+				}
+
+				chunks, err := next.ChatStream(ctx, req)
+				if err != nil || !policy.checksResponses() {
+					return chunks, err
+				}
+
+				out := make(chan StreamChunk, cap(chunks))
+				go func() {
+					defer close(out)
+
+					send := func(chunk StreamChunk) bool {
+						select {
+						case out <- chunk:
+							return true
+						case <-ctx.Done():
+							return false
+						}
+					}
+
+					var held []StreamChunk
+					var content strings.Builder
+					for chunk := range chunks {
+						if chunk.Error != nil {
+							send(chunk)
+							return
+						}
+						content.WriteString(chunk.Content)
+						held = append(held, chunk)
+					}
+
+					if err := check(ctx, content.String(), SafetyPhaseResponse); err != nil {
+						send(StreamChunk{Error: err, Done: true})
+						return
+					}
+					for _, chunk := range held {
+						if !send(chunk) {
+							return
+						}
+					}
+				}()
+				return out, nil
+			},
+		}
+	}
+}
+
+// moderate scores text with the moderation endpoint, keeping the highest
+// score per category across results
+func (c *OpenAIClient) moderate(ctx context.Context, text string) (map[string]float64, error) {
+	ctx, span := tracing.Start(ctx, "safety.moderation")
+	defer span.End()
+
+	resp, err := c.client.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.ModerationNewParamsInputUnion{OfString: openai.String(text)},
+		Model: openai.ModerationModel(c.config.Safety.ModerationModel),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, classifyError(err)
+	}
+
+	scores := make(map[string]float64)
+	for _, result := range resp.Results {
+		var resultScores map[string]float64
+		if err := json.Unmarshal([]byte(result.CategoryScores.RawJSON()), &resultScores); err != nil {
+			return nil, fmt.Errorf("failed to decode moderation scores: %w", err)
+		}
+		for category, score := range resultScores {
+			if score > scores[category] {
+				scores[category] = score
+			}
+		}
+	}
+	span.SetAttribute("safety.flagged", len(resp.Results) > 0 && resp.Results[0].Flagged)
+	return scores, nil
+}
@@ -0,0 +1,259 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"math"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/tracing"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+const endpointEmbeddings = "/v1/embeddings"
+
+// EmbedFunc returns the embedding of text
+type EmbedFunc func(ctx context.Context, text string) (Vector, error)
+
+// SemanticLookup configures the cache interceptor to serve prompts that are
+// similar, not just identical, to a cached one
+type SemanticLookup struct {
+	Embed     EmbedFunc
+	Threshold float64 // minimum cosine similarity for a hit
+}
+
+// SemanticMatch marks a response served for a similar cached prompt
+type SemanticMatch struct {
+	Prompt     string  `json:"prompt"`     // the cached prompt that matched
+	Similarity float64 `json:"similarity"` // cosine similarity, 0 to 1
+}
+
+// SemanticIndex is implemented by caches that can search entries by
+// embedding
+type SemanticIndex interface {
+	// Nearest returns the unexpired entry in scope whose embedding is most
+	// similar to embedding
+	Nearest(scope string, embedding Vector) (key string, entry *CacheEntry, similarity float64, ok bool)
+	// Lookup is Get without counting a miss, so a request answered by a
+	// similar prompt counts one hit, and one that is not counts one miss,
+	// recorded with RecordMiss
+	Lookup(key string) (*CacheEntry, bool)
+	RecordMiss()
+}
+
+// Vector is an embedding. JSON holds it as base64 little-endian float32s,
+// about a third the size of a list of numbers.
+type Vector []float32
+
+// MarshalJSON encodes the vector as base64
+func (v Vector) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON decodes a vector encoded by MarshalJSON
+func (v *Vector) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid vector: %w", err)
+	}
+	if len(buf)%4 != 0 {
+		return fmt.Errorf("invalid vector length %d", len(buf))
+	}
+	vector := make(Vector, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	*v = vector
+	return nil
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0 if
+// they differ in length or either is zero
+func cosineSimilarity(a, b Vector) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// nearestEntry finds the entry in scope most similar to embedding among
+// entries, skipping expired ones
+func nearestEntry(entries iter.Seq2[string, *CacheEntry], scope string, embedding Vector) (string, *CacheEntry, float64, bool) {
+	now := time.Now()
+	var bestKey string
+	var best *CacheEntry
+	bestSimilarity := -1.0
+	for key, entry := range entries {
+		if entry.Scope != scope || len(entry.Embedding) == 0 || now.After(entry.ExpiresAt) {
+			continue
+		}
+		if similarity := cosineSimilarity(entry.Embedding, embedding); similarity > bestSimilarity {
+			bestKey, best, bestSimilarity = key, entry, similarity
+		}
+	}
+	return bestKey, best, bestSimilarity, best != nil
+}
+
+// semanticScope identifies what besides the prompt must match for a
+// semantic hit: the conversation before the last message, including the
+// system prompt, and every option that goes into the cache key, so an
+// answer capped at fewer tokens or made with other sampling settings is
+// never served for a similar prompt
+func semanticScope(req *Request) string {
+	return chatKey(req.Messages[:len(req.Messages)-1], req.Options)
+}
+
+// lastUserPrompt returns the content of the last message if it is from the
+// user
+func lastUserPrompt(messages []Message) string {
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return ""
+	}
+	return messages[len(messages)-1].Content
+}
+
+// cacheLookup is the outcome of looking a request up in the cache, and what
+// to store with its response on a miss
+type cacheLookup struct {
+	key       string
+	entry     *CacheEntry    // set on a hit
+	match     *SemanticMatch // set on a semantic hit
+	prompt    string
+	model     string
+	mode      string
+	scope     string
+	embedding Vector
+}
+
+// lookupCache looks a request up by its exact key, then, if semantic is set
+// and the cache supports it, by the similarity of its last user message
+func lookupCache(ctx context.Context, cache Cache, semantic *SemanticLookup, req *Request) *cacheLookup {
+	ctx, span := tracing.Start(ctx, "cache.lookup")
+	defer span.End()
+
+	lookup := &cacheLookup{
+		key:    cache.GenerateChatKey(req.Messages, req.Options),
+		prompt: lastUserPrompt(req.Messages),
+		model:  req.Options.Model,
+		mode:   modeFrom(ctx),
+	}
+	if index, ok := cache.(SemanticIndex); ok && semantic != nil {
+		lookup.entry, _ = index.Lookup(lookup.key)
+		if lookup.entry == nil {
+			semantic.find(ctx, index, req, lookup)
+		}
+		if lookup.entry == nil {
+			index.RecordMiss()
+		}
+	} else {
+		lookup.entry, _ = cache.Get(lookup.key)
+	}
+
+	span.SetAttribute("cache.hit", lookup.entry != nil)
+	if lookup.match != nil {
+		span.SetAttribute("cache.semantic", true)
+		span.SetAttribute("cache.similarity", lookup.match.Similarity)
+	}
+	return lookup
+}
+
+// find looks for a cached prompt similar to the request's, filling in the
+// lookup's embedding so the response can be stored with it
+func (s *SemanticLookup) find(ctx context.Context, index SemanticIndex, req *Request, lookup *cacheLookup) {
+	if lookup.prompt == "" {
+		return
+	}
+
+	embedding, err := s.Embed(ctx, lookup.prompt)
+	if err != nil {
+		log.Warn().Ctx(ctx).Err(err).Msg("Semantic cache lookup failed")
+		return
+	}
+	lookup.scope = semanticScope(req)
+	lookup.embedding = embedding
+
+	key, entry, similarity, ok := index.Nearest(lookup.scope, embedding)
+	if !ok || similarity < s.Threshold {
+		return
+	}
+	if cached, found := index.Lookup(key); found {
+		lookup.key = key
+		lookup.entry = cached
+		lookup.match = &SemanticMatch{Prompt: entry.Prompt, Similarity: similarity}
+	}
+}
+
+// annotate records where a new entry came from, and what a later semantic
+// lookup needs
+func (l *cacheLookup) annotate(entry *CacheEntry) {
+	entry.Prompt = l.prompt
+	entry.Model = l.model
+	entry.Mode = l.mode
+	if l.embedding != nil {
+		entry.Scope = l.scope
+		entry.Embedding = l.embedding
+	}
+}
+
+// embed returns the embedding of text from the configured embedding model.
+// The request goes through the client's rate limiter and retries.
+func (c *OpenAIClient) embed(ctx context.Context, text string) (Vector, error) {
+	ctx, span := tracing.Start(ctx, "cache.embed")
+	defer span.End()
+
+	model := c.config.Cache.Semantic.Model
+	span.SetAttribute("ai.model", model)
+
+	var resp *openai.CreateEmbeddingResponse
+	err := c.limited(ctx, "embedding", func(ctx context.Context) error {
+		start := time.Now()
+		var err error
+		resp, err = c.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+			Input: openai.EmbeddingNewParamsInputUnion{OfString: openai.String(text)},
+			Model: openai.EmbeddingModel(model),
+		})
+		c.metrics.RecordAPICall(endpointEmbeddings, time.Since(start), statusCode(err), err)
+		return classifyError(err)
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("no embedding returned")
+	}
+
+	c.metrics.RecordTokenUsage(utils.TokenUsage{
+		Model:        model,
+		PromptTokens: int(resp.Usage.PromptTokens),
+		TotalTokens:  int(resp.Usage.TotalTokens),
+	})
+	vector := make(Vector, len(resp.Data[0].Embedding))
+	for i, f := range resp.Data[0].Embedding {
+		vector[i] = float32(f)
+	}
+	return vector, nil
+}
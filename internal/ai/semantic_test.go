@@ -0,0 +1,245 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+)
+
+// wordEmbedder embeds text as counts of a fixed vocabulary, so prompts that
+// share words are similar
+func wordEmbedder(calls *int) EmbedFunc {
+	vocabulary := []string{"list", "show", "docker", "containers", "images", "weather"}
+	return func(ctx context.Context, text string) (Vector, error) {
+		*calls++
+		vector := make(Vector, len(vocabulary))
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			for i, known := range vocabulary {
+				if word == known {
+					vector[i]++
+				}
+			}
+		}
+		return vector, nil
+	}
+}
+
+func promptRequest(model, system, prompt string) *Request {
+	var messages []Message
+	if system != "" {
+		messages = append(messages, Message{Role: "system", Content: system})
+	}
+	messages = append(messages, Message{Role: "user", Content: prompt})
+	return &Request{Messages: messages, Options: ChatOptions{Model: model}}
+}
+
+func TestVector_JSON(t *testing.T) {
+	vector := Vector{0.25, -1, 3.5e-7, 0}
+	data, err := json.Marshal(vector)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), `"`), "vectors are encoded as base64 strings")
+
+	var decoded Vector
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, vector, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`"AAA="`), &decoded))
+	assert.Error(t, json.Unmarshal([]byte(`[1,2]`), &decoded))
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	assert.InDelta(t, 1, cosineSimilarity(Vector{1, 2}, Vector{2, 4}), 1e-9)
+	assert.InDelta(t, 0, cosineSimilarity(Vector{1, 0}, Vector{0, 1}), 1e-9)
+	assert.InDelta(t, -1, cosineSimilarity(Vector{1, 0}, Vector{-1, 0}), 1e-9)
+	assert.Zero(t, cosineSimilarity(Vector{1, 0}, Vector{1, 0, 0}))
+	assert.Zero(t, cosineSimilarity(Vector{0, 0}, Vector{1, 0}))
+}
+
+func TestCacheInterceptor_Semantic(t *testing.T) {
+	setup := func(t *testing.T, threshold float64) (*fakeHandler, Handler, *int) {
+		cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU})
+		t.Cleanup(func() { cache.Close() })
+		embeds := 0
+		base := &fakeHandler{}
+		semantic := &SemanticLookup{Embed: wordEmbedder(&embeds), Threshold: threshold}
+		return base, Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute, Semantic: semantic})), &embeds
+	}
+
+	t.Run("ServesSimilarPrompts", func(t *testing.T) {
+		base, handler, _ := setup(t, 0.6)
+		resp, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "be brief", "list docker containers"))
+		require.NoError(t, err)
+		assert.Nil(t, resp.Semantic)
+
+		resp, err = handler.Chat(context.Background(), promptRequest("gpt-4o", "be brief", "show docker containers"))
+		require.NoError(t, err)
+		assert.Equal(t, "answer", resp.Content)
+		require.NotNil(t, resp.Semantic)
+		assert.Equal(t, "list docker containers", resp.Semantic.Prompt)
+		assert.InDelta(t, 2.0/3.0, resp.Semantic.Similarity, 1e-6)
+		assert.Equal(t, 1, base.calls)
+
+		// Identical prompts are exact hits, not semantic ones
+		resp, err = handler.Chat(context.Background(), promptRequest("gpt-4o", "be brief", "list docker containers"))
+		require.NoError(t, err)
+		assert.Nil(t, resp.Semantic)
+		assert.Equal(t, 1, base.calls)
+	})
+
+	t.Run("RespectsThreshold", func(t *testing.T) {
+		base, handler, _ := setup(t, 0.9)
+		for _, prompt := range []string{"list docker containers", "show docker containers"} {
+			_, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", prompt))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, base.calls)
+	})
+
+	t.Run("ScopedToModelAndConversation", func(t *testing.T) {
+		base, handler, _ := setup(t, 0.6)
+		_, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "be brief", "list docker containers"))
+		require.NoError(t, err)
+
+		for _, req := range []*Request{
+			promptRequest("gpt-4o-mini", "be brief", "show docker containers"),
+			promptRequest("gpt-4o", "be verbose", "show docker containers"),
+			promptRequest("gpt-4o", "", "show docker containers"),
+		} {
+			resp, err := handler.Chat(context.Background(), req)
+			require.NoError(t, err)
+			assert.Nil(t, resp.Semantic)
+		}
+		assert.Equal(t, 4, base.calls)
+	})
+
+	t.Run("ScopedToOptions", func(t *testing.T) {
+		base, handler, _ := setup(t, 0.6)
+		low := promptRequest("gpt-5-mini", "be brief", "list docker containers")
+		low.Options.ReasoningEffort = "low"
+		_, err := handler.Chat(context.Background(), low)
+		require.NoError(t, err)
+
+		high := promptRequest("gpt-5-mini", "be brief", "show docker containers")
+		high.Options.ReasoningEffort = "high"
+		resp, err := handler.Chat(context.Background(), high)
+		require.NoError(t, err)
+		assert.Nil(t, resp.Semantic, "answers made with other options are not similar")
+		assert.Equal(t, 2, base.calls)
+	})
+
+	t.Run("ReplaysStreamsWithMatch", func(t *testing.T) {
+		base, handler, _ := setup(t, 0.6)
+		_, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+		require.NoError(t, err)
+
+		chunks, err := handler.ChatStream(context.Background(), promptRequest("gpt-4o", "", "show docker containers"))
+		require.NoError(t, err)
+		var match *SemanticMatch
+		var content strings.Builder
+		for chunk := range chunks {
+			content.WriteString(chunk.Content)
+			if chunk.Semantic != nil {
+				match = chunk.Semantic
+			}
+		}
+		assert.Equal(t, "answer", content.String())
+		require.NotNil(t, match)
+		assert.Equal(t, "list docker containers", match.Prompt)
+		assert.Equal(t, 1, base.calls)
+	})
+
+	t.Run("FallsThroughWhenEmbeddingFails", func(t *testing.T) {
+		cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU})
+		defer cache.Close()
+		base := &fakeHandler{}
+		semantic := &SemanticLookup{
+			Embed: func(ctx context.Context, text string) (Vector, error) {
+				return nil, errors.New("embeddings unavailable")
+			},
+			Threshold: 0.5,
+		}
+		handler := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute, Semantic: semantic}))
+		for i := 0; i < 2; i++ {
+			resp, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+			require.NoError(t, err)
+			assert.Equal(t, "answer", resp.Content)
+		}
+		assert.Equal(t, 1, base.calls, "exact hits still work without embeddings")
+	})
+
+	t.Run("NoEmbeddingOnExactHit", func(t *testing.T) {
+		_, handler, embeds := setup(t, 0.6)
+		for i := 0; i < 3; i++ {
+			_, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 1, *embeds)
+	})
+}
+
+func TestCacheInterceptor_SemanticStats(t *testing.T) {
+	caches := map[string]func(t *testing.T) Cache{
+		"Memory": func(t *testing.T) Cache {
+			cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU})
+			t.Cleanup(func() { cache.Close() })
+			return cache
+		},
+		"Disk": func(t *testing.T) Cache {
+			return openDiskCache(t, diskCacheConfig(t))
+		},
+	}
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			cache := newCache(t)
+			embeds := 0
+			semantic := &SemanticLookup{Embed: wordEmbedder(&embeds), Threshold: 0.6}
+			handler := Chain(&fakeHandler{}, CacheInterceptor(cache, CacheOptions{TTL: time.Minute, Semantic: semantic}))
+
+			_, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+			require.NoError(t, err)
+			stats := cache.Stats()
+			assert.Equal(t, int64(0), stats.Hits)
+			assert.Equal(t, int64(1), stats.Misses, "a request nothing answers is one miss")
+
+			resp, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "show docker containers"))
+			require.NoError(t, err)
+			require.NotNil(t, resp.Semantic)
+			stats = cache.Stats()
+			assert.Equal(t, int64(1), stats.Hits, "a semantic hit is one hit")
+			assert.Equal(t, int64(1), stats.Misses, "and no miss")
+		})
+	}
+}
+
+func TestDiskCache_Nearest(t *testing.T) {
+	cfg := diskCacheConfig(t)
+	cache := openDiskCache(t, cfg)
+
+	entry := diskEntryFor("docker ps")
+	entry.Prompt = "list docker containers"
+	entry.Scope = "scope"
+	entry.Embedding = Vector{1, 0, 1}
+	require.NoError(t, cache.Set("a", entry, 0))
+	other := diskEntryFor("weather")
+	other.Scope = "scope"
+	other.Embedding = Vector{0, 1, 0}
+	require.NoError(t, cache.Set("b", other, 0))
+
+	// Embeddings persist for other instances
+	reopened := openDiskCache(t, cfg)
+	key, found, similarity, ok := reopened.Nearest("scope", Vector{1, 0, 0.8})
+	require.True(t, ok)
+	assert.Equal(t, "a", key)
+	assert.Equal(t, "list docker containers", found.Prompt)
+	assert.Greater(t, similarity, 0.9)
+
+	_, _, _, ok = reopened.Nearest("other scope", Vector{1, 0, 1})
+	assert.False(t, ok)
+}
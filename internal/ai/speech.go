@@ -0,0 +1,302 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/tracing"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// MaxSpeechInputChars is the input limit of the speech endpoint
+const MaxSpeechInputChars = 4096
+
+// DefaultSpeechModel, DefaultVoice and DefaultSpeechFormat are used when no
+// speech settings are configured
+const (
+	DefaultSpeechModel  = "gpt-4o-mini-tts"
+	DefaultVoice        = "alloy"
+	DefaultSpeechFormat = "mp3"
+)
+
+const endpointSpeech = "/v1/audio/speech"
+
+// SpeechOptions configures speech synthesis
+type SpeechOptions struct {
+	Model         string  // tts-1, tts-1-hd, gpt-4o-mini-tts
+	Voice         string  // alloy, ash, coral, nova, ...
+	Format        string  // mp3, opus, aac, flac, wav, pcm
+	Speed         float64 // 0.25 to 4.0 (0 = API default)
+	Instructions  string  // tone or style, gpt-4o-mini-tts only
+	MaxInputChars int     // split text above this length (0 = MaxSpeechInputChars)
+}
+
+// Speak converts text to audio. Text above the input limit is split at
+// sentence boundaries and the audio of the pieces concatenated. The text is
+// checked against the safety policy before any of it is sent.
+func (c *OpenAIClient) Speak(ctx context.Context, text string, options SpeechOptions) ([]byte, error) {
+	if options.Model == "" {
+		options.Model = DefaultSpeechModel
+	}
+	if options.Voice == "" {
+		options.Voice = DefaultVoice
+	}
+	if options.Format == "" {
+		options.Format = DefaultSpeechFormat
+	}
+	limit := options.MaxInputChars
+	if limit <= 0 {
+		limit = MaxSpeechInputChars
+	}
+
+	pieces := splitSpeechText(speechText(text), limit)
+	if len(pieces) == 0 {
+		return nil, utils.NewValidationError("nothing to speak", "text")
+	}
+	if err := c.safety.guard(ctx, text, SafetyPhasePrompt); err != nil {
+		return nil, err
+	}
+	if len(pieces) > 1 && options.Format == "flac" {
+		return nil, utils.NewValidationError(
+			fmt.Sprintf("flac audio cannot be joined; the answer needs %d speech requests", len(pieces)), "format").
+			WithHint("Use mp3, opus, aac, wav or pcm for long answers")
+	}
+
+	ctx, span := tracing.Start(ctx, "ai.speech")
+	defer span.End()
+	span.SetAttribute("ai.model", options.Model)
+	span.SetAttribute("audio.voice", options.Voice)
+	span.SetAttribute("audio.format", options.Format)
+	span.SetAttribute("audio.pieces", len(pieces))
+
+	parts := make([][]byte, 0, len(pieces))
+	for i, piece := range pieces {
+		log.Debug().Ctx(ctx).Int("piece", i+1).Int("pieces", len(pieces)).Int("chars", utf8.RuneCountInString(piece)).Msg("Synthesizing speech")
+		audio, err := c.speakPiece(ctx, piece, options)
+		if err != nil {
+			span.RecordError(err)
+			if len(pieces) > 1 {
+				return nil, fmt.Errorf("failed to synthesize part %d of %d: %w", i+1, len(pieces), err)
+			}
+			return nil, err
+		}
+		parts = append(parts, audio)
+	}
+
+	audio, err := joinAudio(parts, options.Format)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttribute("audio.bytes", len(audio))
+	return audio, nil
+}
+
+// speakPiece synthesizes a single piece within the input limit
+func (c *OpenAIClient) speakPiece(ctx context.Context, text string, options SpeechOptions) ([]byte, error) {
+	params := openai.AudioSpeechNewParams{
+		Input:          text,
+		Model:          openai.SpeechModel(options.Model),
+		Voice:          openai.AudioSpeechNewParamsVoice(options.Voice),
+		ResponseFormat: openai.AudioSpeechNewParamsResponseFormat(options.Format),
+	}
+	if options.Speed > 0 {
+		params.Speed = openai.Float(options.Speed)
+	}
+	if options.Instructions != "" {
+		params.Instructions = openai.String(options.Instructions)
+	}
+
+	var audio []byte
+	err := c.limited(ctx, "speech", func(ctx context.Context) error {
+		start := time.Now()
+		resp, err := c.client.Audio.Speech.New(ctx, params)
+		if err == nil {
+			defer resp.Body.Close()
+			audio, err = io.ReadAll(resp.Body)
+		}
+		c.metrics.RecordAPICall(endpointSpeech, time.Since(start), statusCode(err), err)
+		return classifyError(err)
+	})
+	return audio, err
+}
+
+var (
+	markdownFence    = regexp.MustCompile("(?m)^[ \t]*```.*$")
+	markdownHeading  = regexp.MustCompile(`(?m)^[ \t]{0,3}#{1,6}[ \t]+`)
+	markdownBullet   = regexp.MustCompile(`(?m)^[ \t]*(?:[-*+]|\d+\.)[ \t]+`)
+	markdownEmphasis = regexp.MustCompile("[*_`]{1,3}")
+	markdownLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+)
+
+// speechText strips markdown markup that would otherwise be read aloud
+func speechText(text string) string {
+	text = markdownFence.ReplaceAllString(text, "")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = markdownHeading.ReplaceAllString(text, "")
+	text = markdownBullet.ReplaceAllString(text, "")
+	text = markdownEmphasis.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+// splitSpeechText splits text into pieces of at most limit characters,
+// breaking at sentence ends where possible, then at spaces
+func splitSpeechText(text string, limit int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+
+	var pieces []string
+	var current strings.Builder
+	flush := func() {
+		if piece := strings.TrimSpace(current.String()); piece != "" {
+			pieces = append(pieces, piece)
+		}
+		current.Reset()
+	}
+
+	for _, sentence := range splitSentences(text) {
+		if utf8.RuneCountInString(current.String())+utf8.RuneCountInString(sentence) > limit {
+			flush()
+		}
+		if utf8.RuneCountInString(sentence) <= limit {
+			current.WriteString(sentence)
+			continue
+		}
+		// A single sentence above the limit is split between words
+		for _, word := range splitLong(sentence, limit) {
+			if utf8.RuneCountInString(current.String())+utf8.RuneCountInString(word) > limit {
+				flush()
+			}
+			current.WriteString(word)
+		}
+	}
+	flush()
+	return pieces
+}
+
+// splitSentences splits text after sentence-ending punctuation and line
+// breaks, keeping the trailing whitespace with each sentence
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		end := runes[i] == '\n'
+		if strings.ContainsRune(".!?。！？", runes[i]) && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+			end = true
+		}
+		if !end {
+			continue
+		}
+		for i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+			i++
+		}
+		sentences = append(sentences, string(runes[start:i+1]))
+		start = i + 1
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+	return sentences
+}
+
+// splitLong splits a sentence into words, cutting words longer than limit
+func splitLong(sentence string, limit int) []string {
+	var words []string
+	for _, word := range strings.SplitAfter(sentence, " ") {
+		runes := []rune(word)
+		for len(runes) > limit {
+			words = append(words, string(runes[:limit]))
+			runes = runes[limit:]
+		}
+		words = append(words, string(runes))
+	}
+	return words
+}
+
+// joinAudio concatenates audio pieces. mp3, aac (ADTS), opus (Ogg) and raw
+// pcm streams can be appended as they are; wav pieces are merged into one
+// RIFF file.
+func joinAudio(parts [][]byte, format string) ([]byte, error) {
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	if format != "wav" {
+		return bytes.Join(parts, nil), nil
+	}
+
+	var header []byte
+	var data bytes.Buffer
+	for i, part := range parts {
+		fmtChunk, samples, err := parseWAV(part)
+		if err != nil {
+			return nil, utils.NewAppError(utils.ErrCodeInvalidResponse,
+				fmt.Sprintf("failed to join speech audio part %d", i+1), err)
+		}
+		if header == nil {
+			header = fmtChunk
+		}
+		data.Write(samples)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(4+8+len(header)+8+data.Len()))
+	out.WriteString("WAVE")
+	out.WriteString("fmt ")
+	binary.Write(&out, binary.LittleEndian, uint32(len(header)))
+	out.Write(header)
+	out.WriteString("data")
+	binary.Write(&out, binary.LittleEndian, uint32(data.Len()))
+	out.Write(data.Bytes())
+	return out.Bytes(), nil
+}
+
+// parseWAV returns the fmt chunk body and the samples of a RIFF/WAVE file.
+// Streamed files may declare an unknown data size, so the data chunk runs to
+// the end of the file when its size does not fit.
+func parseWAV(audio []byte) ([]byte, []byte, error) {
+	if len(audio) < 12 || string(audio[0:4]) != "RIFF" || string(audio[8:12]) != "WAVE" {
+		return nil, nil, fmt.Errorf("not a wav file")
+	}
+
+	var fmtChunk []byte
+	offset := 12
+	for offset+8 <= len(audio) {
+		id := string(audio[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(audio[offset+4 : offset+8]))
+		body := offset + 8
+		switch {
+		case id == "data":
+			if fmtChunk == nil {
+				return nil, nil, fmt.Errorf("wav data before fmt chunk")
+			}
+			end := body + size
+			if size < 0 || end > len(audio) || end < body {
+				end = len(audio)
+			}
+			return fmtChunk, audio[body:end], nil
+		case body+size > len(audio):
+			return nil, nil, fmt.Errorf("truncated %q chunk", id)
+		case id == "fmt ":
+			fmtChunk = audio[body : body+size]
+		}
+		offset = body + size + size%2
+	}
+	return nil, nil, fmt.Errorf("wav file has no data chunk")
+}
@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+func TestSplitSpeechText(t *testing.T) {
+	assert.Nil(t, splitSpeechText("   ", 10))
+	assert.Equal(t, []string{"Short answer."}, splitSpeechText("Short answer.", 100))
+
+	text := "First sentence here. Second one! Third? Fourth sentence ends it."
+	pieces := splitSpeechText(text, 35)
+	assert.Equal(t, []string{"First sentence here. Second one!", "Third? Fourth sentence ends it."}, pieces)
+
+	// Decimal points are not sentence ends
+	assert.Equal(t, []string{"Pi is 3.14 roughly.", "Yes."}, splitSpeechText("Pi is 3.14 roughly. Yes.", 20))
+
+	// Sentences above the limit are split between words, words above it cut
+	pieces = splitSpeechText("one two three four five six "+strings.Repeat("x", 25), 10)
+	for _, piece := range pieces {
+		assert.LessOrEqual(t, utf8.RuneCountInString(piece), 10, piece)
+	}
+	assert.Equal(t, "one two", pieces[0])
+	assert.Equal(t, "one two three four five six "+strings.Repeat("x", 25),
+		strings.Join(pieces[:len(pieces)-3], " ")+" "+strings.Join(pieces[len(pieces)-3:], ""))
+
+	// Limits count characters, not bytes
+	pieces = splitSpeechText("Grüße aus Köln. Schöne Grüße.", 16)
+	assert.Equal(t, []string{"Grüße aus Köln.", "Schöne Grüße."}, pieces)
+}
+
+func TestSpeechText(t *testing.T) {
+	markdown := "## Steps\n\n1. Run **make build**\n- See [the docs](https://example.com)\n\n```bash\nmake\n```\nUse `go test`."
+	assert.Equal(t, "Steps\n\nRun make build\nSee the docs\n\n\nmake\n\nUse go test.", speechText(markdown))
+}
+
+// wavFile builds a mono 16-bit wav file with n zero bytes of samples. With
+// streamed set, the data chunk declares an unknown size.
+func wavFile(n int, streamed bool) []byte {
+	b := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint32(b, 24000)
+	b = binary.LittleEndian.AppendUint32(b, 48000)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "LIST"...)
+	b = binary.LittleEndian.AppendUint32(b, 3)
+	b = append(b, "abc\x00"...) // odd-sized chunk with padding
+	b = append(b, "data"...)
+	size := uint32(n)
+	if streamed {
+		size = 0xFFFFFFFF
+	}
+	b = binary.LittleEndian.AppendUint32(b, size)
+	return append(b, make([]byte, n)...)
+}
+
+func TestJoinAudio(t *testing.T) {
+	joined, err := joinAudio([][]byte{[]byte("ab"), []byte("cd")}, "mp3")
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", string(joined))
+
+	joined, err = joinAudio([][]byte{wavFile(100, false), wavFile(50, true)}, "wav")
+	require.NoError(t, err)
+	format, samples, err := parseWAV(joined)
+	require.NoError(t, err)
+	assert.Len(t, format, 16)
+	assert.Len(t, samples, 150)
+	assert.Equal(t, uint32(len(joined)-8), binary.LittleEndian.Uint32(joined[4:8]))
+
+	_, err = joinAudio([][]byte{wavFile(10, false), []byte("not a wav")}, "wav")
+	assert.ErrorContains(t, err, "part 2")
+}
+
+func TestOpenAIClient_Speak(t *testing.T) {
+	client := newMockClient(t, ``)
+	ctx := context.Background()
+
+	audio, err := client.Speak(ctx, "**Hello** there.", SpeechOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "[Hello there.]", string(audio))
+
+	// Long answers are split at sentence boundaries and joined in order
+	audio, err = client.Speak(ctx, "First sentence. Second sentence. Third.", SpeechOptions{MaxInputChars: 20})
+	require.NoError(t, err)
+	assert.Equal(t, "[First sentence.][Second sentence.][Third.]", string(audio))
+
+	audio, err = client.Speak(ctx, "One two. Three four five.", SpeechOptions{Format: "wav", MaxInputChars: 10})
+	require.NoError(t, err)
+	_, samples, err := parseWAV(audio)
+	require.NoError(t, err)
+	assert.Len(t, samples, 5*4800)
+
+	_, err = client.Speak(ctx, "One two. Three four five.", SpeechOptions{Format: "flac", MaxInputChars: 10})
+	assert.Equal(t, utils.ExitUsage, utils.ExitCode(err))
+
+	// Input above the endpoint limit is rejected by the server
+	_, err = client.Speak(ctx, strings.Repeat("word ", 1000), SpeechOptions{MaxInputChars: 10000})
+	assert.Equal(t, utils.ExitUsage, utils.ExitCode(err))
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// StaleHit marks a response served from an expired cache entry, because the
+// client is offline or the API call failed
+type StaleHit struct {
+	CachedAt  time.Time `json:"cached_at"`  // when the response was cached
+	ExpiredAt time.Time `json:"expired_at"` // when the entry expired; zero if it has not
+	Reason    string    `json:"reason"`     // "offline", or the error that was served around
+}
+
+// StaleReader is implemented by caches that keep expired entries for a
+// grace period so they can be served when the API cannot be reached
+type StaleReader interface {
+	// GetStale returns the entry for key, even if it has expired, unless it
+	// expired longer ago than the grace period. It does not count a hit.
+	GetStale(key string) (*CacheEntry, bool)
+}
+
+// pastGrace reports whether entry expired longer than grace before now, so
+// it can no longer be served stale
+func pastGrace(entry *CacheEntry, grace time.Duration, now time.Time) bool {
+	return now.After(entry.ExpiresAt.Add(grace))
+}
+
+// staleHit returns the marker for serving entry stale for reason
+func staleHit(entry *CacheEntry, reason string, now time.Time) *StaleHit {
+	hit := &StaleHit{CachedAt: entry.CreatedAt, Reason: reason}
+	if now.After(entry.ExpiresAt) {
+		hit.ExpiredAt = entry.ExpiresAt
+	}
+	return hit
+}
+
+// staleReason describes err for a StaleHit
+func staleReason(err error) string {
+	if appErr := utils.GetAppError(err); appErr != nil && appErr.Message != "" {
+		return appErr.Message
+	}
+	return err.Error()
+}
+
+// serveStale returns the expired entry for key and its marker, if enabled
+// and the cache still has it, to answer a call that failed with err. Calls
+// cancelled by the caller are not answered.
+func serveStale(ctx context.Context, cache Cache, key string, err error, enabled bool) (*CacheEntry, *StaleHit) {
+	reader, ok := cache.(StaleReader)
+	if !enabled || !ok || ctx.Err() != nil {
+		return nil, nil
+	}
+	entry, found := reader.GetStale(key)
+	if !found || entry.Response == nil {
+		return nil, nil
+	}
+	log.Warn().
+		Ctx(ctx).
+		Err(err).
+		Str("key", key[:8]).
+		Time("cached_at", entry.CreatedAt).
+		Msg("Serving stale cached response")
+	return entry, staleHit(entry, staleReason(err), time.Now())
+}
+
+// serveOffline returns the entry for key, expired or not, and its marker,
+// or an error if the cache does not have it
+func serveOffline(ctx context.Context, cache Cache, key string) (*CacheEntry, *StaleHit, error) {
+	if reader, ok := cache.(StaleReader); ok {
+		if entry, found := reader.GetStale(key); found && entry.Response != nil {
+			log.Debug().Ctx(ctx).Str("key", key[:8]).Msg("Serving cached response offline")
+			return entry, staleHit(entry, "offline", time.Now()), nil
+		}
+	}
+	return nil, nil, utils.NewAppError(utils.ErrCodeCacheMiss, "no cached response for this prompt (offline)", nil).
+		WithHint("Run without --offline to ask the API, or check 'terminal-ai cache list'")
+}
+
+// errOfflineBypass is returned offline for requests made WithoutCache, which
+// only the API can answer
+func errOfflineBypass() error {
+	return utils.NewAppError(utils.ErrCodeCacheMiss, "this request needs the API and cannot be answered offline", nil).
+		WithHint("Run without --offline")
+}
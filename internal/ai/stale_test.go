@@ -0,0 +1,221 @@
+package ai
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+func staleMemoryCache(t *testing.T, grace time.Duration) *InMemoryCache {
+	cache := NewInMemoryCache(&config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU, StaleOnError: true, StaleGrace: grace})
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestCacheInterceptor_StaleOnError(t *testing.T) {
+	setup := func(t *testing.T, staleOnError bool) (*fakeHandler, Handler) {
+		base := &fakeHandler{}
+		options := CacheOptions{TTL: time.Millisecond, StaleOnError: staleOnError}
+		handler := Chain(base, CacheInterceptor(staleMemoryCache(t, time.Hour), options))
+		_, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		return base, handler
+	}
+
+	t.Run("ServesExpiredEntry", func(t *testing.T) {
+		base, handler := setup(t, true)
+		base.errors = []error{apiError(503)}
+		resp, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+		require.NoError(t, err)
+		assert.Equal(t, "answer", resp.Content)
+		require.NotNil(t, resp.Stale)
+		assert.NotEmpty(t, resp.Stale.Reason)
+		assert.False(t, resp.Stale.ExpiredAt.IsZero())
+		assert.Equal(t, 2, base.calls, "the API is tried first")
+	})
+
+	t.Run("ReplaysStreams", func(t *testing.T) {
+		base, handler := setup(t, true)
+		base.errors = []error{apiError(503)}
+		chunks, err := handler.ChatStream(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+		require.NoError(t, err)
+		var stale *StaleHit
+		for chunk := range chunks {
+			if chunk.Stale != nil {
+				stale = chunk.Stale
+			}
+		}
+		require.NotNil(t, stale)
+	})
+
+	t.Run("RefreshesOnSuccess", func(t *testing.T) {
+		base, handler := setup(t, true)
+		resp, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+		require.NoError(t, err)
+		assert.Nil(t, resp.Stale)
+		assert.Equal(t, 2, base.calls)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		base, handler := setup(t, false)
+		base.errors = []error{apiError(503)}
+		_, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+		assert.Error(t, err)
+	})
+
+	t.Run("NothingCached", func(t *testing.T) {
+		base, handler := setup(t, true)
+		base.errors = []error{apiError(503)}
+		_, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker images"))
+		assert.Error(t, err)
+	})
+
+	t.Run("NotWhenCancelled", func(t *testing.T) {
+		base, handler := setup(t, true)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		base.errors = []error{context.Canceled}
+		_, err := handler.Chat(ctx, promptRequest("gpt-4o", "", "list docker containers"))
+		assert.Error(t, err)
+	})
+}
+
+func TestCacheInterceptor_Offline(t *testing.T) {
+	cache := staleMemoryCache(t, time.Hour)
+	embeds := 0
+	semantic := &SemanticLookup{Embed: wordEmbedder(&embeds), Threshold: 0.1}
+	online := Chain(&fakeHandler{}, CacheInterceptor(cache, CacheOptions{TTL: time.Minute}))
+	_, err := online.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+	require.NoError(t, err)
+	weather := promptRequest("gpt-4o", "", "show the weather")
+	require.NoError(t, cache.Set(cache.GenerateChatKey(weather.Messages, weather.Options), diskEntryFor("sunny"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	base := &fakeHandler{}
+	handler := Chain(base, CacheInterceptor(cache, CacheOptions{TTL: time.Minute, Semantic: semantic, Offline: true}))
+
+	resp, err := handler.Chat(context.Background(), promptRequest("gpt-4o", "", "list docker containers"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer", resp.Content)
+	assert.Nil(t, resp.Stale, "fresh entries are plain hits")
+
+	chunks, err := handler.ChatStream(context.Background(), promptRequest("gpt-4o", "", "show the weather"))
+	require.NoError(t, err)
+	content, _ := drainStream(t, chunks)
+	assert.Equal(t, "sunny", content)
+
+	resp, err = handler.Chat(context.Background(), promptRequest("gpt-4o", "", "show the weather"))
+	require.NoError(t, err)
+	require.NotNil(t, resp.Stale)
+	assert.Equal(t, "offline", resp.Stale.Reason)
+
+	_, err = handler.Chat(context.Background(), promptRequest("gpt-4o", "", "show docker containers"))
+	require.Error(t, err)
+	assert.Equal(t, utils.ErrCodeCacheMiss, utils.GetAppError(err).Code)
+	_, err = handler.ChatStream(context.Background(), promptRequest("gpt-4o", "", "show docker containers"))
+	assert.Error(t, err)
+
+	assert.Zero(t, base.calls, "offline requests never reach the API")
+	assert.Zero(t, embeds, "offline requests are not embedded")
+}
+
+func TestCache_StaleGrace(t *testing.T) {
+	caches := map[string]func(t *testing.T, grace time.Duration) Cache{
+		"Memory": func(t *testing.T, grace time.Duration) Cache {
+			return staleMemoryCache(t, grace)
+		},
+		"Disk": func(t *testing.T, grace time.Duration) Cache {
+			cfg := diskCacheConfig(t)
+			cfg.StaleOnError = true
+			cfg.StaleGrace = grace
+			return openDiskCache(t, cfg)
+		},
+	}
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			cache := newCache(t, time.Hour)
+			require.NoError(t, cache.Set("old", diskEntryFor("docker ps"), time.Nanosecond))
+			time.Sleep(time.Millisecond)
+
+			_, found := cache.Get("old")
+			assert.False(t, found, "expired entries are misses")
+			entry, found := cache.(StaleReader).GetStale("old")
+			require.True(t, found, "expired entries are kept for the grace period")
+			assert.Equal(t, "docker ps", entry.Response.Content)
+			assert.Empty(t, cache.(EntryLister).Entries())
+
+			// Writes and cleanups keep them too
+			require.NoError(t, cache.Set("new", diskEntryFor("docker images"), 0))
+			if memory, ok := cache.(*InMemoryCache); ok {
+				memory.cleanup()
+			}
+			_, found = cache.(StaleReader).GetStale("old")
+			assert.True(t, found)
+
+			// Past the grace period they are gone
+			short := newCache(t, time.Millisecond)
+			require.NoError(t, short.Set("old", diskEntryFor("docker ps"), time.Nanosecond))
+			time.Sleep(5 * time.Millisecond)
+			_, found = short.(StaleReader).GetStale("old")
+			assert.False(t, found)
+			require.NoError(t, short.Set("new", diskEntryFor("docker images"), 0))
+			switch short := short.(type) {
+			case *InMemoryCache:
+				short.cleanup()
+				assert.NotContains(t, short.entries, "old")
+			case *DiskCache:
+				assert.NotContains(t, short.entries, "old")
+			}
+		})
+	}
+}
+
+func TestCache_StaleKeptForOffline(t *testing.T) {
+	// A run without stale_on_error or offline mode keeps expired entries, so
+	// a later --offline run can still answer from them
+	caches := map[string]func(cfg *config.CacheConfig) Cache{
+		"Memory": func(cfg *config.CacheConfig) Cache {
+			cfg.Backend = "memory"
+			return NewInMemoryCache(cfg)
+		},
+		"Disk": func(cfg *config.CacheConfig) Cache {
+			return openDiskCache(t, cfg)
+		},
+	}
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			cfg := diskCacheConfig(t)
+			cfg.StaleGrace = time.Hour
+			req := promptRequest("gpt-4o", "", "list docker containers")
+
+			cache := newCache(cfg)
+			online := Chain(&fakeHandler{}, CacheInterceptor(cache, CacheOptions{TTL: time.Millisecond}))
+			_, err := online.Chat(context.Background(), req)
+			require.NoError(t, err)
+			time.Sleep(5 * time.Millisecond)
+			if memory, ok := cache.(*InMemoryCache); ok {
+				memory.cleanup()
+			}
+			require.NoError(t, cache.Close())
+
+			reopened := newCache(cfg)
+			t.Cleanup(func() { reopened.Close() })
+			_, found := reopened.Get(reopened.GenerateChatKey(req.Messages, req.Options))
+			assert.False(t, found, "expired entries are misses")
+
+			base := &fakeHandler{}
+			offline := Chain(base, CacheInterceptor(reopened, CacheOptions{TTL: time.Minute, Offline: true}))
+			resp, err := offline.Chat(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, "answer", resp.Content)
+			require.NotNil(t, resp.Stale)
+			assert.Zero(t, base.calls)
+		})
+	}
+}
@@ -11,9 +11,7 @@ import (
 	"time"
 
 	"github.com/openai/openai-go/v2"
-	"github.com/openai/openai-go/v2/shared"
 	"github.com/rs/zerolog/log"
-	"github.com/user/terminal-ai/internal/config"
 )
 
 // StreamHandler interface defines methods for handling streaming responses
@@ -39,86 +37,15 @@ func (h *StreamHandler) HandleStream(ctx context.Context, messages []openai.Chat
 	chunks := make(chan StreamChunk, 100) // Larger buffer for smoother streaming
 
 	// Create streaming request parameters
-	params := openai.ChatCompletionNewParams{
-		Model:    shared.ChatModel(options.Model),
-		Messages: messages,
-	}
-
-	// Add optional parameters
-	if options.Temperature > 0 {
-		params.Temperature = openai.Float(float64(options.Temperature))
-	}
-	if options.MaxTokens > 0 {
-		params.MaxCompletionTokens = openai.Int(int64(options.MaxTokens))
-	}
-	if options.TopP > 0 {
-		params.TopP = openai.Float(float64(options.TopP))
-	}
-	if options.N > 0 {
-		params.N = openai.Int(int64(options.N))
-	}
-	if len(options.Stop) > 0 {
-		// Convert stop sequences to the union type
-		if len(options.Stop) == 1 {
-			params.Stop = openai.ChatCompletionNewParamsStopUnion{
-				OfString: openai.String(options.Stop[0]),
-			}
-		} else {
-			params.Stop = openai.ChatCompletionNewParamsStopUnion{
-				OfStringArray: options.Stop,
-			}
-		}
-	}
-	if options.PresencePenalty != 0 {
-		params.PresencePenalty = openai.Float(float64(options.PresencePenalty))
-	}
-	if options.FrequencyPenalty != 0 {
-		params.FrequencyPenalty = openai.Float(float64(options.FrequencyPenalty))
-	}
-	if options.User != "" {
-		params.User = openai.String(options.User)
-	}
-
-	// Handle ServiceTier
-	if options.ServiceTier != "" {
-		switch options.ServiceTier {
-		case "auto":
-			params.ServiceTier = openai.ChatCompletionNewParamsServiceTierAuto
-		case "default":
-			params.ServiceTier = openai.ChatCompletionNewParamsServiceTierDefault
-		case "priority":
-			params.ServiceTier = openai.ChatCompletionNewParamsServiceTierPriority
-		case "flex":
-			params.ServiceTier = openai.ChatCompletionNewParamsServiceTierFlex
-		case "scale":
-			params.ServiceTier = openai.ChatCompletionNewParamsServiceTierScale
-		default:
-			params.ServiceTier = openai.ChatCompletionNewParamsServiceTierAuto
-		}
-	} else {
-		params.ServiceTier = openai.ChatCompletionNewParamsServiceTierDefault
-	}
-
-	// Handle ReasoningEffort for reasoning models
-	if config.IsReasoningModel(options.Model) && options.ReasoningEffort != "" {
-		switch options.ReasoningEffort {
-		case "minimal":
-			params.ReasoningEffort = shared.ReasoningEffortMinimal
-		case "low":
-			params.ReasoningEffort = shared.ReasoningEffortLow
-		case "medium":
-			params.ReasoningEffort = shared.ReasoningEffortMedium
-		case "high":
-			params.ReasoningEffort = shared.ReasoningEffortHigh
-		default:
-			params.ReasoningEffort = shared.ReasoningEffortMinimal
-		}
+	params := buildChatParams(messages, options)
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.Bool(true),
 	}
 
 	stream := h.client.Chat.Completions.NewStreaming(ctx, params)
 	if err := stream.Err(); err != nil {
 		close(chunks)
-		return chunks, fmt.Errorf("failed to create stream: %w", err)
+		return chunks, fmt.Errorf("failed to create stream: %w", classifyError(err))
 	}
 
 	// Process stream in goroutine
@@ -126,6 +53,8 @@ func (h *StreamHandler) HandleStream(ctx context.Context, messages []openai.Chat
 		defer close(chunks)
 
 		var totalContent strings.Builder
+		var usage *Usage
+		var finishReason string
 		hasContent := false
 
 		for stream.Next() {
@@ -133,13 +62,22 @@ func (h *StreamHandler) HandleStream(ctx context.Context, messages []openai.Chat
 			case <-ctx.Done():
 				// Context cancelled, send error and exit
 				chunks <- StreamChunk{
-					Error: ctx.Err(),
+					Error: classifyError(ctx.Err()),
 					Done:  true,
 				}
 				return
 			default:
 				chunk := stream.Current()
-				
+
+				// The final chunk carries usage for the whole request
+				if chunk.Usage.TotalTokens > 0 {
+					usage = &Usage{
+						PromptTokens:     int(chunk.Usage.PromptTokens),
+						CompletionTokens: int(chunk.Usage.CompletionTokens),
+						TotalTokens:      int(chunk.Usage.TotalTokens),
+					}
+				}
+
 				// Process response chunks
 				if len(chunk.Choices) > 0 {
 					choice := chunk.Choices[0]
@@ -153,6 +91,7 @@ func (h *StreamHandler) HandleStream(ctx context.Context, messages []openai.Chat
 
 					// Check for finish reason
 					if choice.FinishReason != "" {
+						finishReason = string(choice.FinishReason)
 						log.Debug().
 							Str("finish_reason", string(choice.FinishReason)).
 							Msg("Stream finished with reason")
@@ -165,7 +104,7 @@ func (h *StreamHandler) HandleStream(ctx context.Context, messages []openai.Chat
 		if err := stream.Err(); err != nil {
 			log.Error().Err(err).Msg("Stream error")
 			chunks <- StreamChunk{
-				Error: err,
+				Error: classifyError(err),
 				Done:  true,
 			}
 			return
@@ -173,7 +112,9 @@ func (h *StreamHandler) HandleStream(ctx context.Context, messages []openai.Chat
 
 		// Stream completed successfully
 		chunks <- StreamChunk{
-			Done: true,
+			Done:         true,
+			Usage:        usage,
+			FinishReason: finishReason,
 		}
 		if hasContent {
 			log.Debug().
@@ -197,6 +138,11 @@ func (h *StreamHandler) ProcessStreamWithCallback(
 		return fmt.Errorf("failed to start stream: %w", err)
 	}
 
+	return processChunks(chunks, callback)
+}
+
+// processChunks delivers streamed content to callback until the stream completes
+func processChunks(chunks <-chan StreamChunk, callback func(chunk string) error) error {
 	var totalContent strings.Builder
 	for chunk := range chunks {
 		// Handle errors in the stream
@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/user/terminal-ai/internal/tracing"
+)
+
+// Outcomes of warming the cache with a prompt
+const (
+	WarmWarmed  = "warmed"
+	WarmSkipped = "skipped"
+	WarmFailed  = "failed"
+)
+
+// WarmRequest is a request to warm the cache with, made the way its mode
+// makes it so later requests in that mode hit the entry
+type WarmRequest struct {
+	Mode     string
+	Messages []Message
+	Options  ChatOptions
+	Stream   bool // whether the mode streams, so the call is made as the mode makes it
+}
+
+// WarmResult is the outcome of warming the cache with one request
+type WarmResult struct {
+	Status   string // WarmWarmed, WarmSkipped or WarmFailed
+	Reason   string // why the request was skipped
+	Key      string
+	Usage    Usage
+	Duration time.Duration
+	Err      error
+}
+
+// WarmOptions configures WarmCache
+type WarmOptions struct {
+	Concurrency int           // requests in flight at once; at least 1
+	TTL         time.Duration // how long warmed responses stay cached; zero means the cache's TTL
+	// OnDone is called as each request finishes, possibly concurrently
+	OnDone func(index int, result WarmResult)
+}
+
+// WarmCache sends each request through the client, at most
+// options.Concurrency at a time, so its response is cached for options.TTL.
+// Requests already cached, or answered by a semantic hit on a similar cached
+// prompt, are skipped. Results are returned in the order of requests.
+func (c *OpenAIClient) WarmCache(ctx context.Context, requests []WarmRequest, options WarmOptions) ([]WarmResult, error) {
+	if c.cache == nil {
+		return nil, errors.New("caching is disabled")
+	}
+	peeker, ok := c.cache.(EntryLister)
+	if !ok {
+		return nil, errors.New("the cache cannot look up entries")
+	}
+	handler, err := c.activeHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Start(ctx, "cache.warm")
+	defer span.End()
+	span.SetAttribute("cache.warm.requests", len(requests))
+
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx = withCacheTTL(ctx, options.TTL)
+
+	results := make([]WarmResult, len(requests))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, warm := range requests {
+		wg.Add(1)
+		go func(i int, warm WarmRequest) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			req := c.chatRequest(warm.Messages, warm.Options, warm.Stream)
+			results[i] = warmRequest(WithMode(ctx, warm.Mode), handler, peeker, c.cache.GenerateChatKey(req.Messages, req.Options), req, warm.Stream)
+			if options.OnDone != nil {
+				options.OnDone(i, results[i])
+			}
+		}(i, warm)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// warmRequest sends one request unless key is already cached
+func warmRequest(ctx context.Context, handler Handler, peeker EntryLister, key string, req *Request, stream bool) WarmResult {
+	result := WarmResult{Key: key}
+	if _, found := peeker.Peek(key); found {
+		result.Status = WarmSkipped
+		result.Reason = "already cached"
+		return result
+	}
+	if err := ctx.Err(); err != nil {
+		result.Status = WarmFailed
+		result.Err = err
+		return result
+	}
+
+	start := time.Now()
+	var match *SemanticMatch
+	if stream {
+		chunks, err := handler.ChatStream(ctx, req)
+		if err != nil {
+			result.Err = err
+		} else {
+			for chunk := range chunks {
+				if chunk.Semantic != nil {
+					match = chunk.Semantic
+				}
+				if chunk.Usage != nil {
+					result.Usage = *chunk.Usage
+				}
+				if chunk.Error != nil {
+					result.Err = chunk.Error
+				}
+			}
+		}
+	} else {
+		resp, err := handler.Chat(ctx, req)
+		if err != nil {
+			result.Err = err
+		} else {
+			match = resp.Semantic
+			result.Usage = resp.Usage
+		}
+	}
+	result.Duration = time.Since(start)
+
+	switch {
+	case result.Err != nil:
+		result.Status = WarmFailed
+	case match != nil:
+		result.Status = WarmSkipped
+		result.Reason = fmt.Sprintf("%.0f%% similar to cached %q", match.Similarity*100, match.Prompt)
+	default:
+		result.Status = WarmWarmed
+	}
+	return result
+}
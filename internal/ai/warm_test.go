@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/mock"
+)
+
+func newWarmClient(t *testing.T) *OpenAIClient {
+	parsed, err := mock.ParseScript([]byte(`
+default: "fresh answer"
+responses:
+  - match: alpha
+    content: "answer A"
+    times: 1
+  - match: beta
+    content: "answer B"
+    times: 1
+  - match: broken
+    error: {status: 400, message: "bad prompt"}
+`))
+	require.NoError(t, err)
+	server := httptest.NewServer(mock.NewServer(parsed).Handler())
+	t.Cleanup(server.Close)
+
+	client, err := NewOpenAIClient(&config.Config{
+		OpenAI: config.OpenAIConfig{APIKey: "test-key", Model: "gpt-4o", N: 1, BaseURL: server.URL + "/v1", Timeout: 5 * time.Second},
+		Cache:  config.CacheConfig{Enabled: true, TTL: time.Minute, MaxSize: 1, Strategy: EvictLRU, Backend: "memory"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	client.rateLimiter.minInterval = 0
+	return client
+}
+
+func warmMessages(prompt string) []Message {
+	return []Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: prompt}}
+}
+
+func TestOpenAIClient_WarmCache(t *testing.T) {
+	client := newWarmClient(t)
+	requests := []WarmRequest{
+		{Mode: ModeQuery, Messages: warmMessages("what is alpha"), Stream: true},
+		{Mode: ModeShell, Messages: warmMessages("run beta"), Options: ChatOptions{Model: "gpt-4o-mini"}},
+		{Mode: ModeQuery, Messages: warmMessages("broken prompt"), Stream: true},
+	}
+
+	var mu sync.Mutex
+	done := 0
+	results, err := client.WarmCache(context.Background(), requests, WarmOptions{
+		Concurrency: 2,
+		TTL:         24 * time.Hour,
+		OnDone: func(index int, result WarmResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, 3, done)
+	assert.Equal(t, WarmWarmed, results[0].Status)
+	assert.Equal(t, WarmWarmed, results[1].Status)
+	assert.Equal(t, WarmFailed, results[2].Status)
+	assert.Error(t, results[2].Err)
+
+	// Entries are stored with the warming TTL and their mode
+	entry, found := client.cache.(EntryLister).Peek(results[1].Key)
+	require.True(t, found)
+	assert.Equal(t, ModeShell, entry.Mode)
+	assert.Equal(t, "gpt-4o-mini", entry.Model)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), entry.ExpiresAt, time.Minute)
+
+	// Requests made the way each mode makes them hit the warmed entries
+	chunks, err := client.ChatStream(context.Background(), warmMessages("what is alpha"), ChatOptions{})
+	require.NoError(t, err)
+	content, _ := drainStream(t, chunks)
+	assert.Equal(t, "answer A", content)
+	resp, err := client.Chat(context.Background(), warmMessages("run beta"), ChatOptions{Model: "gpt-4o-mini"})
+	require.NoError(t, err)
+	assert.Equal(t, "answer B", resp.Content)
+
+	// Warming again skips what is cached and retries failures
+	results, err = client.WarmCache(context.Background(), requests, WarmOptions{Concurrency: 1})
+	require.NoError(t, err)
+	assert.Equal(t, WarmSkipped, results[0].Status)
+	assert.Equal(t, "already cached", results[0].Reason)
+	assert.Equal(t, WarmSkipped, results[1].Status)
+	assert.Equal(t, WarmFailed, results[2].Status)
+}
+
+func TestOpenAIClient_WarmCacheDisabled(t *testing.T) {
+	client := newMockClient(t, `default: "answer"`)
+	_, err := client.WarmCache(context.Background(), []WarmRequest{{Messages: warmMessages("hi")}}, WarmOptions{})
+	assert.Error(t, err)
+}
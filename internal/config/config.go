@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,11 +13,20 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	OpenAI  OpenAIConfig  `mapstructure:"openai"`
-	Cache   CacheConfig   `mapstructure:"cache"`
-	UI      UIConfig      `mapstructure:"ui"`
-	Logging LoggingConfig `mapstructure:"logging"`
-	Profile string        `mapstructure:"profile"` // dev, prod, custom
+	OpenAI    OpenAIConfig      `mapstructure:"openai"`
+	Cache     CacheConfig       `mapstructure:"cache"`
+	UI        UIConfig          `mapstructure:"ui"`
+	Logging   LoggingConfig     `mapstructure:"logging"`
+	Cassette  CassetteConfig    `mapstructure:"cassette"`
+	Tracing   TracingConfig     `mapstructure:"tracing"`
+	Safety    SafetyConfig      `mapstructure:"safety"`
+	Audio     AudioConfig       `mapstructure:"audio"`
+	Image     ImageConfig       `mapstructure:"image"`
+	Models    ModelsConfig      `mapstructure:"models"`
+	Storage   StorageConfig     `mapstructure:"storage"`
+	Retention RetentionConfig   `mapstructure:"retention"`
+	Presets   map[string]Preset `mapstructure:"presets"`
+	Profile   string            `mapstructure:"profile"` // dev, prod, custom
 }
 
 // OpenAIConfig contains OpenAI API settings
@@ -43,6 +53,25 @@ type CacheConfig struct {
 	MaxSize  int           `mapstructure:"max_size"` // in MB
 	Strategy string        `mapstructure:"strategy"` // lru, fifo, lfu
 	Dir      string        `mapstructure:"dir"`      // cache directory
+	Backend  string        `mapstructure:"backend"`  // disk or memory
+
+	Dedup        bool `mapstructure:"dedup"`         // share one API call between concurrent identical requests
+	DedupStreams bool `mapstructure:"dedup_streams"` // also share streams, fanning chunks out to every caller
+	PaceStreams  bool `mapstructure:"pace_streams"`  // replay cached streams with their original chunk timing
+
+	StaleOnError bool          `mapstructure:"stale_on_error"` // serve an expired entry when the API call fails
+	StaleGrace   time.Duration `mapstructure:"stale_grace"`    // how long expired entries are kept for stale and offline serving
+	Offline      bool          `mapstructure:"offline"`        // answer only from the cache, never calling the API
+
+	Semantic SemanticCacheConfig `mapstructure:"semantic"`
+}
+
+// SemanticCacheConfig contains settings for serving cached responses to
+// similar prompts
+type SemanticCacheConfig struct {
+	Enabled   bool    `mapstructure:"enabled"`
+	Threshold float64 `mapstructure:"threshold"` // minimum cosine similarity (0-1)
+	Model     string  `mapstructure:"model"`     // embedding model
 }
 
 // UIConfig contains UI-related settings
@@ -64,12 +93,123 @@ type LoggingConfig struct {
 	NoAPI  bool   `mapstructure:"no_api"` // disable API key logging
 }
 
+// CassetteConfig contains record/replay settings for offline, deterministic runs
+type CassetteConfig struct {
+	Mode string `mapstructure:"mode"` // record, replay (empty disables)
+	Dir  string `mapstructure:"dir"`  // directory holding cassette files
+	Pace bool   `mapstructure:"pace"` // replay streams with the recorded chunk timing
+}
+
+// TracingConfig contains OpenTelemetry trace export settings
+type TracingConfig struct {
+	File        string `mapstructure:"file"`         // OTLP/JSON file to append spans to
+	Endpoint    string `mapstructure:"endpoint"`     // OTLP/HTTP collector URL, e.g. http://localhost:4318
+	ServiceName string `mapstructure:"service_name"` // service.name resource attribute
+}
+
+// AudioConfig contains speech-to-text and text-to-speech settings
+type AudioConfig struct {
+	TranscriptionModel string  `mapstructure:"transcription_model"` // whisper-1, gpt-4o-transcribe, gpt-4o-mini-transcribe
+	Language           string  `mapstructure:"language"`            // default spoken language hint (ISO-639-1)
+	SpeechModel        string  `mapstructure:"speech_model"`        // tts-1, tts-1-hd, gpt-4o-mini-tts
+	Voice              string  `mapstructure:"voice"`               // alloy, ash, ballad, coral, echo, fable, nova, onyx, sage, shimmer, verse
+	Format             string  `mapstructure:"format"`              // mp3, opus, aac, flac, wav, pcm
+	Speed              float64 `mapstructure:"speed"`               // 0.25 to 4.0
+	Player             string  `mapstructure:"player"`              // command that plays audio from stdin, e.g. "mpv -"
+}
+
+// ImageConfig contains image generation settings
+type ImageConfig struct {
+	Model   string `mapstructure:"model"`   // gpt-image-1, dall-e-3, dall-e-2
+	Size    string `mapstructure:"size"`    // e.g. 1024x1024, 1536x1024, auto
+	Quality string `mapstructure:"quality"` // auto, low, medium, high; standard, hd for dall-e-3
+}
+
+// ModelsConfig extends the built-in model registry
+type ModelsConfig struct {
+	File     string        `mapstructure:"file"`      // registry file written by "models refresh"
+	Custom   []ModelInfo   `mapstructure:"custom"`    // extra models; an entry with a known id replaces it
+	CacheTTL time.Duration `mapstructure:"cache_ttl"` // how long the endpoint's model list is reused (0 disables)
+}
+
+// StorageConfig contains settings for encrypting the cache, chat history
+// and saved sessions at rest
+type StorageConfig struct {
+	Encrypt   bool   `mapstructure:"encrypt"`
+	KeySource string `mapstructure:"key_source"` // keyring, passphrase or env
+	KeyEnv    string `mapstructure:"key_env"`    // variable holding the secret for key_source env
+	KeyFile   string `mapstructure:"key_file"`   // where the data key is kept, wrapped by the secret
+}
+
+// RetentionConfig limits how long and how much of each kind of persisted
+// data is kept
+type RetentionConfig struct {
+	History RetentionPolicy `mapstructure:"history"` // saved chat history
+	Cache   RetentionPolicy `mapstructure:"cache"`   // cached responses, expired or not
+	Logs    RetentionPolicy `mapstructure:"logs"`    // lines of the log file
+}
+
+// RetentionPolicy limits one kind of persisted data. Zero limits are not
+// enforced.
+type RetentionPolicy struct {
+	MaxAge  time.Duration `mapstructure:"max_age"`
+	MaxSize int           `mapstructure:"max_size"` // in MB
+}
+
+// toMap converts the policy to a map for viper
+func (p RetentionPolicy) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"max_age":  p.MaxAge.String(),
+		"max_size": p.MaxSize,
+	}
+}
+
+// Preset is a named set of request settings applied over the OpenAI
+// settings with --preset, or by naming it where a model is expected
+type Preset struct {
+	Model           string   `mapstructure:"model" yaml:"model,omitempty"`
+	ReasoningEffort string   `mapstructure:"reasoning_effort" yaml:"reasoning_effort,omitempty"`
+	ServiceTier     string   `mapstructure:"service_tier" yaml:"service_tier,omitempty"`
+	MaxTokens       int      `mapstructure:"max_tokens" yaml:"max_tokens,omitempty"`
+	Temperature     *float32 `mapstructure:"temperature" yaml:"temperature,omitempty"`
+	SystemPrompt    string   `mapstructure:"system_prompt" yaml:"system_prompt,omitempty"`
+}
+
+// SafetyConfig contains moderation and policy settings for prompts and responses
+type SafetyConfig struct {
+	Enabled         bool                      `mapstructure:"enabled"`
+	Moderation      bool                      `mapstructure:"moderation"`       // check text with the moderation endpoint
+	ModerationModel string                    `mapstructure:"moderation_model"` // e.g. omni-moderation-latest
+	CheckResponses  bool                      `mapstructure:"check_responses"`  // also check model output (streams are held until checked)
+	FailOpen        bool                      `mapstructure:"fail_open"`        // send the request when the moderation call fails
+	Action          string                    `mapstructure:"action"`           // default action: block, warn, log
+	Threshold       float64                   `mapstructure:"threshold"`        // default moderation score threshold (0-1)
+	Categories      map[string]SafetyCategory `mapstructure:"categories"`       // per-category overrides
+	Rules           []SafetyRule              `mapstructure:"rules"`            // local regex/keyword policy
+}
+
+// SafetyCategory overrides the threshold and action for one category
+type SafetyCategory struct {
+	Threshold float64 `mapstructure:"threshold"` // 0 uses the default threshold
+	Action    string  `mapstructure:"action"`    // block, warn, log, allow
+}
+
+// SafetyRule flags text matching any pattern or keyword as its category
+type SafetyRule struct {
+	Category string   `mapstructure:"category"`
+	Patterns []string `mapstructure:"patterns"` // regular expressions
+	Keywords []string `mapstructure:"keywords"` // case-insensitive substrings
+}
+
 // Load loads configuration from multiple sources with priority:
 // 1. Command-line flags (highest)
 // 2. Environment variables
 // 3. Config file
 // 4. Defaults (lowest)
-func Load(configPath string) (*Config, error) {
+//
+// Flags are applied by overrides, which run before validation, so a flag
+// can make a setting valid that the other sources leave invalid.
+func Load(configPath string, overrides ...func(*Config)) (*Config, error) {
 	// Initialize new viper instance for isolation
 	v := viper.New()
 
@@ -103,6 +243,14 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// Load the model registry before anything asks about the model
+	config.Models.File = os.ExpandEnv(config.Models.File)
+	registry, err := LoadModelRegistry(config.Models)
+	if err != nil {
+		return nil, err
+	}
+	SetModels(registry)
+
 	// Apply profile-specific overrides
 	config.Profile = profile
 	applyProfile(&config, profile)
@@ -110,6 +258,10 @@ func Load(configPath string) (*Config, error) {
 	// Handle special environment variables
 	handleSpecialEnvVars(&config)
 
+	for _, override := range overrides {
+		override(&config)
+	}
+
 	// Validate configuration
 	validator := NewValidator(&config)
 	if err := validator.Validate(); err != nil {
@@ -123,9 +275,9 @@ func Load(configPath string) (*Config, error) {
 }
 
 // LoadWithProfile loads configuration with a specific profile
-func LoadWithProfile(configPath, profile string) (*Config, error) {
+func LoadWithProfile(configPath, profile string, overrides ...func(*Config)) (*Config, error) {
 	os.Setenv("TERMINAL_AI_PROFILE", profile)
-	return Load(configPath)
+	return Load(configPath, overrides...)
 }
 
 // setupConfigPaths configures where to look for config files
@@ -191,6 +343,8 @@ func handleSpecialEnvVars(config *Config) {
 	config.OpenAI.APIKey = os.ExpandEnv(config.OpenAI.APIKey)
 	config.Cache.Dir = os.ExpandEnv(config.Cache.Dir)
 	config.Logging.File = os.ExpandEnv(config.Logging.File)
+	config.Cassette.Dir = os.ExpandEnv(config.Cassette.Dir)
+	config.Tracing.File = os.ExpandEnv(config.Tracing.File)
 	
 	// Adjust settings based on model type
 	AdjustForModelType(config)
@@ -198,27 +352,64 @@ func handleSpecialEnvVars(config *Config) {
 
 // IsReasoningModel checks if the given model is a reasoning model
 func IsReasoningModel(model string) bool {
-	reasoningModels := map[string]bool{
-		"gpt-5":      true,
-		"gpt-5-mini": true,
-		"gpt-5-nano": true,
-		"o1":         true,
-		"o1-mini":    true,
-		"o3":         true,
-		"o3-mini":    true,
-		"o4-mini":    true,
-	}
-	return reasoningModels[model]
+	info, ok := LookupModel(model)
+	return ok && info.Reasoning
 }
 
 // IsGPT5Model checks if the model is a GPT-5 series model
 func IsGPT5Model(model string) bool {
-	gpt5Models := map[string]bool{
-		"gpt-5":      true,
-		"gpt-5-mini": true,
-		"gpt-5-nano": true,
+	info, ok := LookupModel(model)
+	return ok && info.Family == "gpt-5"
+}
+
+// Preset returns the named preset. Names are case-insensitive, as config
+// keys are.
+func (c *Config) Preset(name string) (Preset, bool) {
+	preset, ok := c.Presets[strings.ToLower(name)]
+	return preset, ok
+}
+
+// PresetNames returns the names of the configured presets, sorted
+func (c *Config) PresetNames() []string {
+	names := make([]string, 0, len(c.Presets))
+	for name := range c.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithPreset returns a copy of the config with the named preset applied over
+// the OpenAI settings, adjusted for the resulting model type
+func (c *Config) WithPreset(name string) (*Config, error) {
+	preset, ok := c.Preset(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown preset: %s", name)
+	}
+
+	copied := *c
+	openai := &copied.OpenAI
+	if preset.Model != "" {
+		openai.Model = preset.Model
+	}
+	if preset.ReasoningEffort != "" {
+		openai.ReasoningEffort = preset.ReasoningEffort
 	}
-	return gpt5Models[model]
+	if preset.ServiceTier != "" {
+		openai.ServiceTier = preset.ServiceTier
+	}
+	if preset.MaxTokens > 0 {
+		openai.MaxTokens = preset.MaxTokens
+	}
+	if preset.Temperature != nil {
+		openai.Temperature = *preset.Temperature
+	}
+	if preset.SystemPrompt != "" {
+		openai.SystemPrompt = preset.SystemPrompt
+	}
+
+	AdjustForModelType(&copied)
+	return &copied, nil
 }
 
 // GetRecommendedServiceTier returns the recommended service tier for a model
@@ -242,7 +433,7 @@ func AdjustForModelType(config *Config) {
 		// Set default reasoning effort if not specified
 		if config.OpenAI.ReasoningEffort == "" {
 			// Use "minimal" for GPT-5 series, "low" for others
-			if strings.HasPrefix(config.OpenAI.Model, "gpt-5") {
+			if IsGPT5Model(config.OpenAI.Model) {
 				config.OpenAI.ReasoningEffort = "minimal"
 			} else {
 				config.OpenAI.ReasoningEffort = "low"
@@ -281,6 +472,16 @@ func setDefaults(v *viper.Viper, profile string) {
 	v.SetDefault("cache.ttl", "5m")
 	v.SetDefault("cache.max_size", 100)
 	v.SetDefault("cache.strategy", "lru")
+	v.SetDefault("cache.backend", "disk")
+	v.SetDefault("cache.dedup", true)
+	v.SetDefault("cache.dedup_streams", false)
+	v.SetDefault("cache.pace_streams", false)
+	v.SetDefault("cache.stale_on_error", false)
+	v.SetDefault("cache.stale_grace", "168h") // Keep expired entries a week for later --offline runs
+	v.SetDefault("cache.offline", false)
+	v.SetDefault("cache.semantic.enabled", false)
+	v.SetDefault("cache.semantic.threshold", 0.92)
+	v.SetDefault("cache.semantic.model", "text-embedding-3-small")
 
 	// UI defaults
 	v.SetDefault("ui.streaming_enabled", true)
@@ -297,6 +498,53 @@ func setDefaults(v *viper.Viper, profile string) {
 	v.SetDefault("logging.file", "")
 	v.SetDefault("logging.no_api", true) // Never log API keys by default
 
+	// Cassette defaults (record/replay disabled)
+	v.SetDefault("cassette.mode", "")
+	v.SetDefault("cassette.dir", "")
+	v.SetDefault("cassette.pace", true)
+
+	// Tracing defaults (export disabled)
+	v.SetDefault("tracing.file", "")
+	v.SetDefault("tracing.endpoint", "")
+	v.SetDefault("tracing.service_name", "terminal-ai")
+
+	// Audio defaults
+	v.SetDefault("audio.transcription_model", "whisper-1")
+	v.SetDefault("audio.language", "")
+	v.SetDefault("audio.speech_model", "gpt-4o-mini-tts")
+	v.SetDefault("audio.voice", "alloy")
+	v.SetDefault("audio.format", "mp3")
+	v.SetDefault("audio.speed", 1.0)
+	v.SetDefault("audio.player", "")
+
+	// Image defaults
+	v.SetDefault("image.model", "gpt-image-1")
+	v.SetDefault("image.size", "1024x1024")
+	v.SetDefault("image.quality", "auto")
+
+	// Model list cache defaults
+	v.SetDefault("models.cache_ttl", "24h")
+
+	// Storage encryption defaults (disabled)
+	v.SetDefault("storage.encrypt", false)
+	v.SetDefault("storage.key_source", "keyring")
+	v.SetDefault("storage.key_env", "TERMINAL_AI_STORAGE_KEY")
+
+	// Retention defaults: opt-in, nothing is deleted unless a limit is set
+	for _, class := range []string{"history", "cache", "logs"} {
+		v.SetDefault("retention."+class+".max_age", "0s")
+		v.SetDefault("retention."+class+".max_size", 0)
+	}
+
+	// Safety defaults (disabled)
+	v.SetDefault("safety.enabled", false)
+	v.SetDefault("safety.moderation", false)
+	v.SetDefault("safety.moderation_model", "omni-moderation-latest")
+	v.SetDefault("safety.check_responses", false)
+	v.SetDefault("safety.fail_open", false)
+	v.SetDefault("safety.action", "block")
+	v.SetDefault("safety.threshold", 0.5)
+
 	// Apply profile-specific defaults
 	switch profile {
 	case "dev":
@@ -311,9 +559,11 @@ func setDefaults(v *viper.Viper, profile string) {
 		v.SetDefault("openai.timeout", "30s")
 	}
 
-	// Set cache directory default
+	// Set cache directory, model registry and storage key file defaults
 	if home, err := os.UserHomeDir(); err == nil {
 		v.SetDefault("cache.dir", filepath.Join(home, ".terminal-ai", "cache"))
+		v.SetDefault("models.file", filepath.Join(home, ".terminal-ai", "models.yaml"))
+		v.SetDefault("storage.key_file", filepath.Join(home, ".terminal-ai", "storage.key"))
 	}
 }
 
@@ -369,7 +619,7 @@ func (c *Config) SaveTo(path string) error {
 
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := os.MkdirAll(dir, 0700); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
@@ -413,11 +663,22 @@ func (c *Config) ToMap() map[string]interface{} {
 			"reasoning_effort": c.OpenAI.ReasoningEffort,
 		},
 		"cache": map[string]interface{}{
-			"enabled":  c.Cache.Enabled,
-			"ttl":      c.Cache.TTL.String(),
-			"max_size": c.Cache.MaxSize,
-			"strategy": c.Cache.Strategy,
-			"dir":      c.Cache.Dir,
+			"enabled":        c.Cache.Enabled,
+			"ttl":            c.Cache.TTL.String(),
+			"max_size":       c.Cache.MaxSize,
+			"strategy":       c.Cache.Strategy,
+			"dir":            c.Cache.Dir,
+			"backend":        c.Cache.Backend,
+			"dedup":          c.Cache.Dedup,
+			"dedup_streams":  c.Cache.DedupStreams,
+			"pace_streams":   c.Cache.PaceStreams,
+			"stale_on_error": c.Cache.StaleOnError,
+			"stale_grace":    c.Cache.StaleGrace.String(),
+			"semantic": map[string]interface{}{
+				"enabled":   c.Cache.Semantic.Enabled,
+				"threshold": c.Cache.Semantic.Threshold,
+				"model":     c.Cache.Semantic.Model,
+			},
 		},
 		"ui": map[string]interface{}{
 			"streaming_enabled":   c.UI.StreamingEnabled,
@@ -434,6 +695,58 @@ func (c *Config) ToMap() map[string]interface{} {
 			"file":   c.Logging.File,
 			"no_api": c.Logging.NoAPI,
 		},
+		"cassette": map[string]interface{}{
+			"mode": c.Cassette.Mode,
+			"dir":  c.Cassette.Dir,
+			"pace": c.Cassette.Pace,
+		},
+		"tracing": map[string]interface{}{
+			"file":         c.Tracing.File,
+			"endpoint":     c.Tracing.Endpoint,
+			"service_name": c.Tracing.ServiceName,
+		},
+		"audio": map[string]interface{}{
+			"transcription_model": c.Audio.TranscriptionModel,
+			"language":            c.Audio.Language,
+			"speech_model":        c.Audio.SpeechModel,
+			"voice":               c.Audio.Voice,
+			"format":              c.Audio.Format,
+			"speed":               c.Audio.Speed,
+			"player":              c.Audio.Player,
+		},
+		"image": map[string]interface{}{
+			"model":   c.Image.Model,
+			"size":    c.Image.Size,
+			"quality": c.Image.Quality,
+		},
+		"models": map[string]interface{}{
+			"file":      c.Models.File,
+			"custom":    c.Models.Custom,
+			"cache_ttl": c.Models.CacheTTL.String(),
+		},
+		"presets": c.Presets,
+		"storage": map[string]interface{}{
+			"encrypt":    c.Storage.Encrypt,
+			"key_source": c.Storage.KeySource,
+			"key_env":    c.Storage.KeyEnv,
+			"key_file":   c.Storage.KeyFile,
+		},
+		"retention": map[string]interface{}{
+			"history": c.Retention.History.toMap(),
+			"cache":   c.Retention.Cache.toMap(),
+			"logs":    c.Retention.Logs.toMap(),
+		},
+		"safety": map[string]interface{}{
+			"enabled":          c.Safety.Enabled,
+			"moderation":       c.Safety.Moderation,
+			"moderation_model": c.Safety.ModerationModel,
+			"check_responses":  c.Safety.CheckResponses,
+			"fail_open":        c.Safety.FailOpen,
+			"action":           c.Safety.Action,
+			"threshold":        c.Safety.Threshold,
+			"categories":       c.Safety.Categories,
+			"rules":            c.Safety.Rules,
+		},
 	}
 }
 
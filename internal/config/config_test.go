@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -105,6 +106,9 @@ func TestLoadConfig(t *testing.T) {
 		if config.Cache.Strategy != "lru" {
 			t.Errorf("Expected default cache strategy lru, got %s", config.Cache.Strategy)
 		}
+		if config.Cache.StaleGrace != 168*time.Hour {
+			t.Errorf("Expected default stale grace 168h, got %v", config.Cache.StaleGrace)
+		}
 		if config.UI.StreamingEnabled != true {
 			t.Error("Streaming should be enabled by default")
 		}
@@ -112,6 +116,24 @@ func TestLoadConfig(t *testing.T) {
 			t.Error("Color output should be enabled by default")
 		}
 	})
+
+	// Flag overrides apply before validation
+	t.Run("Overrides", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("OPENAI_API_KEY", "")
+
+		if _, err := Load(""); err == nil {
+			t.Fatal("Expected validation to fail without an API key")
+		}
+
+		config, err := Load("", func(c *Config) { c.Cache.Offline = true })
+		if err != nil {
+			t.Fatalf("Offline run without an API key should load: %v", err)
+		}
+		if !config.Cache.Offline {
+			t.Error("Override was not applied")
+		}
+	})
 }
 
 func TestConfigValidation(t *testing.T) {
@@ -219,6 +241,212 @@ func TestConfigValidation(t *testing.T) {
 			t.Error("Should fail validation when max_tokens exceeds model limit")
 		}
 	})
+
+	t.Run("CassetteMode", func(t *testing.T) {
+		config := &Config{
+			UI:      UIConfig{Theme: "auto"},
+			Logging: LoggingConfig{Level: "info", Format: "json"},
+		}
+
+		// Replay does not need an API key but needs an existing directory
+		config.Cassette = CassetteConfig{Mode: "replay", Dir: t.TempDir()}
+		if err := NewValidator(config).Validate(); err != nil {
+			t.Errorf("Replay without API key should pass validation: %v", err)
+		}
+
+		config.Cassette.Dir = filepath.Join(t.TempDir(), "missing")
+		if err := NewValidator(config).Validate(); err == nil {
+			t.Error("Should fail validation when replay directory does not exist")
+		}
+
+		config.Cassette = CassetteConfig{Mode: "rewind", Dir: t.TempDir()}
+		if err := NewValidator(config).Validate(); err == nil {
+			t.Error("Should fail validation with unknown cassette mode")
+		}
+	})
+
+	t.Run("AudioSettings", func(t *testing.T) {
+		config := &Config{
+			Cassette: CassetteConfig{Mode: "replay", Dir: t.TempDir()},
+			UI:       UIConfig{Theme: "auto"},
+			Logging:  LoggingConfig{Level: "info", Format: "json"},
+			Audio:    AudioConfig{Voice: "nova", Format: "opus", Speed: 1.5},
+		}
+		if err := NewValidator(config).Validate(); err != nil {
+			t.Errorf("Valid audio settings should pass validation: %v", err)
+		}
+
+		config.Audio = AudioConfig{Voice: "robot", Format: "ogg", Speed: 8}
+		err := NewValidator(config).Validate()
+		if err == nil {
+			t.Fatal("Should fail validation with invalid audio settings")
+		}
+		for _, field := range []string{"voice", "format", "speed"} {
+			if !strings.Contains(err.Error(), "audio "+field) {
+				t.Errorf("Expected an audio %s error, got: %v", field, err)
+			}
+		}
+	})
+	t.Run("CacheStrategy", func(t *testing.T) {
+		config := &Config{
+			Cassette: CassetteConfig{Mode: "replay", Dir: t.TempDir()},
+			UI:       UIConfig{Theme: "auto"},
+			Logging:  LoggingConfig{Level: "info", Format: "json"},
+		}
+		for _, strategy := range []string{"lru", "lfu", "fifo", "size"} {
+			config.Cache.Strategy = strategy
+			if err := NewValidator(config).Validate(); err != nil {
+				t.Errorf("Strategy %s should pass validation: %v", strategy, err)
+			}
+		}
+
+		config.Cache.Strategy = "random"
+		err := NewValidator(config).Validate()
+		if err == nil || !strings.Contains(err.Error(), "invalid cache strategy") {
+			t.Errorf("Should fail validation with unknown cache strategy, got: %v", err)
+		}
+	})
+
+	t.Run("SemanticCache", func(t *testing.T) {
+		config := &Config{
+			Cache: CacheConfig{
+				Semantic: SemanticCacheConfig{Enabled: true, Threshold: 0.92, Model: "text-embedding-3-small"},
+			},
+			Cassette: CassetteConfig{Mode: "replay", Dir: t.TempDir()},
+			UI:       UIConfig{Theme: "auto"},
+			Logging:  LoggingConfig{Level: "info", Format: "json"},
+		}
+		if err := NewValidator(config).Validate(); err != nil {
+			t.Errorf("Semantic cache config should pass validation: %v", err)
+		}
+
+		config.Cache.Semantic.Threshold = 1.5
+		err := NewValidator(config).Validate()
+		if err == nil || !strings.Contains(err.Error(), "semantic cache threshold") {
+			t.Errorf("Should fail validation with threshold above 1, got: %v", err)
+		}
+
+		// Disabled semantic caching is not validated
+		config.Cache.Semantic = SemanticCacheConfig{Threshold: 5}
+		if err := NewValidator(config).Validate(); err != nil {
+			t.Errorf("Disabled semantic cache should pass validation: %v", err)
+		}
+	})
+
+	t.Run("OfflineCache", func(t *testing.T) {
+		config := &Config{
+			Cache:   CacheConfig{Enabled: true, Offline: true, StaleGrace: time.Hour},
+			UI:      UIConfig{Theme: "auto"},
+			Logging: LoggingConfig{Level: "info", Format: "json"},
+		}
+		// Offline runs never reach the API, so no key is needed
+		if err := NewValidator(config).Validate(); err != nil {
+			t.Errorf("Offline cache config should pass validation: %v", err)
+		}
+
+		config.Cache.Enabled = false
+		err := NewValidator(config).Validate()
+		if err == nil || !strings.Contains(err.Error(), "offline mode requires the cache") {
+			t.Errorf("Should fail validation offline without a cache, got: %v", err)
+		}
+
+		config.Cache = CacheConfig{Enabled: true, StaleGrace: -time.Hour}
+		config.Cassette = CassetteConfig{Mode: "replay", Dir: t.TempDir()}
+		err = NewValidator(config).Validate()
+		if err == nil || !strings.Contains(err.Error(), "stale grace") {
+			t.Errorf("Should fail validation with a negative stale grace, got: %v", err)
+		}
+	})
+
+	t.Run("StorageEncryption", func(t *testing.T) {
+		config := &Config{
+			OpenAI: OpenAIConfig{
+				APIKey:    "sk-test1234567890abcdefghijklmnopqrstuvwxyz12345678",
+				Model:     "gpt-4",
+				MaxTokens: 100,
+				Timeout:   30 * time.Second,
+				TopP:      1.0,
+				N:         1,
+			},
+			UI:      UIConfig{Theme: "auto"},
+			Logging: LoggingConfig{Level: "info", Format: "json"},
+			Storage: StorageConfig{Encrypt: true, KeySource: "env", KeyEnv: "TERMINAL_AI_STORAGE_KEY", KeyFile: filepath.Join(t.TempDir(), "storage.key")},
+		}
+		if err := NewValidator(config).Validate(); err != nil {
+			t.Errorf("Encrypted storage config should pass validation: %v", err)
+		}
+
+		config.Storage.KeySource = "vault"
+		err := NewValidator(config).Validate()
+		if err == nil || !strings.Contains(err.Error(), "invalid storage key source") {
+			t.Errorf("Should fail validation with an unknown key source, got: %v", err)
+		}
+
+		config.Storage.KeySource = "env"
+		config.Storage.KeyEnv = ""
+		err = NewValidator(config).Validate()
+		if err == nil || !strings.Contains(err.Error(), "requires storage.key_env") {
+			t.Errorf("Should fail validation with the env source and no variable, got: %v", err)
+		}
+
+		// Settings are not checked while encryption is off
+		config.Storage = StorageConfig{KeySource: "vault"}
+		if err := NewValidator(config).Validate(); err != nil {
+			t.Errorf("Unencrypted storage config should pass validation: %v", err)
+		}
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		config := &Config{
+			OpenAI: OpenAIConfig{
+				APIKey:    "sk-test1234567890abcdefghijklmnopqrstuvwxyz12345678",
+				Model:     "gpt-4",
+				MaxTokens: 100,
+				Timeout:   30 * time.Second,
+				TopP:      1.0,
+				N:         1,
+			},
+			UI:      UIConfig{Theme: "auto"},
+			Logging: LoggingConfig{Level: "info", Format: "json"},
+			Retention: RetentionConfig{
+				History: RetentionPolicy{MaxAge: 30 * 24 * time.Hour},
+				Cache:   RetentionPolicy{MaxSize: 100},
+			},
+		}
+		if err := NewValidator(config).Validate(); err != nil {
+			t.Errorf("Retention config should pass validation: %v", err)
+		}
+
+		config.Retention.Logs = RetentionPolicy{MaxAge: -time.Hour, MaxSize: -1}
+		err := NewValidator(config).Validate()
+		if err == nil || !strings.Contains(err.Error(), "retention.logs.max_age") || !strings.Contains(err.Error(), "retention.logs.max_size") {
+			t.Errorf("Should fail validation with negative limits, got: %v", err)
+		}
+	})
+}
+
+func TestValidator_StaleGraceWarning(t *testing.T) {
+	config := &Config{
+		Cache:    CacheConfig{Enabled: true, StaleOnError: true},
+		UI:       UIConfig{Theme: "auto"},
+		Logging:  LoggingConfig{Level: "info", Format: "json"},
+		Cassette: CassetteConfig{Mode: "replay", Dir: t.TempDir()},
+	}
+	validator := NewValidator(config)
+	if err := validator.Validate(); err != nil {
+		t.Fatalf("A zero stale grace should pass validation: %v", err)
+	}
+	if warnings := validator.GetWarnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "stale_grace") {
+		t.Errorf("Expected a stale_grace warning, got %v", warnings)
+	}
+
+	config.Cache.StaleGrace = time.Hour
+	if err := validator.Validate(); err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if warnings := validator.GetWarnings(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
 }
 
 func TestConfigSave(t *testing.T) {
@@ -369,6 +597,93 @@ func TestGetString(t *testing.T) {
 	}
 }
 
+func TestPresets(t *testing.T) {
+	os.Setenv("OPENAI_API_KEY", "sk-test1234567890abcdefghijklmnopqrstuvwxyz12345678")
+	defer os.Unsetenv("OPENAI_API_KEY")
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configFile, []byte(`openai:
+  model: gpt-4o
+  max_tokens: 2000
+  temperature: 0.5
+presets:
+  fast:
+    model: gpt-5-nano
+    service_tier: flex
+  Smart:
+    model: gpt-5
+    reasoning_effort: high
+    max_tokens: 8000
+  cheap:
+    model: gpt-4o-mini
+    temperature: 0.2
+`), 0644)
+
+	config, err := Load(configFile)
+	if err != nil {
+		t.Fatalf("Failed to load config with presets: %v", err)
+	}
+	if names := config.PresetNames(); strings.Join(names, ",") != "cheap,fast,smart" {
+		t.Errorf("PresetNames() = %v", names)
+	}
+
+	t.Run("AdjustsForModelType", func(t *testing.T) {
+		fast, err := config.WithPreset("fast")
+		if err != nil {
+			t.Fatalf("WithPreset(fast) failed: %v", err)
+		}
+		if fast.OpenAI.Model != "gpt-5-nano" || fast.OpenAI.ServiceTier != "flex" || fast.OpenAI.MaxTokens != 2000 {
+			t.Errorf("Preset not applied: %+v", fast.OpenAI)
+		}
+		if fast.OpenAI.Temperature != 1.0 || fast.OpenAI.ReasoningEffort != "minimal" {
+			t.Errorf("Reasoning model adjustments not applied: %+v", fast.OpenAI)
+		}
+		if config.OpenAI.Model != "gpt-4o" {
+			t.Error("WithPreset should not change the original config")
+		}
+	})
+
+	t.Run("CaseInsensitive", func(t *testing.T) {
+		smart, err := config.WithPreset("SMART")
+		if err != nil {
+			t.Fatalf("WithPreset(SMART) failed: %v", err)
+		}
+		if smart.OpenAI.ReasoningEffort != "high" || smart.OpenAI.MaxTokens != 8000 {
+			t.Errorf("Preset not applied: %+v", smart.OpenAI)
+		}
+	})
+
+	t.Run("NonReasoningModel", func(t *testing.T) {
+		cheap, _ := config.WithPreset("cheap")
+		if cheap.OpenAI.Temperature != 0.2 || cheap.OpenAI.ReasoningEffort != "" {
+			t.Errorf("Unexpected cheap preset: %+v", cheap.OpenAI)
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		if _, err := config.WithPreset("missing"); err == nil {
+			t.Error("Unknown presets should be rejected")
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		tests := map[string]Preset{
+			"unknown model":    {Model: "invalid-model"},
+			"reasoning effort": {ReasoningEffort: "extreme"},
+			"service tier":     {ServiceTier: "premium"},
+			"max tokens":       {Model: "gpt-4o", MaxTokens: 100000},
+		}
+		for name, preset := range tests {
+			invalid := *config
+			invalid.Presets = map[string]Preset{"bad": preset}
+			err := invalid.Validate()
+			if err == nil || !strings.Contains(err.Error(), "preset bad") {
+				t.Errorf("%s: expected a preset validation error, got %v", name, err)
+			}
+		}
+	})
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return findSubstring(s, substr)
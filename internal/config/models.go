@@ -0,0 +1,276 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed models.yaml
+var builtinModels []byte
+
+// ModelInfo describes the capabilities and price of a model
+type ModelInfo struct {
+	ID            string        `yaml:"id" mapstructure:"id" json:"id"`
+	Provider      string        `yaml:"provider,omitempty" mapstructure:"provider" json:"provider,omitempty"` // defaults from the id, see ModelProvider
+	Family        string        `yaml:"family,omitempty" mapstructure:"family" json:"family,omitempty"`
+	ContextWindow int           `yaml:"context_window,omitempty" mapstructure:"context_window" json:"context_window,omitempty"` // tokens
+	MaxOutput     int           `yaml:"max_output,omitempty" mapstructure:"max_output" json:"max_output,omitempty"`             // tokens
+	Reasoning     bool          `yaml:"reasoning,omitempty" mapstructure:"reasoning" json:"reasoning"`
+	Parameters    []string      `yaml:"parameters,omitempty" mapstructure:"parameters" json:"parameters,omitempty"` // optional chat parameters the model accepts
+	Pricing       *ModelPricing `yaml:"pricing,omitempty" mapstructure:"pricing" json:"pricing,omitempty"`
+	Aliases       []string      `yaml:"aliases,omitempty" mapstructure:"aliases" json:"aliases,omitempty"`
+	Discovered    bool          `yaml:"discovered,omitempty" mapstructure:"discovered" json:"discovered,omitempty"` // added by "models refresh"; only these are pruned
+}
+
+// ModelPricing is the list price of a model in USD per million tokens
+type ModelPricing struct {
+	Input  float64 `yaml:"input" mapstructure:"input" json:"input"`
+	Output float64 `yaml:"output,omitempty" mapstructure:"output" json:"output"`
+}
+
+// SupportsParameter reports whether the model accepts an optional chat
+// parameter such as temperature or reasoning_effort. Models without a
+// parameter list are assumed to accept everything.
+func (m ModelInfo) SupportsParameter(name string) bool {
+	if len(m.Parameters) == 0 {
+		return true
+	}
+	for _, parameter := range m.Parameters {
+		if parameter == name {
+			return true
+		}
+	}
+	return false
+}
+
+// modelFile is the layout of the embedded registry and of registry files
+type modelFile struct {
+	Models []ModelInfo `yaml:"models"`
+}
+
+// snapshotSuffix matches the date or revision that snapshot names append to
+// their base model, as in gpt-5-2025-08-07 or gpt-4-0613
+var snapshotSuffix = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}|\d{4})$`)
+
+// ModelRegistry resolves model names, aliases and snapshots to their
+// capabilities
+type ModelRegistry struct {
+	models []ModelInfo
+	index  map[string]int // ids and aliases to positions in models
+}
+
+// NewModelRegistry creates a registry from model definitions. Later
+// definitions replace earlier ones with the same id or alias.
+func NewModelRegistry(models ...[]ModelInfo) *ModelRegistry {
+	r := &ModelRegistry{index: make(map[string]int)}
+	for _, list := range models {
+		for _, model := range list {
+			r.Add(model)
+		}
+	}
+	return r
+}
+
+// Add adds a model, replacing a known model with the same id or alias
+func (r *ModelRegistry) Add(model ModelInfo) {
+	model.ID = strings.TrimSpace(model.ID)
+	if model.ID == "" {
+		return
+	}
+
+	i, ok := r.index[model.ID]
+	if ok && r.models[i].ID == model.ID {
+		// Drop the old entry's aliases so they no longer point at it
+		for _, alias := range r.models[i].Aliases {
+			if r.index[alias] == i {
+				delete(r.index, alias)
+			}
+		}
+		r.models[i] = model
+	} else {
+		// A new model, or one taking over a name that was an alias
+		r.models = append(r.models, model)
+		i = len(r.models) - 1
+	}
+
+	r.index[model.ID] = i
+	for _, alias := range model.Aliases {
+		r.index[alias] = i
+	}
+}
+
+// Lookup returns the model a name refers to. Aliases resolve to their model,
+// snapshots such as gpt-5-2025-08-07 to the longest matching base model, and
+// fine-tuned models such as ft:gpt-4o-mini-2024-07-18:org::id to the model
+// they were tuned from.
+func (r *ModelRegistry) Lookup(name string) (ModelInfo, bool) {
+	name = strings.TrimSpace(name)
+	if i, ok := r.index[name]; ok {
+		return r.models[i], true
+	}
+
+	if strings.HasPrefix(name, "ft:") {
+		base := strings.SplitN(strings.TrimPrefix(name, "ft:"), ":", 2)[0]
+		if base != "" && base != name {
+			return r.Lookup(base)
+		}
+		return ModelInfo{}, false
+	}
+
+	best := ""
+	for known := range r.index {
+		if len(known) > len(best) && strings.HasPrefix(name, known+"-") && snapshotSuffix.MatchString(name[len(known)+1:]) {
+			best = known
+		}
+	}
+	if best == "" {
+		return ModelInfo{}, false
+	}
+	return r.models[r.index[best]], true
+}
+
+// All returns every model in the registry, sorted by id
+func (r *ModelRegistry) All() []ModelInfo {
+	models := make([]ModelInfo, len(r.models))
+	copy(models, r.models)
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models
+}
+
+// Unknown returns the names the registry cannot resolve, in their original
+// order and without duplicates
+func (r *ModelRegistry) Unknown(names []string) []string {
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := r.Lookup(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// ParseModels parses a registry document with a top-level models list
+func ParseModels(data []byte) ([]ModelInfo, error) {
+	var file modelFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid model registry: %w", err)
+	}
+	for i, model := range file.Models {
+		if strings.TrimSpace(model.ID) == "" {
+			return nil, fmt.Errorf("invalid model registry: model %d has no id", i+1)
+		}
+	}
+	return file.Models, nil
+}
+
+// ReadModelFile reads a registry file. A missing file holds no models.
+func ReadModelFile(path string) ([]ModelInfo, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model registry: %w", err)
+	}
+	models, err := ParseModels(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return models, nil
+}
+
+// WriteModelFile writes models to a registry file, creating its directory.
+// Like every file the app writes, it is readable by the user only.
+func WriteModelFile(path string, models []ModelInfo) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create model registry directory: %w", err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# Models recorded by \"terminal-ai models refresh\". Entries here extend the\n# built-in registry; edit them to add context windows, pricing and the like.\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(modelFile{Models: models}); err != nil {
+		return fmt.Errorf("failed to encode model registry: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write model registry: %w", err)
+	}
+	// WriteFile keeps the permissions of a file that already exists
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("failed to secure model registry: %w", err)
+	}
+	return nil
+}
+
+// BuiltinModels returns the models of the embedded registry
+func BuiltinModels() []ModelInfo {
+	models, err := ParseModels(builtinModels)
+	if err != nil {
+		panic(fmt.Sprintf("embedded models.yaml: %v", err))
+	}
+	return models
+}
+
+// LoadModelRegistry builds the registry from the built-in models, the
+// registry file and custom models from the config, later sources replacing
+// models of earlier ones
+func LoadModelRegistry(cfg ModelsConfig) (*ModelRegistry, error) {
+	var fileModels []ModelInfo
+	if cfg.File != "" {
+		var err error
+		if fileModels, err = ReadModelFile(cfg.File); err != nil {
+			return nil, err
+		}
+	}
+	return NewModelRegistry(BuiltinModels(), fileModels, cfg.Custom), nil
+}
+
+var (
+	modelsMu sync.RWMutex
+	models   = NewModelRegistry(BuiltinModels())
+)
+
+// Models returns the registry used by the model helpers
+func Models() *ModelRegistry {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	return models
+}
+
+// SetModels replaces the registry used by the model helpers
+func SetModels(registry *ModelRegistry) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	models = registry
+}
+
+// ModelProvider returns who serves a model: the provider recorded in the
+// registry, the route prefix of gateway names such as local/llama, or openai
+func ModelProvider(name string) string {
+	if info, ok := LookupModel(name); ok && info.Provider != "" {
+		return info.Provider
+	}
+	if i := strings.Index(name, "/"); i > 0 {
+		return name[:i]
+	}
+	return "openai"
+}
+
+// LookupModel resolves a model name with the current registry
+func LookupModel(name string) (ModelInfo, bool) {
+	return Models().Lookup(name)
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModelRegistryLookup(t *testing.T) {
+	registry := NewModelRegistry(BuiltinModels())
+
+	tests := []struct {
+		name string
+		want string // resolved id, empty when unknown
+	}{
+		{"gpt-5", "gpt-5"},
+		{"gpt-5-2025-08-07", "gpt-5"},
+		{"gpt-5-mini-2025-08-07", "gpt-5-mini"},
+		{"gpt-4o-2024-08-06", "gpt-4o"},
+		{"gpt-4o-mini-2024-07-18", "gpt-4o-mini"},
+		{"gpt-4-0613", "gpt-4"},
+		{"gpt-4-32k-0314", "gpt-4-32k"},
+		{"gpt-3.5-turbo-16k-0613", "gpt-3.5-turbo-16k"},
+		{"gpt-4-turbo-preview", "gpt-4-turbo"},
+		{"o3-mini-2025-01-31", "o3-mini"},
+		{"ft:gpt-4o-mini-2024-07-18:acme::abc123", "gpt-4o-mini"},
+		{"gpt-5-chat-latest", ""},
+		{"gpt-4oo", ""},
+		{"gpt-4o-audio-preview", ""},
+		{"invalid-model", ""},
+		{"ft:", ""},
+	}
+
+	for _, tt := range tests {
+		info, ok := registry.Lookup(tt.name)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("Lookup(%q) resolved to %s, want unknown", tt.name, info.ID)
+			}
+			continue
+		}
+		if !ok || info.ID != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q", tt.name, info.ID, ok, tt.want)
+		}
+	}
+}
+
+func TestModelRegistryCapabilities(t *testing.T) {
+	registry := NewModelRegistry(BuiltinModels())
+
+	gpt5, _ := registry.Lookup("gpt-5")
+	if !gpt5.Reasoning || gpt5.Family != "gpt-5" || gpt5.MaxOutput == 0 || gpt5.Pricing == nil {
+		t.Errorf("Unexpected gpt-5 entry: %+v", gpt5)
+	}
+	if gpt5.SupportsParameter("temperature") || !gpt5.SupportsParameter("reasoning_effort") {
+		t.Errorf("gpt-5 parameters = %v", gpt5.Parameters)
+	}
+
+	gpt4o, _ := registry.Lookup("gpt-4o")
+	if gpt4o.Reasoning || !gpt4o.SupportsParameter("temperature") || gpt4o.SupportsParameter("reasoning_effort") {
+		t.Errorf("Unexpected gpt-4o entry: %+v", gpt4o)
+	}
+
+	// Models without a parameter list accept everything
+	if !(ModelInfo{ID: "local"}).SupportsParameter("temperature") {
+		t.Error("A model without parameters should accept temperature")
+	}
+}
+
+func TestModelRegistryOverrides(t *testing.T) {
+	registry := NewModelRegistry(BuiltinModels(), []ModelInfo{
+		{ID: "gpt-4o", Family: "custom", MaxOutput: 1000},
+		{ID: "local/llama", ContextWindow: 8192, Aliases: []string{"llama"}},
+		{ID: "gpt-4-turbo-preview", MaxOutput: 2000},
+	})
+
+	if info, _ := registry.Lookup("gpt-4o-2024-08-06"); info.Family != "custom" || info.MaxOutput != 1000 {
+		t.Errorf("Override not applied to snapshot: %+v", info)
+	}
+	if info, ok := registry.Lookup("llama"); !ok || info.ID != "local/llama" {
+		t.Errorf("Alias lookup = %+v, %v", info, ok)
+	}
+
+	// Taking over an alias leaves the model it belonged to alone
+	if info, _ := registry.Lookup("gpt-4-turbo-preview"); info.ID != "gpt-4-turbo-preview" || info.MaxOutput != 2000 {
+		t.Errorf("gpt-4-turbo-preview = %+v", info)
+	}
+	if info, _ := registry.Lookup("gpt-4-turbo"); info.MaxOutput != 4096 {
+		t.Errorf("gpt-4-turbo changed: %+v", info)
+	}
+
+	unknown := registry.Unknown([]string{"gpt-5-2025-08-07", "new-model", "llama", "new-model", "other"})
+	if len(unknown) != 2 || unknown[0] != "new-model" || unknown[1] != "other" {
+		t.Errorf("Unknown = %v", unknown)
+	}
+}
+
+func TestModelFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry", "models.yaml")
+
+	models, err := ReadModelFile(path)
+	if err != nil || models != nil {
+		t.Fatalf("Missing file should hold no models, got %v, %v", models, err)
+	}
+
+	if err := WriteModelFile(path, []ModelInfo{{ID: "new-model"}, {ID: "priced", Pricing: &ModelPricing{Input: 1, Output: 2}}}); err != nil {
+		t.Fatalf("Failed to write registry file: %v", err)
+	}
+	models, err = ReadModelFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read registry file: %v", err)
+	}
+	if len(models) != 2 || models[1].Pricing == nil || models[1].Pricing.Output != 2 {
+		t.Errorf("Round trip lost data: %+v", models)
+	}
+
+	os.WriteFile(path, []byte("models:\n  - family: x\n"), 0644)
+	if _, err := ReadModelFile(path); err == nil {
+		t.Error("A model without an id should be rejected")
+	}
+}
+
+func TestLoadModelRegistry(t *testing.T) {
+	defer SetModels(NewModelRegistry(BuiltinModels()))
+
+	dir := t.TempDir()
+	registryFile := filepath.Join(dir, "models.yaml")
+	os.WriteFile(registryFile, []byte("models:\n  - id: team/model\n    max_output: 500\n"), 0644)
+
+	configFile := filepath.Join(dir, "config.yaml")
+	os.WriteFile(configFile, []byte(`openai:
+  model: team/model
+  max_tokens: 400
+models:
+  file: `+registryFile+`
+  custom:
+    - id: my-reasoner
+      family: custom
+      reasoning: true
+      max_output: 9000
+      pricing: {input: 1, output: 3}
+`), 0644)
+
+	os.Setenv("OPENAI_API_KEY", "sk-test1234567890abcdefghijklmnopqrstuvwxyz12345678")
+	defer os.Unsetenv("OPENAI_API_KEY")
+
+	config, err := Load(configFile)
+	if err != nil {
+		t.Fatalf("Model from the registry file should be valid: %v", err)
+	}
+	if len(config.Models.Custom) != 1 || config.Models.Custom[0].Pricing == nil || config.Models.Custom[0].Pricing.Output != 3 {
+		t.Errorf("Custom models not loaded: %+v", config.Models.Custom)
+	}
+	if !IsReasoningModel("my-reasoner") || IsGPT5Model("my-reasoner") {
+		t.Error("Custom model capabilities should come from the config")
+	}
+
+	// The registry file's max_output limits max_tokens
+	config.OpenAI.MaxTokens = 600
+	if err := config.Validate(); err == nil {
+		t.Error("max_tokens above the registry file's max_output should fail validation")
+	}
+}
+
+func TestModelHelpersAcceptSnapshots(t *testing.T) {
+	if !IsReasoningModel("gpt-5-2025-08-07") || !IsGPT5Model("gpt-5-2025-08-07") {
+		t.Error("gpt-5 snapshots should be GPT-5 reasoning models")
+	}
+	if IsReasoningModel("gpt-4.1-2025-04-14") {
+		t.Error("gpt-4.1 snapshots are not reasoning models")
+	}
+
+	v := NewValidator(&Config{})
+	if !v.isValidModel("gpt-5-2025-08-07") {
+		t.Error("gpt-5-2025-08-07 should be a valid model")
+	}
+	if v.getMaxTokensForModel("gpt-4o-2024-08-06") != 16384 {
+		t.Errorf("getMaxTokensForModel(gpt-4o snapshot) = %d", v.getMaxTokensForModel("gpt-4o-2024-08-06"))
+	}
+	if v.getMaxTokensForModel("unknown") != 4096 {
+		t.Error("Unknown models should default to 4096 max tokens")
+	}
+}
+
+func TestModelProvider(t *testing.T) {
+	defer SetModels(NewModelRegistry(BuiltinModels()))
+	SetModels(NewModelRegistry(BuiltinModels(), []ModelInfo{{ID: "claude-proxy", Provider: "anthropic"}}))
+
+	tests := map[string]string{
+		"gpt-5-2025-08-07": "openai",
+		"local/llama":      "local",
+		"claude-proxy":     "anthropic",
+		"unknown-model":    "openai",
+	}
+	for name, want := range tests {
+		if got := ModelProvider(name); got != want {
+			t.Errorf("ModelProvider(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
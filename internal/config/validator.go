@@ -13,27 +13,37 @@ import (
 
 // Validator provides configuration validation
 type Validator struct {
-	config *Config
-	errors []string
+	config   *Config
+	errors   []string
+	warnings []string
 }
 
 // NewValidator creates a new configuration validator
 func NewValidator(config *Config) *Validator {
 	return &Validator{
-		config: config,
-		errors: []string{},
+		config:   config,
+		errors:   []string{},
+		warnings: []string{},
 	}
 }
 
 // Validate performs full configuration validation
 func (v *Validator) Validate() error {
 	v.errors = []string{}
+	v.warnings = []string{}
 
 	// Validate all sections
 	v.validateOpenAI()
 	v.validateCache()
 	v.validateUI()
 	v.validateLogging()
+	v.validateCassette()
+	v.validateTracing()
+	v.validateSafety()
+	v.validateAudio()
+	v.validatePresets()
+	v.validateStorage()
+	v.validateRetention()
 
 	if len(v.errors) > 0 {
 		return errors.New(strings.Join(v.errors, "; "))
@@ -44,7 +54,10 @@ func (v *Validator) Validate() error {
 
 // validateOpenAI validates OpenAI configuration
 func (v *Validator) validateOpenAI() {
-	// API Key validation
+	// API Key validation (replayed and offline runs never reach the API)
+	if v.config.OpenAI.APIKey == "" && (v.config.Cassette.Mode == "replay" || v.config.Cache.Offline) {
+		return
+	}
 	if v.config.OpenAI.APIKey == "" {
 		v.errors = append(v.errors, "OpenAI API key is required (set OPENAI_API_KEY or configure in file)")
 		return // Skip other validations if no API key
@@ -64,7 +77,7 @@ func (v *Validator) validateOpenAI() {
 
 	// Model validation
 	if !v.isValidModel(v.config.OpenAI.Model) {
-		v.errors = append(v.errors, fmt.Sprintf("unsupported model: %s", v.config.OpenAI.Model))
+		v.errors = append(v.errors, fmt.Sprintf("unsupported model: %s (add it under models.custom or run 'terminal-ai models refresh')", v.config.OpenAI.Model))
 	}
 
 	// Temperature validation - reasoning models require 1.0
@@ -137,9 +150,34 @@ func (v *Validator) validateCache() {
 	}
 
 	// Strategy validation
-	validStrategies := []string{"lru", "lfu", "fifo", ""}
+	validStrategies := []string{"lru", "lfu", "fifo", "size", ""}
 	if !v.contains(validStrategies, v.config.Cache.Strategy) {
-		v.errors = append(v.errors, fmt.Sprintf("invalid cache strategy: %s (must be lru, lfu, or fifo)", v.config.Cache.Strategy))
+		v.errors = append(v.errors, fmt.Sprintf("invalid cache strategy: %s (must be lru, lfu, fifo, or size)", v.config.Cache.Strategy))
+	}
+
+	// Backend validation
+	validBackends := []string{"disk", "memory", ""}
+	if !v.contains(validBackends, v.config.Cache.Backend) {
+		v.errors = append(v.errors, fmt.Sprintf("invalid cache backend: %s (must be disk or memory)", v.config.Cache.Backend))
+	}
+
+	if v.config.Cache.StaleGrace < 0 {
+		v.errors = append(v.errors, "cache stale grace must be non-negative")
+	}
+	if v.config.Cache.StaleOnError && v.config.Cache.StaleGrace == 0 {
+		v.warnings = append(v.warnings, "cache stale_on_error has no effect with stale_grace 0: expired entries are dropped before they can be served")
+	}
+	if v.config.Cache.Offline && !v.config.Cache.Enabled {
+		v.errors = append(v.errors, "offline mode requires the cache to be enabled")
+	}
+
+	if semantic := v.config.Cache.Semantic; semantic.Enabled {
+		if semantic.Threshold <= 0 || semantic.Threshold > 1 {
+			v.errors = append(v.errors, fmt.Sprintf("semantic cache threshold must be above 0 and at most 1, got %.2f", semantic.Threshold))
+		}
+		if semantic.Model == "" {
+			v.errors = append(v.errors, "semantic cache requires an embedding model")
+		}
 	}
 
 	// Cache directory validation
@@ -213,7 +251,7 @@ func (v *Validator) validateLogging() {
 		// Check if directory exists or can be created
 		if _, err := os.Stat(logDir); os.IsNotExist(err) {
 			// Try to create the directory
-			if err := os.MkdirAll(logDir, 0755); err != nil {
+			if err := os.MkdirAll(logDir, 0700); err != nil {
 				v.errors = append(v.errors, fmt.Sprintf("cannot create log directory: %s", logDir))
 			} else {
 				// Clean up test directory
@@ -224,7 +262,7 @@ func (v *Validator) validateLogging() {
 		// Check if file is writable (if it exists)
 		if _, err := os.Stat(logFile); err == nil {
 			// File exists, check if writable
-			if file, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+			if file, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0600); err != nil {
 				v.errors = append(v.errors, fmt.Sprintf("log file is not writable: %s", logFile))
 			} else {
 				file.Close()
@@ -233,6 +271,167 @@ func (v *Validator) validateLogging() {
 	}
 }
 
+// validateCassette validates record/replay configuration
+func (v *Validator) validateCassette() {
+	validModes := []string{"record", "replay", ""}
+	if !v.contains(validModes, v.config.Cassette.Mode) {
+		v.errors = append(v.errors, fmt.Sprintf("invalid cassette mode: %s (must be record or replay)", v.config.Cassette.Mode))
+		return
+	}
+
+	if v.config.Cassette.Mode != "" && v.config.Cassette.Dir == "" {
+		v.errors = append(v.errors, "cassette directory is required when cassette mode is set")
+	}
+
+	// Replay needs existing recordings
+	if v.config.Cassette.Mode == "replay" && v.config.Cassette.Dir != "" {
+		if info, err := os.Stat(os.ExpandEnv(v.config.Cassette.Dir)); err != nil || !info.IsDir() {
+			v.errors = append(v.errors, fmt.Sprintf("cassette directory not found: %s", v.config.Cassette.Dir))
+		}
+	}
+}
+
+// validateTracing validates trace export configuration
+func (v *Validator) validateTracing() {
+	if v.config.Tracing.Endpoint == "" {
+		return
+	}
+	u, err := url.Parse(v.config.Tracing.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.errors = append(v.errors, fmt.Sprintf("invalid tracing endpoint: %s (must be an http or https URL)", v.config.Tracing.Endpoint))
+	}
+}
+
+// validateSafety validates moderation and policy configuration
+func (v *Validator) validateSafety() {
+	safety := v.config.Safety
+	if !safety.Enabled {
+		return
+	}
+
+	if !safety.Moderation && len(safety.Rules) == 0 {
+		v.errors = append(v.errors, "safety is enabled but neither moderation nor rules are configured")
+	}
+
+	validActions := []string{"block", "warn", "log", "allow"}
+	if !v.contains(validActions, safety.Action) {
+		v.errors = append(v.errors, fmt.Sprintf("invalid safety action: %s (must be block, warn, log or allow)", safety.Action))
+	}
+	if safety.Threshold < 0 || safety.Threshold > 1 {
+		v.errors = append(v.errors, fmt.Sprintf("safety threshold must be between 0 and 1, got %.2f", safety.Threshold))
+	}
+
+	for name, category := range safety.Categories {
+		if category.Action != "" && !v.contains(validActions, category.Action) {
+			v.errors = append(v.errors, fmt.Sprintf("invalid safety action for %s: %s", name, category.Action))
+		}
+		if category.Threshold < 0 || category.Threshold > 1 {
+			v.errors = append(v.errors, fmt.Sprintf("safety threshold for %s must be between 0 and 1", name))
+		}
+	}
+
+	for i, rule := range safety.Rules {
+		if rule.Category == "" {
+			v.errors = append(v.errors, fmt.Sprintf("safety rule %d has no category", i+1))
+		}
+		if len(rule.Patterns) == 0 && len(rule.Keywords) == 0 {
+			v.errors = append(v.errors, fmt.Sprintf("safety rule %d has no patterns or keywords", i+1))
+		}
+		for _, pattern := range rule.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				v.errors = append(v.errors, fmt.Sprintf("invalid safety pattern %q: %v", pattern, err))
+			}
+		}
+	}
+}
+
+// validateAudio validates text-to-speech settings. Empty values fall back to
+// the API defaults.
+func (v *Validator) validateAudio() {
+	audio := v.config.Audio
+
+	validVoices := []string{"alloy", "ash", "ballad", "coral", "echo", "fable", "nova", "onyx", "sage", "shimmer", "verse"}
+	if audio.Voice != "" && !v.contains(validVoices, audio.Voice) {
+		v.errors = append(v.errors, fmt.Sprintf("invalid audio voice: %s (must be one of %s)", audio.Voice, strings.Join(validVoices, ", ")))
+	}
+
+	validFormats := []string{"mp3", "opus", "aac", "flac", "wav", "pcm"}
+	if audio.Format != "" && !v.contains(validFormats, audio.Format) {
+		v.errors = append(v.errors, fmt.Sprintf("invalid audio format: %s (must be mp3, opus, aac, flac, wav or pcm)", audio.Format))
+	}
+
+	if audio.Speed != 0 && (audio.Speed < 0.25 || audio.Speed > 4.0) {
+		v.errors = append(v.errors, fmt.Sprintf("audio speed must be between 0.25 and 4.0, got %.2f", audio.Speed))
+	}
+}
+
+// validateStorage validates storage encryption settings
+func (v *Validator) validateStorage() {
+	storage := v.config.Storage
+	if !storage.Encrypt {
+		return
+	}
+
+	validSources := []string{"keyring", "passphrase", "env"}
+	if !v.contains(validSources, storage.KeySource) {
+		v.errors = append(v.errors, fmt.Sprintf("invalid storage key source: %s (must be keyring, passphrase or env)", storage.KeySource))
+	}
+	if storage.KeySource == "env" && storage.KeyEnv == "" {
+		v.errors = append(v.errors, "storage key source env requires storage.key_env")
+	}
+	if storage.KeyFile == "" {
+		v.errors = append(v.errors, "storage encryption requires storage.key_file")
+	}
+}
+
+// validateRetention validates the retention policy of each kind of data
+func (v *Validator) validateRetention() {
+	policies := []struct {
+		class  string
+		policy RetentionPolicy
+	}{
+		{"history", v.config.Retention.History},
+		{"cache", v.config.Retention.Cache},
+		{"logs", v.config.Retention.Logs},
+	}
+	for _, p := range policies {
+		if p.policy.MaxAge < 0 {
+			v.errors = append(v.errors, fmt.Sprintf("retention.%s.max_age cannot be negative", p.class))
+		}
+		if p.policy.MaxSize < 0 {
+			v.errors = append(v.errors, fmt.Sprintf("retention.%s.max_size cannot be negative", p.class))
+		}
+	}
+}
+
+// validatePresets validates each preset as applied over the OpenAI settings
+func (v *Validator) validatePresets() {
+	validTiers := []string{"auto", "default", "priority", "flex", "scale"}
+	for _, name := range v.config.PresetNames() {
+		preset := v.config.Presets[name]
+		applied, _ := v.config.WithPreset(name)
+
+		if preset.Model != "" && !v.isValidModel(preset.Model) {
+			v.errors = append(v.errors, fmt.Sprintf("preset %s: unsupported model: %s", name, preset.Model))
+			continue
+		}
+		if preset.ReasoningEffort != "" && !v.isValidReasoningEffort(preset.ReasoningEffort) {
+			v.errors = append(v.errors, fmt.Sprintf("preset %s: invalid reasoning_effort: %s (must be minimal, low, medium, or high)", name, preset.ReasoningEffort))
+		}
+		if preset.ServiceTier != "" && !v.contains(validTiers, preset.ServiceTier) {
+			v.errors = append(v.errors, fmt.Sprintf("preset %s: invalid service_tier: %s (must be auto, default, priority, flex or scale)", name, preset.ServiceTier))
+		}
+		if preset.MaxTokens < 0 {
+			v.errors = append(v.errors, fmt.Sprintf("preset %s: max_tokens must be at least 1", name))
+		} else if limit := v.getMaxTokensForModel(applied.OpenAI.Model); applied.OpenAI.MaxTokens > limit {
+			v.errors = append(v.errors, fmt.Sprintf("preset %s: max_tokens exceeds the limit of %d for %s", name, limit, applied.OpenAI.Model))
+		}
+		if preset.Temperature != nil && (*preset.Temperature < 0 || *preset.Temperature > 2) {
+			v.errors = append(v.errors, fmt.Sprintf("preset %s: temperature must be between 0 and 2", name))
+		}
+	}
+}
+
 // isValidAPIKey performs basic validation of API key format
 func (v *Validator) isValidAPIKey(key string) bool {
 	// Skip validation for environment variable references
@@ -263,85 +462,21 @@ func (v *Validator) isValidAPIKey(key string) bool {
 	return len(key) >= 20 && len(key) <= 200
 }
 
-// isValidModel checks if a model name is valid
+// isValidModel checks if a model name is known to the model registry
 func (v *Validator) isValidModel(model string) bool {
 	// Allow fine-tuned models
 	if strings.HasPrefix(model, "ft:") || strings.HasPrefix(model, "ft-") {
 		return true
 	}
 
-	// List of valid OpenAI models (as of 2025)
-	validModels := []string{
-		// GPT-5 reasoning models
-		"gpt-5", "gpt-5-mini", "gpt-5-nano",
-		
-		// O-series reasoning models
-		"o1", "o1-mini", "o3", "o3-mini", "o4-mini",
-		
-		// GPT-4.1 non-reasoning models
-		"gpt-4.1", "gpt-4.1-mini", "gpt-4.1-nano",
-		
-		// GPT-4 models
-		"gpt-4", "gpt-4-0314", "gpt-4-0613", "gpt-4-32k", "gpt-4-32k-0314", "gpt-4-32k-0613",
-		"gpt-4-turbo", "gpt-4-turbo-preview", "gpt-4-1106-preview", "gpt-4-vision-preview",
-		"gpt-4o", "gpt-4o-mini",
-
-		// GPT-3.5 models
-		"gpt-3.5-turbo", "gpt-3.5-turbo-0301", "gpt-3.5-turbo-0613", "gpt-3.5-turbo-1106",
-		"gpt-3.5-turbo-16k", "gpt-3.5-turbo-16k-0613", "gpt-3.5-turbo-instruct",
-
-		// Legacy models (might still be in use)
-		"text-davinci-003", "text-davinci-002", "code-davinci-002",
-
-		// Embedding models (in case they're used)
-		"text-embedding-ada-002", "text-embedding-3-small", "text-embedding-3-large",
-	}
-
-	return v.contains(validModels, model)
+	_, ok := LookupModel(model)
+	return ok
 }
 
-// getMaxTokensForModel returns the maximum tokens for a given model
+// getMaxTokensForModel returns the maximum output tokens for a given model
 func (v *Validator) getMaxTokensForModel(model string) int {
-	// Model-specific limits
-	modelLimits := map[string]int{
-		// GPT-5 reasoning models
-		"gpt-5":               200000,
-		"gpt-5-mini":          100000,
-		"gpt-5-nano":          50000,
-		
-		// O-series reasoning models
-		"o1":                  200000,
-		"o1-mini":             100000,
-		"o3":                  200000,
-		"o3-mini":             100000,
-		"o4-mini":             100000,
-		
-		// GPT-4.1 non-reasoning models
-		"gpt-4.1":             128000,
-		"gpt-4.1-mini":        64000,
-		"gpt-4.1-nano":        32000,
-		
-		// GPT-4 models
-		"gpt-4":               8192,
-		"gpt-4-32k":           32768,
-		"gpt-4-turbo":         128000,
-		"gpt-4-turbo-preview": 128000,
-		"gpt-4o":              128000,
-		"gpt-4o-mini":         16384,
-		"gpt-3.5-turbo":       4096,
-		"gpt-3.5-turbo-16k":   16384,
-	}
-
-	// Check for exact match
-	if limit, ok := modelLimits[model]; ok {
-		return limit
-	}
-
-	// Check for prefix match (for versioned models)
-	for prefix, limit := range modelLimits {
-		if strings.HasPrefix(model, prefix) {
-			return limit
-		}
+	if info, ok := LookupModel(model); ok && info.MaxOutput > 0 {
+		return info.MaxOutput
 	}
 
 	// Default limit for unknown models
@@ -377,6 +512,11 @@ func (v *Validator) GetErrors() []string {
 	return v.errors
 }
 
+// GetWarnings returns settings that are valid but likely not what was meant
+func (v *Validator) GetWarnings() []string {
+	return v.warnings
+}
+
 // ValidateAPIKeyFile validates an API key file
 func ValidateAPIKeyFile(path string) error {
 	// Check if file exists
@@ -0,0 +1,234 @@
+// Package gateway exposes an ai.Client as a small OpenAI-compatible HTTP
+// service, so shared boxes can route requests through the client's cache,
+// rate limiter and retries and be scraped for metrics.
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// Gateway serves chat completions through an ai.Client
+type Gateway struct {
+	client  ai.Client
+	metrics *utils.MetricsCollector
+}
+
+// chatRequest is the subset of the OpenAI chat completion request the gateway accepts
+type chatRequest struct {
+	Model               string       `json:"model"`
+	Messages            []ai.Message `json:"messages"`
+	Stream              bool         `json:"stream"`
+	Temperature         float32      `json:"temperature"`
+	TopP                float32      `json:"top_p"`
+	MaxTokens           int          `json:"max_tokens"`
+	MaxCompletionTokens int          `json:"max_completion_tokens"`
+	Stop                []string     `json:"stop"`
+	User                string       `json:"user"`
+	ReasoningEffort     string       `json:"reasoning_effort"`
+	ServiceTier         string       `json:"service_tier"`
+}
+
+// New creates a gateway for client. Metrics are served at /metrics when metrics is not nil.
+func New(client ai.Client, metrics *utils.MetricsCollector) *Gateway {
+	return &Gateway{client: client, metrics: metrics}
+}
+
+// Handler returns the HTTP handler for the gateway
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", g.handleChatCompletions)
+	mux.HandleFunc("/v1/models", g.handleModels)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	if g.metrics != nil {
+		mux.Handle("/metrics", g.metrics.PrometheusHandler())
+	}
+	return mux
+}
+
+// handleModels serves /v1/models
+func (g *Gateway) handleModels(w http.ResponseWriter, r *http.Request) {
+	models, err := g.client.ListModels(r.Context())
+	if err != nil {
+		writeError(w, upstreamStatus(err), err.Error())
+		return
+	}
+
+	data := make([]map[string]interface{}, len(models))
+	for i, model := range models {
+		data[i] = map[string]interface{}{"id": model, "object": "model"}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"object": "list", "data": data})
+}
+
+// handleChatCompletions serves /v1/chat/completions
+func (g *Gateway) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "messages are required")
+		return
+	}
+
+	options := ai.ChatOptions{
+		Model:           req.Model,
+		Temperature:     req.Temperature,
+		TopP:            req.TopP,
+		MaxTokens:       req.MaxCompletionTokens,
+		Stop:            req.Stop,
+		User:            req.User,
+		ReasoningEffort: req.ReasoningEffort,
+		ServiceTier:     req.ServiceTier,
+	}
+	if options.MaxTokens == 0 {
+		options.MaxTokens = req.MaxTokens
+	}
+
+	log.Debug().
+		Str("model", req.Model).
+		Bool("stream", req.Stream).
+		Int("messages", len(req.Messages)).
+		Msg("Gateway chat request")
+
+	if req.Stream {
+		g.stream(w, r, req, options)
+		return
+	}
+
+	resp, err := g.client.Chat(r.Context(), req.Messages, options)
+	if err != nil {
+		writeError(w, upstreamStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      resp.ID,
+		"object":  "chat.completion",
+		"created": resp.Created.Unix(),
+		"model":   resp.Model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       map[string]string{"role": "assistant", "content": resp.Content},
+			"finish_reason": resp.FinishReason,
+		}},
+		"usage": map[string]int{
+			"prompt_tokens":     resp.Usage.PromptTokens,
+			"completion_tokens": resp.Usage.CompletionTokens,
+			"total_tokens":      resp.Usage.TotalTokens,
+		},
+	})
+}
+
+// stream relays a ChatStream as Server-Sent Events
+func (g *Gateway) stream(w http.ResponseWriter, r *http.Request, req chatRequest, options ai.ChatOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	chunks, err := g.client.ChatStream(r.Context(), req.Messages, options)
+	if err != nil {
+		writeError(w, upstreamStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	id := fmt.Sprintf("chatcmpl-gw-%d", time.Now().UnixNano())
+	created := time.Now().Unix()
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			// Headers are already sent, so report the failure in-band
+			writeEvent(w, map[string]interface{}{
+				"error": map[string]string{"message": chunk.Error.Error(), "type": "server_error"},
+			})
+			flusher.Flush()
+			return
+		}
+		if chunk.Content != "" {
+			writeEvent(w, streamChunk(id, req.Model, created, map[string]string{"content": chunk.Content}, nil))
+			flusher.Flush()
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	finish := "stop"
+	writeEvent(w, streamChunk(id, req.Model, created, map[string]string{}, &finish))
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// streamChunk builds a chat.completion.chunk payload
+func streamChunk(id, model string, created int64, delta map[string]string, finishReason *string) map[string]interface{} {
+	choice := map[string]interface{}{
+		"index": 0,
+		"delta": delta,
+	}
+	if finishReason != nil {
+		choice["finish_reason"] = *finishReason
+	}
+	return map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   model,
+		"choices": []map[string]interface{}{choice},
+	}
+}
+
+// upstreamStatus returns the status for a client error. Rejections such as
+// safety policy blocks or invalid requests keep their 4xx status; everything
+// else is reported as a bad gateway.
+func upstreamStatus(err error) int {
+	if appErr := utils.GetAppError(err); appErr != nil {
+		if status := appErr.HTTPStatus(); status >= 400 && status < 500 && status != http.StatusRequestTimeout {
+			return status
+		}
+	}
+	return http.StatusBadGateway
+}
+
+// writeError writes an OpenAI-style error body
+func writeError(w http.ResponseWriter, status int, message string) {
+	errType := "invalid_request_error"
+	if status >= 500 {
+		errType = "server_error"
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]string{"message": message, "type": errType},
+	})
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeEvent writes a single SSE data event
+func writeEvent(w http.ResponseWriter, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
@@ -0,0 +1,124 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// fakeClient is a minimal ai.Client for exercising the gateway
+type fakeClient struct {
+	lastOptions ai.ChatOptions
+	err         error
+}
+
+func (f *fakeClient) Query(ctx context.Context, prompt string) (string, error) {
+	return "answer", f.err
+}
+
+func (f *fakeClient) StreamQuery(ctx context.Context, prompt string, callback func(chunk string)) error {
+	callback("answer")
+	return f.err
+}
+
+func (f *fakeClient) Chat(ctx context.Context, messages []ai.Message, options ai.ChatOptions) (*ai.Response, error) {
+	f.lastOptions = options
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ai.Response{
+		ID:           "chatcmpl-1",
+		Content:      "answer to " + messages[len(messages)-1].Content,
+		Model:        options.Model,
+		FinishReason: "stop",
+		Created:      time.Now(),
+		Usage:        ai.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5},
+	}, nil
+}
+
+func (f *fakeClient) ChatStream(ctx context.Context, messages []ai.Message, options ai.ChatOptions) (<-chan ai.StreamChunk, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	chunks := make(chan ai.StreamChunk, 3)
+	chunks <- ai.StreamChunk{Content: "Hello"}
+	chunks <- ai.StreamChunk{Content: " world"}
+	chunks <- ai.StreamChunk{Done: true}
+	close(chunks)
+	return chunks, nil
+}
+
+func (f *fakeClient) ListModels(ctx context.Context) ([]string, error) {
+	return []string{"gpt-4o"}, f.err
+}
+
+func (f *fakeClient) Close() error { return nil }
+
+func post(t *testing.T, url, body string) (*http.Response, string) {
+	resp, err := http.Post(url+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestGateway_Chat(t *testing.T) {
+	client := &fakeClient{}
+	server := httptest.NewServer(New(client, nil).Handler())
+	defer server.Close()
+
+	resp, body := post(t, server.URL, `{"model":"gpt-4o","max_tokens":50,"messages":[{"role":"user","content":"hi"}]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"content":"answer to hi"`)
+	assert.Contains(t, body, `"total_tokens":5`)
+	assert.Equal(t, 50, client.lastOptions.MaxTokens)
+}
+
+func TestGateway_Stream(t *testing.T) {
+	server := httptest.NewServer(New(&fakeClient{}, nil).Handler())
+	defer server.Close()
+
+	resp, body := post(t, server.URL, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, `"content":"Hello"`)
+	assert.Contains(t, body, `"content":" world"`)
+	assert.True(t, strings.HasSuffix(body, "data: [DONE]\n\n"))
+}
+
+func TestGateway_Errors(t *testing.T) {
+	server := httptest.NewServer(New(&fakeClient{err: errors.New("upstream failed")}, nil).Handler())
+	defer server.Close()
+
+	resp, body := post(t, server.URL, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Contains(t, body, "upstream failed")
+
+	resp, _ = post(t, server.URL, `{"model":"gpt-4o","messages":[]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGateway_Metrics(t *testing.T) {
+	metrics := utils.InitMetrics()
+	metrics.RecordCacheHit()
+
+	server := httptest.NewServer(New(&fakeClient{}, metrics).Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "terminal_ai_cache_hits_total")
+}
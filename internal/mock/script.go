@@ -0,0 +1,158 @@
+package mock
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultContent is returned when no script rule matches a request
+const DefaultContent = "This is a mock response from terminal-ai mock-server."
+
+// Script describes how the mock server responds to requests
+type Script struct {
+	Models     []string          `yaml:"models"`     // models returned by /v1/models
+	Default    string            `yaml:"default"`    // content when no rule matches
+	Responses  []*Rule           `yaml:"responses"`  // rules evaluated in order
+	Moderation []*ModerationRule `yaml:"moderation"` // scores for /v1/moderations
+	Transcript string            `yaml:"transcript"` // text returned by /v1/audio/transcriptions
+	mu         sync.Mutex
+}
+
+// Rule matches a chat request and defines the response or injected failure
+type Rule struct {
+	Match         string     `yaml:"match"`          // substring of the last user message (empty matches all)
+	Model         string     `yaml:"model"`          // only match this model (empty matches all)
+	Content       string     `yaml:"content"`        // response content
+	Error         *ErrorSpec `yaml:"error"`          // inject an HTTP error instead of a response
+	Times         int        `yaml:"times"`          // number of times the rule applies (0 = unlimited)
+	Latency       string     `yaml:"latency"`        // delay before responding, e.g. 500ms
+	ChunkDelay    string     `yaml:"chunk_delay"`    // delay between stream chunks (slow streams)
+	TruncateAfter int        `yaml:"truncate_after"` // drop the connection after N stream chunks
+
+	latency    time.Duration
+	chunkDelay time.Duration
+	used       int
+}
+
+// ModerationRule flags moderation input containing Match with category scores
+type ModerationRule struct {
+	Match  string             `yaml:"match"`  // substring of the input (case-insensitive)
+	Scores map[string]float64 `yaml:"scores"` // category scores, e.g. violence: 0.9
+}
+
+// ErrorSpec describes an injected API error
+type ErrorSpec struct {
+	Status     int    `yaml:"status"`      // HTTP status code, e.g. 429 or 500
+	Message    string `yaml:"message"`     // error message in the response body
+	Type       string `yaml:"type"`        // error type, e.g. rate_limit_error
+	Code       string `yaml:"code"`        // error code, e.g. rate_limit_exceeded
+	RetryAfter int    `yaml:"retry_after"` // Retry-After header in seconds
+}
+
+// DefaultScript returns a script that answers every request with DefaultContent
+func DefaultScript() *Script {
+	s := &Script{}
+	s.applyDefaults()
+	return s
+}
+
+// LoadScript reads a script from a YAML file
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	return ParseScript(data)
+}
+
+// ParseScript parses a YAML script
+func ParseScript(data []byte) (*Script, error) {
+	var s Script
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse script: %w", err)
+	}
+
+	for i, rule := range s.Responses {
+		if rule == nil {
+			return nil, fmt.Errorf("response %d is empty", i+1)
+		}
+		if rule.Latency != "" {
+			d, err := time.ParseDuration(rule.Latency)
+			if err != nil {
+				return nil, fmt.Errorf("response %d: invalid latency: %w", i+1, err)
+			}
+			rule.latency = d
+		}
+		if rule.ChunkDelay != "" {
+			d, err := time.ParseDuration(rule.ChunkDelay)
+			if err != nil {
+				return nil, fmt.Errorf("response %d: invalid chunk_delay: %w", i+1, err)
+			}
+			rule.chunkDelay = d
+		}
+		if rule.Error != nil && (rule.Error.Status < 400 || rule.Error.Status > 599) {
+			return nil, fmt.Errorf("response %d: error status must be 4xx or 5xx", i+1)
+		}
+		if rule.Times < 0 || rule.TruncateAfter < 0 {
+			return nil, fmt.Errorf("response %d: times and truncate_after must be non-negative", i+1)
+		}
+	}
+
+	s.applyDefaults()
+	return &s, nil
+}
+
+// applyDefaults fills in models and default content
+func (s *Script) applyDefaults() {
+	if len(s.Models) == 0 {
+		s.Models = []string{"gpt-5", "gpt-5-mini", "gpt-5-nano", "gpt-4.1", "gpt-4o", "gpt-4o-mini"}
+	}
+	if s.Default == "" {
+		s.Default = DefaultContent
+	}
+}
+
+// next returns the first rule matching the request and consumes one use of it.
+// A nil rule means the default content should be served.
+func (s *Script) next(model, prompt string) *Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rule := range s.Responses {
+		if rule.Times > 0 && rule.used >= rule.Times {
+			continue
+		}
+		if rule.Model != "" && rule.Model != model {
+			continue
+		}
+		if rule.Match != "" && !strings.Contains(strings.ToLower(prompt), strings.ToLower(rule.Match)) {
+			continue
+		}
+		rule.used++
+		return rule
+	}
+	return nil
+}
+
+// moderationScores returns the category scores for input; unmatched
+// categories score zero
+func (s *Script) moderationScores(input string) map[string]float64 {
+	scores := make(map[string]float64)
+	lower := strings.ToLower(input)
+	for _, rule := range s.Moderation {
+		if rule == nil || !strings.Contains(lower, strings.ToLower(rule.Match)) {
+			continue
+		}
+		for category, score := range rule.Scores {
+			if score > scores[category] {
+				scores[category] = score
+			}
+		}
+	}
+	return scores
+}
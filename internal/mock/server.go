@@ -0,0 +1,696 @@
+// Package mock implements a local OpenAI-compatible server for exercising
+// the client's retry, rate-limit, cache and streaming paths without network access.
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Server is an OpenAI-compatible mock server driven by a Script
+type Server struct {
+	script   *Script
+	server   *http.Server
+	requests int64
+}
+
+// chatRequest is the subset of the chat completion request the server reads
+type chatRequest struct {
+	Model         string `json:"model"`
+	Stream        bool   `json:"stream"`
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+}
+
+// NewServer creates a mock server using the given script
+func NewServer(script *Script) *Server {
+	if script == nil {
+		script = DefaultScript()
+	}
+	s := &Server{script: script}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", s.handleChatCompletions)
+	mux.HandleFunc("/v1/models", s.handleModels)
+	mux.HandleFunc("/v1/moderations", s.handleModerations)
+	mux.HandleFunc("/v1/embeddings", s.handleEmbeddings)
+	mux.HandleFunc("/v1/audio/transcriptions", s.handleTranscriptions)
+	mux.HandleFunc("/v1/audio/speech", s.handleSpeech)
+	mux.HandleFunc("/v1/images/generations", s.handleImages)
+	mux.HandleFunc("/v1/images/edits", s.handleImages)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown endpoint %s", r.URL.Path), "invalid_request_error", "not_found")
+	})
+
+	s.server = &http.Server{Handler: mux}
+	return s
+}
+
+// Handler returns the HTTP handler, e.g. for use with httptest
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
+
+// Serve accepts connections on the listener until Shutdown is called
+func (s *Server) Serve(listener net.Listener) error {
+	err := s.server.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// ListenAndServe listens on addr and serves requests
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return s.Serve(listener)
+}
+
+// Shutdown gracefully stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// Requests returns the number of chat completion requests served
+func (s *Server) Requests() int64 {
+	return atomic.LoadInt64(&s.requests)
+}
+
+// handleModels serves /v1/models
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	data := make([]map[string]interface{}, len(s.script.Models))
+	for i, model := range s.script.Models {
+		data[i] = map[string]interface{}{
+			"id":       model,
+			"object":   "model",
+			"created":  time.Now().Unix(),
+			"owned_by": "terminal-ai-mock",
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// moderationCategories are the categories reported by the moderation endpoint
+var moderationCategories = []string{
+	"harassment", "harassment/threatening", "hate", "hate/threatening",
+	"illicit", "illicit/violent", "self-harm", "self-harm/instructions",
+	"self-harm/intent", "sexual", "sexual/minors", "violence", "violence/graphic",
+}
+
+// handleModerations serves /v1/moderations with scripted category scores
+func (s *Server) handleModerations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	var req struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err), "invalid_request_error", "")
+		return
+	}
+
+	var inputs []string
+	var single string
+	if err := json.Unmarshal(req.Input, &single); err == nil {
+		inputs = []string{single}
+	} else if err := json.Unmarshal(req.Input, &inputs); err != nil {
+		writeError(w, http.StatusBadRequest, "input must be a string or an array of strings", "invalid_request_error", "")
+		return
+	}
+
+	results := make([]map[string]interface{}, len(inputs))
+	for i, input := range inputs {
+		scores := s.script.moderationScores(input)
+		flags := make(map[string]bool, len(moderationCategories))
+		allScores := make(map[string]float64, len(moderationCategories))
+		flagged := false
+		for _, category := range moderationCategories {
+			allScores[category] = scores[category]
+			flags[category] = scores[category] >= 0.5
+			flagged = flagged || flags[category]
+		}
+		results[i] = map[string]interface{}{
+			"flagged":         flagged,
+			"categories":      flags,
+			"category_scores": allScores,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      fmt.Sprintf("modr-mock-%d", time.Now().UnixNano()),
+		"model":   req.Model,
+		"results": results,
+	})
+}
+
+// embeddingDimensions is the length of mock embeddings
+const embeddingDimensions = 256
+
+// handleEmbeddings serves /v1/embeddings with normalized bag-of-words
+// vectors, so inputs sharing most of their words are similar
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	var req struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err), "invalid_request_error", "")
+		return
+	}
+
+	var inputs []string
+	var single string
+	if err := json.Unmarshal(req.Input, &single); err == nil {
+		inputs = []string{single}
+	} else if err := json.Unmarshal(req.Input, &inputs); err != nil {
+		writeError(w, http.StatusBadRequest, "input must be a string or an array of strings", "invalid_request_error", "")
+		return
+	}
+
+	data := make([]map[string]interface{}, len(inputs))
+	tokens := 0
+	for i, input := range inputs {
+		data[i] = map[string]interface{}{
+			"object":    "embedding",
+			"index":     i,
+			"embedding": embed(input),
+		}
+		tokens += countTokens(input)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   data,
+		"model":  req.Model,
+		"usage":  map[string]interface{}{"prompt_tokens": tokens, "total_tokens": tokens},
+	})
+}
+
+// embed hashes the lowercased words of text into a unit vector
+func embed(text string) []float64 {
+	vector := make([]float64, embeddingDimensions)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.Trim(word, ".,;:!?\"'()")
+		if word == "" {
+			continue
+		}
+		hash := fnv.New32a()
+		hash.Write([]byte(word))
+		vector[hash.Sum32()%embeddingDimensions]++
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector
+}
+
+// handleTranscriptions serves /v1/audio/transcriptions. Each sentence of the
+// scripted transcript becomes a segment lasting 0.4s per word.
+func (s *Server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error", "")
+		return
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid multipart body: %v", err), "invalid_request_error", "")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file is required", "invalid_request_error", "")
+		return
+	}
+	file.Close()
+
+	text := s.script.Transcript
+	if text == "" {
+		text = fmt.Sprintf("This is a mock transcript of %s.", header.Filename)
+	}
+
+	type segment struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+	}
+	var segments []segment
+	position := 0.0
+	for _, sentence := range strings.SplitAfter(text, ". ") {
+		if strings.TrimSpace(sentence) == "" {
+			continue
+		}
+		length := 0.4 * float64(len(strings.Fields(sentence)))
+		segments = append(segments, segment{Start: position, End: position + length, Text: strings.TrimSpace(sentence)})
+		position += length
+	}
+
+	switch r.FormValue("response_format") {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, text)
+	case "verbose_json":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"task":     "transcribe",
+			"language": "english",
+			"duration": position,
+			"text":     text,
+			"segments": segments,
+		})
+	default:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"text": text})
+	}
+}
+
+// maxSpeechInput is the input limit of /v1/audio/speech
+const maxSpeechInput = 4096
+
+// handleSpeech serves /v1/audio/speech. wav requests get a silent 24kHz mono
+// file lasting 0.1s per word; other formats get the input text framed as
+// "[input]" so tests can check how text was split.
+func (s *Server) handleSpeech(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	var req struct {
+		Model          string `json:"model"`
+		Input          string `json:"input"`
+		Voice          string `json:"voice"`
+		ResponseFormat string `json:"response_format"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err), "invalid_request_error", "")
+		return
+	}
+	if req.Voice == "" {
+		writeError(w, http.StatusBadRequest, "voice is required", "invalid_request_error", "")
+		return
+	}
+	if n := utf8.RuneCountInString(req.Input); n > maxSpeechInput {
+		writeError(w, http.StatusBadRequest,
+			fmt.Sprintf("input is %d characters, above the %d character limit", n, maxSpeechInput),
+			"invalid_request_error", "string_above_max_length")
+		return
+	}
+
+	if req.ResponseFormat != "wav" {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		fmt.Fprintf(w, "[%s]", req.Input)
+		return
+	}
+
+	samples := make([]byte, 4800*len(strings.Fields(req.Input)))
+	var body bytes.Buffer
+	body.WriteString("RIFF")
+	binary.Write(&body, binary.LittleEndian, uint32(36+len(samples)))
+	body.WriteString("WAVEfmt ")
+	binary.Write(&body, binary.LittleEndian, []uint32{16})
+	binary.Write(&body, binary.LittleEndian, []uint16{1, 1})         // PCM, mono
+	binary.Write(&body, binary.LittleEndian, []uint32{24000, 48000}) // sample and byte rate
+	binary.Write(&body, binary.LittleEndian, []uint16{2, 16})        // block align, bits per sample
+	body.WriteString("data")
+	binary.Write(&body, binary.LittleEndian, uint32(len(samples)))
+	body.Write(samples)
+
+	w.Header().Set("Content-Type", "audio/wav")
+	w.Write(body.Bytes())
+}
+
+// handleImages serves /v1/images/generations (JSON) and /v1/images/edits
+// (multipart) with n single-pixel PNGs. dall-e models get a revised prompt,
+// gpt-image models report token usage.
+func (s *Server) handleImages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	var req struct {
+		Model          string `json:"model"`
+		Prompt         string `json:"prompt"`
+		N              int    `json:"n"`
+		ResponseFormat string `json:"response_format"`
+	}
+	if strings.HasSuffix(r.URL.Path, "/edits") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid multipart body: %v", err), "invalid_request_error", "")
+			return
+		}
+		if r.MultipartForm.File["image"] == nil && r.MultipartForm.File["image[]"] == nil {
+			writeError(w, http.StatusBadRequest, "image is required", "invalid_request_error", "")
+			return
+		}
+		req.Model = r.FormValue("model")
+		req.Prompt = r.FormValue("prompt")
+		req.N, _ = strconv.Atoi(r.FormValue("n"))
+		req.ResponseFormat = r.FormValue("response_format")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err), "invalid_request_error", "")
+		return
+	}
+
+	if req.Prompt == "" {
+		writeError(w, http.StatusBadRequest, "prompt is required", "invalid_request_error", "")
+		return
+	}
+	if req.N == 0 {
+		req.N = 1
+	}
+	dalle := strings.HasPrefix(req.Model, "dall-e")
+	if !dalle && req.ResponseFormat != "" {
+		writeError(w, http.StatusBadRequest, "Unknown parameter: 'response_format'.", "invalid_request_error", "unknown_parameter")
+		return
+	}
+
+	var pixel bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	png.Encode(&pixel, img)
+
+	data := make([]map[string]interface{}, req.N)
+	for i := range data {
+		item := map[string]interface{}{}
+		if dalle && req.ResponseFormat != "b64_json" {
+			item["url"] = fmt.Sprintf("http://%s/mock-image-%d.png", r.Host, i+1)
+		} else {
+			item["b64_json"] = base64.StdEncoding.EncodeToString(pixel.Bytes())
+		}
+		if dalle {
+			item["revised_prompt"] = "A detailed rendering of " + req.Prompt
+		}
+		data[i] = item
+	}
+
+	body := map[string]interface{}{
+		"created": time.Now().Unix(),
+		"data":    data,
+	}
+	if !dalle {
+		inputTokens := len(strings.Fields(req.Prompt))
+		body["output_format"] = "png"
+		body["usage"] = map[string]interface{}{
+			"input_tokens":         inputTokens,
+			"output_tokens":        272 * req.N,
+			"total_tokens":         inputTokens + 272*req.N,
+			"input_tokens_details": map[string]int{"text_tokens": inputTokens, "image_tokens": 0},
+		}
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleChatCompletions serves /v1/chat/completions in both unary and streaming form
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err), "invalid_request_error", "")
+		return
+	}
+
+	n := atomic.AddInt64(&s.requests, 1)
+	w.Header().Set("x-request-id", fmt.Sprintf("req_mock_%d", n))
+	prompt := lastUserMessage(req)
+	rule := s.script.next(req.Model, prompt)
+
+	log.Info().
+		Int64("request", n).
+		Str("model", req.Model).
+		Bool("stream", req.Stream).
+		Bool("scripted", rule != nil).
+		Msg("Mock chat completion")
+
+	content := s.script.Default
+	if rule != nil {
+		if rule.latency > 0 {
+			select {
+			case <-time.After(rule.latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if rule.Error != nil {
+			writeScriptedError(w, rule.Error)
+			return
+		}
+		if rule.Content != "" {
+			content = rule.Content
+		}
+	}
+
+	id := fmt.Sprintf("chatcmpl-mock-%d", n)
+	if req.Stream {
+		s.stream(w, r, id, content, req, rule)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       map[string]interface{}{"role": "assistant", "content": content},
+			"finish_reason": "stop",
+		}},
+		"usage": usage(prompt, content),
+	})
+}
+
+// usage builds an approximate usage object for a prompt and completion
+func usage(prompt, content string) map[string]interface{} {
+	promptTokens := countTokens(prompt)
+	completionTokens := countTokens(content)
+	return map[string]interface{}{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": completionTokens,
+		"total_tokens":      promptTokens + completionTokens,
+	}
+}
+
+// stream writes content as Server-Sent Events, optionally slowly or truncated
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, id, content string, req chatRequest, rule *Rule) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported", "server_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	var chunkDelay time.Duration
+	truncateAfter := 0
+	if rule != nil {
+		chunkDelay = rule.chunkDelay
+		truncateAfter = rule.TruncateAfter
+	}
+
+	model := req.Model
+	created := time.Now().Unix()
+	for i, piece := range splitChunks(content) {
+		if truncateAfter > 0 && i >= truncateAfter {
+			// Drop the connection mid-stream without a finish reason or [DONE]
+			log.Info().Int("chunks", i).Msg("Truncating mock stream")
+			panic(http.ErrAbortHandler)
+		}
+		if chunkDelay > 0 && i > 0 {
+			select {
+			case <-time.After(chunkDelay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		writeEvent(w, streamChunk(id, model, created, map[string]interface{}{"content": piece}, nil))
+		flusher.Flush()
+	}
+
+	finish := "stop"
+	writeEvent(w, streamChunk(id, model, created, map[string]interface{}{}, &finish))
+	if req.StreamOptions.IncludeUsage {
+		writeEvent(w, map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   model,
+			"choices": []interface{}{},
+			"usage":   usage(lastUserMessage(req), content),
+		})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// streamChunk builds a chat.completion.chunk payload
+func streamChunk(id, model string, created int64, delta map[string]interface{}, finishReason *string) map[string]interface{} {
+	choice := map[string]interface{}{
+		"index": 0,
+		"delta": delta,
+	}
+	if finishReason != nil {
+		choice["finish_reason"] = *finishReason
+	}
+	return map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   model,
+		"choices": []map[string]interface{}{choice},
+	}
+}
+
+// writeScriptedError writes an injected error response
+func writeScriptedError(w http.ResponseWriter, spec *ErrorSpec) {
+	if spec.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(spec.RetryAfter))
+	}
+
+	message := spec.Message
+	if message == "" {
+		message = http.StatusText(spec.Status)
+	}
+	errType := spec.Type
+	if errType == "" {
+		switch {
+		case spec.Status == http.StatusTooManyRequests:
+			errType = "rate_limit_error"
+		case spec.Status >= 500:
+			errType = "server_error"
+		default:
+			errType = "invalid_request_error"
+		}
+	}
+	writeError(w, spec.Status, message, errType, spec.Code)
+}
+
+// writeError writes an OpenAI-style error body
+func writeError(w http.ResponseWriter, status int, message, errType, code string) {
+	body := map[string]interface{}{
+		"message": message,
+		"type":    errType,
+	}
+	if code != "" {
+		body["code"] = code
+	}
+	writeJSON(w, status, map[string]interface{}{"error": body})
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeEvent writes a single SSE data event
+func writeEvent(w http.ResponseWriter, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
+
+// lastUserMessage extracts the text of the last user message
+func lastUserMessage(req chatRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		msg := req.Messages[i]
+		if msg.Role != "user" {
+			continue
+		}
+		var text string
+		if err := json.Unmarshal(msg.Content, &text); err == nil {
+			return text
+		}
+		// Content parts: concatenate the text parts
+		var parts []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(msg.Content, &parts); err == nil {
+			var sb strings.Builder
+			for _, part := range parts {
+				sb.WriteString(part.Text)
+			}
+			return sb.String()
+		}
+	}
+	return ""
+}
+
+// splitChunks splits content into word-sized stream chunks, keeping whitespace
+func splitChunks(content string) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, r := range content {
+		current.WriteRune(r)
+		if r == ' ' || r == '\n' {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// countTokens roughly approximates token usage from word count
+func countTokens(text string) int {
+	return len(strings.Fields(text))
+}
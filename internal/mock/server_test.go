@@ -0,0 +1,320 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScript = `
+models: [mock-model]
+default: "fallback answer"
+responses:
+  - match: "busy"
+    times: 1
+    error:
+      status: 429
+      retry_after: 2
+  - match: "broken"
+    error:
+      status: 500
+      message: "boom"
+  - match: "slow"
+    content: "one two three"
+    chunk_delay: 30ms
+  - match: "cut"
+    content: "one two three four"
+    truncate_after: 2
+  - match: "docker"
+    content: "docker ps -a"
+`
+
+func newTestServer(t *testing.T) *httptest.Server {
+	script, err := ParseScript([]byte(testScript))
+	require.NoError(t, err)
+	server := httptest.NewServer(NewServer(script).Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func postChat(t *testing.T, url, prompt string, stream bool) (*http.Response, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"model":    "mock-model",
+		"stream":   stream,
+		"messages": []map[string]string{{"role": "user", "content": prompt}},
+	})
+	return http.Post(url+"/v1/chat/completions", "application/json", strings.NewReader(string(body)))
+}
+
+func TestParseScript_Invalid(t *testing.T) {
+	_, err := ParseScript([]byte("responses:\n  - chunk_delay: soon\n"))
+	assert.Error(t, err)
+
+	_, err = ParseScript([]byte("responses:\n  - error: {status: 200}\n"))
+	assert.Error(t, err)
+}
+
+func TestServer_Models(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/v1/models")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "mock-model", list.Data[0].ID)
+}
+
+func TestServer_ChatCompletion(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := postChat(t, server.URL, "list docker containers", false)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&completion))
+	require.Len(t, completion.Choices, 1)
+	assert.Equal(t, "docker ps -a", completion.Choices[0].Message.Content)
+
+	// Unmatched prompts get the default content
+	resp, err = postChat(t, server.URL, "hello", false)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), "fallback answer")
+}
+
+func TestServer_ErrorInjection(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := postChat(t, server.URL, "busy", false)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+
+	// The 429 rule only applies once
+	resp, err = postChat(t, server.URL, "busy", false)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = postChat(t, server.URL, "broken", false)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, string(body), "boom")
+}
+
+func TestServer_Streaming(t *testing.T) {
+	server := newTestServer(t)
+
+	start := time.Now()
+	resp, err := postChat(t, server.URL, "slow", true)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), `"content":"three"`)
+	assert.Contains(t, string(body), `"finish_reason":"stop"`)
+	assert.True(t, strings.HasSuffix(string(body), "data: [DONE]\n\n"))
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond, "chunk_delay should slow the stream")
+}
+
+func TestServer_TruncatedStream(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := postChat(t, server.URL, "cut", true)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Error(t, err, "truncated stream should end with a transport error")
+	assert.Contains(t, string(body), `"content":"two "`)
+	assert.NotContains(t, string(body), "[DONE]")
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	s := NewServer(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, s.Shutdown(ctx))
+}
+
+func TestServer_Moderations(t *testing.T) {
+	script, err := ParseScript([]byte(`
+moderation:
+  - match: punch
+    scores: {violence: 0.9}
+`))
+	require.NoError(t, err)
+	server := httptest.NewServer(NewServer(script).Handler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/moderations", "application/json",
+		strings.NewReader(`{"model":"omni-moderation-latest","input":["how to PUNCH a wall","hello"]}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body struct {
+		Results []struct {
+			Flagged        bool               `json:"flagged"`
+			CategoryScores map[string]float64 `json:"category_scores"`
+		} `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Results, 2)
+	assert.True(t, body.Results[0].Flagged)
+	assert.Equal(t, 0.9, body.Results[0].CategoryScores["violence"])
+	assert.False(t, body.Results[1].Flagged)
+	assert.Zero(t, body.Results[1].CategoryScores["violence"])
+}
+
+func TestServer_Embeddings(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.Post(server.URL+"/v1/embeddings", "application/json",
+		strings.NewReader(`{"model":"text-embedding-3-small","input":["list docker containers","show docker containers","what is the weather"]}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Model string `json:"model"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Data, 3)
+	assert.Equal(t, "text-embedding-3-small", body.Model)
+
+	dot := func(a, b []float64) float64 {
+		var sum float64
+		for i := range a {
+			sum += a[i] * b[i]
+		}
+		return sum
+	}
+	list, show, weather := body.Data[0].Embedding, body.Data[1].Embedding, body.Data[2].Embedding
+	assert.InDelta(t, 1, dot(list, list), 1e-6, "embeddings are normalized")
+	assert.Greater(t, dot(list, show), 0.6)
+	assert.Less(t, dot(list, weather), 0.3)
+}
+
+func TestServer_Transcriptions(t *testing.T) {
+	server := newTestServer(t)
+
+	post := func(format string) *http.Response {
+		var body strings.Builder
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("file", "standup.m4a")
+		require.NoError(t, err)
+		part.Write([]byte("audio"))
+		writer.WriteField("model", "whisper-1")
+		writer.WriteField("response_format", format)
+		require.NoError(t, writer.Close())
+
+		resp, err := http.Post(server.URL+"/v1/audio/transcriptions", writer.FormDataContentType(), strings.NewReader(body.String()))
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp
+	}
+
+	text, _ := io.ReadAll(post("text").Body)
+	assert.Equal(t, "This is a mock transcript of standup.m4a.\n", string(text))
+
+	var verbose struct {
+		Text     string `json:"text"`
+		Segments []struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+		} `json:"segments"`
+	}
+	require.NoError(t, json.NewDecoder(post("verbose_json").Body).Decode(&verbose))
+	assert.Equal(t, "This is a mock transcript of standup.m4a.", verbose.Text)
+	require.Len(t, verbose.Segments, 1)
+	assert.InDelta(t, 2.8, verbose.Segments[0].End, 1e-9)
+}
+
+func TestServer_Speech(t *testing.T) {
+	server := newTestServer(t)
+
+	post := func(body string) *http.Response {
+		resp, err := http.Post(server.URL+"/v1/audio/speech", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := post(`{"model":"tts-1","voice":"alloy","input":"hello world"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	audio, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "[hello world]", string(audio))
+
+	resp = post(`{"model":"tts-1","voice":"alloy","input":"hello world","response_format":"wav"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	audio, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "RIFF", string(audio[:4]))
+	assert.Len(t, audio, 44+2*4800)
+
+	resp = post(`{"model":"tts-1","voice":"alloy","input":"` + strings.Repeat("a", 4097) + `"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServer_Images(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.Post(server.URL+"/v1/images/generations", "application/json",
+		strings.NewReader(`{"model":"gpt-image-1","prompt":"a red pixel","n":2}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []struct {
+			B64JSON string `json:"b64_json"`
+		} `json:"data"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Data, 2)
+	assert.NotEmpty(t, body.Data[0].B64JSON)
+	assert.Equal(t, 3+2*272, body.Usage.TotalTokens)
+
+	// gpt-image models reject response_format like the real endpoint
+	resp, err = http.Post(server.URL+"/v1/images/generations", "application/json",
+		strings.NewReader(`{"model":"gpt-image-1","prompt":"a red pixel","response_format":"url"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
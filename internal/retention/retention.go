@@ -0,0 +1,318 @@
+// Package retention enforces how long and how much persisted data is kept:
+// saved chat history, cached responses and log lines. Policies come from the
+// retention section of the config, and are enforced when the commands that
+// write this data start, at most once per StartupInterval, and by the purge
+// command.
+package retention
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/storage"
+)
+
+// Kinds of persisted data
+const (
+	History = "history"
+	Cache   = "cache"
+	Logs    = "logs"
+)
+
+// Classes lists every kind of persisted data, in the order they are purged
+var Classes = []string{History, Cache, Logs}
+
+// StartupInterval is how often startup enforces the policies
+const StartupInterval = time.Hour
+
+// CachePurger is a cache that can enforce a policy, such as *ai.OpenAIClient
+type CachePurger interface {
+	PurgeCache(maxAge time.Duration, maxBytes int64, dryRun bool) (ai.PurgeResult, error)
+}
+
+// Targets locates the persisted data. Empty targets are skipped.
+type Targets struct {
+	HistoryDir string
+	Cache      CachePurger
+	LogFile    string
+}
+
+// Result is what a purge of one kind of data removed, or would remove
+type Result struct {
+	Class   string
+	Items   int    // files, entries or lines
+	Unit    string // what Items counts
+	Bytes   int64
+	Skipped string // why the data was not purged, if it was not
+}
+
+// Purge enforces the policies in cfg on the named kinds of data, or on all
+// of them without names. With dryRun nothing is removed, and the results
+// report what would be.
+func Purge(cfg config.RetentionConfig, targets Targets, classes []string, dryRun bool, now time.Time) ([]Result, error) {
+	if len(classes) == 0 {
+		classes = Classes
+	}
+
+	var results []Result
+	for _, class := range classes {
+		var result Result
+		var err error
+		switch class {
+		case History:
+			result, err = PurgeDir(targets.HistoryDir, cfg.History, dryRun, now)
+		case Cache:
+			result, err = purgeCache(targets.Cache, cfg.Cache, dryRun)
+		case Logs:
+			result, err = TrimLog(targets.LogFile, cfg.Logs, dryRun, now)
+		default:
+			return results, fmt.Errorf("unknown kind of data: %s", class)
+		}
+		if err != nil {
+			return results, fmt.Errorf("failed to purge %s: %w", class, err)
+		}
+		result.Class = class
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// purgeCache enforces policy on the cache
+func purgeCache(cache CachePurger, policy config.RetentionPolicy, dryRun bool) (Result, error) {
+	result := Result{Unit: "entries"}
+	if cache == nil {
+		result.Skipped = "cache disabled"
+		return result, nil
+	}
+	if !enforced(policy) {
+		result.Skipped = "no limits"
+		return result, nil
+	}
+	purged, err := cache.PurgeCache(policy.MaxAge, maxBytes(policy), dryRun)
+	if err != nil {
+		return result, err
+	}
+	result.Items = purged.Entries
+	result.Bytes = purged.Bytes
+	return result, nil
+}
+
+// PurgeDir removes the files in dir modified more than policy.MaxAge ago,
+// and then the oldest files until the rest fit in policy.MaxSize
+func PurgeDir(dir string, policy config.RetentionPolicy, dryRun bool, now time.Time) (Result, error) {
+	result := Result{Unit: "files"}
+	if dir == "" {
+		result.Skipped = "no directory"
+		return result, nil
+	}
+	if !enforced(policy) {
+		result.Skipped = "no limits"
+		return result, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	var total int64
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // removed since the directory was read
+		}
+		files = append(files, file{filepath.Join(dir, entry.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	limit := maxBytes(policy)
+	for _, f := range files {
+		tooOld := policy.MaxAge > 0 && now.Sub(f.modTime) > policy.MaxAge
+		tooBig := limit > 0 && total > limit
+		if !tooOld && !tooBig {
+			break
+		}
+		if !dryRun {
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				return result, err
+			}
+		}
+		result.Items++
+		result.Bytes += f.size
+		total -= f.size
+	}
+	return result, nil
+}
+
+// TrimLog removes the lines of the log file at path logged more than
+// policy.MaxAge ago, and then the oldest lines until the rest fit in
+// policy.MaxSize. Lines are dated by their JSON time field; lines without
+// one go with the next line that has one. The file is rewritten in place, so
+// processes appending to it keep logging to it; lines they append while it
+// is trimmed are carried over, see dropHead.
+func TrimLog(path string, policy config.RetentionPolicy, dryRun bool, now time.Time) (Result, error) {
+	result := Result{Unit: "lines"}
+	if path == "" {
+		result.Skipped = "no log file"
+		return result, nil
+	}
+	if !enforced(policy) {
+		result.Skipped = "no limits"
+		return result, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return result, err
+	}
+
+	limit := maxBytes(policy)
+	cut := 0     // bytes removed from the start of the file
+	pending := 0 // undated lines waiting for the next dated one
+	pendingBytes := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		logged, ok := lineTime(line)
+		if !ok {
+			pending++
+			pendingBytes += len(line)
+			continue
+		}
+		tooOld := policy.MaxAge > 0 && now.Sub(logged) > policy.MaxAge
+		tooBig := limit > 0 && int64(len(data)-cut) > limit
+		if !tooOld && !tooBig {
+			break
+		}
+		cut += pendingBytes + len(line)
+		result.Items += pending + 1
+		pending, pendingBytes = 0, 0
+	}
+	result.Bytes = int64(cut)
+
+	if dryRun || cut == 0 {
+		return result, nil
+	}
+	if err := dropHead(file, data, cut); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// dropHead removes the first cut bytes of file, which held data when it was
+// read. Bytes appended since are read after the kept ones are written and
+// moved up behind them, until no more arrive, and only then is the file
+// truncated. Just a line appended between that last read and the truncate
+// is lost. The kept bytes always end before the unread ones start, so
+// writing them never overwrites what has not been read yet.
+func dropHead(file *os.File, data []byte, cut int) error {
+	kept := data[cut:]
+	read := int64(len(data)) // bytes of the file read so far
+	written := 0             // bytes of kept written so far
+	for {
+		if _, err := file.WriteAt(kept[written:], int64(written)); err != nil {
+			return err
+		}
+		written = len(kept)
+
+		appended, err := io.ReadAll(io.NewSectionReader(file, read, math.MaxInt64-read))
+		if err != nil {
+			return err
+		}
+		if len(appended) == 0 {
+			break
+		}
+		read += int64(len(appended))
+		kept = append(kept, appended...)
+	}
+	return file.Truncate(int64(len(kept)))
+}
+
+// scanLines splits data into lines, keeping their newlines so removed bytes
+// can be counted exactly
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// lineTime returns the time a JSON log line was logged at, opening lines
+// sealed by storage encryption first
+func lineTime(line []byte) (time.Time, bool) {
+	line, err := storage.Default().OpenLine(line)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var fields struct {
+		Time time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(line, &fields); err != nil || fields.Time.IsZero() {
+		return time.Time{}, false
+	}
+	return fields.Time, true
+}
+
+// enforced reports whether policy sets any limit
+func enforced(policy config.RetentionPolicy) bool {
+	return policy.MaxAge > 0 || policy.MaxSize > 0
+}
+
+// maxBytes returns the policy's size limit in bytes
+func maxBytes(policy config.RetentionPolicy) int64 {
+	return int64(policy.MaxSize) * 1024 * 1024
+}
+
+// Due reports whether startup enforcement should run: when the stamp file
+// is missing or older than StartupInterval
+func Due(stamp string, now time.Time) bool {
+	info, err := os.Stat(stamp)
+	return err != nil || now.Sub(info.ModTime()) >= StartupInterval
+}
+
+// MarkRun records that the policies were enforced, for Due
+func MarkRun(stamp string, now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(stamp), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(stamp, nil, 0600); err != nil {
+		return err
+	}
+	return os.Chtimes(stamp, now, now)
+}
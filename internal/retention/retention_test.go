@@ -0,0 +1,196 @@
+package retention
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/ai"
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/storage"
+)
+
+const day = 24 * time.Hour
+
+// writeAged writes a file last modified age before now
+func writeAged(t *testing.T, path string, size int, age time.Duration, now time.Time) {
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0600))
+	require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+}
+
+func TestPurgeDir(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	writeAged(t, filepath.Join(dir, "old.json"), 100, 40*day, now)
+	writeAged(t, filepath.Join(dir, "week.json"), 2<<20, 7*day, now)
+	writeAged(t, filepath.Join(dir, "last-chat.json"), 100, time.Hour, now)
+
+	result, err := PurgeDir(dir, config.RetentionPolicy{MaxAge: 30 * day}, true, now)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Items: 1, Unit: "files", Bytes: 100}, result)
+	assert.FileExists(t, filepath.Join(dir, "old.json"), "a dry run removes nothing")
+
+	result, err = PurgeDir(dir, config.RetentionPolicy{MaxAge: 30 * day, MaxSize: 1}, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Items)
+	assert.NoFileExists(t, filepath.Join(dir, "old.json"))
+	assert.NoFileExists(t, filepath.Join(dir, "week.json"))
+	assert.FileExists(t, filepath.Join(dir, "last-chat.json"))
+
+	result, err = PurgeDir(filepath.Join(dir, "missing"), config.RetentionPolicy{MaxAge: day}, false, now)
+	require.NoError(t, err)
+	assert.Zero(t, result.Items)
+
+	result, err = PurgeDir(dir, config.RetentionPolicy{}, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, "no limits", result.Skipped)
+}
+
+func logLine(at time.Time, message string) string {
+	return fmt.Sprintf(`{"level":"info","time":"%s","message":"%s"}`+"\n", at.Format(time.RFC3339), message)
+}
+
+func TestTrimLog(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "terminal-ai.log")
+	content := "torn line\n" +
+		logLine(now.Add(-40*day), "prompt: old secret") +
+		logLine(now.Add(-31*day), "prompt: older secret") +
+		logLine(now.Add(-2*day), "prompt: recent") +
+		"not json\n" +
+		logLine(now.Add(-time.Hour), "prompt: latest")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	result, err := TrimLog(path, config.RetentionPolicy{MaxAge: 30 * day}, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Items, "an undated line goes with the next dated one")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.True(t, strings.HasPrefix(string(data), logLine(now.Add(-2*day), "prompt: recent")))
+	assert.Equal(t, int64(len(content)-len(data)), result.Bytes)
+
+	// Only the oldest lines go to fit the size limit
+	big := strings.Repeat(logLine(now, strings.Repeat("x", 1000)), 1100)
+	require.NoError(t, os.WriteFile(path, []byte(big), 0600))
+	result, err = TrimLog(path, config.RetentionPolicy{MaxSize: 1}, false, now)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1<<20))
+	assert.Greater(t, info.Size(), int64(1<<20)-1100)
+	assert.Equal(t, int64(len(big))-info.Size(), result.Bytes)
+}
+
+func TestDropHead_KeepsAppendedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terminal-ai.log")
+	require.NoError(t, os.WriteFile(path, []byte("old\nkept\n"), 0600))
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	require.NoError(t, err)
+
+	// Another process logs after the file was read
+	logger, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = logger.WriteString("appended\n")
+	require.NoError(t, err)
+
+	require.NoError(t, dropHead(file, data, len("old\n")))
+	_, err = logger.WriteString("after\n")
+	require.NoError(t, err)
+	require.NoError(t, logger.Close())
+
+	trimmed, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "kept\nappended\nafter\n", string(trimmed))
+}
+
+func TestTrimLog_Encrypted(t *testing.T) {
+	store, err := storage.UnlockWith(config.StorageConfig{
+		Encrypt:   true,
+		KeySource: storage.SourceEnv,
+		KeyEnv:    "TERMINAL_AI_STORAGE_KEY",
+		KeyFile:   filepath.Join(t.TempDir(), "storage.key"),
+	}, storage.Secrets{Getenv: func(string) string { return "test secret" }})
+	require.NoError(t, err)
+	previous := storage.Default()
+	storage.SetDefault(store)
+	t.Cleanup(func() { storage.SetDefault(previous) })
+
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "terminal-ai.log")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	w := storage.LineWriter(file)
+	fmt.Fprint(w, logLine(now.Add(-40*day), "prompt: old secret"))
+	fmt.Fprint(w, logLine(now.Add(-time.Hour), "prompt: latest"))
+	require.NoError(t, file.Close())
+
+	// Sealed lines are dated by what they hold
+	result, err := TrimLog(path, config.RetentionPolicy{MaxAge: 30 * day}, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Items)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	opened, err := store.OpenLines(data)
+	require.NoError(t, err)
+	assert.Equal(t, logLine(now.Add(-time.Hour), "prompt: latest"), string(opened))
+}
+
+// fakeCache records the policy it was purged with
+type fakeCache struct {
+	maxAge   time.Duration
+	maxBytes int64
+	dryRun   bool
+}
+
+func (c *fakeCache) PurgeCache(maxAge time.Duration, maxBytes int64, dryRun bool) (ai.PurgeResult, error) {
+	c.maxAge, c.maxBytes, c.dryRun = maxAge, maxBytes, dryRun
+	return ai.PurgeResult{Entries: 3, Bytes: 300}, nil
+}
+
+func TestPurge(t *testing.T) {
+	now := time.Now()
+	cfg := config.RetentionConfig{
+		History: config.RetentionPolicy{MaxAge: 30 * day},
+		Cache:   config.RetentionPolicy{MaxAge: 30 * day, MaxSize: 50},
+		Logs:    config.RetentionPolicy{MaxAge: 30 * day},
+	}
+	cache := &fakeCache{}
+	targets := Targets{HistoryDir: t.TempDir(), Cache: cache}
+
+	results, err := Purge(cfg, targets, nil, true, now)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, Result{Class: Cache, Items: 3, Unit: "entries", Bytes: 300}, results[1])
+	assert.Equal(t, "no log file", results[2].Skipped)
+	assert.Equal(t, &fakeCache{maxAge: 30 * day, maxBytes: 50 << 20, dryRun: true}, cache)
+
+	results, err = Purge(cfg, Targets{}, []string{Cache}, false, now)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "cache disabled", results[0].Skipped)
+
+	_, err = Purge(cfg, targets, []string{"sessions"}, false, now)
+	assert.Error(t, err)
+}
+
+func TestDue(t *testing.T) {
+	now := time.Now()
+	stamp := filepath.Join(t.TempDir(), "state", "retention.stamp")
+	assert.True(t, Due(stamp, now))
+
+	require.NoError(t, MarkRun(stamp, now))
+	assert.False(t, Due(stamp, now.Add(StartupInterval/2)))
+	assert.True(t, Due(stamp, now.Add(StartupInterval)))
+}
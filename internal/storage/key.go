@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/user/terminal-ai/internal/config"
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// Sources of the secret that wraps the data key
+const (
+	SourceKeyring    = "keyring"
+	SourcePassphrase = "passphrase"
+	SourceEnv        = "env"
+)
+
+// PBKDF2 iterations for deriving the wrapping key. Keyring secrets are
+// random keys and need no stretching; passphrases and environment values
+// may be guessable.
+const (
+	keyringIterations    = 1
+	passphraseIterations = 600000
+)
+
+const keyFileVersion = 1
+
+// keyFile is the data key wrapped by a secret, as stored on disk
+type keyFile struct {
+	Version    int    `json:"version"`
+	Source     string `json:"source"` // where the wrapping secret comes from
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	WrappedKey []byte `json:"wrapped_key"` // the data key, sealed with the derived key
+	// Data keys replaced by a rotation that has not finished, sealed like
+	// WrappedKey, so files not yet resealed stay readable
+	RetiredKeys [][]byte `json:"retired_keys,omitempty"`
+}
+
+// Secrets supplies the secrets that wrap data keys
+type Secrets struct {
+	Keyring Keyring
+	// Prompt reads a passphrase without echoing it
+	Prompt func(prompt string) (string, error)
+	Getenv func(key string) string
+}
+
+// DefaultSecrets reads secrets from the OS keyring, the terminal and the
+// environment
+func DefaultSecrets() Secrets {
+	return Secrets{Keyring: SystemKeyring(), Prompt: promptPassphrase, Getenv: os.Getenv}
+}
+
+// Unlock returns the store cfg describes, unwrapping its data key, or
+// creating one on first use. Without encryption it returns a store that
+// does not encrypt.
+func Unlock(cfg config.StorageConfig) (*Store, error) {
+	return UnlockWith(cfg, DefaultSecrets())
+}
+
+// UnlockWith is Unlock with secrets read from secrets. An existing key file
+// is unwrapped with the secret of the source it records, so after
+// storage.key_source changes the old secret is used until a rekey.
+func UnlockWith(cfg config.StorageConfig, secrets Secrets) (*Store, error) {
+	if !cfg.Encrypt {
+		return &Store{}, nil
+	}
+
+	kf, err := readKeyFile(cfg.KeyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return createKey(cfg, secrets)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := currentSecret(kf.Source, cfg, secrets)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, retired, err := kf.unwrap(secret)
+	if err != nil {
+		return nil, err
+	}
+	store, err := newStore(dataKey, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.retire(retired); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// createKey creates a data key wrapped by a new secret from cfg.KeySource
+func createKey(cfg config.StorageConfig, secrets Secrets) (*Store, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to create storage key: %w", err)
+	}
+	store, err := newStore(dataKey, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// A keyring secret left from an earlier key file is reused, so no other
+	// secret is overwritten
+	if cfg.KeySource == SourceKeyring {
+		if secret, err := keyringSecret(secrets.Keyring); err == nil {
+			return store, store.saveKey(cfg.KeySource, secret)
+		}
+	}
+	if _, err := store.wrapNew(cfg, secrets, ""); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Rekey wraps the store's data key with a new secret from cfg.KeySource: a
+// new random key saved in the keyring, a new passphrase, or, for the env
+// source, newSecret. Files keep their data key, so none are rewritten.
+func (s *Store) Rekey(cfg config.StorageConfig, secrets Secrets, newSecret string) error {
+	if !s.Encrypted() {
+		return errEncryptionOff()
+	}
+	_, err := s.wrapNew(cfg, secrets, newSecret)
+	return err
+}
+
+// Rotate replaces the data key with a new random one, wrapped by a new
+// secret as Rekey does, and returns the store for it. reseal is called with
+// that store, which seals under the new key and still opens files sealed
+// under the old one, to rewrite the stored files. The key file keeps the old
+// key until reseal succeeds, so a rotation cut short leaves every file
+// readable and can be run again. Files reseal did not rewrite cannot be read
+// afterwards.
+func (s *Store) Rotate(cfg config.StorageConfig, secrets Secrets, newSecret string, reseal func(*Store) error) (*Store, error) {
+	if !s.Encrypted() {
+		return nil, errEncryptionOff()
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to create storage key: %w", err)
+	}
+	rotated, err := newStore(dataKey, s.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := rotated.retire(append([][]byte{s.dataKey}, s.retiredKeys...)); err != nil {
+		return nil, err
+	}
+	secret, err := rotated.wrapNew(cfg, secrets, newSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reseal(rotated); err != nil {
+		return nil, err
+	}
+	rotated.retired, rotated.retiredKeys = nil, nil
+	if err := rotated.saveKey(cfg.KeySource, secret); err != nil {
+		return nil, err
+	}
+	return rotated, nil
+}
+
+// wrapNew wraps the data key with a new secret from cfg.KeySource, saves it
+// and returns the secret. If the keyring cannot store a new secret, the
+// previous key file is put back, so the old secret keeps working.
+func (s *Store) wrapNew(cfg config.StorageConfig, secrets Secrets, secret string) (string, error) {
+	switch cfg.KeySource {
+	case SourceKeyring:
+		if secrets.Keyring == nil {
+			return "", errKeyringUnavailable(errors.New("no keyring"))
+		}
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return "", fmt.Errorf("failed to create storage secret: %w", err)
+		}
+		secret = base64.StdEncoding.EncodeToString(random)
+		// Keep the previous key file until the keyring holds the new secret
+		previous, _ := os.ReadFile(cfg.KeyFile)
+		if err := s.saveKey(cfg.KeySource, secret); err != nil {
+			return "", err
+		}
+		if err := secrets.Keyring.Set(secret); err != nil {
+			if previous != nil {
+				writePrivate(cfg.KeyFile, previous)
+			} else {
+				os.Remove(cfg.KeyFile)
+			}
+			return "", errKeyringUnavailable(err)
+		}
+		return secret, nil
+	case SourcePassphrase:
+		if secrets.Prompt == nil {
+			return "", errNoPassphrase()
+		}
+		passphrase, err := secrets.Prompt("New storage passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		confirm, err := secrets.Prompt("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if passphrase != confirm {
+			return "", utils.NewValidationError("passphrases do not match", "passphrase")
+		}
+		if passphrase == "" {
+			return "", utils.NewValidationError("passphrase is empty", "passphrase")
+		}
+		return passphrase, s.saveKey(cfg.KeySource, passphrase)
+	case SourceEnv:
+		if secret == "" && secrets.Getenv != nil {
+			secret = secrets.Getenv(cfg.KeyEnv)
+		}
+		if secret == "" {
+			return "", utils.NewValidationError(fmt.Sprintf("%s is not set", cfg.KeyEnv), "storage.key_env").
+				WithHint("Export the storage secret in " + cfg.KeyEnv)
+		}
+		return secret, s.saveKey(cfg.KeySource, secret)
+	default:
+		return "", utils.NewValidationError(fmt.Sprintf("invalid storage key source: %s", cfg.KeySource), "storage.key_source")
+	}
+}
+
+// saveKey writes the key file with the data key wrapped by secret
+func (s *Store) saveKey(source, secret string) error {
+	kf := keyFile{
+		Version:    keyFileVersion,
+		Source:     source,
+		KDF:        "pbkdf2-sha256",
+		Iterations: passphraseIterations,
+		Salt:       make([]byte, 16),
+	}
+	if source == SourceKeyring {
+		kf.Iterations = keyringIterations
+	}
+	if _, err := rand.Read(kf.Salt); err != nil {
+		return fmt.Errorf("failed to wrap storage key: %w", err)
+	}
+	wrapping, err := kf.wrappingKey(secret)
+	if err != nil {
+		return err
+	}
+	if kf.WrappedKey, err = seal(wrapping, s.dataKey); err != nil {
+		return err
+	}
+	for _, key := range s.retiredKeys {
+		wrapped, err := seal(wrapping, key)
+		if err != nil {
+			return err
+		}
+		kf.RetiredKeys = append(kf.RetiredKeys, wrapped)
+	}
+
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode storage key: %w", err)
+	}
+	if err := MkdirPrivate(filepath.Dir(s.keyFile)); err != nil {
+		return fmt.Errorf("failed to create storage key directory: %w", err)
+	}
+	if err := writePrivate(s.keyFile, data); err != nil {
+		return fmt.Errorf("failed to save storage key: %w", err)
+	}
+	return nil
+}
+
+// readKeyFile reads and checks a key file
+func readKeyFile(path string) (*keyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("invalid storage key file %s: %w", path, err)
+	}
+	if kf.Version != keyFileVersion || kf.KDF != "pbkdf2-sha256" || kf.Iterations < 1 {
+		return nil, fmt.Errorf("unsupported storage key file %s", path)
+	}
+	return &kf, nil
+}
+
+// wrappingKey derives the key that wraps the data key from secret
+func (kf *keyFile) wrappingKey(secret string) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, secret, kf.Salt, kf.Iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive storage key: %w", err)
+	}
+	return newAEAD(key)
+}
+
+// unwrap returns the data key and the retired ones, or an error if secret is
+// not the one that wrapped them
+func (kf *keyFile) unwrap(secret string) ([]byte, [][]byte, error) {
+	wrapping, err := kf.wrappingKey(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !IsEncrypted(kf.WrappedKey) {
+		return nil, nil, fmt.Errorf("invalid storage key file")
+	}
+	dataKey, err := open(wrapping, kf.WrappedKey)
+	if err != nil {
+		return nil, nil, utils.NewAuthError("wrong storage secret: the storage key could not be unlocked").
+			WithHint(fmt.Sprintf("Use the %s secret the key was last wrapped with", kf.Source))
+	}
+	var retired [][]byte
+	for _, wrapped := range kf.RetiredKeys {
+		if !IsEncrypted(wrapped) {
+			return nil, nil, fmt.Errorf("invalid storage key file")
+		}
+		key, err := open(wrapping, wrapped)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid storage key file: %w", err)
+		}
+		retired = append(retired, key)
+	}
+	return dataKey, retired, nil
+}
+
+// currentSecret reads the secret an existing key file was wrapped with
+func currentSecret(source string, cfg config.StorageConfig, secrets Secrets) (string, error) {
+	switch source {
+	case SourceKeyring:
+		secret, err := keyringSecret(secrets.Keyring)
+		if errors.Is(err, ErrNotFound) {
+			return "", utils.NewAuthError("storage secret not found in the keyring").
+				WithHint("Restore it, or remove " + cfg.KeyFile + " to start over (encrypted files are lost)")
+		}
+		return secret, err
+	case SourcePassphrase:
+		if secrets.Prompt == nil {
+			return "", errNoPassphrase()
+		}
+		return secrets.Prompt("Storage passphrase: ")
+	case SourceEnv:
+		secret := ""
+		if secrets.Getenv != nil {
+			secret = secrets.Getenv(cfg.KeyEnv)
+		}
+		if secret == "" {
+			return "", utils.NewValidationError(fmt.Sprintf("%s is not set", cfg.KeyEnv), "storage.key_env").
+				WithHint("Export the storage secret in " + cfg.KeyEnv)
+		}
+		return secret, nil
+	default:
+		return "", fmt.Errorf("storage key file has unknown source %q", source)
+	}
+}
+
+// keyringSecret reads the secret from keyring
+func keyringSecret(keyring Keyring) (string, error) {
+	if keyring == nil {
+		return "", errKeyringUnavailable(errors.New("no keyring"))
+	}
+	secret, err := keyring.Get()
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", errKeyringUnavailable(err)
+	}
+	return secret, err
+}
+
+func errKeyringUnavailable(err error) error {
+	return utils.NewAppError(utils.ErrCodeConfiguration, "OS keyring is unavailable", err).
+		WithHint("Set storage.key_source to passphrase or env")
+}
+
+func errEncryptionOff() error {
+	return utils.NewValidationError("storage encryption is off", "storage.encrypt").
+		WithHint("Set storage.encrypt: true to encrypt the cache, chat history and saved sessions")
+}
+
+func errNoPassphrase() error {
+	return utils.NewAppError(utils.ErrCodeConfiguration, "no terminal to read the storage passphrase from", nil).
+		WithHint("Set storage.key_source: env and export the passphrase in storage.key_env")
+}
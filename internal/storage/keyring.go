@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrNotFound is returned by a Keyring holding no secret
+var ErrNotFound = errors.New("secret not found in keyring")
+
+// Keyring holds the secret that wraps the data key
+type Keyring interface {
+	// Get returns the secret, or ErrNotFound
+	Get() (string, error)
+	Set(secret string) error
+}
+
+// Service and account the secret is saved under in the OS keyring
+const (
+	keyringService = "terminal-ai"
+	keyringAccount = "storage"
+)
+
+// SystemKeyring returns the OS keyring: the login keychain on macOS, or the
+// Secret Service through secret-tool on Linux
+func SystemKeyring() Keyring {
+	switch runtime.GOOS {
+	case "darwin":
+		return macKeyring{}
+	case "linux":
+		return secretServiceKeyring{}
+	default:
+		return unsupportedKeyring{}
+	}
+}
+
+// macKeyring stores the secret with the security command
+type macKeyring struct{}
+
+func (macKeyring) Get() (string, error) {
+	out, err := exec.Command("security", "find-generic-password",
+		"-s", keyringService, "-a", keyringAccount, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// 44 is errSecItemNotFound
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("security: %w", err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (macKeyring) Set(secret string) error {
+	if out, err := macSetCommand(secret).CombinedOutput(); err != nil {
+		return fmt.Errorf("security: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// macSetCommand returns the command saving secret in the keychain. -w is
+// given last without a value, so security asks for the secret, and it is
+// read from stdin, twice to confirm it, keeping it out of the process list.
+// -U updates an existing item instead of failing.
+func macSetCommand(secret string) *exec.Cmd {
+	cmd := exec.Command("security", "add-generic-password", "-U",
+		"-s", keyringService, "-a", keyringAccount, "-w")
+	cmd.Stdin = strings.NewReader(secret + "\n" + secret + "\n")
+	return cmd
+}
+
+// secretServiceKeyring stores the secret with secret-tool
+type secretServiceKeyring struct{}
+
+func (secretServiceKeyring) Get() (string, error) {
+	out, err := exec.Command("secret-tool", "lookup",
+		"service", keyringService, "account", keyringAccount).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// secret-tool exits 1 without output when nothing matches
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) == 0 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("secret-tool: %w", err)
+	}
+	if len(out) == 0 {
+		return "", ErrNotFound
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (secretServiceKeyring) Set(secret string) error {
+	if out, err := secretToolSetCommand(secret).CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// secretToolSetCommand returns the command saving secret with secret-tool,
+// which reads it from stdin, keeping it out of the process list
+func secretToolSetCommand(secret string) *exec.Cmd {
+	cmd := exec.Command("secret-tool", "store", "--label", "terminal-ai storage key",
+		"service", keyringService, "account", keyringAccount)
+	cmd.Stdin = strings.NewReader(secret)
+	return cmd
+}
+
+// unsupportedKeyring is used where no OS keyring is supported
+type unsupportedKeyring struct{}
+
+func (unsupportedKeyring) Get() (string, error) {
+	return "", fmt.Errorf("no supported keyring on %s", runtime.GOOS)
+}
+
+func (unsupportedKeyring) Set(string) error {
+	return fmt.Errorf("no supported keyring on %s", runtime.GOOS)
+}
+
+// promptPassphrase reads a passphrase from the terminal without echoing it
+func promptPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errNoPassphrase()
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
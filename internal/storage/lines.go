@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// sealedLinePrefix starts every line sealed by SealLine: the base64 of the
+// first bytes of magic, which no JSON line starts with
+var sealedLinePrefix = []byte(base64.StdEncoding.EncodeToString(magic[:6]))
+
+// SealLine encrypts one line of a file that is appended a line at a time,
+// such as a log, as base64, so the file keeps one record per line. Without
+// encryption the line is returned as is. line must not hold a newline.
+func (s *Store) SealLine(line []byte) ([]byte, error) {
+	if s.aead == nil {
+		return line, nil
+	}
+	sealed, err := s.Seal(line)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.AppendEncode(nil, sealed), nil
+}
+
+// OpenLine decrypts a line sealed by SealLine. Other lines are returned as
+// is, so files that were appended to before encryption was turned on read
+// as a whole.
+func (s *Store) OpenLine(line []byte) ([]byte, error) {
+	if !bytes.HasPrefix(line, sealedLinePrefix) {
+		return line, nil
+	}
+	sealed, err := base64.StdEncoding.AppendDecode(nil, line)
+	if err != nil || !IsEncrypted(sealed) {
+		return line, nil
+	}
+	return s.Open(sealed)
+}
+
+// lineWriter seals each line written to it with the default store
+type lineWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	pending []byte // the start of a line not yet ended
+}
+
+// LineWriter returns a writer that seals each line written to it with the
+// default store, as SealLine does, before writing it to w. The store is the
+// one set when the line ends, so a file opened before storage is unlocked
+// is encrypted from then on.
+func LineWriter(w io.Writer) io.Writer {
+	return &lineWriter{w: w}
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	data := append(lw.pending, p...)
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		lw.pending = data
+		return len(p), nil
+	}
+	lw.pending = append([]byte(nil), data[end+1:]...)
+
+	store := Default()
+	var out []byte
+	for _, line := range bytes.SplitAfter(data[:end+1], []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		sealed, err := store.SealLine(bytes.TrimSuffix(line, []byte("\n")))
+		if err != nil {
+			return 0, err
+		}
+		out = append(append(out, sealed...), '\n')
+	}
+	if _, err := lw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// OpenLines decrypts every sealed line of a file written a line at a time
+func (s *Store) OpenLines(data []byte) ([]byte, error) {
+	return mapLines(data, s.OpenLine)
+}
+
+// ResealLines rewrites the sealed lines of the file at path, sealed under
+// this store's key or a retired one, sealing them under this store's key.
+// It reports whether it rewrote the file. A missing file has no lines.
+func (s *Store) ResealLines(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	resealed := false
+	out, err := mapLines(data, func(line []byte) ([]byte, error) {
+		if !bytes.HasPrefix(line, sealedLinePrefix) {
+			return line, nil
+		}
+		plain, err := s.OpenLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		resealed = true
+		return s.SealLine(plain)
+	})
+	if err != nil || !resealed {
+		return false, err
+	}
+	return true, writePrivate(path, out)
+}
+
+// mapLines applies fn to each line of data, without its newline
+func mapLines(data []byte, fn func([]byte) ([]byte, error)) ([]byte, error) {
+	var out []byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		text, ended := bytes.CutSuffix(line, []byte("\n"))
+		mapped, err := fn(text)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, mapped...)
+		if ended {
+			out = append(out, '\n')
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineWriter(t *testing.T) {
+	store := mustStore(t)
+	previous := Default()
+	SetDefault(store)
+	defer SetDefault(previous)
+
+	var buf bytes.Buffer
+	w := LineWriter(&buf)
+	// A line written in pieces is sealed once it ends
+	fmt.Fprint(w, `{"time":"2026-10-18T09:00:00Z","message":"prompt: `)
+	assert.Zero(t, buf.Len())
+	fmt.Fprint(w, "secret plans\"}\n{\"message\":\"second\"}\n")
+
+	assert.NotContains(t, buf.String(), "secret plans")
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")), "one sealed record per line")
+
+	opened, err := store.OpenLines(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, `{"time":"2026-10-18T09:00:00Z","message":"prompt: secret plans"}`+"\n"+`{"message":"second"}`+"\n", string(opened))
+
+	// Without encryption lines are written as they are
+	SetDefault(&Store{})
+	buf.Reset()
+	fmt.Fprint(w, "plain line\n")
+	assert.Equal(t, "plain line\n", buf.String())
+}
+
+func TestStore_OpenLine(t *testing.T) {
+	store := mustStore(t)
+	sealed, err := store.SealLine([]byte(`{"message":"hi"}`))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "hi")
+
+	opened, err := store.OpenLine(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"message":"hi"}`, string(opened))
+
+	// Lines logged before encryption was turned on read as they are
+	opened, err = store.OpenLine([]byte(`{"message":"older"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"message":"older"}`, string(opened))
+
+	other, err := newStore(append(make([]byte, 31), 1), "")
+	require.NoError(t, err)
+	_, err = other.OpenLine(sealed)
+	assert.Error(t, err)
+}
+
+func TestStore_ResealLines(t *testing.T) {
+	store := mustStore(t)
+	sealed, err := store.SealLine([]byte("logged line"))
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "terminal-ai.log")
+	require.NoError(t, os.WriteFile(path, append(append([]byte("plain line\n"), sealed...), '\n'), 0644))
+
+	rotated, err := newStore(append(make([]byte, 31), 1), "")
+	require.NoError(t, err)
+	require.NoError(t, rotated.retire([][]byte{store.dataKey}))
+
+	resealed, err := rotated.ResealLines(path)
+	require.NoError(t, err)
+	assert.True(t, resealed)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	_, err = store.OpenLines(data)
+	assert.Error(t, err, "the lines are sealed under the new key")
+	rotated.retired = nil
+	opened, err := rotated.OpenLines(data)
+	require.NoError(t, err)
+	assert.Equal(t, "plain line\nlogged line\n", string(opened))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	resealed, err = rotated.ResealLines(filepath.Join(t.TempDir(), "missing.log"))
+	require.NoError(t, err)
+	assert.False(t, resealed)
+}
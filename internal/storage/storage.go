@@ -0,0 +1,250 @@
+// Package storage encrypts the files terminal-ai persists: the response
+// cache, chat history and saved sessions.
+//
+// Encryption is envelope-style. Files are sealed with AES-256-GCM under a
+// random data key, which is kept in a key file wrapped by a secret from the
+// OS keyring, a passphrase or an environment variable. Rekeying wraps the
+// same data key under a new secret, so files already written, wherever they
+// are, stay readable. Rotating replaces the data key itself, and the files
+// that are not resealed under the new one can no longer be read.
+//
+// A store without encryption reads and writes files as they are, so every
+// caller goes through a Store whether or not encryption is enabled. Both
+// kinds of store read unencrypted files, so turning encryption on migrates
+// each file as it is next written.
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+// magic starts every encrypted blob, identifying it and its format
+var magic = []byte("TAIENC1\x00")
+
+// Store reads and writes files, encrypting them when it holds a data key.
+// The zero value is a store without encryption.
+type Store struct {
+	aead    cipher.AEAD // nil without encryption
+	dataKey []byte
+	keyFile string // where the wrapped data key is kept
+
+	// Data keys replaced by a rotation that has not finished. Files sealed
+	// under them still open; nothing is sealed under them.
+	retired     []cipher.AEAD
+	retiredKeys [][]byte
+}
+
+var defaultStore atomic.Pointer[Store]
+
+// Default returns the store set with SetDefault, or a store without
+// encryption
+func Default() *Store {
+	if store := defaultStore.Load(); store != nil {
+		return store
+	}
+	return &Store{}
+}
+
+// SetDefault sets the store returned by Default
+func SetDefault(store *Store) {
+	defaultStore.Store(store)
+}
+
+// newStore returns a store encrypting with dataKey
+func newStore(dataKey []byte, keyFile string) (*Store, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{aead: aead, dataKey: dataKey, keyFile: keyFile}, nil
+}
+
+// retire adds keys the store opens files with but no longer seals under
+func (s *Store) retire(keys [][]byte) error {
+	for _, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return err
+		}
+		s.retired = append(s.retired, aead)
+		s.retiredKeys = append(s.retiredKeys, key)
+	}
+	return nil
+}
+
+// newAEAD returns AES-GCM with key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypted reports whether the store encrypts what it writes
+func (s *Store) Encrypted() bool {
+	return s.aead != nil
+}
+
+// IsEncrypted reports whether data was sealed by a store
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts data, or returns it as is without encryption
+func (s *Store) Seal(data []byte) ([]byte, error) {
+	if s.aead == nil {
+		return data, nil
+	}
+	return seal(s.aead, data)
+}
+
+// Open decrypts data sealed by Seal. Unencrypted data is returned as is.
+func (s *Store) Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if s.aead == nil {
+		return nil, utils.NewAppError(utils.ErrCodeConfiguration, "file is encrypted but storage encryption is off", nil).
+			WithHint("Set storage.encrypt: true with the key source it was written with")
+	}
+	plain, err := open(s.aead, data)
+	for _, aead := range s.retired {
+		if err == nil {
+			break
+		}
+		plain, err = open(aead, data)
+	}
+	return plain, err
+}
+
+// seal encrypts data with aead as magic, nonce, ciphertext
+func seal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	out := make([]byte, 0, len(magic)+len(nonce)+len(data)+aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, magic), nil
+}
+
+// open decrypts data sealed by seal
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	data = data[len(magic):]
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, magic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: data is corrupt or was written with another key")
+	}
+	return plain, nil
+}
+
+// WriteFile seals data and writes it to path with 0600 permissions. The
+// file is replaced atomically, so an existing file with wider permissions
+// is tightened too.
+func (s *Store) WriteFile(path string, data []byte) error {
+	sealed, err := s.Seal(data)
+	if err != nil {
+		return err
+	}
+	return writePrivate(path, sealed)
+}
+
+// ReadFile reads path and opens its contents
+func (s *Store) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = s.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// ResealDir rewrites the encrypted files in dir, sealed under this store's
+// key or a retired one, sealing them under this store's key. It returns how
+// many files it rewrote. A missing dir has none.
+func (s *Store) ResealDir(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	resealed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return resealed, err
+		}
+		if !IsEncrypted(raw) {
+			continue
+		}
+		data, err := s.Open(raw)
+		if err != nil {
+			return resealed, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := s.WriteFile(path, data); err != nil {
+			return resealed, err
+		}
+		resealed++
+	}
+	return resealed, nil
+}
+
+// MkdirPrivate creates dir and its parents with 0700 permissions, and
+// tightens dir itself if it already exists
+func MkdirPrivate(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.Chmod(dir, 0700)
+}
+
+// writePrivate writes data to path through a temporary file, created with
+// 0600 permissions and renamed into place, so readers never see a partial
+// file
+func writePrivate(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/user/terminal-ai/internal/config"
+)
+
+// memoryKeyring is a keyring held in memory
+type memoryKeyring struct {
+	secret string
+	err    error // returned by Set
+}
+
+func (k *memoryKeyring) Get() (string, error) {
+	if k.secret == "" {
+		return "", ErrNotFound
+	}
+	return k.secret, nil
+}
+
+func (k *memoryKeyring) Set(secret string) error {
+	if k.err != nil {
+		return k.err
+	}
+	k.secret = secret
+	return nil
+}
+
+func envSecrets(env map[string]string) Secrets {
+	return Secrets{Getenv: func(key string) string { return env[key] }}
+}
+
+func storageConfig(t *testing.T, source string) config.StorageConfig {
+	return config.StorageConfig{
+		Encrypt:   true,
+		KeySource: source,
+		KeyEnv:    "TERMINAL_AI_STORAGE_KEY",
+		KeyFile:   filepath.Join(t.TempDir(), "keys", "storage.key"),
+	}
+}
+
+func TestStore_SealOpen(t *testing.T) {
+	store, err := newStore(make([]byte, 32), "")
+	require.NoError(t, err)
+
+	sealed, err := store.Seal([]byte("api key: sk-secret"))
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(sealed))
+	assert.NotContains(t, string(sealed), "sk-secret")
+
+	opened, err := store.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "api key: sk-secret", string(opened))
+
+	// Unencrypted data is read as is, so existing files stay readable
+	opened, err = store.Open([]byte(`{"messages":[]}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"messages":[]}`, string(opened))
+
+	other, err := newStore(append(make([]byte, 31), 1), "")
+	require.NoError(t, err)
+	_, err = other.Open(sealed)
+	assert.Error(t, err)
+
+	_, err = (&Store{}).Open(sealed)
+	assert.ErrorContains(t, err, "encryption is off")
+}
+
+func TestStore_WriteFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, MkdirPrivate(dir))
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	path := filepath.Join(dir, "last-chat.json")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+
+	for _, store := range []*Store{{}, mustStore(t)} {
+		require.NoError(t, store.WriteFile(path, []byte("secret")))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, store.Encrypted(), IsEncrypted(raw))
+
+		data, err := store.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", string(data))
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files are removed")
+}
+
+func mustStore(t *testing.T) *Store {
+	store, err := newStore(make([]byte, 32), "")
+	require.NoError(t, err)
+	return store
+}
+
+func TestUnlock(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		store, err := UnlockWith(config.StorageConfig{}, Secrets{})
+		require.NoError(t, err)
+		assert.False(t, store.Encrypted())
+	})
+
+	t.Run("Env", func(t *testing.T) {
+		cfg := storageConfig(t, SourceEnv)
+		secrets := envSecrets(map[string]string{"TERMINAL_AI_STORAGE_KEY": "correct horse"})
+
+		store, err := UnlockWith(cfg, secrets)
+		require.NoError(t, err)
+		require.True(t, store.Encrypted())
+		sealed, err := store.Seal([]byte("history"))
+		require.NoError(t, err)
+
+		info, err := os.Stat(cfg.KeyFile)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		// The same secret unwraps the same data key
+		reopened, err := UnlockWith(cfg, secrets)
+		require.NoError(t, err)
+		opened, err := reopened.Open(sealed)
+		require.NoError(t, err)
+		assert.Equal(t, "history", string(opened))
+
+		_, err = UnlockWith(cfg, envSecrets(map[string]string{"TERMINAL_AI_STORAGE_KEY": "wrong"}))
+		assert.ErrorContains(t, err, "wrong storage secret")
+
+		_, err = UnlockWith(cfg, envSecrets(nil))
+		assert.ErrorContains(t, err, "is not set")
+	})
+
+	t.Run("Keyring", func(t *testing.T) {
+		cfg := storageConfig(t, SourceKeyring)
+		keyring := &memoryKeyring{}
+
+		store, err := UnlockWith(cfg, Secrets{Keyring: keyring})
+		require.NoError(t, err)
+		assert.NotEmpty(t, keyring.secret, "a new secret is saved in the keyring")
+
+		reopened, err := UnlockWith(cfg, Secrets{Keyring: keyring})
+		require.NoError(t, err)
+		assert.Equal(t, store.dataKey, reopened.dataKey)
+
+		_, err = UnlockWith(cfg, Secrets{Keyring: &memoryKeyring{}})
+		assert.ErrorContains(t, err, "not found in the keyring")
+	})
+
+	t.Run("Passphrase", func(t *testing.T) {
+		cfg := storageConfig(t, SourcePassphrase)
+		answers := []string{"hunter2", "hunter3"}
+		prompt := func(string) (string, error) {
+			answer := answers[0]
+			answers = answers[1:]
+			return answer, nil
+		}
+
+		_, err := UnlockWith(cfg, Secrets{Prompt: prompt})
+		assert.ErrorContains(t, err, "do not match")
+		assert.NoFileExists(t, cfg.KeyFile)
+
+		_, err = UnlockWith(cfg, Secrets{})
+		assert.ErrorContains(t, err, "no terminal")
+	})
+}
+
+func TestStore_Rekey(t *testing.T) {
+	cfg := storageConfig(t, SourceEnv)
+	store, err := UnlockWith(cfg, envSecrets(map[string]string{"TERMINAL_AI_STORAGE_KEY": "old secret"}))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	require.NoError(t, store.WriteFile(path, []byte("saved session")))
+
+	require.NoError(t, store.Rekey(cfg, envSecrets(nil), "new secret"))
+
+	_, err = UnlockWith(cfg, envSecrets(map[string]string{"TERMINAL_AI_STORAGE_KEY": "old secret"}))
+	assert.ErrorContains(t, err, "wrong storage secret")
+
+	reopened, err := UnlockWith(cfg, envSecrets(map[string]string{"TERMINAL_AI_STORAGE_KEY": "new secret"}))
+	require.NoError(t, err)
+	data, err := reopened.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "saved session", string(data))
+
+	t.Run("SwitchSource", func(t *testing.T) {
+		keyring := &memoryKeyring{}
+		cfg := cfg
+		cfg.KeySource = SourceKeyring
+		require.NoError(t, reopened.Rekey(cfg, Secrets{Keyring: keyring}, ""))
+
+		// The key file records its source, so the keyring unlocks it now
+		switched, err := UnlockWith(cfg, Secrets{Keyring: keyring})
+		require.NoError(t, err)
+		data, err := switched.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "saved session", string(data))
+	})
+
+	t.Run("KeyringFailure", func(t *testing.T) {
+		keyring := &memoryKeyring{secret: "kept"}
+		before, err := os.ReadFile(cfg.KeyFile)
+		require.NoError(t, err)
+
+		keyring.err = os.ErrPermission
+		cfg := cfg
+		cfg.KeySource = SourceKeyring
+		assert.Error(t, reopened.Rekey(cfg, Secrets{Keyring: keyring}, ""))
+
+		after, err := os.ReadFile(cfg.KeyFile)
+		require.NoError(t, err)
+		assert.Equal(t, before, after, "the previous key file is restored")
+	})
+
+	assert.Error(t, (&Store{}).Rekey(cfg, envSecrets(nil), "new secret"))
+}
+
+func TestStore_Rotate(t *testing.T) {
+	cfg := storageConfig(t, SourceEnv)
+	store, err := UnlockWith(cfg, envSecrets(map[string]string{"TERMINAL_AI_STORAGE_KEY": "old secret"}))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	history := filepath.Join(dir, "last-chat.json")
+	require.NoError(t, store.WriteFile(history, []byte("chat history")))
+	plain := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(plain, []byte("not encrypted"), 0600))
+	elsewhere := filepath.Join(t.TempDir(), "session.json")
+	require.NoError(t, store.WriteFile(elsewhere, []byte("saved session")))
+	before, err := os.ReadFile(history)
+	require.NoError(t, err)
+
+	t.Run("Interrupted", func(t *testing.T) {
+		_, err := store.Rotate(cfg, envSecrets(nil), "interrupted secret", func(*Store) error {
+			return os.ErrClosed
+		})
+		require.ErrorIs(t, err, os.ErrClosed)
+
+		// The key file kept the old key, so every file still opens
+		resumed, err := UnlockWith(cfg, envSecrets(map[string]string{"TERMINAL_AI_STORAGE_KEY": "interrupted secret"}))
+		require.NoError(t, err)
+		data, err := resumed.ReadFile(elsewhere)
+		require.NoError(t, err)
+		assert.Equal(t, "saved session", string(data))
+		store = resumed
+	})
+
+	resealed := 0
+	rotated, err := store.Rotate(cfg, envSecrets(nil), "new secret", func(s *Store) error {
+		var err error
+		resealed, err = s.ResealDir(dir)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resealed, "only encrypted files are resealed")
+
+	after, err := os.ReadFile(history)
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after)
+	data, err := os.ReadFile(plain)
+	require.NoError(t, err)
+	assert.Equal(t, "not encrypted", string(data))
+
+	reopened, err := UnlockWith(cfg, envSecrets(map[string]string{"TERMINAL_AI_STORAGE_KEY": "new secret"}))
+	require.NoError(t, err)
+	for _, s := range []*Store{rotated, reopened} {
+		data, err := s.ReadFile(history)
+		require.NoError(t, err)
+		assert.Equal(t, "chat history", string(data))
+
+		// The old key is gone, so files that were not resealed are too
+		_, err = s.ReadFile(elsewhere)
+		assert.ErrorContains(t, err, "another key")
+	}
+
+	_, err = (&Store{}).Rotate(cfg, envSecrets(nil), "new secret", func(*Store) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestKeyringSetCommands(t *testing.T) {
+	const secret = "c2VjcmV0LXN0b3JhZ2Uta2V5"
+	for name, cmd := range map[string]*exec.Cmd{
+		"security":    macSetCommand(secret),
+		"secret-tool": secretToolSetCommand(secret),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Arguments are visible to every local user in the process list
+			for _, arg := range cmd.Args {
+				assert.NotContains(t, arg, secret)
+			}
+			stdin, err := io.ReadAll(cmd.Stdin)
+			require.NoError(t, err)
+			assert.Contains(t, string(stdin), secret)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileExporter appends one OTLP/JSON ExportTraceServiceRequest per line to a file
+type FileExporter struct {
+	mu          sync.Mutex
+	file        *os.File
+	w           io.Writer // file, possibly wrapped
+	serviceName string
+	version     string
+}
+
+// NewFileExporter opens path for appending, with permissions only the user
+// has, as spans can carry prompt details. Lines are written through wrap,
+// if set, to encrypt them for example.
+func NewFileExporter(path, serviceName, version string, wrap func(io.Writer) io.Writer) (*FileExporter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create trace directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	file.Chmod(0600)
+	e := &FileExporter{file: file, w: file, serviceName: serviceName, version: version}
+	if wrap != nil {
+		e.w = wrap(file)
+	}
+	return e, nil
+}
+
+// Export writes spans as a single JSON line
+func (e *FileExporter) Export(ctx context.Context, spans []*Span) error {
+	data, err := json.Marshal(encodeRequest(spans, e.serviceName, e.version))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the file
+func (e *FileExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
+
+// HTTPExporter posts OTLP/JSON to a collector's /v1/traces endpoint
+type HTTPExporter struct {
+	endpoint    string
+	client      *http.Client
+	serviceName string
+	version     string
+}
+
+// NewHTTPExporter creates an exporter for an OTLP/HTTP collector.
+// An endpoint without a path gets the standard /v1/traces path.
+func NewHTTPExporter(endpoint, serviceName, version string) *HTTPExporter {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	return &HTTPExporter{
+		endpoint:    endpoint,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		serviceName: serviceName,
+		version:     version,
+	}
+}
+
+// Export posts spans to the collector
+func (e *HTTPExporter) Export(ctx context.Context, spans []*Span) error {
+	data, err := json.Marshal(encodeRequest(spans, e.serviceName, e.version))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector rejected spans: %s", resp.Status)
+	}
+	return nil
+}
+
+// Close releases idle connections
+func (e *HTTPExporter) Close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// OTLP/JSON wire types (opentelemetry-proto ExportTraceServiceRequest)
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// encodeRequest converts spans into an OTLP export request
+func encodeRequest(spans []*Span, serviceName, version string) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		encoded = append(encoded, encodeSpan(span))
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: encodeAttributes(map[string]interface{}{
+					"service.name":    serviceName,
+					"service.version": version,
+				}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: serviceName, Version: version},
+				Spans: encoded,
+			}},
+		}},
+	}
+}
+
+// encodeSpan converts a finished span
+func encodeSpan(span *Span) otlpSpan {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	out := otlpSpan{
+		TraceID:           hex.EncodeToString(span.traceID[:]),
+		SpanID:            hex.EncodeToString(span.spanID[:]),
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        encodeAttributes(span.attributes),
+		Status:            otlpStatus{Code: span.statusCode, Message: span.statusMessage},
+	}
+	if span.parentID != [8]byte{} {
+		out.ParentSpanID = hex.EncodeToString(span.parentID[:])
+	}
+	for _, event := range span.events {
+		out.Events = append(out.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+			Name:         event.Name,
+			Attributes:   encodeAttributes(event.Attributes),
+		})
+	}
+	return out
+}
+
+// encodeAttributes converts attributes in key order
+func encodeAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make([]otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, otlpKeyValue{Key: key, Value: encodeValue(attributes[key])})
+	}
+	return out
+}
+
+// encodeValue converts an attribute value to its OTLP representation
+func encodeValue(value interface{}) otlpValue {
+	switch v := value.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		s := strconv.Itoa(v)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &s}
+	case float32:
+		f := float64(v)
+		return otlpValue{DoubleValue: &f}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	case time.Duration:
+		s := strconv.FormatInt(v.Milliseconds(), 10)
+		return otlpValue{IntValue: &s}
+	default:
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	}
+}
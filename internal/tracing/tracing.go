@@ -0,0 +1,342 @@
+// Package tracing records spans for CLI commands and AI calls and exports
+// them in the OTLP/JSON format, either to a file or to a collector endpoint.
+//
+// Tracing is disabled until Setup is called with an exporter. While disabled,
+// Start returns a nil *Span and every Span method is a no-op, so call sites
+// never need to check whether tracing is on.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SpanKind describes the relationship between a span and its caller
+type SpanKind int
+
+// Span kinds, matching the OTLP enumeration
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// Status codes, matching the OTLP enumeration
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+// Exporter sends finished spans to a backend
+type Exporter interface {
+	// Export sends a batch of finished spans
+	Export(ctx context.Context, spans []*Span) error
+	// Close releases exporter resources
+	Close() error
+}
+
+// Span is a timed operation within a trace
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	kind     SpanKind
+
+	mu            sync.Mutex
+	name          string
+	start         time.Time
+	end           time.Time
+	attributes    map[string]interface{}
+	events        []Event
+	statusCode    int
+	statusMessage string
+	ended         bool
+}
+
+// Event is a timestamped annotation on a span
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]interface{}
+}
+
+// Tracer creates spans and batches them for export
+type Tracer struct {
+	exporter    Exporter
+	serviceName string
+	version     string
+
+	mu      sync.Mutex
+	pending []*Span
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Config configures the global tracer
+type Config struct {
+	ServiceName   string
+	Version       string
+	Exporter      Exporter
+	FlushInterval time.Duration // periodic export for long-running processes (0 = only on Shutdown)
+}
+
+// maxPendingSpans triggers an export when this many spans are waiting
+const maxPendingSpans = 512
+
+type spanKey struct{}
+
+var (
+	globalMu     sync.RWMutex
+	globalTracer *Tracer
+)
+
+// Setup installs the global tracer. A nil exporter disables tracing.
+func Setup(cfg Config) *Tracer {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	if cfg.Exporter == nil {
+		globalTracer = nil
+		return nil
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "terminal-ai"
+	}
+
+	t := &Tracer{
+		exporter:    cfg.Exporter,
+		serviceName: cfg.ServiceName,
+		version:     cfg.Version,
+		stop:        make(chan struct{}),
+	}
+	if cfg.FlushInterval > 0 {
+		t.wg.Add(1)
+		go t.flushRoutine(cfg.FlushInterval)
+	}
+
+	globalTracer = t
+	return t
+}
+
+// Enabled reports whether a tracer is installed
+func Enabled() bool {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return globalTracer != nil
+}
+
+// Shutdown exports pending spans and closes the global tracer
+func Shutdown(ctx context.Context) error {
+	globalMu.Lock()
+	t := globalTracer
+	globalTracer = nil
+	globalMu.Unlock()
+
+	if t == nil {
+		return nil
+	}
+	return t.Shutdown(ctx)
+}
+
+// Start begins a span as a child of the span in ctx, if any.
+// It returns ctx unchanged and a nil span when tracing is disabled.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return StartWithKind(ctx, name, KindInternal)
+}
+
+// StartWithKind begins a span of the given kind
+func StartWithKind(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	globalMu.RLock()
+	t := globalTracer
+	globalMu.RUnlock()
+
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext returns the current span, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// TraceID returns the hex trace ID of the current span, or "" when there is none
+func TraceID(ctx context.Context) string {
+	return SpanFromContext(ctx).TraceID()
+}
+
+// TraceParent returns a W3C traceparent header value for the current span, or ""
+func TraceParent(ctx context.Context) string {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", span.TraceID(), span.SpanID())
+}
+
+// TraceID returns the hex trace ID
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// SpanID returns the hex span ID
+func (s *Span) SpanID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.spanID[:])
+}
+
+// SetName renames the span
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttribute sets a string, bool, integer or float attribute
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// AddEvent records a timestamped event on the span
+func (s *Span) AddEvent(name string, attributes map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, Event{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+// RecordError marks the span as failed. A nil error is ignored; cancellation
+// is recorded as an event rather than an error.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		s.AddEvent("cancelled", nil)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusCode = StatusError
+	s.statusMessage = err.Error()
+	s.events = append(s.events, Event{
+		Name:       "exception",
+		Time:       time.Now(),
+		Attributes: map[string]interface{}{"exception.message": err.Error()},
+	})
+}
+
+// End finishes the span and queues it for export. Calling End twice has no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.enqueue(s)
+}
+
+// enqueue adds a finished span to the pending batch
+func (t *Tracer) enqueue(span *Span) {
+	t.mu.Lock()
+	t.pending = append(t.pending, span)
+	full := len(t.pending) >= maxPendingSpans
+	t.mu.Unlock()
+
+	if full {
+		go t.Flush(context.Background())
+	}
+}
+
+// Flush exports all pending spans
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	return t.exporter.Export(ctx, spans)
+}
+
+// Shutdown stops periodic export, flushes pending spans and closes the exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	close(t.stop)
+	t.wg.Wait()
+
+	err := t.Flush(ctx)
+	if closeErr := t.exporter.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// flushRoutine periodically exports pending spans
+func (t *Tracer) flushRoutine(interval time.Duration) {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.Flush(context.Background())
+		case <-t.stop:
+			return
+		}
+	}
+}
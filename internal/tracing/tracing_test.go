@@ -0,0 +1,194 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryExporter keeps exported spans for inspection
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (m *memoryExporter) Export(ctx context.Context, spans []*Span) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spans = append(m.spans, spans...)
+	return nil
+}
+
+func (m *memoryExporter) Close() error { return nil }
+
+func (m *memoryExporter) byName(name string) *Span {
+	for _, span := range m.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func setupMemory(t *testing.T) *memoryExporter {
+	exporter := &memoryExporter{}
+	Setup(Config{Exporter: exporter})
+	t.Cleanup(func() { Shutdown(context.Background()) })
+	return exporter
+}
+
+func TestDisabledTracingIsNoop(t *testing.T) {
+	Setup(Config{})
+	assert.False(t, Enabled())
+
+	ctx, span := Start(context.Background(), "noop")
+	assert.Nil(t, span)
+	assert.Equal(t, context.Background(), ctx)
+
+	// Every method is safe on a nil span
+	span.SetAttribute("key", "value")
+	span.AddEvent("event", nil)
+	span.RecordError(errors.New("boom"))
+	span.End()
+	assert.Empty(t, TraceID(ctx))
+	assert.Empty(t, TraceParent(ctx))
+}
+
+func TestSpanHierarchy(t *testing.T) {
+	exporter := setupMemory(t)
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	child.SetAttribute("ai.model", "gpt-4o")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	child.End() // second End is ignored
+	parent.End()
+
+	require.NoError(t, Shutdown(context.Background()))
+	require.Len(t, exporter.spans, 2)
+
+	got := exporter.byName("child")
+	require.NotNil(t, got)
+	assert.Equal(t, parent.traceID, got.traceID)
+	assert.Equal(t, parent.spanID, got.parentID)
+	assert.Equal(t, StatusError, got.statusCode)
+	assert.Equal(t, "gpt-4o", got.attributes["ai.model"])
+
+	assert.Equal(t, parent.TraceID(), TraceID(ctx))
+	assert.Equal(t, "00-"+parent.TraceID()+"-"+parent.SpanID()+"-01", TraceParent(ctx))
+}
+
+func TestRecordError_Cancellation(t *testing.T) {
+	setupMemory(t)
+
+	_, span := Start(context.Background(), "cancelled")
+	span.RecordError(context.Canceled)
+	assert.Equal(t, StatusUnset, span.statusCode)
+	require.Len(t, span.events, 1)
+	assert.Equal(t, "cancelled", span.events[0].Name)
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces", "out.jsonl")
+	exporter, err := NewFileExporter(path, "terminal-ai", "1.0.0", nil)
+	require.NoError(t, err)
+
+	Setup(Config{Exporter: exporter})
+	_, span := Start(context.Background(), "command terminal-ai")
+	span.SetAttribute("ai.usage.total_tokens", 42)
+	span.SetAttribute("cache.hit", true)
+	span.End()
+	require.NoError(t, Shutdown(context.Background()))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	require.True(t, scanner.Scan())
+
+	var request otlpRequest
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &request))
+	require.Len(t, request.ResourceSpans, 1)
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 1)
+
+	assert.Equal(t, "command terminal-ai", spans[0].Name)
+	assert.Len(t, spans[0].TraceID, 32)
+	assert.Len(t, spans[0].SpanID, 16)
+	assert.Empty(t, spans[0].ParentSpanID)
+
+	// Integers are encoded as strings per the OTLP/JSON mapping
+	assert.Contains(t, scanner.Text(), `{"key":"ai.usage.total_tokens","value":{"intValue":"42"}}`)
+	assert.Contains(t, scanner.Text(), `{"key":"service.name","value":{"stringValue":"terminal-ai"}}`)
+
+	// Spans can carry prompt details, so only the user may read them
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
+
+func TestHTTPExporter(t *testing.T) {
+	var body []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer collector.Close()
+
+	Setup(Config{Exporter: NewHTTPExporter(collector.URL, "terminal-ai", "1.0.0")})
+	_, span := Start(context.Background(), "ai.chat")
+	span.End()
+	require.NoError(t, Shutdown(context.Background()))
+
+	assert.Contains(t, string(body), `"name":"ai.chat"`)
+}
+
+func TestTransport(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("x-request-id", "req_123")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	exporter := setupMemory(t)
+	ctx, parent := Start(context.Background(), "parent")
+
+	client := &http.Client{Transport: NewTransport(nil)}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/chat/completions", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	parent.End()
+
+	require.NoError(t, Shutdown(context.Background()))
+	span := exporter.byName("HTTP POST /v1/chat/completions")
+	require.NotNil(t, span)
+
+	assert.Equal(t, parent.spanID, span.parentID)
+	assert.True(t, strings.HasPrefix(traceparent, "00-"+parent.TraceID()+"-"+span.SpanID()))
+	assert.Equal(t, KindClient, span.kind)
+	assert.Equal(t, 429, span.attributes["http.response.status_code"])
+	assert.Equal(t, "req_123", span.attributes["openai.request_id"])
+	assert.Equal(t, StatusError, span.statusCode)
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// Transport wraps an http.RoundTripper with a client span per request and
+// propagates the trace with a W3C traceparent header
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// NewTransport wraps base, using http.DefaultTransport when base is nil
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper. The span covers the time until
+// response headers arrive; streamed bodies are traced by the caller.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := StartWithKind(req.Context(), "HTTP "+req.Method+" "+req.URL.Path, KindClient)
+	if span == nil {
+		return t.Base.RoundTrip(req)
+	}
+	defer span.End()
+
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("url.path", req.URL.Path)
+	span.SetAttribute("server.address", req.URL.Hostname())
+
+	req = req.Clone(ctx)
+	req.Header.Set("traceparent", TraceParent(ctx))
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	if requestID := resp.Header.Get("x-request-id"); requestID != "" {
+		span.SetAttribute("openai.request_id", requestID)
+	}
+	if resp.StatusCode >= 400 {
+		span.mu.Lock()
+		span.statusCode = StatusError
+		span.statusMessage = resp.Status
+		span.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// LogHook adds the trace ID to zerolog events logged with a traced context
+type LogHook struct{}
+
+// Run implements zerolog.Hook
+func (LogHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if traceID := TraceID(e.GetCtx()); traceID != "" {
+		e.Str("trace_id", traceID)
+	}
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// columnGap separates adjacent columns
+const columnGap = " │ "
+
+// Columns renders texts side by side in equal-width columns under their
+// titles, wrapping each text to its column. With maxLines above zero only the
+// last maxLines lines of each column are shown, which keeps a live view within
+// the screen while text is still arriving.
+func Columns(titles, texts []string, width, maxLines int) string {
+	if len(titles) == 0 {
+		return ""
+	}
+
+	columnWidth := (width - lipgloss.Width(columnGap)*(len(titles)-1)) / len(titles)
+	if columnWidth < 10 {
+		columnWidth = 10
+	}
+
+	wrapped := make([][]string, len(titles))
+	height := 0
+	for i := range titles {
+		text := ""
+		if i < len(texts) {
+			text = texts[i]
+		}
+		lines := wrapText(text, columnWidth)
+		if maxLines > 0 && len(lines) > maxLines {
+			lines = lines[len(lines)-maxLines:]
+		}
+		wrapped[i] = lines
+		if len(lines) > height {
+			height = len(lines)
+		}
+	}
+
+	var sb strings.Builder
+	header := make([]string, len(titles))
+	rule := make([]string, len(titles))
+	for i, title := range titles {
+		header[i] = padCell(truncateCell(title, columnWidth), columnWidth)
+		rule[i] = strings.Repeat("─", columnWidth)
+	}
+	sb.WriteString(strings.TrimRight(strings.Join(header, columnGap), " "))
+	sb.WriteString("\n")
+	sb.WriteString(strings.Join(rule, "─┼─"))
+	sb.WriteString("\n")
+
+	row := make([]string, len(titles))
+	for line := 0; line < height; line++ {
+		for i := range titles {
+			cell := ""
+			if line < len(wrapped[i]) {
+				cell = wrapped[i][line]
+			}
+			row[i] = padCell(cell, columnWidth)
+		}
+		sb.WriteString(strings.TrimRight(strings.Join(row, columnGap), " "))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// wrapText wraps text to width, keeping line breaks and splitting words
+// longer than a line
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for lipgloss.Width(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				cut := width
+				for lipgloss.Width(string(runes[:cut])) > width {
+					cut--
+				}
+				lines = append(lines, string(runes[:cut]))
+				word = string(runes[cut:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case lipgloss.Width(line)+1+lipgloss.Width(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// padCell pads s with spaces to width display cells
+func padCell(s string, width int) string {
+	if w := lipgloss.Width(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// truncateCell shortens s to width display cells, marking the cut with "…"
+func truncateCell(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
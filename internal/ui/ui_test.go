@@ -536,3 +536,28 @@ func TestProgressModel(t *testing.T) {
 		}
 	})
 }
+
+func TestColumns(t *testing.T) {
+	output := Columns([]string{"gpt-5", "gpt-4.1"}, []string{"one two three four", "short\nsecond line"}, 25, 0)
+	expected := "gpt-5       │ gpt-4.1\n" +
+		"────────────┼────────────\n" +
+		"one two     │ short\n" +
+		"three four  │ second line\n"
+	if output != expected {
+		t.Errorf("Unexpected columns:\n%q\nwant:\n%q", output, expected)
+	}
+
+	// Live views keep the last lines of each column
+	output = Columns([]string{"a", "b"}, []string{"l1\nl2\nl3", "x"}, 30, 2)
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "l2") || !strings.HasPrefix(lines[3], "l3") {
+		t.Errorf("Expected the last two lines, got:\n%s", output)
+	}
+
+	// Words longer than a column are split
+	for _, line := range wrapText(strings.Repeat("x", 25), 10) {
+		if len(line) > 10 {
+			t.Errorf("Line %q exceeds the column width", line)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"runtime"
 	"strings"
 	"time"
+
+	"github.com/user/terminal-ai/internal/tracing"
 )
 
 // AppError represents an application error with context
@@ -20,6 +23,7 @@ type AppError struct {
 	StatusCode int                    `json:"status_code,omitempty"`
 	Timestamp  time.Time              `json:"timestamp"`
 	RequestID  string                 `json:"request_id,omitempty"`
+	Hint       string                 `json:"hint,omitempty"` // remediation shown to the user
 }
 
 // Error codes
@@ -39,6 +43,8 @@ const (
 	ErrCodeTimeout     = "TIMEOUT"
 	ErrCodeServiceDown = "SERVICE_UNAVAILABLE"
 
+	ErrCodeInvalidResponse = "INVALID_RESPONSE"
+
 	// Network errors
 	ErrCodeNetwork    = "NETWORK_ERROR"
 	ErrCodeConnection = "CONNECTION_ERROR"
@@ -193,6 +199,29 @@ func (e *AppError) WithRequestID(requestID string) *AppError {
 	return e
 }
 
+// WithTraceContext records the trace ID of the span in ctx and uses it as
+// the request ID unless a provider request ID is already set
+func (e *AppError) WithTraceContext(ctx context.Context) *AppError {
+	traceID := tracing.TraceID(ctx)
+	if traceID == "" {
+		return e
+	}
+	if e.Context == nil {
+		e.Context = make(map[string]interface{})
+	}
+	e.Context["trace_id"] = traceID
+	if e.RequestID == "" {
+		e.RequestID = traceID
+	}
+	return e
+}
+
+// WithHint sets a remediation hint for the user
+func (e *AppError) WithHint(hint string) *AppError {
+	e.Hint = hint
+	return e
+}
+
 // WithStatusCode sets the HTTP status code
 func (e *AppError) WithStatusCode(code int) *AppError {
 	e.StatusCode = code
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -288,6 +289,36 @@ func TestIsRetryable(t *testing.T) {
 	}
 }
 
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"Nil error", nil, ExitOK},
+		{"Plain error", errors.New("boom"), ExitGeneral},
+		{"Authentication", NewAuthError("bad key"), ExitAuth},
+		{"Rate limit", ErrRateLimited, ExitRateLimit},
+		{"Quota", ErrQuotaExceeded, ExitQuota},
+		{"Configuration", NewConfigError("bad config", nil), ExitConfig},
+		{"Validation", NewValidationError("bad input", "field"), ExitUsage},
+		{"Network", NewNetworkError("unreachable", nil), ExitNetwork},
+		{"Service down", ErrServiceUnavailable, ExitUnavailable},
+		{"Wrapped AppError", fmt.Errorf("request failed: %w", ErrRequestTimeout), ExitTimeout},
+		{"Offline cache miss", NewAppError(ErrCodeCacheMiss, "not cached", nil), ExitCacheMiss},
+		{"Context canceled", fmt.Errorf("stream: %w", context.Canceled), ExitCanceled},
+		{"Unknown code", NewAppError("SOMETHING_ELSE", "odd", nil), ExitGeneral},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := ExitCode(tt.err); code != tt.code {
+				t.Errorf("Expected exit code %d, got %d", tt.code, code)
+			}
+		})
+	}
+}
+
 func TestWrapError(t *testing.T) {
 	// Test wrapping nil error
 	wrapped := WrapError(nil, ErrCodeInternal, "Test")
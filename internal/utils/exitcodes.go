@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"errors"
+)
+
+// Process exit codes, one per error class, so scripts can branch on failures.
+// These values are part of the CLI contract; do not renumber them.
+const (
+	ExitOK           = 0   // success
+	ExitGeneral      = 1   // unclassified or internal error
+	ExitUsage        = 2   // invalid flags, arguments or input
+	ExitConfig       = 3   // missing or invalid configuration
+	ExitAuth         = 4   // authentication failed (bad or missing API key)
+	ExitPermission   = 5   // permission denied by the provider or a policy
+	ExitNotFound     = 6   // model or resource not found
+	ExitRateLimit    = 7   // rate limited after retries
+	ExitQuota        = 8   // usage quota or billing limit exceeded
+	ExitTimeout      = 9   // request timed out
+	ExitNetwork      = 10  // network, DNS or connection failure
+	ExitUnavailable  = 11  // provider error or service unavailable (5xx)
+	ExitInvalidReply = 12  // the provider accepted the request but the reply was unusable
+	ExitCacheMiss    = 13  // --offline and the prompt is not cached
+	ExitCanceled     = 130 // canceled by the user (matches SIGINT convention)
+)
+
+// exitCodes maps error codes to process exit codes
+var exitCodes = map[string]int{
+	ErrCodeInvalidInput:     ExitUsage,
+	ErrCodeValidation:       ExitUsage,
+	ErrCodeConfiguration:    ExitConfig,
+	ErrCodeMissingConfig:    ExitConfig,
+	ErrCodeInvalidConfig:    ExitConfig,
+	ErrCodeAuthentication:   ExitAuth,
+	ErrCodePermissionDenied: ExitPermission,
+	ErrCodeNotFound:         ExitNotFound,
+	ErrCodeRateLimit:        ExitRateLimit,
+	ErrCodeQuotaExceeded:    ExitQuota,
+	ErrCodeTimeout:          ExitTimeout,
+	ErrCodeDeadline:         ExitTimeout,
+	ErrCodeNetwork:          ExitNetwork,
+	ErrCodeConnection:       ExitNetwork,
+	ErrCodeDNS:              ExitNetwork,
+	ErrCodeAPIFailure:       ExitUnavailable,
+	ErrCodeServiceDown:      ExitUnavailable,
+	ErrCodeInvalidResponse:  ExitInvalidReply,
+	ErrCodeCacheMiss:        ExitCacheMiss,
+	ErrCodeCanceled:         ExitCanceled,
+	ErrCodeAborted:          ExitCanceled,
+}
+
+// ExitCode returns the process exit code for err
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	if appErr := GetAppError(err); appErr != nil {
+		if code, ok := exitCodes[appErr.Code]; ok {
+			return code
+		}
+		return ExitGeneral
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ExitCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	default:
+		return ExitGeneral
+	}
+}
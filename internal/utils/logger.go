@@ -14,6 +14,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/rs/zerolog/pkgerrors"
+	"github.com/user/terminal-ai/internal/tracing"
 )
 
 var (
@@ -47,8 +48,9 @@ type LogConfig struct {
 	TimeFormat    string
 	Pretty        bool
 	FilePath      string
-	MaskSensitive bool // mask sensitive data
-	StackTrace    bool // include stack traces for errors
+	MaskSensitive bool                      // mask sensitive data
+	StackTrace    bool                      // include stack traces for errors
+	WrapFile      func(io.Writer) io.Writer // wraps the log file's writer, e.g. to encrypt it
 }
 
 // NewLogger creates a new logger instance
@@ -96,13 +98,18 @@ func NewLogger(config LogConfig) *Logger {
 	// File output
 	var fileWriter io.WriteCloser
 	if config.FilePath != "" {
-		// Ensure directory exists
+		// Logs can hold prompts and responses, so only the user may read them
 		dir := filepath.Dir(config.FilePath)
-		if err := os.MkdirAll(dir, 0755); err == nil {
-			file, err := os.OpenFile(config.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			file, err := os.OpenFile(config.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
 			if err == nil {
+				file.Chmod(0600)
 				fileWriter = file
-				writers = append(writers, fileWriter)
+				if config.WrapFile != nil {
+					writers = append(writers, config.WrapFile(fileWriter))
+				} else {
+					writers = append(writers, fileWriter)
+				}
 			}
 		}
 	}
@@ -118,7 +125,7 @@ func NewLogger(config LogConfig) *Logger {
 	}
 
 	// Create logger with context
-	logger := zerolog.New(writer).With().
+	logger := zerolog.New(writer).Hook(tracing.LogHook{}).With().
 		Timestamp().
 		Str("app", "terminal-ai").
 		Str("version", "1.0.0").
@@ -360,6 +367,8 @@ func (l *Logger) WithContext(ctx context.Context) *Logger {
 	}
 	if traceID := ctx.Value("trace_id"); traceID != nil {
 		newLogger = newLogger.With().Str("trace_id", fmt.Sprint(traceID)).Logger()
+	} else if traceID := tracing.TraceID(ctx); traceID != "" {
+		newLogger = newLogger.With().Str("trace_id", traceID).Logger()
 	}
 
 	return &Logger{
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -50,6 +53,39 @@ func TestLoggerCreation(t *testing.T) {
 	}
 }
 
+func TestLoggerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "terminal-ai.log")
+	var wrapped bytes.Buffer
+	logger := NewLogger(LogConfig{
+		Level:    "info",
+		Output:   "json",
+		FilePath: path,
+		WrapFile: func(w io.Writer) io.Writer { return io.MultiWriter(w, &wrapped) },
+	})
+	logger.Info("logged to file")
+	logger.Close()
+
+	// Logs can hold prompts and responses, so only the user may read them
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected log file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected log file mode 0600, got %v", info.Mode().Perm())
+	}
+	info, err = os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("Expected log directory: %v", err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("Expected log directory mode 0700, got %v", info.Mode().Perm())
+	}
+
+	if !strings.Contains(wrapped.String(), "logged to file") {
+		t.Errorf("Expected file output to go through WrapFile, got %q", wrapped.String())
+	}
+}
+
 func TestSensitiveDataMasking(t *testing.T) {
 	logger := NewLogger(LogConfig{
 		Level:         "debug",
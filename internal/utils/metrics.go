@@ -6,6 +6,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/user/terminal-ai/internal/config"
 )
 
 // MetricsCollector collects performance and usage metrics
@@ -24,12 +26,17 @@ type MetricsCollector struct {
 	cacheEvictions int64
 
 	// Token usage
-	totalTokensUsed int64
-	tokensByModel   map[string]int64
+	totalTokensUsed         int64
+	tokensByModel           map[string]int64
+	promptTokensByModel     map[string]int64
+	completionTokensByModel map[string]int64
 
 	// Performance metrics
 	durations map[string]*DurationMetrics
 
+	// Latency histograms by metric name and label value
+	histograms map[string]map[string]*Histogram
+
 	// Memory metrics
 	memStats      runtime.MemStats
 	lastMemUpdate time.Time
@@ -79,10 +86,13 @@ var (
 func InitMetrics() *MetricsCollector {
 	metricsOnce.Do(func() {
 		globalMetrics = &MetricsCollector{
-			apiCalls:      make(map[string]*APIMetrics),
-			tokensByModel: make(map[string]int64),
-			durations:     make(map[string]*DurationMetrics),
-			startTime:     time.Now(),
+			apiCalls:                make(map[string]*APIMetrics),
+			tokensByModel:           make(map[string]int64),
+			promptTokensByModel:     make(map[string]int64),
+			completionTokensByModel: make(map[string]int64),
+			durations:               make(map[string]*DurationMetrics),
+			histograms:              make(map[string]map[string]*Histogram),
+			startTime:               time.Now(),
 		}
 	})
 	return globalMetrics
@@ -138,6 +148,30 @@ func (m *MetricsCollector) RecordAPICall(endpoint string, duration time.Duration
 	}
 
 	metrics.LastCallTime = time.Now()
+
+	m.observeLocked(HistogramAPILatency, endpoint, duration)
+}
+
+// RecordTimeToFirstToken records the delay before the first streamed token for a model
+func (m *MetricsCollector) RecordTimeToFirstToken(model string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.observeLocked(HistogramTimeToFirstToken, model, duration)
+}
+
+// observeLocked adds a duration to a labelled histogram (must be called with lock held)
+func (m *MetricsCollector) observeLocked(name, label string, duration time.Duration) {
+	if m.histograms == nil {
+		m.histograms = make(map[string]map[string]*Histogram)
+	}
+	if _, exists := m.histograms[name]; !exists {
+		m.histograms[name] = make(map[string]*Histogram)
+	}
+	if _, exists := m.histograms[name][label]; !exists {
+		m.histograms[name][label] = NewHistogram(DefaultLatencyBuckets)
+	}
+	m.histograms[name][label].Observe(duration.Seconds())
 }
 
 // RecordCacheHit records a cache hit
@@ -171,6 +205,13 @@ func (m *MetricsCollector) RecordTokenUsage(usage TokenUsage) {
 		m.tokensByModel[usage.Model] = 0
 	}
 	m.tokensByModel[usage.Model] += int64(usage.TotalTokens)
+
+	if m.promptTokensByModel == nil {
+		m.promptTokensByModel = make(map[string]int64)
+		m.completionTokensByModel = make(map[string]int64)
+	}
+	m.promptTokensByModel[usage.Model] += int64(usage.PromptTokens)
+	m.completionTokensByModel[usage.Model] += int64(usage.CompletionTokens)
 }
 
 // RecordDuration records a duration for an operation
@@ -275,20 +316,17 @@ func (m *MetricsCollector) GetTokenStats() map[string]interface{} {
 		"by_model":     m.tokensByModel,
 	}
 
-	// Calculate cost estimates (example rates)
+	// Estimate costs from the model registry's list prices, falling back
+	// to a flat example rate for models without one
 	costEstimates := make(map[string]float64)
 	for model, tokens := range m.tokensByModel {
-		// Example pricing per 1K tokens
-		var rate float64
-		switch model {
-		case "gpt-4":
-			rate = 0.03
-		case "gpt-3.5-turbo":
-			rate = 0.002
-		default:
-			rate = 0.01
+		info, ok := config.LookupModel(model)
+		if !ok || info.Pricing == nil {
+			costEstimates[model] = float64(tokens) / 1000.0 * 0.01
+			continue
 		}
-		costEstimates[model] = float64(tokens) / 1000.0 * rate
+		costEstimates[model] = (float64(m.promptTokensByModel[model])*info.Pricing.Input +
+			float64(m.completionTokensByModel[model])*info.Pricing.Output) / 1e6
 	}
 	stats["cost_estimates"] = costEstimates
 
@@ -370,9 +408,12 @@ func (m *MetricsCollector) Reset() {
 	// Reset token metrics
 	atomic.StoreInt64(&m.totalTokensUsed, 0)
 	m.tokensByModel = make(map[string]int64)
+	m.promptTokensByModel = make(map[string]int64)
+	m.completionTokensByModel = make(map[string]int64)
 
 	// Reset duration metrics
 	m.durations = make(map[string]*DurationMetrics)
+	m.histograms = make(map[string]map[string]*Histogram)
 
 	// Update start time
 	m.startTime = time.Now()
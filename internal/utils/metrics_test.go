@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -329,3 +330,56 @@ func TestPerformanceStats(t *testing.T) {
 		t.Errorf("Expected uptime around 3600 seconds, got %f", uptime)
 	}
 }
+
+func TestWritePrometheus(t *testing.T) {
+	metrics := &MetricsCollector{
+		apiCalls:      make(map[string]*APIMetrics),
+		tokensByModel: make(map[string]int64),
+		durations:     make(map[string]*DurationMetrics),
+		startTime:     time.Now(),
+	}
+
+	metrics.RecordAPICall("/v1/chat/completions", 300*time.Millisecond, http.StatusOK, nil)
+	metrics.RecordAPICall("/v1/chat/completions", 2*time.Second, http.StatusTooManyRequests, fmt.Errorf("rate limited"))
+	metrics.RecordTimeToFirstToken("gpt-4o", 80*time.Millisecond)
+	metrics.RecordTokenUsage(TokenUsage{Model: "gpt-4o", PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15})
+	metrics.RecordCacheHit()
+	metrics.RecordCacheEviction()
+
+	var sb strings.Builder
+	if err := metrics.WritePrometheus(&sb); err != nil {
+		t.Fatalf("WritePrometheus failed: %v", err)
+	}
+	output := sb.String()
+
+	expected := []string{
+		`# TYPE terminal_ai_api_requests_total counter`,
+		`terminal_ai_api_requests_total{endpoint="/v1/chat/completions",status="200"} 1`,
+		`terminal_ai_api_requests_total{endpoint="/v1/chat/completions",status="429"} 1`,
+		`terminal_ai_api_errors_total{endpoint="/v1/chat/completions"} 1`,
+		`# TYPE terminal_ai_api_request_duration_seconds histogram`,
+		`terminal_ai_api_request_duration_seconds_bucket{endpoint="/v1/chat/completions",le="0.5"} 1`,
+		`terminal_ai_api_request_duration_seconds_bucket{endpoint="/v1/chat/completions",le="2.5"} 2`,
+		`terminal_ai_api_request_duration_seconds_bucket{endpoint="/v1/chat/completions",le="+Inf"} 2`,
+		`terminal_ai_api_request_duration_seconds_count{endpoint="/v1/chat/completions"} 2`,
+		`terminal_ai_time_to_first_token_seconds_bucket{model="gpt-4o",le="0.1"} 1`,
+		`terminal_ai_tokens_total{model="gpt-4o",type="prompt"} 10`,
+		`terminal_ai_tokens_total{model="gpt-4o",type="completion"} 5`,
+		`terminal_ai_tokens_total{model="gpt-4o",type="total"} 15`,
+		`terminal_ai_cache_hits_total 1`,
+		`terminal_ai_cache_evictions_total 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected output to contain %q\n%s", line, output)
+		}
+	}
+}
+
+func TestPrometheusLabelEscaping(t *testing.T) {
+	got := labels("model", "a\"b\\c\nd")
+	want := `{model="a\"b\\c\nd"}`
+	if got != want {
+		t.Errorf("labels() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Histogram names used by the collector
+const (
+	HistogramAPILatency       = "api_request_duration_seconds"
+	HistogramTimeToFirstToken = "time_to_first_token_seconds"
+)
+
+// metricsPrefix namespaces all exported metrics
+const metricsPrefix = "terminal_ai_"
+
+// DefaultLatencyBuckets are the histogram upper bounds in seconds
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram creates a histogram with the given upper bounds
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe records a value
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// Snapshot returns the bucket bounds, cumulative counts, total count and sum
+func (h *Histogram) Snapshot() (buckets []float64, counts []uint64, count uint64, sum float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.buckets, append([]uint64(nil), h.counts...), h.count, h.sum
+}
+
+// histogramLabels maps histogram names to their label and help text
+var histogramLabels = map[string][2]string{
+	HistogramAPILatency:       {"endpoint", "API request latency in seconds."},
+	HistogramTimeToFirstToken: {"model", "Time to first streamed token in seconds."},
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format
+func (m *MetricsCollector) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	m.mu.RLock()
+
+	// API calls by endpoint and status code
+	writeHeader(bw, "api_requests_total", "counter", "Total API requests by endpoint and HTTP status code.")
+	for _, endpoint := range sortedKeys(m.apiCalls) {
+		metrics := m.apiCalls[endpoint]
+		metrics.mu.RLock()
+		codes := make([]int, 0, len(metrics.StatusCodes))
+		for code := range metrics.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			writeSample(bw, "api_requests_total", labels("endpoint", endpoint, "status", strconv.Itoa(code)), float64(metrics.StatusCodes[code]))
+		}
+		metrics.mu.RUnlock()
+	}
+
+	writeHeader(bw, "api_errors_total", "counter", "Total failed API requests by endpoint.")
+	for _, endpoint := range sortedKeys(m.apiCalls) {
+		metrics := m.apiCalls[endpoint]
+		metrics.mu.RLock()
+		writeSample(bw, "api_errors_total", labels("endpoint", endpoint), float64(metrics.Errors))
+		metrics.mu.RUnlock()
+	}
+
+	// Latency histograms
+	for _, name := range []string{HistogramAPILatency, HistogramTimeToFirstToken} {
+		meta := histogramLabels[name]
+		writeHeader(bw, name, "histogram", meta[1])
+		for _, label := range sortedKeys(m.histograms[name]) {
+			buckets, counts, count, sum := m.histograms[name][label].Snapshot()
+			for i, bound := range buckets {
+				writeSample(bw, name+"_bucket", labels(meta[0], label, "le", formatFloat(bound)), float64(counts[i]))
+			}
+			writeSample(bw, name+"_bucket", labels(meta[0], label, "le", "+Inf"), float64(count))
+			writeSample(bw, name+"_sum", labels(meta[0], label), sum)
+			writeSample(bw, name+"_count", labels(meta[0], label), float64(count))
+		}
+	}
+
+	// Token usage by model
+	writeHeader(bw, "tokens_total", "counter", "Total tokens used by model and type.")
+	for _, model := range sortedKeys(m.tokensByModel) {
+		writeSample(bw, "tokens_total", labels("model", model, "type", "prompt"), float64(m.promptTokensByModel[model]))
+		writeSample(bw, "tokens_total", labels("model", model, "type", "completion"), float64(m.completionTokensByModel[model]))
+		writeSample(bw, "tokens_total", labels("model", model, "type", "total"), float64(m.tokensByModel[model]))
+	}
+
+	uptime := time.Since(m.startTime).Seconds()
+	m.mu.RUnlock()
+
+	// Cache counters
+	writeCounter(bw, "cache_hits_total", "Total cache hits.", atomic.LoadInt64(&m.cacheHits))
+	writeCounter(bw, "cache_misses_total", "Total cache misses.", atomic.LoadInt64(&m.cacheMisses))
+	writeCounter(bw, "cache_writes_total", "Total cache writes.", atomic.LoadInt64(&m.cacheWrites))
+	writeCounter(bw, "cache_evictions_total", "Total cache evictions.", atomic.LoadInt64(&m.cacheEvictions))
+
+	// Process gauges
+	writeHeader(bw, "uptime_seconds", "gauge", "Seconds since the metrics collector started.")
+	writeSample(bw, "uptime_seconds", "", uptime)
+	writeHeader(bw, "goroutines", "gauge", "Number of goroutines.")
+	writeSample(bw, "goroutines", "", float64(runtime.NumGoroutine()))
+
+	return bw.Flush()
+}
+
+// PrometheusHandler serves the metrics in Prometheus text format
+func (m *MetricsCollector) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := m.WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// StartMetricsServer serves /metrics on addr in the background
+func (m *MetricsCollector) StartMetricsServer(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.PrometheusHandler())
+	server := &http.Server{Handler: mux}
+
+	go server.Serve(listener)
+	return server, nil
+}
+
+// writeHeader writes the HELP and TYPE lines for a metric
+func writeHeader(w *bufio.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s%s %s\n", metricsPrefix, name, help)
+	fmt.Fprintf(w, "# TYPE %s%s %s\n", metricsPrefix, name, metricType)
+}
+
+// writeSample writes a single sample line
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s%s%s %s\n", metricsPrefix, name, labels, formatFloat(value))
+}
+
+// writeCounter writes an unlabelled counter with its header
+func writeCounter(w *bufio.Writer, name, help string, value int64) {
+	writeHeader(w, name, "counter", help)
+	writeSample(w, name, "", float64(value))
+}
+
+// labels formats name/value pairs as a Prometheus label set
+func labels(pairs ...string) string {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(pairs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(pairs[i+1]))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+// formatFloat formats a sample value
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,32 +1,70 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/user/terminal-ai/cmd"
 	"github.com/user/terminal-ai/internal/utils"
 )
 
+// interruptGrace is how long a cancelled command gets to return before the
+// process exits anyway, for commands blocked on terminal input
+var interruptGrace = 2 * time.Second
+
 func main() {
-	// Setup signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	os.Exit(run(cmd.Execute, cmd.Cleanup, os.Exit))
+}
 
+// run executes the command and returns the process exit code. SIGINT and
+// SIGTERM cancel the command; if it has not returned within interruptGrace,
+// the process is cleaned up and ended with exit. Once the command returns,
+// interrupts no longer take that path, so a slow cleanup after a successful
+// run cannot turn it into a cancelled one.
+func run(execute func(context.Context) error, cleanup func(), exit func(int)) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan struct{})
 	go func() {
-		<-sigChan
-		// Cleanup on exit
-		cmd.Cleanup()
-		os.Exit(0)
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		// A second signal kills the process outright
+		stop()
+		select {
+		case <-done:
+			return
+		case <-time.After(interruptGrace):
+		}
+		cleanup()
+		exit(utils.ExitCanceled)
 	}()
 
-	// Execute the root command
-	if err := cmd.Execute(); err != nil {
+	err := execute(ctx)
+	close(done)
+
+	if ctx.Err() != nil {
+		// Interrupted runs never exit 0, whatever the command returned
+		cleanup()
+		return utils.ExitCanceled
+	}
+	if err != nil {
 		logger := utils.GetLogger()
 		if logger != nil {
 			logger.Error("Failed to execute command", err)
 		}
-		os.Exit(1)
+		cmd.PrintError(err)
+		cleanup()
+		return utils.ExitCode(err)
 	}
+
+	// Cleanup on exit, saving the cache for the next run
+	cleanup()
+	return utils.ExitOK
 }
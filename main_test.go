@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/terminal-ai/internal/utils"
+)
+
+func TestRun_SlowCleanupAfterSuccess(t *testing.T) {
+	previous := interruptGrace
+	interruptGrace = 10 * time.Millisecond
+	t.Cleanup(func() { interruptGrace = previous })
+
+	var mu sync.Mutex
+	var exits []int
+	exit := func(code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		exits = append(exits, code)
+	}
+	// Cleanup outlasts the interrupt grace, as exporting traces or
+	// compacting the cache can
+	cleanup := func() { time.Sleep(10 * interruptGrace) }
+
+	code := run(func(ctx context.Context) error { return nil }, cleanup, exit)
+	if code != utils.ExitOK {
+		t.Errorf("Expected exit code %d, got %d", utils.ExitOK, code)
+	}
+
+	time.Sleep(5 * interruptGrace)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(exits) != 0 {
+		t.Errorf("A successful run took the interrupt path, exiting with %v", exits)
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	cleanups := 0
+	cleanup := func() { cleanups++ }
+	exit := func(code int) { t.Errorf("Unexpected exit(%d)", code) }
+
+	code := run(func(ctx context.Context) error { return context.Canceled }, cleanup, exit)
+	if code != utils.ExitCanceled {
+		t.Errorf("Expected exit code %d, got %d", utils.ExitCanceled, code)
+	}
+	code = run(func(ctx context.Context) error { return errors.New("failed") }, cleanup, exit)
+	if code != utils.ExitGeneral {
+		t.Errorf("Expected exit code %d, got %d", utils.ExitGeneral, code)
+	}
+	if cleanups != 2 {
+		t.Errorf("Expected cleanup once per run, got %d", cleanups)
+	}
+}